@@ -0,0 +1,144 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdattach
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/serial"
+	"github.com/sisatech/vcli/shared"
+)
+
+// Command ...
+type Command struct {
+	*kingpin.CmdClause
+	name       string
+	json       bool
+	level      string
+	timestamps bool
+}
+
+// New ...
+func New() *Command {
+
+	return &Command{}
+
+}
+
+// Attach ...
+func (cmd *Command) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("attach", shared.Catenate(`Attach to
+		the serial console of a running instance, printing its output
+		and sending anything typed to the guest. Press Ctrl-C to detach
+		and leave the instance running. Only one client can be
+		attached to a console at a time.`))
+
+	arg := cmd.Arg("instance", "Name of the instance.")
+	arg.Required()
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.name)
+
+	flag := cmd.Flag("json", shared.Catenate(`Decode lines logged as JSON
+		objects, printing their level, message and fields.`))
+	flag.BoolVar(&cmd.json)
+
+	flag = cmd.Flag("level", shared.Catenate(`Only print lines at or above
+		this level (trace, debug, info, warn, error, fatal). Lines
+		without a level count as info.`))
+	flag.Default("trace")
+	flag.StringVar(&cmd.level)
+
+	flag = cmd.Flag("timestamps", shared.Catenate(`Print the time each line
+		was received in front of it.`))
+	flag.Default("true")
+	flag.BoolVar(&cmd.timestamps)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *Command) action(ctx *kingpin.ParseContext) error {
+
+	level, err := serial.ParseLevel(cmd.level)
+	if err != nil {
+		return err
+	}
+
+	r, err := hypervisor.LoadRecord(cmd.name)
+	if err != nil {
+		return err
+	}
+
+	if !r.Running() {
+		return fmt.Errorf("instance '%v' is not running", r.Name)
+	}
+
+	if r.Console == "" {
+		return fmt.Errorf("instance '%v' has no serial console to attach to", r.Name)
+	}
+
+	stream, err := r.OpenConsole()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	fmt.Printf("Attached to %s; press Ctrl-C to detach\n", r.Name)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	detached := make(chan struct{})
+	go func() {
+		<-interrupt
+		close(detached)
+		stream.Close()
+	}()
+
+	if w, ok := stream.(io.Writer); ok {
+		go io.Copy(w, os.Stdin)
+	}
+
+	p := &serial.Printer{
+		W:          os.Stdout,
+		Level:      level,
+		Timestamps: cmd.timestamps,
+		JSON:       cmd.json,
+	}
+
+	err = p.Copy(stream)
+
+	select {
+	case <-detached:
+		return nil
+	default:
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Instance %s stopped\n", r.Name)
+
+	return nil
+
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/sisatech/vcli/compiler"
 	"github.com/sisatech/vcli/compiler/converter"
 	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/oci"
 	"github.com/sisatech/vcli/shared"
 	"github.com/sisatech/vcli/vml"
 )
@@ -58,7 +59,8 @@ func (cmd *Command) Attach(parent command.Node) {
 	// TODO: incorporate building from vcli repository
 
 	arg := cmd.Arg("source", shared.Catenate(`Target application to use for
-		build.`))
+		build. Apps in an OCI registry can be built from a reference
+		such as 'oci://registry/ns/app:tag'.`))
 	arg.Required()
 	arg.StringVar(&cmd.binary)
 
@@ -82,6 +84,12 @@ func (cmd *Command) Attach(parent command.Node) {
 	flag.Default(home.GlobalDefaults.Kernel)
 	flag.StringVar(&cmd.kernel)
 
+	flag = cmd.Flag("allow-unsigned-kernel", shared.Catenate(`Allow building
+		with a kernel that has no signature, such as one added with 'vcli
+		settings kernel add'. Kernels with an invalid signature are
+		always refused.`))
+	flag.BoolVar(&compiler.AllowUnsignedKernel)
+
 	flag = cmd.Flag("debug", shared.Catenate(`Build the Vorteil application
 		using the debug version of the kernel instead of the production
 		version.`))
@@ -184,68 +192,10 @@ func (cmd *Command) firstTimeKernel() error {
 	fmt.Printf("Vorteil Kernel [%v] - %v\n", ret[index].Name, ret[index].Created)
 	arg := ret[index].Name
 
-	// TODO: robust filesystem verification
-
-	// retrieve vtramp if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vtramp.img"))
+	// retrieve the kernel and the boot assets released with it
+	err = compiler.FetchKernel(arg)
 	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vtramp.img", "vtramp")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve vboot if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vboot.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vboot.img", "vboot")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve prod kernel
-	prod := "vkernel-PROD-" + arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + prod))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(prod, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve debug kernel
-	debug := "vkernel-DEBUG-" + arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + debug))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(debug, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
+		return errors.New("unexpected error: " + err.Error())
 	}
 
 	// set global default to the new kernel if it was previously blank
@@ -320,6 +270,7 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 
 		// load input
 		var in converter.Convertible
+		name := shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix))
 
 		if strings.HasPrefix(cmd.binary, shared.RepoPrefix) {
 
@@ -332,6 +283,17 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 			in, err = repo.Export(strings.TrimPrefix(cmd.binary, shared.RepoPrefix), "")
 			sherlock.Check(err)
 
+		} else if strings.HasPrefix(cmd.binary, oci.Prefix) {
+
+			// load input from an OCI registry
+			ref, err := oci.ParseReference(cmd.binary)
+			sherlock.Check(err)
+
+			in, err = oci.NewClient().Pull(ref)
+			sherlock.Check(err)
+
+			name = ref.Name()
+
 		} else {
 
 			// load input from filesystem
@@ -364,7 +326,7 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 		case shared.Loose:
 
 			if output == "" {
-				output = shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix))
+				output = name
 			}
 
 			err = converter.ExportLoose(in, output)
@@ -377,7 +339,7 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 		case shared.ZipArchive:
 
 			if output == "" {
-				output = shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix)) + ".zip"
+				output = name + ".zip"
 			}
 
 			_, err = converter.ExportZipFile(in, output)
@@ -390,7 +352,7 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 		case shared.VMDK:
 
 			if output == "" {
-				output = shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix)) + ".vmdk"
+				output = name + ".vmdk"
 			}
 
 			_, err = converter.ExportSparseVMDK(in, output, cmd.kernel, cmd.debug)
@@ -403,7 +365,7 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 		case shared.OVA:
 
 			if output == "" {
-				output = shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix)) + ".ova"
+				output = name + ".ova"
 			}
 
 			_, err = converter.ExportOVA(in, output, cmd.kernel, cmd.debug)
@@ -416,7 +378,7 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 		case shared.RAWSparse:
 
 			if output == "" {
-				output = shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix))
+				output = name
 			}
 
 			err := converter.ExportRAWSparse(in, output, cmd.kernel, cmd.debug)
@@ -80,6 +80,7 @@ type cmdUploadTemplate struct {
 	infType  string
 
 	envPath string
+	vm      *shared.VMConfig
 	inf     *shared.GCPInfrastructure
 	keyData *shared.GoogleKey
 
@@ -134,6 +135,12 @@ func (cmd *cmdUploadTemplate) Attach(parent command.Node) {
 	kernelflag.Default(home.GlobalDefaults.Kernel)
 	kernelflag.StringVar(&cmd.kernel)
 
+	unsignedflag := cmd.Flag("allow-unsigned-kernel", shared.Catenate(`Allow building
+		with a kernel that has no signature, such as one added with 'vcli
+		settings kernel add'. Kernels with an invalid signature are
+		always refused.`))
+	unsignedflag.BoolVar(&compiler.AllowUnsignedKernel)
+
 	debugflag := cmd.Flag("debug", shared.Catenate(`Build the Vorteil application
 		using the debug version of the kernel instead of the production
 		version.`))
@@ -212,6 +219,12 @@ func (cmd *cmdUploadTemplate) action(ctx *kingpin.ParseContext) error {
 
 		defer in.Close()
 
+		// the vm section picks the machine type of cloud templates
+		var cfg *shared.BuildConfig
+		cfg, in, err = converter.ReadBuildConfig(in)
+		sherlock.Check(err)
+		cmd.vm = cfg.VM
+
 		if cmd.infType == shared.VMWareInf {
 			cmd.foo, err = converter.ExportOVA(in, "", cmd.kernel, cmd.debug)
 			sherlock.Check(err)
@@ -252,6 +265,42 @@ func (cmd *cmdUploadTemplate) action(ctx *kingpin.ParseContext) error {
 
 }
 
+// gcpMachineTypes are the predefined machine types templates can use,
+// smallest first, with their vcpus and memory in megabytes.
+var gcpMachineTypes = []struct {
+	name   string
+	vcpus  uint16
+	memory uint32
+}{
+	{"f1-micro", 1, 614},
+	{"g1-small", 1, 1740},
+	{"n1-standard-1", 1, 3840},
+	{"n1-standard-2", 2, 7680},
+	{"n1-standard-4", 4, 15360},
+	{"n1-standard-8", 8, 30720},
+	{"n1-standard-16", 16, 61440},
+	{"n1-standard-32", 32, 122880},
+	{"n1-standard-64", 64, 245760},
+}
+
+// gcpMachineType returns the smallest machine type that satisfies the app's
+// vm section.
+func gcpMachineType(vm *shared.VMConfig) (string, error) {
+
+	if vm == nil {
+		return gcpMachineTypes[0].name, nil
+	}
+
+	for _, t := range gcpMachineTypes {
+		if vm.VCPUs <= t.vcpus && vm.Memory <= t.memory {
+			return t.name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no Google Cloud machine type has %d vcpus and %d MB of memory", vm.VCPUs, vm.Memory)
+
+}
+
 func (cmd *cmdUploadTemplate) gcpLogic() error {
 
 	fmt.Println("Infrastructure type detected as Google Cloud Platform.")
@@ -389,13 +438,18 @@ func (cmd *cmdUploadTemplate) gcpLogic() error {
 		}
 	}
 
+	machineType, err := gcpMachineType(cmd.vm)
+	if err != nil {
+		return err
+	}
+
 	// Create New Instance Template ...
 	fmt.Println("Creating template from image...")
 	insertRequest := cpu.InstanceTemplates.Insert(cmd.keyData.Project_id, &compute.InstanceTemplate{
 		Name: cmd.name,
 		Properties: &compute.InstanceProperties{
 			CanIpForward: false,
-			MachineType:  "f1-micro",
+			MachineType:  machineType,
 			Disks: []*compute.AttachedDisk{&compute.AttachedDisk{
 				Type:       "PERSISTENT",
 				Boot:       true,
@@ -799,68 +853,10 @@ func (cmd *cmdUploadTemplate) firstTimeKernel() error {
 	fmt.Printf("Vorteil Kernel [%v] - %v\n", ret[0].Name, ret[0].Created)
 	arg := ret[0].Name
 
-	// TODO: robust filesystem verification
-
-	// retrieve vtramp if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vtramp.img"))
+	// retrieve the kernel and the boot assets released with it
+	err = compiler.FetchKernel(arg)
 	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vtramp.img", "vtramp")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve vboot if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vboot.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vboot.img", "vboot")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve prod kernel
-	prod := "vkernel-PROD-" + arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + prod))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(prod, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve debug kernel
-	debug := "vkernel-DEBUG-" + arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + debug))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(debug, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
+		return errors.New("unexpected error: " + err.Error())
 	}
 
 	// set global default to the new kernel if it was previously blank
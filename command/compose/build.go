@@ -0,0 +1,269 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdcompose
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sisatech/vcli/compiler/converter"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+	"github.com/sisatech/vcli/vml/archiver"
+)
+
+// override wraps an app, replacing its configuration and files with versions
+// adjusted by the compose file.
+type override struct {
+	converter.Convertible
+	cfg   io.Reader
+	files io.ReadCloser
+	close func()
+}
+
+func (o *override) Config() io.Reader {
+
+	if o.cfg != nil {
+		return o.cfg
+	}
+
+	return o.Convertible.Config()
+
+}
+
+func (o *override) FilesTar() io.Reader {
+
+	if o.files != nil {
+		return o.files
+	}
+
+	return o.Convertible.FilesTar()
+
+}
+
+func (o *override) Close() error {
+
+	if o.files != nil {
+		o.files.Close()
+	}
+
+	err := o.Convertible.Close()
+
+	if o.close != nil {
+		o.close()
+	}
+
+	return err
+
+}
+
+func (f *File) load(svc *Service) (converter.Convertible, error) {
+
+	app := f.path(svc.App)
+	o := new(override)
+
+	if strings.HasPrefix(app, shared.RepoPrefix) {
+
+		repo, err := vml.NewTinyRepo(home.Path(home.Repository))
+		if err != nil {
+			return nil, err
+		}
+
+		o.Convertible, err = repo.Export(strings.TrimPrefix(app, shared.RepoPrefix), "")
+		if err != nil {
+			repo.Close()
+			return nil, err
+		}
+
+		o.close = func() { repo.Close() }
+
+	} else {
+
+		if _, err := os.Stat(app); os.IsNotExist(err) {
+			return nil, fmt.Errorf("app '%v' not found", app)
+		}
+
+		var err error
+		if shared.IsELF(app) {
+
+			config := f.path(svc.Config)
+			if config == "" {
+				config = app + ".vcfg"
+			}
+
+			o.Convertible, err = converter.LoadLoose(app, config, "", f.path(svc.Files))
+
+		} else if shared.IsZip(app) {
+			o.Convertible, err = converter.LoadZipFile(app)
+		} else {
+			err = fmt.Errorf("'%v' not a valid ELF or zip archive", app)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+	}
+
+	err := f.applyOverrides(svc, o)
+	if err != nil {
+		o.Close()
+		return nil, err
+	}
+
+	return o, nil
+
+}
+
+func (f *File) applyOverrides(svc *Service, o *override) error {
+
+	if svc.Args != nil || svc.Envs != nil || svc.DNS != nil || svc.Cards != nil {
+
+		data, err := ioutil.ReadAll(o.Convertible.Config())
+		if err != nil {
+			return err
+		}
+
+		cfg := new(shared.BuildConfig)
+		err = json.Unmarshal(data, cfg)
+		if err != nil {
+			return err
+		}
+
+		if cfg.App == nil {
+			cfg.App = new(shared.BuildAppConfig)
+		}
+
+		if cfg.Network == nil {
+			cfg.Network = new(shared.NetworkConfig)
+		}
+
+		if svc.Args != nil {
+			cfg.App.BinaryArgs = svc.Args
+		}
+
+		if svc.Envs != nil {
+			cfg.App.SystemEnvs = svc.Envs
+		}
+
+		if svc.DNS != nil {
+			cfg.Network.DNS = svc.DNS
+		}
+
+		if svc.Cards != nil {
+			cfg.Network.NetworkCards = svc.Cards
+		}
+
+		data, err = json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		o.cfg = bytes.NewReader(data)
+
+	}
+
+	if len(svc.Volumes) == 0 {
+		return nil
+	}
+
+	// volumes are copied into the app's file system when it is built
+	staging, err := ioutil.TempDir("", "compose-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if files := o.Convertible.FilesTar(); files != nil {
+		err = archiver.ExpandTarStream(files, staging)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, v := range svc.Volumes {
+
+		parts := strings.SplitN(v, ":", 2)
+		src := f.path(parts[0])
+		dst := filepath.Join(staging, filepath.Clean("/"+parts[1]))
+
+		err = copyTree(src, dst)
+		if err != nil {
+			return fmt.Errorf("volume '%v': %v", v, err)
+		}
+
+	}
+
+	o.files, err = archiver.NewTarWriter(staging)
+	return err
+
+}
+
+func copyTree(src, dst string) error {
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode()|0700)
+		}
+
+		if !info.Mode().IsRegular() {
+			return errors.New("only regular files and directories are supported: " + path)
+		}
+
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(out, in)
+		if err != nil {
+			out.Close()
+			return err
+		}
+
+		return out.Close()
+
+	})
+
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdcompose
+
+import (
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/shared"
+)
+
+// Command ...
+type Command struct {
+	*kingpin.CmdClause
+	file    string
+	project string
+}
+
+// New ...
+func New() *Command {
+
+	return &Command{}
+
+}
+
+// Attach ...
+func (cmd *Command) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("compose", shared.Catenate(`The compose
+		command launches and manages a whole stack of Vorteil
+		applications on local hypervisors, as described by a compose
+		file. The file lists each app along with its configuration
+		overrides, networks, port maps, volumes and dependencies.`))
+
+	flag := cmd.Flag("file", shared.Catenate(`Compose file describing the
+		stack.`))
+	flag.Short('f')
+	flag.Default(defaultFile)
+	flag.StringVar(&cmd.file)
+
+	flag = cmd.Flag("project", shared.Catenate(`Project name used to keep
+		track of the stack's virtual machines. Defaults to the 'name'
+		in the compose file, or the name of the directory it is in.`))
+	flag.Short('p')
+	flag.StringVar(&cmd.project)
+
+	cmd.PreAction(cmd.preaction)
+
+	newUpCmd(cmd).Attach(cmd)
+	newDownCmd(cmd).Attach(cmd)
+	newPsCmd(cmd).Attach(cmd)
+	newLogsCmd(cmd).Attach(cmd)
+
+}
+
+func (cmd *Command) preaction(ctx *kingpin.ParseContext) error {
+
+	return command.NodeOnlyCheck(cmd, ctx)
+
+}
+
+// projectName returns the project being managed, reading the compose file
+// only if no project was given explicitly.
+func (cmd *Command) projectName() (string, error) {
+
+	if cmd.project != "" {
+		return cmd.project, nil
+	}
+
+	f, err := loadFile(cmd.file, "")
+	if err != nil {
+		return "", err
+	}
+
+	return f.Name, nil
+
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdcompose
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdDown struct {
+	*kingpin.CmdClause
+	parent *Command
+}
+
+func newDownCmd(parent *Command) *cmdDown {
+
+	return &cmdDown{parent: parent}
+
+}
+
+// Attach ...
+func (cmd *cmdDown) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("down", shared.Catenate(`Stop every app
+		in the stack, in reverse start order, and clean up their
+		disks. Disks kept with 'persist' are left in place.`))
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdDown) action(ctx *kingpin.ParseContext) error {
+
+	project, err := cmd.parent.projectName()
+	if err != nil {
+		return err
+	}
+
+	recs, err := records(project)
+	if err != nil {
+		return err
+	}
+
+	if len(recs) == 0 {
+		return fmt.Errorf("project '%v' is not up", project)
+	}
+
+	for i := len(recs) - 1; i >= 0; i-- {
+
+		err = recs[i].Stop()
+		if err != nil {
+			return fmt.Errorf("%v: %v", recs[i].Service, err)
+		}
+
+		fmt.Printf("Stopped %v\n", recs[i].Service)
+
+	}
+
+	return nil
+
+}
@@ -0,0 +1,260 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdcompose
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultFile         = "vcli-compose.yaml"
+	defaultReadyTimeout = "60s"
+)
+
+// File is a compose file describing a stack of Vorteil applications.
+type File struct {
+	Name     string              `yaml:"name"`
+	Networks []string            `yaml:"networks"`
+	Apps     map[string]*Service `yaml:"apps"`
+
+	dir string
+}
+
+// Service is one application in a compose file.
+type Service struct {
+	App        string   `yaml:"app"`
+	Config     string   `yaml:"config"`
+	Files      string   `yaml:"files"`
+	Kernel     string   `yaml:"kernel"`
+	Hypervisor string   `yaml:"hypervisor"`
+	Memory     uint32   `yaml:"ram"`
+	CPUs       uint16   `yaml:"cpus"`
+	PortMap    string   `yaml:"port-map"`
+	Networks   []string `yaml:"networks"`
+	Volumes    []string `yaml:"volumes"`
+	Persist    string   `yaml:"persist"`
+	DependsOn  []string `yaml:"depends-on"`
+
+	// Ready is a pattern the service prints on its serial console once it
+	// is ready, which services depending on it wait for.
+	Ready        string `yaml:"ready"`
+	ReadyTimeout string `yaml:"ready-timeout"`
+
+	// Overrides replace parts of the app's .vcfg.
+	Args  []string                   `yaml:"args"`
+	Envs  []string                   `yaml:"envs"`
+	DNS   []string                   `yaml:"dns"`
+	Cards []shared.NetworkCardConfig `yaml:"cards"`
+
+	name         string
+	ready        *regexp.Regexp
+	readyTimeout time.Duration
+}
+
+func loadFile(path, project string) (*File, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := new(File)
+	err = yaml.Unmarshal(data, f)
+	if err != nil {
+		return nil, fmt.Errorf("compose file %v is invalid yaml: %v", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	f.dir = filepath.Dir(abs)
+
+	if project != "" {
+		f.Name = project
+	}
+
+	if f.Name == "" {
+		f.Name = filepath.Base(f.dir)
+	}
+
+	err = f.validate()
+	if err != nil {
+		return nil, fmt.Errorf("compose file %v: %v", path, err)
+	}
+
+	return f, nil
+
+}
+
+func (f *File) validate() error {
+
+	if len(f.Apps) == 0 {
+		return fmt.Errorf("no apps defined")
+	}
+
+	for name, svc := range f.Apps {
+
+		if svc == nil {
+			return fmt.Errorf("app '%v' is empty", name)
+		}
+		svc.name = name
+
+		if svc.App == "" {
+			return fmt.Errorf("app '%v' has no 'app' to run", name)
+		}
+
+		for _, dep := range svc.DependsOn {
+			if _, ok := f.Apps[dep]; !ok {
+				return fmt.Errorf("app '%v' depends on unknown app '%v'", name, dep)
+			}
+		}
+
+		err := hypervisor.ValidatePortMap(svc.PortMap)
+		if err != nil {
+			return fmt.Errorf("app '%v': %v", name, err)
+		}
+
+		for _, v := range svc.Volumes {
+			if strings.Count(v, ":") != 1 {
+				return fmt.Errorf("app '%v': volume '%v' should be 'host-path:guest-path'", name, v)
+			}
+		}
+
+		if svc.Ready != "" {
+			svc.ready, err = regexp.Compile(svc.Ready)
+			if err != nil {
+				return fmt.Errorf("app '%v': ready: %v", name, err)
+			}
+		}
+
+		timeout := svc.ReadyTimeout
+		if timeout == "" {
+			timeout = defaultReadyTimeout
+		}
+
+		svc.readyTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("app '%v': ready-timeout: %v", name, err)
+		}
+
+	}
+
+	_, err := f.order()
+	return err
+
+}
+
+// path resolves paths in the compose file relative to the file itself.
+func (f *File) path(p string) string {
+
+	if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, shared.RepoPrefix) {
+		return p
+	}
+
+	return filepath.Join(f.dir, p)
+
+}
+
+// order returns the services sorted so every service comes after the services
+// it depends on.
+func (f *File) order() ([]*Service, error) {
+
+	var names []string
+	for name := range f.Apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []*Service
+	state := make(map[string]int) // 1 visiting, 2 done
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %v", strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+
+		state[name] = 1
+		deps := append([]string(nil), f.Apps[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+
+		out = append(out, f.Apps[name])
+		return nil
+
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+
+}
+
+// instance returns the registry name of a service's virtual machine.
+func (f *File) instance(svc string) string {
+
+	return f.Name + "_" + svc
+
+}
+
+// records returns the registry records for this project, in start order.
+func records(project string) ([]*hypervisor.Record, error) {
+
+	all, err := hypervisor.ListRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*hypervisor.Record
+	for _, r := range all {
+		if r.Project == project {
+			out = append(out, r)
+		}
+	}
+
+	sort.Sort(byStarted(out))
+
+	return out, nil
+
+}
+
+type byStarted []*hypervisor.Record
+
+func (s byStarted) Len() int           { return len(s) }
+func (s byStarted) Less(i, j int) bool { return s[i].Started.Before(s[j].Started) }
+func (s byStarted) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
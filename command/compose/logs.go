@@ -0,0 +1,167 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdcompose
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/hpcloud/tail"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdLogs struct {
+	*kingpin.CmdClause
+	parent *Command
+	follow bool
+	apps   []string
+}
+
+func newLogsCmd(parent *Command) *cmdLogs {
+
+	return &cmdLogs{parent: parent}
+
+}
+
+// Attach ...
+func (cmd *cmdLogs) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("logs", shared.Catenate(`Show the serial
+		console output of the apps in the stack, interleaved and
+		prefixed with the name of the app.`))
+
+	arg := cmd.Arg("app", shared.Catenate(`Only show logs from these
+		apps.`))
+	arg.StringsVar(&cmd.apps)
+
+	flag := cmd.Flag("follow", shared.Catenate(`Keep following the logs
+		until interrupted.`))
+	flag.Short('F')
+	flag.BoolVar(&cmd.follow)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdLogs) action(ctx *kingpin.ParseContext) error {
+
+	project, err := cmd.parent.projectName()
+	if err != nil {
+		return err
+	}
+
+	recs, err := records(project)
+	if err != nil {
+		return err
+	}
+
+	if len(cmd.apps) > 0 {
+
+		want := make(map[string]bool)
+		for _, a := range cmd.apps {
+			want[a] = true
+		}
+
+		var filtered []*hypervisor.Record
+		for _, r := range recs {
+			if want[r.Service] {
+				filtered = append(filtered, r)
+				delete(want, r.Service)
+			}
+		}
+
+		for a := range want {
+			return fmt.Errorf("app '%v' is not running in project '%v'", a, project)
+		}
+
+		recs = filtered
+
+	}
+
+	if len(recs) == 0 {
+		return fmt.Errorf("project '%v' is not up", project)
+	}
+
+	stop := make(chan struct{})
+	if cmd.follow {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		go func() {
+			<-interrupt
+			close(stop)
+		}()
+	}
+
+	streamLogs(recs, cmd.follow, stop)
+
+	return nil
+
+}
+
+// streamLogs prints the serial logs of the records line by line, prefixed
+// with their app names. If follow is set it keeps going until stop is
+// closed.
+func streamLogs(recs []*hypervisor.Record, follow bool, stop <-chan struct{}) {
+
+	width := 0
+	for _, r := range recs {
+		if len(r.Service) > width {
+			width = len(r.Service)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, r := range recs {
+
+		t, err := tail.TailFile(r.SerialLog, tail.Config{Follow: follow})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", r.Service, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, t *tail.Tail) {
+
+			defer wg.Done()
+
+			for {
+				select {
+				case line, ok := <-t.Lines:
+					if !ok {
+						return
+					}
+					mu.Lock()
+					fmt.Printf("%-*s | %s\n", width, name, line.Text)
+					mu.Unlock()
+				case <-stop:
+					t.Stop()
+					return
+				}
+			}
+
+		}(r.Service, t)
+
+	}
+
+	wg.Wait()
+
+}
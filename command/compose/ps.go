@@ -0,0 +1,88 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdcompose
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdPs struct {
+	*kingpin.CmdClause
+	parent *Command
+}
+
+func newPsCmd(parent *Command) *cmdPs {
+
+	return &cmdPs{parent: parent}
+
+}
+
+// Attach ...
+func (cmd *cmdPs) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("ps", shared.Catenate(`List the apps in
+		the stack and whether they are still running.`))
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdPs) action(ctx *kingpin.ParseContext) error {
+
+	project, err := cmd.parent.projectName()
+	if err != nil {
+		return err
+	}
+
+	recs, err := records(project)
+	if err != nil {
+		return err
+	}
+
+	if len(recs) == 0 {
+		return fmt.Errorf("project '%v' is not up", project)
+	}
+
+	return printStatus(recs)
+
+}
+
+func printStatus(recs []*hypervisor.Record) error {
+
+	vals := [][]string{{"App", "Hypervisor", "Status", "Ports", "Networks", "Started"}}
+	for _, r := range recs {
+
+		status := "exited"
+		if r.Running() {
+			status = "running"
+		}
+
+		vals = append(vals, []string{r.Service, r.Hypervisor, status,
+			r.PortMap, strings.Join(r.Networks, ","),
+			r.Started.Format(time.RFC822)})
+
+	}
+
+	shared.PrettyLeftTable(vals)
+
+	return nil
+
+}
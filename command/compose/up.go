@@ -0,0 +1,292 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdcompose
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler/converter"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdUp struct {
+	*kingpin.CmdClause
+	parent *Command
+	detach bool
+}
+
+func newUpCmd(parent *Command) *cmdUp {
+
+	return &cmdUp{parent: parent}
+
+}
+
+// Attach ...
+func (cmd *cmdUp) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("up", shared.Catenate(`Build and start
+		every app in the compose file, in dependency order. Logs from
+		every app are shown until interrupted, at which point the stack
+		is stopped, unless '--detach' is given.`))
+
+	flag := cmd.Flag("detach", shared.Catenate(`Leave the stack running in
+		the background. Use 'vcli compose down' to stop it.`))
+	flag.Short('d')
+	flag.BoolVar(&cmd.detach)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdUp) action(ctx *kingpin.ParseContext) error {
+
+	f, err := loadFile(cmd.parent.file, cmd.parent.project)
+	if err != nil {
+		return err
+	}
+
+	existing, err := records(f.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range existing {
+		if r.Running() {
+			return fmt.Errorf("project '%v' is already up; try 'vcli compose down' first", f.Name)
+		}
+	}
+
+	// clean up after anything that stopped on its own
+	for _, r := range existing {
+		r.Stop()
+	}
+
+	for _, name := range f.Networks {
+		if _, err := hypervisor.LoadNetwork(name); err != nil {
+			if _, err = hypervisor.CreateNetwork(name, ""); err != nil {
+				return err
+			}
+			fmt.Printf("Created network '%v'\n", name)
+		}
+	}
+
+	services, err := f.order()
+	if err != nil {
+		return err
+	}
+
+	var started []*hypervisor.Record
+	var instances []*hypervisor.Instance
+
+	stopAll := func() {
+		for i := len(started) - 1; i >= 0; i-- {
+			started[i].Stop()
+		}
+	}
+
+	for _, svc := range services {
+
+		fmt.Printf("Starting %v\n", svc.name)
+
+		inst, r, err := cmd.start(f, svc)
+		if err != nil {
+			stopAll()
+			return fmt.Errorf("%v: %v", svc.name, err)
+		}
+
+		started = append(started, r)
+		instances = append(instances, inst)
+
+		err = waitReady(inst, svc)
+		if err != nil {
+			stopAll()
+			return fmt.Errorf("%v: %v", svc.name, err)
+		}
+
+	}
+
+	if cmd.detach {
+		return printStatus(started)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	stop := make(chan struct{})
+	logsDone := make(chan struct{})
+	go func() {
+		streamLogs(started, true, stop)
+		close(logsDone)
+	}()
+
+	allDone := make(chan struct{})
+	go func() {
+		for _, inst := range instances {
+			<-inst.Done()
+		}
+		close(allDone)
+	}()
+
+	select {
+	case <-interrupt:
+	case <-allDone:
+	}
+
+	close(stop)
+	<-logsDone
+
+	fmt.Println("Stopping...")
+	stopAll()
+
+	return nil
+
+}
+
+func (cmd *cmdUp) start(f *File, svc *Service) (*hypervisor.Instance, *hypervisor.Record, error) {
+
+	hyp := svc.Hypervisor
+	if hyp == "" {
+		hyp = home.GlobalDefaults.Hypervisor
+	}
+	hyp = strings.ToUpper(hyp)
+
+	if !shared.ValidHypervisorWithHidden(hyp) {
+		return nil, nil, fmt.Errorf("invalid hypervisor '%v'", hyp)
+	}
+
+	if hyp == shared.VMwarePlayer {
+		return nil, nil, errors.New("VMWARE_PLAYER does not support headless mode")
+	}
+
+	kernel := svc.Kernel
+	if kernel == "" {
+		kernel = home.GlobalDefaults.Kernel
+	}
+
+	if !home.ValidLocalKernel(kernel) {
+		return nil, nil, fmt.Errorf("kernel %v not found locally; try 'vcli settings kernel download %v'", kernel, kernel)
+	}
+
+	in, err := f.load(svc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	disk, err := converter.ExportSparseVMDK(in, f.path(svc.Persist), kernel, false)
+	in.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	disk.Close()
+
+	cleanup := func() {
+		if svc.Persist == "" {
+			os.Remove(disk.Name())
+		}
+	}
+
+	cfg, err := hypervisor.NewConfig(hyp, disk.Name())
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	// machines are named after the project and service so several copies
+	// of the same app can run side by side
+	cfg.Name = f.instance(svc.name)
+	cfg.PortMap = svc.PortMap
+	cfg.Persist = f.path(svc.Persist)
+	cfg.Detach = cmd.detach
+
+	if svc.CPUs != 0 {
+		cfg.CPUs = svc.CPUs
+	}
+
+	if svc.Memory != 0 {
+		cfg.Memory = svc.Memory
+	}
+
+	err = cfg.AttachNetworks(svc.Networks)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	err = cfg.Track(f.instance(svc.name))
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	inst, err := hypervisor.Start(cfg)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	r := inst.Record(f.instance(svc.name))
+	r.Project = f.Name
+	r.Service = svc.name
+	r.KeepDisk = svc.Persist != ""
+
+	err = r.Save()
+	if err != nil {
+		inst.Stop()
+		cleanup()
+		return nil, nil, err
+	}
+
+	return inst, r, nil
+
+}
+
+// waitReady blocks until the service prints its ready pattern, if it has one.
+func waitReady(inst *hypervisor.Instance, svc *Service) error {
+
+	if svc.ready == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(svc.readyTimeout)
+	for {
+
+		data, err := ioutil.ReadFile(inst.Config.SerialLog)
+		if err == nil && svc.ready.Match(data) {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not ready after %v", svc.readyTimeout)
+		}
+
+		select {
+		case <-inst.Done():
+			return errors.New("stopped before it was ready")
+		case <-time.After(500 * time.Millisecond):
+		}
+
+	}
+
+}
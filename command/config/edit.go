@@ -126,7 +126,7 @@ func (cmd *cmdEditConfig) action(ctx *kingpin.ParseContext) error {
 			tmp, err := json.Marshal(cfg)
 			sherlock.Check(err)
 
-			hash, summary, err := mgr.Import(cmd.addr, out.App(), bytes.NewReader(tmp), out.Icon(), out.FilesTar())
+			hash, summary, err := mgr.Import(cmd.addr, out.App(), bytes.NewReader(tmp), out.Icon(), out.FilesTar(), "Edit build config")
 			sherlock.Check(err)
 
 			cmdrepo.Summary(summary, false)
@@ -0,0 +1,134 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdinstance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdForward struct {
+	*kingpin.CmdClause
+	instance string
+	mapping  string
+	remove   bool
+}
+
+func newForwardCmd() *cmdForward {
+
+	return &cmdForward{}
+
+}
+
+// Attach ...
+func (cmd *cmdForward) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("forward", shared.Catenate(`Forward a
+		host port to a running QEMU or KVM virtual machine, or stop
+		forwarding it with '--remove'. Both TCP and UDP are forwarded,
+		as with 'vcli run --port-map'.`))
+
+	arg := cmd.Arg("instance", shared.Catenate(`Name of the instance.`))
+	arg.Required()
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.instance)
+
+	arg = cmd.Arg("mapping", shared.Catenate(`Port mapping in the format
+		"x:y:z" where x = Network Card number, y = the Host Port, and z
+		= the Guest Port.`))
+	arg.Required()
+	arg.StringVar(&cmd.mapping)
+
+	flag := cmd.Flag("remove", shared.Catenate(`Remove the forward
+		instead of adding it.`))
+	flag.BoolVar(&cmd.remove)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdForward) action(ctx *kingpin.ParseContext) error {
+
+	err := hypervisor.ValidatePortMap(cmd.mapping)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(cmd.mapping, ":")
+	if len(parts) != 3 || strings.Contains(cmd.mapping, ",") {
+		return fmt.Errorf("expected a single mapping, not '%v'", cmd.mapping)
+	}
+
+	card, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid network card '%v'", parts[0])
+	}
+	host, _ := strconv.Atoi(parts[1])
+	guest, _ := strconv.Atoi(parts[2])
+
+	r, err := hypervisor.LoadRecord(cmd.instance)
+	if err != nil {
+		return err
+	}
+
+	q, err := connect(cmd.instance)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	var mappings []string
+	for _, m := range strings.Split(r.PortMap, ",") {
+		if m != "" && !strings.HasPrefix(m, parts[0]+":"+parts[1]+":") {
+			mappings = append(mappings, m)
+		}
+	}
+
+	for _, protocol := range []string{"tcp", "udp"} {
+		if cmd.remove {
+			err = q.RemoveHostForward(card, protocol, host)
+		} else {
+			err = q.AddHostForward(card, protocol, host, guest)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !cmd.remove {
+		mappings = append(mappings, cmd.mapping)
+	}
+
+	// keep the record's port map in step so it lists the live forwards
+	r.PortMap = strings.Join(mappings, ",")
+	err = r.Save()
+	if err != nil {
+		return err
+	}
+
+	if cmd.remove {
+		fmt.Printf("Stopped forwarding host port %v\n", host)
+	} else {
+		fmt.Printf("Forwarding host port %v to port %v on card %v\n", host, guest, card)
+	}
+
+	return nil
+
+}
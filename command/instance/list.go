@@ -0,0 +1,91 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdinstance
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdList struct {
+	*kingpin.CmdClause
+}
+
+func newListCmd() *cmdList {
+
+	return &cmdList{}
+
+}
+
+// Attach ...
+func (cmd *cmdList) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("list", shared.Catenate(`List the
+		virtual machines vcli is managing and their state.`))
+	cmd.Alias("ls")
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdList) action(ctx *kingpin.ParseContext) error {
+
+	records, err := hypervisor.ListRecords()
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return errors.New("no instances found")
+	}
+
+	vals := [][]string{{"Name", "Project", "Hypervisor", "Status", "Ports", "Started"}}
+	for _, r := range records {
+		vals = append(vals, []string{r.Name, r.Project, r.Hypervisor,
+			status(r), r.PortMap, r.Started.Format(time.RFC822)})
+	}
+
+	shared.PrettyTable(vals)
+
+	return nil
+
+}
+
+// status returns QEMU's own run state where it's available, so paused machines
+// show up as paused.
+func status(r *hypervisor.Record) string {
+
+	if !r.Running() {
+		return "exited"
+	}
+
+	q, err := r.QMP()
+	if err != nil {
+		return "running"
+	}
+	defer q.Close()
+
+	s, err := q.Status()
+	if err != nil {
+		return "running"
+	}
+
+	return s
+
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdinstance
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdPause struct {
+	*kingpin.CmdClause
+	instance string
+}
+
+func newPauseCmd() *cmdPause {
+
+	return &cmdPause{}
+
+}
+
+// Attach ...
+func (cmd *cmdPause) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("pause", shared.Catenate(`Stop the
+		virtual CPUs of a QEMU or KVM virtual machine, freezing it
+		until it is resumed.`))
+
+	arg := cmd.Arg("instance", shared.Catenate(`Name of the instance.`))
+	arg.Required()
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.instance)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdPause) action(ctx *kingpin.ParseContext) error {
+
+	q, err := connect(cmd.instance)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	err = q.Pause()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Paused %v\n", cmd.instance)
+
+	return nil
+
+}
+
+// connect returns a QMP connection to a running instance.
+func connect(name string) (*hypervisor.QMP, error) {
+
+	r, err := hypervisor.LoadRecord(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.Running() {
+		return nil, fmt.Errorf("instance '%v' is not running", name)
+	}
+
+	return r.QMP()
+
+}
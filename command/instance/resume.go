@@ -0,0 +1,69 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdinstance
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdResume struct {
+	*kingpin.CmdClause
+	instance string
+}
+
+func newResumeCmd() *cmdResume {
+
+	return &cmdResume{}
+
+}
+
+// Attach ...
+func (cmd *cmdResume) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("resume", shared.Catenate(`Resume a
+		virtual machine paused with 'vcli instance pause', or started
+		with 'vcli run --gdb-pause'.`))
+
+	arg := cmd.Arg("instance", shared.Catenate(`Name of the instance.`))
+	arg.Required()
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.instance)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdResume) action(ctx *kingpin.ParseContext) error {
+
+	q, err := connect(cmd.instance)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	err = q.Resume()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Resumed %v\n", cmd.instance)
+
+	return nil
+
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdinstance
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdStop struct {
+	*kingpin.CmdClause
+	instance string
+}
+
+func newStopCmd() *cmdStop {
+
+	return &cmdStop{}
+
+}
+
+// Attach ...
+func (cmd *cmdStop) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("stop", shared.Catenate(`Shut down a
+		virtual machine, pressing its ACPI power button first and
+		powering it off if it hasn't stopped within ten seconds.`))
+
+	arg := cmd.Arg("instance", shared.Catenate(`Name of the instance.`))
+	arg.Required()
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.instance)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdStop) action(ctx *kingpin.ParseContext) error {
+
+	r, err := hypervisor.LoadRecord(cmd.instance)
+	if err != nil {
+		return err
+	}
+
+	err = r.Stop()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Stopped %v\n", cmd.instance)
+
+	return nil
+
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdlogs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/pcap"
+	"github.com/sisatech/vcli/shared"
+)
+
+// Command ...
+type Command struct {
+	*kingpin.CmdClause
+	target string
+	net    bool
+}
+
+// New ...
+func New() *Command {
+
+	return &Command{}
+
+}
+
+// Attach ...
+func (cmd *Command) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("logs", shared.Catenate(`Show the
+		serial console output of an instance, or with '--net' a summary
+		of the network traffic captured by 'vcli run --pcap'.`))
+
+	arg := cmd.Arg("target", shared.Catenate(`Name of an instance, or
+		with '--net' a pcap file.`))
+	arg.Required()
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.target)
+
+	flag := cmd.Flag("net", shared.Catenate(`Decode captured network
+		traffic and list the flows between the guest and its peers.`))
+	flag.BoolVar(&cmd.net)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *Command) action(ctx *kingpin.ParseContext) error {
+
+	if cmd.net {
+		return cmd.flows()
+	}
+
+	r, err := hypervisor.LoadRecord(cmd.target)
+	if err != nil {
+		return err
+	}
+
+	if r.SerialLog == "" {
+		return fmt.Errorf("instance '%v' has no serial log", r.Name)
+	}
+
+	f, err := os.Open(r.SerialLog)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(os.Stdout, f)
+
+	return err
+
+}
+
+// captures returns the pcap files for the target, which is either a file or
+// an instance started with '--pcap'.
+func (cmd *Command) captures() ([]string, error) {
+
+	if _, err := os.Stat(cmd.target); err == nil {
+		return []string{cmd.target}, nil
+	}
+
+	r, err := hypervisor.LoadRecord(cmd.target)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.PCAPs) == 0 {
+		return nil, fmt.Errorf("instance '%v' was not started with --pcap", r.Name)
+	}
+
+	return r.PCAPs, nil
+
+}
+
+func (cmd *Command) flows() error {
+
+	files, err := cmd.captures()
+	if err != nil {
+		return err
+	}
+
+	for i, file := range files {
+
+		if len(files) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%v (card %d):\n", file, i)
+		}
+
+		err = printFlows(file)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+func printFlows(file string) error {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	summary, err := pcap.Flows(f)
+	if err != nil {
+		return fmt.Errorf("%v: %v", file, err)
+	}
+
+	if len(summary.Flows) == 0 {
+		fmt.Println("no traffic captured")
+		return nil
+	}
+
+	vals := [][]string{{"Proto", "Client", "Server", "Packets", "Bytes", "Duration", "Info"}}
+	for _, fl := range summary.Flows {
+		d := fl.Last.Sub(fl.First)
+		d -= d % time.Millisecond
+		vals = append(vals, []string{fl.Protocol, fl.Client, fl.Server,
+			strconv.Itoa(fl.Packets), strconv.Itoa(fl.Bytes), d.String(), fl.Info})
+	}
+
+	shared.PrettyTable(vals)
+
+	fmt.Printf("%d packets, %d bytes, %d flows\n", summary.Packets, summary.Bytes, len(summary.Flows))
+
+	return nil
+
+}
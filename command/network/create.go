@@ -0,0 +1,71 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdnetwork
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdCreate struct {
+	*kingpin.CmdClause
+	name  string
+	vmnet string
+}
+
+func newCreateCmd() *cmdCreate {
+
+	return &cmdCreate{}
+
+}
+
+// Attach ...
+func (cmd *cmdCreate) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("create", shared.Catenate(`Create a named
+		private network. On QEMU and KVM it is a multicast socket
+		network and on VirtualBox an internal network, both of which
+		are created on demand. VMware has no equivalent, so the network
+		uses an existing host-only vmnet.`))
+
+	arg := cmd.Arg("name", shared.Catenate(`Name of the network.`))
+	arg.Required()
+	arg.StringVar(&cmd.name)
+
+	flag := cmd.Flag("vmnet", shared.Catenate(`Host-only VMware network to
+		use for this network. It must already be set up with VMware's
+		virtual network editor, and can't be used by another network.
+		Defaults to the first vmnet no other network uses.`))
+	flag.StringVar(&cmd.vmnet)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdCreate) action(ctx *kingpin.ParseContext) error {
+
+	n, err := hypervisor.CreateNetwork(cmd.name, cmd.vmnet)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created network '%v' (VMware %v)\n", n.Name, n.VMnet)
+
+	return nil
+
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdnetwork
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdList struct {
+	*kingpin.CmdClause
+}
+
+func newListCmd() *cmdList {
+
+	return &cmdList{}
+
+}
+
+// Attach ...
+func (cmd *cmdList) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("list", shared.Catenate(`List the private
+		networks defined locally, and how each hypervisor implements
+		them.`))
+	cmd.Alias("ls")
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdList) action(ctx *kingpin.ParseContext) error {
+
+	networks, err := hypervisor.ListNetworks()
+	if err != nil {
+		return err
+	}
+
+	if len(networks) == 0 {
+		return errors.New("no networks found; try 'vcli network create'")
+	}
+
+	vals := [][]string{{"Name", "QEMU / KVM", "VirtualBox", "VMware", "Created"}}
+	for _, n := range networks {
+		vals = append(vals, []string{n.Name, n.Multicast, n.Internal,
+			n.VMnet, n.Created.Format(time.RFC822)})
+	}
+
+	shared.PrettyTable(vals)
+
+	return nil
+
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdnetwork
+
+import (
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdRemove struct {
+	*kingpin.CmdClause
+	names []string
+}
+
+func newRemoveCmd() *cmdRemove {
+
+	return &cmdRemove{}
+
+}
+
+// Attach ...
+func (cmd *cmdRemove) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("remove", shared.Catenate(`Remove private
+		networks. Applications already attached keep their connection
+		until they are stopped.`))
+	cmd.Alias("rm")
+
+	arg := cmd.Arg("name", shared.Catenate(`Names of the networks to
+		remove.`))
+	arg.Required()
+	arg.HintAction(hypervisor.ListNetworkNames)
+	arg.StringsVar(&cmd.names)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdRemove) action(ctx *kingpin.ParseContext) error {
+
+	for _, name := range cmd.names {
+		err := hypervisor.RemoveNetwork(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdClone struct {
+	*kingpin.CmdClause
+	url   string
+	addr  string
+	name  string
+	token string
+}
+
+// New ...
+func newCloneCmd() *cmdClone {
+
+	return &cmdClone{}
+
+}
+
+// Attach ...
+func (cmd *cmdClone) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("clone", shared.Catenate(`The clone
+		command saves a remote repository as a new remote and pulls
+		everything at an address in it into the local repository.`))
+
+	clause := cmd.Arg("url", shared.Catenate(`URL the remote repository is
+		served at.`))
+	clause.Required()
+	clause.StringVar(&cmd.url)
+
+	clause = cmd.Arg("address", shared.Catenate(`App or directory to pull.
+		Defaults to the whole repository.`))
+	clause.StringVar(&cmd.addr)
+
+	flag := cmd.Flag("name", shared.Catenate(`Name to save the remote
+		under.`))
+	flag.Default("origin")
+	flag.StringVar(&cmd.name)
+
+	flag = cmd.Flag("token", shared.Catenate(`Token to authenticate with,
+		if the remote was served with one.`))
+	flag.StringVar(&cmd.token)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdClone) action(ctx *kingpin.ParseContext) error {
+
+	cmd.addr = strings.TrimPrefix(cmd.addr, shared.RepoPrefix)
+
+	if r, ok := home.GlobalDefaults.Remotes[cmd.name]; ok && r.URL != cmd.url {
+		return fmt.Errorf("remote '%v' already exists for %v; choose another name with --name", cmd.name, r.URL)
+	}
+
+	remote, err := vml.NewRemote(cmd.url, cmd.token)
+	if err != nil {
+		return err
+	}
+
+	err = pull(remote, cmd.addr)
+	if err != nil {
+		return err
+	}
+
+	addRemote(cmd.name, cmd.url, cmd.token)
+
+	return nil
+
+}
@@ -45,10 +45,19 @@ func (cmd *Command) Attach(parent command.Node) {
 	newImportCmd().Attach(cmd)
 	newExportCmd().Attach(cmd) // todo duplicate export functionality
 	newListCmd().Attach(cmd)
+	newLogCmd().Attach(cmd)
 	newDeleteCmd().Attach(cmd)
 	newTagCmd().Attach(cmd)
 	newUntagCmd().Attach(cmd)
 	newEditVCFGCmd().Attach(cmd)
+	newRemoteCmd().Attach(cmd)
+	newPushCmd().Attach(cmd)
+	newPullCmd().Attach(cmd)
+	newCloneCmd().Attach(cmd)
+	newServeCmd().Attach(cmd)
+	newMigrateCmd().Attach(cmd)
+	newFsckCmd().Attach(cmd)
+	newGCCmd().Attach(cmd)
 
 }
 
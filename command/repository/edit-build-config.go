@@ -112,7 +112,7 @@ func (cmd *cmdExport) action(ctx *kingpin.ParseContext) error {
 		return err
 	}
 
-	hash, summary, err := mgr.Import(cmd.addr, out.App(), bytes.NewReader(tmp), out.Icon(), out.FilesTar())
+	hash, summary, err := mgr.Import(cmd.addr, out.App(), bytes.NewReader(tmp), out.Icon(), out.FilesTar(), "Edit build config")
 	if err != nil {
 		return err
 	}
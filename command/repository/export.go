@@ -22,6 +22,7 @@ import (
 
 	"github.com/alecthomas/kingpin"
 	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
 	"github.com/sisatech/vcli/compiler/converter"
 	"github.com/sisatech/vcli/home"
 	"github.com/sisatech/vcli/shared"
@@ -86,6 +87,12 @@ func (cmd *cmdEditVCFG) Attach(parent command.Node) {
 	flag.Default(home.GlobalDefaults.Kernel)
 	flag.StringVar(&cmd.kernel)
 
+	flag = cmd.Flag("allow-unsigned-kernel", shared.Catenate(`Allow building
+		with a kernel that has no signature, such as one added with 'vcli
+		settings kernel add'. Kernels with an invalid signature are
+		always refused.`))
+	flag.BoolVar(&compiler.AllowUnsignedKernel)
+
 	flag = cmd.Flag("debug", shared.Catenate(`Build the Vorteil application
 		using the debug version of the kernel instead of the production
 		version.`))
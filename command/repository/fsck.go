@@ -0,0 +1,107 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdFsck struct {
+	*kingpin.CmdClause
+	dryRun bool
+}
+
+// New ...
+func newFsckCmd() *cmdFsck {
+
+	return &cmdFsck{}
+
+}
+
+// Attach ...
+func (cmd *cmdFsck) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("fsck", shared.Catenate(`The fsck command
+		checks the integrity of the local repository. Every blob an app
+		version refers to is read back and compared with its checksum,
+		and missing or corrupt blobs are reported along with the
+		versions that need them. Blobs that nothing refers to are
+		reported as orphans, which 'vcli repository gc' deletes. Nodes
+		and tags that don't match the app versions are fixed.`))
+
+	flag := cmd.Flag("dry-run", shared.Catenate(`Only report problems,
+		without fixing any.`))
+	flag.Short('n')
+	flag.BoolVar(&cmd.dryRun)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdFsck) action(ctx *kingpin.ParseContext) error {
+
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	report, err := mgr.Fsck(!cmd.dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked %v blob(s)\n", report.Checked)
+
+	for _, blob := range report.Missing {
+		fmt.Printf("missing blob %v\n", blob)
+	}
+
+	for _, blob := range report.Corrupt {
+		fmt.Printf("corrupt blob %v\n", blob)
+	}
+
+	for _, version := range report.Broken {
+		fmt.Printf("broken version %v\n", version)
+	}
+
+	for _, p := range report.Problems {
+		if p.Fixed {
+			fmt.Printf("fixed: %v\n", p.Description)
+		} else {
+			fmt.Printf("%v\n", p.Description)
+		}
+	}
+
+	if n := len(report.Orphaned); n > 0 {
+		fmt.Printf("%v orphaned blob(s); run 'vcli repository gc' to delete them\n", n)
+	}
+
+	if !report.OK() {
+		if len(report.Broken) > 0 {
+			fmt.Println("Broken versions can be removed with 'vcli repository delete --ref'.")
+		}
+		return errors.New("the repository has problems that weren't fixed")
+	}
+
+	return nil
+
+}
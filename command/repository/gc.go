@@ -0,0 +1,106 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdGC struct {
+	*kingpin.CmdClause
+	compress bool
+	dryRun   bool
+	grace    time.Duration
+}
+
+// New ...
+func newGCCmd() *cmdGC {
+
+	return &cmdGC{}
+
+}
+
+// Attach ...
+func (cmd *cmdGC) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("gc", shared.Catenate(`The gc command
+		tidies the blob storage of the local repository, deleting every
+		blob that no app version refers to along with temporary files
+		left by interrupted imports. Blobs are deleted one at a time,
+		so this is safe to interrupt. Blobs kept in S3 or a storage
+		directory may be shared with other repositories, so they are
+		never collected.`))
+
+	flag := cmd.Flag("grace", shared.Catenate(`Keep unreferenced blobs
+		modified within this long, which may belong to an import that
+		is still running.`))
+	flag.Default("1h")
+	flag.DurationVar(&cmd.grace)
+
+	flag = cmd.Flag("dry-run", shared.Catenate(`Only print how much would be
+		deleted.`))
+	flag.Short('n')
+	flag.BoolVar(&cmd.dryRun)
+
+	flag = cmd.Flag("compress", shared.Catenate(`Also recompress every blob
+		with the compression from 'vcli settings compression', in
+		place, wherever that makes it smaller. Blobs are replaced one
+		at a time, so this is safe to interrupt.`))
+	flag.BoolVar(&cmd.compress)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdGC) action(ctx *kingpin.ParseContext) error {
+
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	n, size, err := mgr.GC(cmd.grace, cmd.dryRun)
+	if err != nil {
+		return err
+	}
+
+	if cmd.dryRun {
+		fmt.Printf("Would delete %v blob(s), %v bytes\n", n, size)
+		return nil
+	}
+
+	fmt.Printf("Deleted %v blob(s), %v bytes\n", n, size)
+
+	if !cmd.compress {
+		return nil
+	}
+
+	n, before, after, err := mgr.Compress()
+	if n > 0 {
+		fmt.Printf("Compressed %v blob(s) with %v: %v bytes -> %v bytes\n", n, vml.Compression, before, after)
+	} else if err == nil {
+		fmt.Println("Every blob is already compressed.")
+	}
+
+	return err
+
+}
@@ -31,11 +31,12 @@ import (
 
 type cmdImport struct {
 	*kingpin.CmdClause
-	src   string
-	files string
-	icon  string
-	addr  string
-	tag   string
+	src     string
+	files   string
+	icon    string
+	addr    string
+	tags    []string
+	message string
 }
 
 // New ...
@@ -81,8 +82,15 @@ func (cmd *cmdImport) Attach(parent command.Node) {
 	flag.Hidden()
 
 	flag = cmd.Flag("tag", shared.Catenate(`Tag the new version of the app
-		during the import process with the provided name.`))
-	flag.StringVar(&cmd.tag)
+		during the import process with the provided name. May be
+		repeated to apply several tags.`))
+	flag.StringsVar(&cmd.tags)
+
+	flag = cmd.Flag("message", shared.Catenate(`Changelog message to record
+		with the new version of the app, shown by 'vcli repository
+		log'.`))
+	flag.Short('m')
+	flag.StringVar(&cmd.message)
 
 	cmd.Action(cmd.action)
 
@@ -147,10 +155,10 @@ func (cmd *cmdImport) argValidation() error {
 		}
 	}
 
-	// Validate tag
-	if cmd.tag != "" {
-		err := cmd.valChars(cmd.tag)
-		if err != nil {
+	// Validate tags
+	for _, tag := range cmd.tags {
+		err := cmd.valChars(tag)
+		if tag == "" || err != nil {
 			return errors.New("illegal characters detected in provided tag")
 		}
 	}
@@ -242,11 +250,18 @@ func (cmd *cmdImport) action(ctx *kingpin.ParseContext) error {
 			sherlock.Check(err)
 		}
 
-		hash, summary, err := mgr.Import(cmd.addr, in.App(), in.Config(), in.Icon(), in.FilesTar())
+		hash, summary, err := mgr.Import(cmd.addr, in.App(), in.Config(), in.Icon(), in.FilesTar(), cmd.message)
 		if err != nil {
 			sherlock.Check(err)
 		}
 
+		for _, tag := range cmd.tags {
+			_, err = mgr.Tag(cmd.addr, hash, tag)
+			if err != nil {
+				sherlock.Check(fmt.Errorf("tag %v: %v", tag, err))
+			}
+		}
+
 		Summary(summary, false)
 
 		fmt.Println("Imported application with reference id: " + hash)
@@ -133,12 +133,14 @@ func (cmd *cmdList) listDir(list vml.DirectoryList) {
 
 func (cmd *cmdList) listApp(list vml.AppList) {
 	var data [][]string
-	data = [][]string{[]string{"DATE", "REFERENCE"}}
+	data = [][]string{[]string{"DATE", "REFERENCE", "VERSION", "TAGS"}}
 	for _, x := range list {
 		t := time.Unix(x.Uploaded, 0)
 		data = append(data, []string{
 			t.Format(time.RFC822),
-			x.Reference,
+			x.Version,
+			x.AppVersion,
+			strings.Join(x.Tags, ", "),
 		})
 	}
 	shared.PrettyLeftTable(data)
@@ -0,0 +1,126 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdLog struct {
+	*kingpin.CmdClause
+	addr  string
+	limit int
+}
+
+// New ...
+func newLogCmd() *cmdLog {
+
+	return &cmdLog{}
+
+}
+
+// Attach ...
+func (cmd *cmdLog) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("log", shared.Catenate(`The log command
+		prints the version history of an app in the local repository,
+		newest first, including the version, author and description
+		from each version's build config and the changelog message it
+		was imported with.`))
+
+	clause := cmd.Arg("address", shared.Catenate(`Address of the app within
+		the local repository.`))
+	clause.Required()
+	clause.StringVar(&cmd.addr)
+
+	flag := cmd.Flag("max-count", shared.Catenate(`Only print this many of
+		the most recent versions.`))
+	flag.Short('n')
+	flag.IntVar(&cmd.limit)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdLog) action(ctx *kingpin.ParseContext) error {
+
+	cmd.addr = strings.TrimPrefix(cmd.addr, shared.RepoPrefix)
+
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	nt, err := mgr.NodeType(cmd.addr)
+	if err != nil {
+		return err
+	}
+
+	if nt != "app" {
+		return fmt.Errorf("%v is not an app", cmd.addr)
+	}
+
+	list, err := mgr.ListApp(cmd.addr)
+	if err != nil {
+		return err
+	}
+
+	if cmd.limit > 0 && len(list) > cmd.limit {
+		list = list[:cmd.limit]
+	}
+
+	for i, x := range list {
+
+		if i > 0 {
+			fmt.Println()
+		}
+
+		fmt.Printf("ref %v", x.Version)
+		if len(x.Tags) > 0 {
+			fmt.Printf(" (tags: %v)", strings.Join(x.Tags, ", "))
+		}
+		fmt.Println()
+
+		if x.AppVersion != "" {
+			fmt.Printf("Version:     %v\n", x.AppVersion)
+		}
+		if x.Author != "" {
+			fmt.Printf("Author:      %v\n", x.Author)
+		}
+		if x.Description != "" {
+			fmt.Printf("Description: %v\n", x.Description)
+		}
+		fmt.Printf("Date:        %v\n", time.Unix(x.Uploaded, 0).Format(time.RFC1123))
+
+		if x.Message != "" {
+			fmt.Println()
+			for _, line := range strings.Split(strings.TrimRight(x.Message, "\n"), "\n") {
+				fmt.Printf("    %v\n", line)
+			}
+		}
+
+	}
+
+	return nil
+
+}
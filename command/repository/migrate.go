@@ -0,0 +1,162 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+	"github.com/sisatech/vcli/vml/archiver"
+)
+
+type cmdMigrate struct {
+	*kingpin.CmdClause
+	dest     string
+	noSwitch bool
+}
+
+// New ...
+func newMigrateCmd() *cmdMigrate {
+
+	return &cmdMigrate{}
+
+}
+
+// Attach ...
+func (cmd *cmdMigrate) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("migrate", shared.Catenate(`The migrate
+		command copies the blobs of the local repository from its
+		current storage to another, checking each against its checksum,
+		and then switches the repository to the new storage. Blobs the
+		destination already has are skipped, so an interrupted
+		migration can be run again. Nothing is deleted from the old
+		storage. See 'vcli settings storage' for the locations
+		supported.`))
+
+	clause := cmd.Arg("destination", shared.Catenate(`Storage to copy blobs
+		to, or 'local' for the repository directory.`))
+	clause.Required()
+	clause.StringVar(&cmd.dest)
+
+	flag := cmd.Flag("no-switch", shared.Catenate(`Only copy the blobs,
+		leaving the repository using its current storage. Useful for
+		backups.`))
+	flag.BoolVar(&cmd.noSwitch)
+
+	cmd.Action(cmd.action)
+
+}
+
+func openStorage(location string) (archiver.Archiver, error) {
+
+	if location == "" {
+		return archiver.New(home.Path(home.Repository))
+	}
+
+	return archiver.Open(location)
+
+}
+
+func describeStorage(location string) string {
+
+	if location == "" {
+		return "the repository directory"
+	}
+
+	return location
+
+}
+
+func (cmd *cmdMigrate) action(ctx *kingpin.ParseContext) error {
+
+	dest := cmd.dest
+	if dest == "local" {
+		dest = ""
+	}
+
+	dest, err := archiver.CleanLocation(dest)
+	if err != nil {
+		return err
+	}
+
+	if dest == vml.Storage {
+		return fmt.Errorf("the repository already keeps its blobs in %v", describeStorage(dest))
+	}
+
+	src, err := openStorage(vml.Storage)
+	if err != nil {
+		return err
+	}
+
+	dst, err := openStorage(dest)
+	if err != nil {
+		return err
+	}
+
+	n, size, err := archiver.Migrate(dst, src)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Copied %v blob(s), %v bytes to %v\n", n, size, describeStorage(dest))
+
+	// make sure nothing the repository needs was missed before switching
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	blobs, err := mgr.Blobs()
+	if err != nil {
+		return err
+	}
+
+	list, err := dst.List()
+	if err != nil {
+		return err
+	}
+
+	have := make(map[string]bool)
+	for _, info := range list {
+		have[info.Name()] = true
+	}
+
+	var missing int
+	for _, blob := range blobs {
+		if !have[blob] {
+			missing++
+		}
+	}
+
+	if missing > 0 {
+		return fmt.Errorf("%v blob(s) the repository refers to are missing from %v, so it wasn't switched", missing, describeStorage(dest))
+	}
+
+	if cmd.noSwitch {
+		return nil
+	}
+
+	home.GlobalDefaults.Storage = dest
+	vml.Storage = dest
+	fmt.Printf("The repository now keeps its blobs in %v\n", describeStorage(dest))
+
+	return nil
+
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/oci"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdPull struct {
+	*kingpin.CmdClause
+	remote string
+	addr   string
+}
+
+// New ...
+func newPullCmd() *cmdPull {
+
+	return &cmdPull{}
+
+}
+
+// Attach ...
+func (cmd *cmdPull) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("pull", shared.Catenate(`The pull
+		command downloads every version of the apps at an address in a
+		remote repository that the local repository doesn't have yet.
+		Only blobs missing locally are downloaded. Versions keep the
+		reference and date they have on the remote, and tags are pulled
+		along with their versions, replacing local tags of the same
+		name. If the remote is an OCI registry reference such as
+		'oci://registry/ns/app:tag', that app is imported as a new
+		version instead, at the address given or at the last part of
+		its repository name.`))
+
+	clause := cmd.Arg("remote", shared.Catenate(`Name of a saved remote, the
+		URL of a remote repository, or an OCI registry reference.`))
+	clause.Required()
+	clause.StringVar(&cmd.remote)
+
+	clause = cmd.Arg("address", shared.Catenate(`App or directory to pull.
+		Defaults to the whole repository.`))
+	clause.StringVar(&cmd.addr)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdPull) action(ctx *kingpin.ParseContext) error {
+
+	cmd.addr = strings.TrimPrefix(cmd.addr, shared.RepoPrefix)
+
+	if strings.HasPrefix(cmd.remote, oci.Prefix) {
+		return cmd.pullOCI()
+	}
+
+	remote, err := openRemote(cmd.remote)
+	if err != nil {
+		return err
+	}
+
+	return pull(remote, cmd.addr)
+
+}
+
+func pull(remote *vml.Remote, addr string) error {
+
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	sum, err := vml.Sync(mgr, remote, addr)
+	if err != nil {
+		return err
+	}
+
+	printSync("Pulled", sum)
+
+	return nil
+
+}
+
+func (cmd *cmdPull) pullOCI() error {
+
+	ref, err := oci.ParseReference(cmd.remote)
+	if err != nil {
+		return err
+	}
+
+	if cmd.addr == "" {
+		cmd.addr = ref.Name()
+	}
+
+	app, err := oci.NewClient().Pull(ref)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	version, summary, err := mgr.Import(cmd.addr, app.App(), app.Config(),
+		app.Icon(), app.FilesTar(), fmt.Sprintf("Pulled from %v (%v)", ref,
+			app.Digest))
+	if err != nil {
+		return err
+	}
+
+	Summary(summary, false)
+	fmt.Printf("Imported %v as %v@%v\n", ref, cmd.addr, version)
+
+	return nil
+
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/oci"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdPush struct {
+	*kingpin.CmdClause
+	remote string
+	addr   string
+}
+
+// New ...
+func newPushCmd() *cmdPush {
+
+	return &cmdPush{}
+
+}
+
+// Attach ...
+func (cmd *cmdPush) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("push", shared.Catenate(`The push
+		command uploads every version of the apps at an address in the
+		local repository that a remote repository doesn't have yet.
+		Blobs are addressed by their checksums, so only files the
+		remote is missing are uploaded. Tags are pushed along with
+		their versions, replacing tags of the same name on the
+		remote. If the remote is an OCI registry reference such as
+		'oci://registry/ns/app:tag', a single version of the app at the
+		address is pushed to it as an OCI artifact instead. Registry
+		credentials are read from VCLI_OCI_USERNAME and
+		VCLI_OCI_PASSWORD.`))
+
+	clause := cmd.Arg("remote", shared.Catenate(`Name of a saved remote, the
+		URL of a remote repository, or an OCI registry reference.`))
+	clause.Required()
+	clause.StringVar(&cmd.remote)
+
+	clause = cmd.Arg("address", shared.Catenate(`App or directory to push.
+		Defaults to the whole repository. When pushing to a registry
+		this must be an app, optionally with a version as in
+		'app@1.2.0'.`))
+	clause.StringVar(&cmd.addr)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdPush) action(ctx *kingpin.ParseContext) error {
+
+	cmd.addr = strings.TrimPrefix(cmd.addr, shared.RepoPrefix)
+
+	if strings.HasPrefix(cmd.remote, oci.Prefix) {
+		return cmd.pushOCI()
+	}
+
+	remote, err := openRemote(cmd.remote)
+	if err != nil {
+		return err
+	}
+
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	sum, err := vml.Sync(remote, mgr, cmd.addr)
+	if err != nil {
+		return err
+	}
+
+	printSync("Pushed", sum)
+
+	return nil
+
+}
+
+func (cmd *cmdPush) pushOCI() error {
+
+	ref, err := oci.ParseReference(cmd.remote)
+	if err != nil {
+		return err
+	}
+
+	if cmd.addr == "" {
+		return errors.New("an app address is required to push to a registry")
+	}
+
+	mgr, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	out, err := mgr.Export(cmd.addr, "")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	digest, err := oci.NewClient().Push(ref, out.App(), out.Config(),
+		out.Icon(), out.FilesTar())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Pushed %v to %v\n", cmd.addr, ref)
+	fmt.Printf("Digest: %v\n", digest)
+
+	return nil
+
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdRemoteAdd struct {
+	*kingpin.CmdClause
+	name  string
+	url   string
+	token string
+}
+
+// New ...
+func newRemoteAddCmd() *cmdRemoteAdd {
+
+	return &cmdRemoteAdd{}
+
+}
+
+// Attach ...
+func (cmd *cmdRemoteAdd) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("add", shared.Catenate(`The add remote
+		command saves a remote repository under a name so that it can
+		be used with push and pull. Adding a name that already exists
+		replaces it.`))
+
+	cmd.Arg("name", "Name of the remote.").Required().StringVar(&cmd.name)
+	cmd.Arg("url", "URL the remote repository is served at.").Required().StringVar(&cmd.url)
+
+	flag := cmd.Flag("token", shared.Catenate(`Token to authenticate with,
+		if the remote was served with one.`))
+	flag.StringVar(&cmd.token)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdRemoteAdd) action(ctx *kingpin.ParseContext) error {
+
+	_, err := vml.NewRemote(cmd.url, cmd.token)
+	if err != nil {
+		return err
+	}
+
+	addRemote(cmd.name, cmd.url, cmd.token)
+	fmt.Printf("Added remote %v\n", cmd.name)
+
+	return nil
+
+}
+
+func addRemote(name, url, token string) {
+
+	if home.GlobalDefaults.Remotes == nil {
+		home.GlobalDefaults.Remotes = make(map[string]*home.Remote)
+	}
+
+	home.GlobalDefaults.Remotes[name] = &home.Remote{
+		URL:   url,
+		Token: token,
+	}
+
+}
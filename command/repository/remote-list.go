@@ -0,0 +1,62 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"sort"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdRemoteList struct {
+	*kingpin.CmdClause
+}
+
+// New ...
+func newRemoteListCmd() *cmdRemoteList {
+
+	return &cmdRemoteList{}
+
+}
+
+// Attach ...
+func (cmd *cmdRemoteList) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("list", shared.Catenate(`The list remote
+		command prints every saved remote repository.`))
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdRemoteList) action(ctx *kingpin.ParseContext) error {
+
+	var names []string
+	for name := range home.GlobalDefaults.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	table := [][]string{{"Name", "URL"}}
+	for _, name := range names {
+		table = append(table, []string{name, home.GlobalDefaults.Remotes[name].URL})
+	}
+	shared.PrettyTable(table)
+
+	return nil
+
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdRemoteRemove struct {
+	*kingpin.CmdClause
+	name string
+}
+
+// New ...
+func newRemoteRemoveCmd() *cmdRemoteRemove {
+
+	return &cmdRemoteRemove{}
+
+}
+
+// Attach ...
+func (cmd *cmdRemoteRemove) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("remove", shared.Catenate(`The remove
+		remote command forgets a saved remote repository. Nothing is
+		deleted from the remote itself.`))
+
+	cmd.Arg("name", "Name of the remote.").Required().StringVar(&cmd.name)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdRemoteRemove) action(ctx *kingpin.ParseContext) error {
+
+	if _, ok := home.GlobalDefaults.Remotes[cmd.name]; !ok {
+		return fmt.Errorf("no remote named '%v'", cmd.name)
+	}
+
+	delete(home.GlobalDefaults.Remotes, cmd.name)
+	fmt.Printf("Removed remote %v\n", cmd.name)
+
+	return nil
+
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdRemote struct {
+	*kingpin.CmdClause
+}
+
+func newRemoteCmd() *cmdRemote {
+
+	return &cmdRemote{}
+
+}
+
+// Attach ...
+func (cmd *cmdRemote) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("remote", shared.Catenate(`The remote
+		subcommand contains commands to manage the remote repositories
+		that apps can be pushed to and pulled from. A remote repository
+		is hosted with 'vcli repository serve'.`))
+
+	cmd.PreAction(cmd.preaction)
+
+	newRemoteAddCmd().Attach(cmd)
+	newRemoteListCmd().Attach(cmd)
+	newRemoteRemoveCmd().Attach(cmd)
+
+}
+
+func (cmd *cmdRemote) preaction(ctx *kingpin.ParseContext) error {
+
+	return command.NodeOnlyCheck(cmd, ctx)
+
+}
+
+// openRemote returns a client for a saved remote, or for a URL if no remote
+// has that name.
+func openRemote(name string) (*vml.Remote, error) {
+
+	if r, ok := home.GlobalDefaults.Remotes[name]; ok {
+		return vml.NewRemote(r.URL, r.Token)
+	}
+
+	remote, err := vml.NewRemote(name, "")
+	if err != nil {
+		return nil, fmt.Errorf("no remote named '%v'", name)
+	}
+
+	return remote, nil
+
+}
+
+func printSync(verb string, sum vml.SyncSummary) {
+
+	if sum.Versions == 0 {
+		fmt.Println("Already up to date.")
+		return
+	}
+
+	fmt.Printf("%v %v version(s), %v blob(s), %v bytes\n", verb,
+		sum.Versions, sum.Blobs, sum.Bytes)
+
+}
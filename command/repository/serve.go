@@ -0,0 +1,112 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrepo
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+type cmdServe struct {
+	*kingpin.CmdClause
+	listen string
+	token  string
+	dir    string
+}
+
+// New ...
+func newServeCmd() *cmdServe {
+
+	return &cmdServe{}
+
+}
+
+// Attach ...
+func (cmd *cmdServe) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("serve", shared.Catenate(`The serve
+		command hosts a repository over HTTP so that others can push to
+		and pull from it as a remote. It serves the local repository
+		unless another directory is given.`))
+
+	flag := cmd.Flag("listen", shared.Catenate(`Address to listen on. Only
+		this machine can reach the default address; listening anywhere
+		else requires a token.`))
+	flag.Short('l')
+	flag.Default("127.0.0.1:7470")
+	flag.StringVar(&cmd.listen)
+
+	flag = cmd.Flag("token", shared.Catenate(`Require clients to
+		authenticate with this token. Without one, anyone who can reach
+		the server can push to it, so it is required unless the server
+		only listens on a loopback address.`))
+	flag.StringVar(&cmd.token)
+
+	flag = cmd.Flag("dir", shared.Catenate(`Directory of the repository to
+		serve, which is created if it doesn't exist. Its blobs are kept
+		in the same directory regardless of the storage setting.`))
+	flag.StringVar(&cmd.dir)
+
+	cmd.Action(cmd.action)
+
+}
+
+// loopback returns true if an address can only be reached from this machine.
+func loopback(addr string) bool {
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+
+}
+
+func (cmd *cmdServe) action(ctx *kingpin.ParseContext) error {
+
+	if cmd.token == "" && !loopback(cmd.listen) {
+		return fmt.Errorf("refusing to serve on %v without a token, as anyone who can reach it could push to the repository; set one with --token", cmd.listen)
+	}
+
+	// another directory keeps its blobs to itself
+	if cmd.dir == "" {
+		cmd.dir = home.Path(home.Repository)
+	} else {
+		vml.Storage = ""
+	}
+
+	mgr, err := vml.NewTinyRepo(cmd.dir)
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	fmt.Printf("Serving %v on %v\n", cmd.dir, cmd.listen)
+
+	return http.ListenAndServe(cmd.listen, vml.NewServer(mgr, cmd.token))
+
+}
@@ -44,7 +44,11 @@ func newTagCmd() *cmdTag {
 func (cmd *cmdTag) Attach(parent command.Node) {
 
 	cmd.CmdClause = parent.Command("tag", shared.Catenate(`The tag command
-		maps an alias or 'tag' for a specific app version's id.`))
+		maps an alias or 'tag' for a specific app version's id. A
+		version may have any number of tags. Versions can also be
+		selected by the version in their build config, using a
+		constraint such as '^1.2' wherever a ref is accepted, or with
+		an address of the form 'repo:path@^1.2'.`))
 
 	clause := cmd.Arg("address", shared.Catenate(`Address within the local
 		repository to tag.`))
@@ -56,7 +60,8 @@ func (cmd *cmdTag) Attach(parent command.Node) {
 	clause.StringVar(&cmd.tag)
 
 	flag := cmd.Flag("ref", shared.Catenate(`Specific version of the app to
-		apply the tag to.`))
+		apply the tag to: a reference id, an existing tag, or a version
+		constraint. Defaults to the most recent version.`))
 	flag.StringVar(&cmd.ref)
 
 	cmd.Action(cmd.action)
@@ -44,7 +44,9 @@ func newUntagCmd() *cmdUntag {
 func (cmd *cmdUntag) Attach(parent command.Node) {
 
 	cmd.CmdClause = parent.Command("untag", shared.Catenate(`The untag
-		command unmaps an alias 'tag' from an application version.`))
+		command unmaps an alias 'tag' from an application version. If
+		the given ref is a version rather than a tag, every tag on that
+		version is removed.`))
 
 	clause := cmd.Arg("address", shared.Catenate(`Address within the local
 		repository to tag.`))
@@ -22,31 +22,24 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
-	"path"
 	"path/filepath"
-	"runtime"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin"
 
-	"github.com/hpcloud/tail"
-
 	"github.com/sisatech/sherlock"
 	"github.com/sisatech/vcli/command"
 	"github.com/sisatech/vcli/compiler"
 	"github.com/sisatech/vcli/compiler/converter"
 	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/oci"
+	"github.com/sisatech/vcli/serial"
 	"github.com/sisatech/vcli/shared"
 	"github.com/sisatech/vcli/vml"
 )
 
-var fullPath string
-var vBoxSerial *os.File
-var dbf *os.File
-var vmwname string
-
 // Command ...
 type Command struct {
 	*kingpin.CmdClause
@@ -62,6 +55,19 @@ type Command struct {
 	headless   bool
 	echo       bool
 	tempdir    bool
+	watch      bool
+	gdb        string
+	gdbPause   bool
+	gdbOffset  uint64
+	networks   []string
+	pcap       string
+	json       bool
+	level      string
+	minLevel   serial.Level
+	timestamps bool
+	logSink    string
+	sink       serial.Sink
+	tags       serial.Tags
 }
 
 // New ...
@@ -75,12 +81,15 @@ func New() *Command {
 func (cmd *Command) Attach(parent command.Node) {
 
 	cmd.CmdClause = parent.Command("run", shared.Catenate(`The run command
-		launches a Vorteil application on a local hypervisor.`))
+		launches a Vorteil application on a local hypervisor. Once the
+		application shuts down vcli exits with its exit status, or with
+		status 128 if the kernel panicked.`))
 	cmd.Alias("start")
 	cmd.Alias("launch")
 
 	clause := cmd.Arg("application", shared.Catenate(`Target application to
-		launch in a local hypervisor.`))
+		launch in a local hypervisor. Apps in an OCI registry can be run
+		from a reference such as 'oci://registry/ns/app:tag'.`))
 	clause.Required()
 	clause.StringVar(&cmd.binary)
 
@@ -102,6 +111,12 @@ func (cmd *Command) Attach(parent command.Node) {
 	flag.HintAction(home.ListLocalKernels)
 	flag.StringVar(&cmd.kernel)
 
+	flag = cmd.Flag("allow-unsigned-kernel", shared.Catenate(`Allow building
+		with a kernel that has no signature, such as one added with 'vcli
+		settings kernel add'. Kernels with an invalid signature are
+		always refused.`))
+	flag.BoolVar(&compiler.AllowUnsignedKernel)
+
 	flag = cmd.Flag("echo", shared.Catenate(`Echo VM's stderrr and stdout to standard output`))
 	flag.Short('e')
 	flag.BoolVar(&cmd.echo)
@@ -123,13 +138,13 @@ func (cmd *Command) Attach(parent command.Node) {
 
 	// TODO: some sort of defaults for the following vm settings flags
 	flag = cmd.Flag("ram", shared.Catenate(`RAM in megabytes to assign to
-		the VM.`))
-	flag.Default("64")
+		the VM, overriding the 'vm' section of the app's configuration.
+		Defaults to 64.`))
 	flag.Uint32Var(&cmd.memory)
 
 	flag = cmd.Flag("cpus", shared.Catenate(`Number of virtual cpus to
-		assign to the VM.`))
-	flag.Default("1")
+		assign to the VM, overriding the 'vm' section of the app's
+		configuration. Defaults to 1.`))
 	flag.Uint16Var(&cmd.cpus)
 	flag.Hidden()
 
@@ -137,6 +152,74 @@ func (cmd *Command) Attach(parent command.Node) {
 		Mappings should be separated with a ',' (ie. --port-map=x:y:z,a:b:c)'`))
 	flag.StringVar(&cmd.pmap)
 
+	flag = cmd.Flag("network", shared.Catenate(`Attach a network card to a
+		private network created with 'vcli network create', instead of
+		the hypervisor's NAT. Networks are attached to the app's cards
+		in order, or to a specific card with '--network=card:name'. Can
+		be repeated.`))
+	flag.PlaceHolder("[CARD:]NAME")
+	flag.HintAction(hypervisor.ListNetworkNames)
+	flag.StringsVar(&cmd.networks)
+
+	flag = cmd.Flag("pcap", shared.Catenate(`Capture the traffic on the
+		app's network cards to a pcap file, which can be summarised
+		with 'vcli logs --net' or opened in Wireshark. Cards after the
+		first are captured to their own files, ie. 'out-1.pcap'. Only
+		supported on QEMU, KVM and VirtualBox hypervisors.`))
+	flag.PlaceHolder("FILE")
+	flag.StringVar(&cmd.pcap)
+
+	flag = cmd.Flag("json", shared.Catenate(`Decode serial console lines
+		logged as JSON objects, printing their level, message and
+		fields.`))
+	flag.BoolVar(&cmd.json)
+
+	flag = cmd.Flag("level", shared.Catenate(`Only echo serial console
+		lines at or above this level (trace, debug, info, warn, error,
+		fatal). Lines without a level count as info.`))
+	flag.Default("trace")
+	flag.StringVar(&cmd.level)
+
+	flag = cmd.Flag("timestamps", shared.Catenate(`Print the time each
+		serial console line was received in front of it.`))
+	flag.Default("true")
+	flag.BoolVar(&cmd.timestamps)
+
+	flag = cmd.Flag("log-sink", shared.Catenate(`Forward the serial
+		console to a log collector as structured records tagged with
+		the app's name and version. Plain and JSON lines are both
+		understood. Supported sinks are syslog://HOST:PORT (RFC 5424
+		over UDP, or syslog+tcp://), gelf://HOST:PORT (or gelf+tcp://)
+		and otlp://HOST:PORT for OTLP/HTTP.`))
+	flag.PlaceHolder("URL")
+	flag.StringVar(&cmd.logSink)
+
+	flag = cmd.Flag("gdb", shared.Catenate(`Enable QEMU's gdbstub, listening
+		on the given address (ie. --gdb=:1234), and write a gdb init
+		script that loads the app's symbols and connects to it. Only
+		supported on QEMU and KVM hypervisors.`))
+	flag.PlaceHolder("[HOST]:PORT")
+	flag.StringVar(&cmd.gdb)
+
+	flag = cmd.Flag("gdb-pause", shared.Catenate(`Halt the VM at boot
+		until a debugger attached with '--gdb' continues it.`))
+	flag.BoolVar(&cmd.gdbPause)
+
+	flag = cmd.Flag("gdb-offset", shared.Catenate(`Address a position
+		independent app is loaded at, used when loading its symbols in
+		the gdb init script instead of the address recorded in the
+		disk's image header.`))
+	flag.Default("0")
+	flag.Uint64Var(&cmd.gdbOffset)
+
+	flag = cmd.Flag("watch", shared.Catenate(`Watch the application, its
+		configuration file and the files directory for changes, and
+		rebuild and restart the VM whenever they change. The VM runs
+		headless with its console attached to the terminal until
+		interrupted.`))
+	flag.Short('w')
+	flag.BoolVar(&cmd.watch)
+
 	cmd.Action(cmd.action)
 
 }
@@ -189,68 +272,10 @@ func (cmd *Command) firstTimeKernel() error {
 	fmt.Printf("Vorteil Kernel [%v] - %v\n", ret[index].Name, ret[index].Created)
 	arg := ret[index].Name
 
-	// TODO: robust filesystem verification
-
-	// retrieve vtramp if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vtramp.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vtramp.img", "vtramp")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve vboot if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vboot.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vboot.img", "vboot")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve prod kernel
-	prod := "vkernel-PROD-" + arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + prod))
+	// retrieve the kernel and the boot assets released with it
+	err = compiler.FetchKernel(arg)
 	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(prod, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve debug kernel
-	debug := "vkernel-DEBUG-" + arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + debug))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(debug, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
+		return errors.New("unexpected error: " + err.Error())
 	}
 
 	// set global default to the new kernel if it was previously blank
@@ -313,13 +338,34 @@ func (cmd *Command) validateArgs() error {
 		return errors.New("hypervisor '" + binhyp + "' not found in path'")
 	}
 
+	cmd.minLevel, err = serial.ParseLevel(cmd.level)
+	if err != nil {
+		return err
+	}
+
+	if cmd.logSink != "" && cmd.headless {
+		return errors.New("--log-sink can't be used with --headless, as vcli doesn't stay attached to the console")
+	}
+
+	if cmd.pcap != "" {
+		switch cmd.hypervisor {
+		case shared.QEMU, shared.KVM, shared.KVMClassic, shared.VirtualBox:
+		default:
+			return errors.New("--pcap only supported on QEMU, KVM and VirtualBox hypervisors")
+		}
+		cmd.pcap, err = filepath.Abs(cmd.pcap)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Validate sufficient RAM
 	// Must be at least 2MB (for kernel) + 2 MB per CPU + size of app, rounded up to nearest 2
 	// ram := cmd.memory
 	// if ram < (uint32(appsizeMB) + 2*(uint32(cmd.cpus)) + 2) {
 	// 	return errors.New("insufficient RAM allocated to the virtual machine.")
 	// }
-	if cmd.memory < 16 {
+	if cmd.memory != 0 && cmd.memory < 16 {
 		return errors.New("insufficient RAM. Must be at least 16 MB.")
 	}
 	if cmd.memory%4 != 0 {
@@ -327,29 +373,9 @@ func (cmd *Command) validateArgs() error {
 	}
 
 	if (cmd.hypervisor == shared.KVMClassic || cmd.hypervisor == shared.QEMU || cmd.hypervisor == shared.VirtualBox) && cmd.pmap != "" {
-		pmap := strings.Split(cmd.pmap, ",")
-		if len(pmap) > 0 {
-			for i, p := range pmap {
-				pmapIndividual := strings.Split(p, ":")
-				if len(pmapIndividual) != 3 {
-					return errors.New("argument '--port-map' formatted incorrectly. Correct format should be '<cardNumber:hostPort:guestPort>', with additional mappings separated by commas.")
-				} else {
-					a, err := strconv.Atoi(pmapIndividual[1])
-					if err != nil {
-						return errors.New("unexpected value in port-map flag. Values should be numeric only.")
-					}
-					b, err := strconv.Atoi(pmapIndividual[2])
-					if err != nil {
-						return errors.New("unexpected value in port-map flag. Values should be numeric only.")
-					}
-
-					if a < 0 || a > 65535 {
-						return errors.New(fmt.Sprintf("port-map %v: host port out of bounds. Must be between 0-65535.", i))
-					} else if b < 0 || b > 65535 {
-						return errors.New(fmt.Sprintf("port-map %v: guest port out of bounds. Must be between 0-65535.", i))
-					}
-				}
-			}
+		err = hypervisor.ValidatePortMap(cmd.pmap)
+		if err != nil {
+			return err
 		}
 	} else if (cmd.hypervisor == shared.VMwarePlayer || cmd.hypervisor == shared.VMwareWorkstation) && cmd.pmap != "" {
 		fmt.Println("WARNING: flag '--port-map' is ignored while hypervisor is set to VMWARE_PLAYER or VMWARE.")
@@ -380,6 +406,49 @@ func (cmd *Command) validateArgs() error {
 		}
 	}
 
+	next := 0
+	for _, n := range cmd.networks {
+		card, name, err := hypervisor.ParseNetworkFlag(n, next)
+		if err != nil {
+			return err
+		}
+		if _, err = hypervisor.LoadNetwork(name); err != nil {
+			return err
+		}
+		next = card + 1
+	}
+
+	if cmd.gdb != "" {
+
+		if cmd.hypervisor != shared.QEMU && cmd.hypervisor != shared.KVM && cmd.hypervisor != shared.KVMClassic {
+			return errors.New("flag '--gdb' is only supported on QEMU and KVM hypervisors")
+		}
+
+		_, _, err = hypervisor.GDBAddress(cmd.gdb)
+		if err != nil {
+			return err
+		}
+
+	} else if cmd.gdbPause {
+		return errors.New("flag '--gdb-pause' requires '--gdb'")
+	}
+
+	if cmd.watch {
+
+		if strings.HasPrefix(cmd.binary, shared.RepoPrefix) {
+			return errors.New("flag '--watch' cannot be used with applications in the repository")
+		}
+
+		if strings.HasPrefix(cmd.binary, oci.Prefix) {
+			return errors.New("flag '--watch' cannot be used with applications in a registry")
+		}
+
+		if cmd.hypervisor == shared.VMwarePlayer {
+			return errors.New("flag '--watch' is not supported by VMWARE_PLAYER, which has no headless mode")
+		}
+
+	}
+
 	if cmd.headless && cmd.hypervisor == shared.VMwarePlayer {
 		fmt.Println("WARNING: VMWARE_PLAYER does not support headless mode. Continuing with GUI enabled.")
 		cmd.headless = false
@@ -398,95 +467,147 @@ func (cmd *Command) action(ctx *kingpin.ParseContext) error {
 			sherlock.Check(err)
 		}
 
-		// TODO: support launching any and all of the supported formats
-		// TODO: argument validation
-		config := cmd.binary + ".vcfg"
+		if cmd.logSink != "" {
+			cmd.sink, err = serial.OpenSink(cmd.logSink)
+			sherlock.Check(err)
+			defer cmd.sink.Close()
+		}
 
-		// load input
-		var in converter.Convertible
+		if cmd.watch {
+			err = cmd.watchLoop()
+			sherlock.Check(err)
+			return
+		}
 
-		if strings.HasPrefix(cmd.binary, shared.RepoPrefix) {
+		name, err := cmd.compile()
+		if cmd.tempdir {
+			os.Remove(cmd.files)
+		}
 
-			// load input from repository
-			repo, err := vml.NewTinyRepo(home.Path(home.Repository))
+		if err != nil {
 			sherlock.Check(err)
+		}
 
-			defer repo.Close()
+		if cmd.gdb != "" {
+			err = cmd.announceGDB(name)
+			if err != nil {
+				os.Remove(name)
+				sherlock.Throw(err)
+			}
+		}
 
-			in, err = repo.Export(strings.TrimPrefix(cmd.binary, shared.RepoPrefix), "")
-			sherlock.Check(err)
+		// TODO: move file before launch if persist flag set
 
-		} else {
+		// TODO: export into any filetype?
 
-			if _, err := os.Stat(cmd.binary); os.IsNotExist(err) {
-				sherlock.Check(errors.New("target not found"))
-			}
+		// TODO: check compile target is elf
+		// TODO: check disk size is greater than files size
 
-			// load input from filesystem
-			if shared.IsELF(cmd.binary) {
+		if cmd.persist == "" {
+			if cmd.hypervisor != shared.VMwarePlayer && cmd.hypervisor != shared.VMwareWorkstation && cmd.hypervisor != shared.VMwareTest {
+				defer os.Remove(name)
+			}
+		}
 
-				_, err = os.Stat(config)
-				if os.IsNotExist(err) {
-					sherlock.Check(fmt.Errorf("no configuration file at \"%v\", try 'vcli vm-config new %v' to create one", config, config))
-				}
+		err = cmd.start(name)
+		sherlock.Check(err)
 
-				in, err = converter.LoadLoose(cmd.binary, config, "", cmd.files)
-				if err != nil {
-					sherlock.Check(err)
-				}
+	})
 
-			} else if shared.IsZip(cmd.binary) {
+}
 
-				in, err = converter.LoadZipFile(cmd.binary)
-				if err != nil {
-					sherlock.Check(err)
-				}
+// compile builds the target into a VMDK and returns the path of the disk.
+func (cmd *Command) compile() (string, error) {
 
-			} else {
+	// TODO: support launching any and all of the supported formats
+	// TODO: argument validation
+	config := cmd.binary + ".vcfg"
 
-				sherlock.Check(errors.New("target not a valid ELF or zip archive"))
+	// load input
+	var in converter.Convertible
+	var err error
 
-			}
+	if strings.HasPrefix(cmd.binary, shared.RepoPrefix) {
 
+		// load input from repository
+		repo, err := vml.NewTinyRepo(home.Path(home.Repository))
+		if err != nil {
+			return "", err
 		}
 
-		defer in.Close()
+		defer repo.Close()
 
-		vmdkPath, err := converter.ExportSparseVMDK(in, cmd.persist, cmd.kernel, cmd.debug)
+		in, err = repo.Export(strings.TrimPrefix(cmd.binary, shared.RepoPrefix), "")
 		if err != nil {
-			sherlock.Check(err)
+			return "", err
 		}
-		vmdkPath.Close()
 
-		name := vmdkPath.Name()
+	} else if strings.HasPrefix(cmd.binary, oci.Prefix) {
 
-		if cmd.tempdir {
-			os.Remove(cmd.files)
+		// load input from an OCI registry
+		ref, err := oci.ParseReference(cmd.binary)
+		if err != nil {
+			return "", err
 		}
 
+		in, err = oci.NewClient().Pull(ref)
 		if err != nil {
-			sherlock.Check(err)
+			return "", err
 		}
 
-		// TODO: move file before launch if persist flag set
+	} else {
 
-		// TODO: export into any filetype?
+		if _, err := os.Stat(cmd.binary); os.IsNotExist(err) {
+			return "", errors.New("target not found")
+		}
 
-		// TODO: check compile target is elf
-		// TODO: check disk size is greater than files size
+		// load input from filesystem
+		if shared.IsELF(cmd.binary) {
 
-		if cmd.persist == "" {
-			if cmd.hypervisor != shared.VMwarePlayer && cmd.hypervisor != shared.VMwareWorkstation && cmd.hypervisor != shared.VMwareTest {
-				defer os.Remove(name)
+			_, err = os.Stat(config)
+			if os.IsNotExist(err) {
+				return "", fmt.Errorf("no configuration file at \"%v\", try 'vcli vm-config new %v' to create one", config, config)
+			}
+
+			in, err = converter.LoadLoose(cmd.binary, config, "", cmd.files)
+			if err != nil {
+				return "", err
+			}
+
+		} else if shared.IsZip(cmd.binary) {
+
+			in, err = converter.LoadZipFile(cmd.binary)
+			if err != nil {
+				return "", err
 			}
+
+		} else {
+
+			return "", errors.New("target not a valid ELF or zip archive")
+
 		}
 
-		err = cmd.start(name)
+	}
+
+	if cmd.logSink != "" {
+		bcfg, buffered, err := converter.ReadBuildConfig(in)
 		if err != nil {
-			fmt.Printf("error: %s\n", err)
+			return "", err
 		}
+		in = buffered
+		cmd.tags.App = bcfg.Name
+		cmd.tags.Version = bcfg.Version
+	}
 
-	})
+	defer in.Close()
+
+	vmdkPath, err := converter.ExportSparseVMDK(in, cmd.persist, cmd.kernel, cmd.debug)
+	if err != nil {
+		return "", err
+	}
+	vmdkPath.Close()
+
+	return vmdkPath.Name(), nil
 
 }
 
@@ -495,100 +616,60 @@ func (cmd *Command) start(disk string) error {
 
 	fmt.Printf("Using VMDK: %s\n", disk)
 
-	// executable := "qemu-system-x86_64"
-	executable := "qemu-system-x86_64"
-	var args []string
-
-	coresString := strconv.FormatUint(uint64(cmd.cpus), 10)
-	memoryString := strconv.FormatUint(uint64(cmd.memory), 10)
-	appName, err := compiler.ReadAppNameFromVMDK(disk)
+	cfg, err := hypervisor.NewConfig(cmd.hypervisor, disk)
 	sherlock.Check(err)
-	numberOfNetworkCards, err := compiler.ReadNetworkCardCountFromVMDK(disk)
-
-	sherlock.Check(err)
-
-	// var tcpserver net.Listener
 
-	f, err := os.Open(disk)
-	sherlock.Check(err)
-	f.Close()
-
-	switch cmd.hypervisor {
-	case shared.VMwarePlayer:
-		executable = "vmplayer"
-		args, err = cmd.paramsVMWare(coresString, memoryString, f.Name(), appName, true, numberOfNetworkCards)
-		sherlock.Assert(errors.New("error preparing vmware-player workstation"), err == nil)
-	case shared.VirtualBox:
-		// TODO: fix up hypervisor call
-		if !cmd.headless {
-			executable = "VirtualBox"
-		} else {
-			executable = "VBoxManage"
-		}
-
-		args, err = cmd.paramsVirtualBox(coresString, memoryString, f.Name(), appName, numberOfNetworkCards, cmd.pmap)
-		if err != nil {
-			fmt.Printf("Error preparing VirtualBox workstation: %v\n", err)
-			panic(err)
-		}
-	case shared.KVMClassic:
-		args = cmd.paramsKVM(coresString, memoryString, f.Name(), numberOfNetworkCards, cmd.pmap)
-		// case hypervisorKVMCLOUD:
-		// 	args = paramsKVMCloud(coresString, memoryString, f.Name(), numberOfNetworkCards, *cmd.pmap)
-	case shared.KVM:
-		args = cmd.paramsKVM(coresString, memoryString, f.Name(), numberOfNetworkCards, cmd.pmap)
-	case shared.QEMU:
-		var dbs string
-		if cmd.debug {
-			var err error
-			dbf, err = ioutil.TempFile("", "QEMU-")
-			sherlock.Check(err)
-			dbf.Close()
-			dbs = dbf.Name()
-			fmt.Println("QEMU debug file: " + dbs)
-		}
-		args = cmd.paramsQemu(coresString, memoryString, f.Name(), dbs, numberOfNetworkCards, cmd.pmap)
-
-	case shared.VMwareWorkstation:
-		executable = "vmrun"
-		args, err = cmd.paramsVMWare(coresString, memoryString, f.Name(), appName, false, numberOfNetworkCards)
-		sherlock.Assert(errors.New("error preparing vmware-workstation workstation"), err == nil)
-	case shared.VMwareTest:
-		executable = "vmrun"
-		args, err = cmd.paramsVMWare(coresString, memoryString, f.Name(), appName, false, numberOfNetworkCards)
-		sherlock.Assert(errors.New("error preparing vmware-workstation workstation"), err == nil)
-	default:
-		return errors.New("unsupported hypervisor")
+	if cmd.cpus != 0 {
+		cfg.CPUs = cmd.cpus
 	}
 
-	// cleanup vmware and virtual box
-	if cmd.hypervisor == shared.VMwarePlayer || cmd.hypervisor == shared.VMwareWorkstation || cmd.hypervisor == shared.VMwareTest {
+	if cmd.memory != 0 {
+		cfg.Memory = cmd.memory
+	}
 
-		// defer os.RemoveAll(fullPath)
-		//
-		// command := exec.Command("vmrun", "deleteVM", args[len(args)-1])
-		// defer command.Run()
+	cfg.PortMap = cmd.pmap
+	cfg.Headless = cmd.headless
+	cfg.Echo = cmd.echo
+	cfg.Debug = cmd.debug
+	cfg.Persist = cmd.persist
+	cfg.GDB = cmd.gdb
+	cfg.GDBPause = cmd.gdbPause
+	cfg.PCAP = cmd.pcap
 
-		if runtime.GOOS != "darwin" {
-			command := exec.Command("vmrun", "stop", args[len(args)-1], "hard")
-			defer command.Run()
-		}
+	err = cfg.AttachNetworks(cmd.networks)
+	if err != nil {
+		return err
+	}
 
-	} else if cmd.hypervisor == shared.VirtualBox {
+	if cfg.PCAP != "" {
+		fmt.Printf("Capturing network traffic to %s; summarise it with 'vcli logs --net %s'\n", strings.Join(cfg.PCAPFiles(), ", "), cfg.PCAP)
+	}
 
-		command := exec.Command("vboxmanage", "unregistervm", f.Name(), "--delete")
-		defer command.Run()
+	if cmd.debug && cmd.hypervisor == shared.QEMU {
+		dbf, err := ioutil.TempFile("", "QEMU-")
+		sherlock.Check(err)
+		dbf.Close()
+		cfg.DebugFile = dbf.Name()
+		fmt.Println("QEMU debug file: " + cfg.DebugFile)
+	}
 
-		command = exec.Command("vboxmanage", "controlvm", f.Name(), "poweroff")
-		defer command.Run()
+	hypervisor.Prune()
+	instance := hypervisor.UniqueName(cfg.Name)
+	cmd.tags.Instance = instance
+	err = cfg.Track(instance)
+	if err != nil {
+		return err
+	}
 
+	executable, args, err := cfg.Command()
+	if err != nil {
+		return err
 	}
 
-	command := exec.Command(executable, args...)
+	// cleanup vmware and virtual box
+	defer cfg.Teardown()
 
-	if (cmd.hypervisor == shared.VirtualBox) && cmd.headless {
-		command = exec.Command("vboxmanage", "startvm", appName, "--type", "headless")
-	}
+	command := exec.Command(executable, args...)
 
 	// returns a pipe that will be connected to the command's standard error
 	// when the command starts.
@@ -605,39 +686,69 @@ func (cmd *Command) start(disk string) error {
 	signal.Notify(signal_channel, os.Interrupt)
 	chBool := make(chan bool, 1)
 
+	console := new(hypervisor.Console)
+	var status error
+
 	// launch hypervisor and also listen for interrupts
-	// fmt.Printf("Args are: %v\n", args)
 	if cmd.headless {
 		err = command.Start()
+		if err != nil {
+			return fmt.Errorf("failed to start %v: %v", executable, err)
+		}
+		if cfg.PIDFile != "" {
+			// QEMU daemonizes, so its record outlives vcli and is
+			// pruned once the machine stops
+			if cmd.track(cfg, instance, nil) != nil {
+				fmt.Printf("Attach to its console with 'vcli attach %s'\n", instance)
+			}
+		}
 	} else {
+		var interrupted bool
 		var errb bytes.Buffer
 
+		// the console is followed until the machine is known to have
+		// stopped, which VMware only reports through vmrun
+		stopped := make(chan struct{})
+		var streamed <-chan struct{}
+
 		command.Stderr = &errb
 
-		if (cmd.hypervisor == shared.KVMClassic || cmd.hypervisor == shared.QEMU || cmd.hypervisor == shared.KVM) && cmd.echo {
-			stdout, err := command.StdoutPipe()
-			if err != nil {
-				panic(err)
-			}
+		// interrupts are handled by vcli, which shuts the guest down
+		// cleanly rather than leaving it to the hypervisor
+		hypervisor.SetProcessGroup(command)
+
+		err = command.Start()
+		if err != nil {
+			return fmt.Errorf("failed to start %v: %v", executable, err)
+		}
 
-			go func() {
-				io.Copy(os.Stdout, stdout)
-			}()
+		r := cmd.track(cfg, instance, command.Process)
 
-		} else {
-			var errS bytes.Buffer
-			command.Stdout = &errS
-			go cmd.serialOut()
+		var waitErr error
+		exited := make(chan struct{})
+		go func() {
+			waitErr = command.Wait()
+			close(exited)
+		}()
+
+		streamed = cmd.streamConsole(cfg, console, stopped)
+
+		select {
+		case <-exited:
+		case <-signal_channel:
+			interrupted = true
+			fmt.Println("\nShutting down...")
+			cfg.Stop(command, exited)
 		}
 
-		err = command.Run()
+		if r != nil {
+			r.Remove()
+		}
 
 		fmt.Printf("\n%v", command.Stderr)
 
 		go func() {
 
-			command.Wait()
-
 			if cmd.hypervisor == shared.VMwareWorkstation || cmd.hypervisor == shared.VMwareTest {
 
 				for {
@@ -654,7 +765,7 @@ func (cmd *Command) start(disk string) error {
 
 					str := strings.Split(out.String(), "\n")
 					for _, ln := range str {
-						if ln == vmwname {
+						if ln == cfg.VMX {
 							running = true
 						}
 					}
@@ -670,402 +781,127 @@ func (cmd *Command) start(disk string) error {
 
 		}()
 
-		select {
-		case <-signal_channel:
-		case <-chBool:
+		if !interrupted {
+			select {
+			case <-signal_channel:
+				interrupted = true
+			case <-chBool:
+			}
 		}
 
-		if cmd.hypervisor == shared.VirtualBox {
-			// fmt.Println(f.Name())
-			cmD := exec.Command("VBoxManage", "controlvm", appName, "poweroff")
-			cmD.Run()
-			cmD = exec.Command("VBoxManage", "unregistervm", appName)
-			cmD.Run()
+		close(stopped)
+		if streamed != nil {
+			select {
+			case <-streamed:
+			case <-time.After(2 * time.Second):
+			}
+		}
 
-			if cmd.echo {
-				os.Remove(vBoxSerial.Name())
+		// a serial log has everything the guest printed even if the
+		// stream was cut short
+		if cfg.SerialLog != "" {
+			c, err := hypervisor.ScanConsole(cfg.SerialLog)
+			if err == nil {
+				console = c
 			}
 		}
-		if cmd.debug && cmd.hypervisor == shared.QEMU {
-			// Don't delete the QEMU log file after process finishes ...
-			if !cmd.debug {
-				os.Remove(dbf.Name())
+
+		if !interrupted {
+			status = cfg.Status(command.ProcessState, console)
+			// non-zero exit statuses are decoded by Status, but anything
+			// else means the hypervisor couldn't be waited on at all
+			if _, ok := waitErr.(*exec.ExitError); waitErr != nil && !ok {
+				status = fmt.Errorf("%v failed: %v", executable, waitErr)
 			}
 		}
 
 	}
-	if err != nil {
-		// fmt.Printf("error: %v\n", err)
-	}
+
 	// Write StderrPipe to stdout
 	if cmd.headless {
 		io.Copy(os.Stdout, rc)
-	}
-
-	return nil
-}
-
-func (cmd *Command) serialOut() {
-	if (cmd.hypervisor == shared.VMwarePlayer || cmd.hypervisor == shared.VMwareWorkstation || cmd.hypervisor == shared.VMwareTest) && cmd.echo {
-
-		t, err := tail.TailFile(fullPath+"/serial.log", tail.Config{Follow: true})
-		if err != nil {
-			fmt.Println("Err on Tail")
-		}
-		for line := range t.Lines {
-			fmt.Println(line.Text)
-		}
-	} else if cmd.hypervisor == shared.VirtualBox && cmd.echo {
-		t, err := tail.TailFile(vBoxSerial.Name(), tail.Config{Follow: true})
+		// QEMU daemonizes and the other hypervisors return once the
+		// machine has started, so any failure is reported here
+		err = command.Wait()
 		if err != nil {
-			fmt.Println("Err on Tail")
+			return fmt.Errorf("%v failed: %v", executable, err)
 		}
-		for line := range t.Lines {
-			fmt.Println(line.Text)
-		}
-
-	}
-}
-
-func (cmd *Command) paramsVMWare(cores, memory, disk, name string, vmplayer bool, numberOfNetworkCards int) ([]string, error) {
-
-	tmpDir := os.TempDir()
-	var sub string
-
-	if vmplayer {
-		sub = "player"
-	} else {
-		sub = "workstation"
-	}
-	name = strings.Replace(name, " ", "", -1)
-	fullPath = path.Join(tmpDir, "vorteil", sub, name)
-	os.RemoveAll(fullPath)
-
-	vmxName := filepath.Join(fullPath, name+".vmx")
-
-	// just in case
-	os.MkdirAll(fullPath, os.ModePerm)
-
-	// delete if we ran it before
-	cmD := exec.Command("vmrun", "stop", vmxName)
-	cmD.Run()
-
-	cmD = exec.Command("vmrun", "deleteVM", vmxName)
-	cmD.Run()
-
-	vmwname = vmxName
-
-	// handles vmx disk name for persist or not persist
-	var diskname string
-	if cmd.persist != "" {
-		diskname = strings.Split(disk, "/")[len(strings.Split(disk, "/"))-1]
-	} else {
-		diskname = disk
 	}
 
-	// generate the vmx string with actual settings
-	vmxString := shared.GenerateVMX(cores, memory, diskname, name, fullPath, numberOfNetworkCards)
-
-	err := ioutil.WriteFile(vmxName, []byte(vmxString), os.ModePerm)
-	if err != nil {
-		return nil, err
-	}
-
-	var args []string
-	if vmplayer {
-		args = []string{vmxName}
-	} else {
-		args = []string{"start", vmxName}
-		if cmd.headless {
-			args = append(args, "nogui")
-		}
-	}
-
-	// Handle Persists
-	if cmd.persist != "" {
-
-		old, err := filepath.Abs(cmd.persist)
-		if err != nil {
-			fmt.Println(err)
-		}
-		// pers := strings.Split(cmd.persist, "/")
-		pers := filepath.SplitList(cmd.persist)
-		new := fullPath + "/" + pers[len(pers)-1]
-
-		err = os.Symlink(old, new)
-		if err != nil {
-			fmt.Println(err)
-		}
-	}
-
-	return args, nil
+	return status
 }
 
-func (cmd *Command) paramsVirtualBox(cores, memory, disk, name string, numberOfNetworkCards int, mappings string) ([]string, error) {
-
-	// delete vm
-	tmpDir := os.TempDir()
-	fullPath := path.Join(tmpDir, "vorteil")
-
-	os.RemoveAll(fullPath)
-
-	cmD := exec.Command("VBoxManage", "controlvm", name, "poweroff")
-	cmD.Run()
-
-	// if image by specified name already exists, remove image before creating new one
-	cmD = exec.Command("VBoxManage", "unregistervm", name)
-	cmD.Run()
-
-	// create vm
-	cmD = exec.Command("vboxmanage", "createvm", "--basefolder", fullPath, "--name", name, "--register")
-	err := cmD.Run()
-	if err != nil {
-		return nil, err
-	}
-
-	args := []string{"modifyvm", name, "--memory", memory, "--acpi", "on", "--ioapic", "on", "--cpus", cores, "--pae", "on"}
-	args = append(args, "--longmode", "on", "--largepages", "on", "--chipset", "ich9", "--bioslogofadein", "off")
-	args = append(args, "--bioslogofadeout", "off", "--bioslogodisplaytime", "1", "--biosbootmenu", "disabled", "--rtcuseutc", "on")
-
-	if !cmd.headless && cmd.echo {
-		vBoxSerial, err = ioutil.TempFile(tmpDir, "vBoxSerial")
-		if err != nil {
-			fmt.Println(err)
-		}
-		args = append(args, "--uart1", "0x3F8", "4", "--uartmode1", "file", vBoxSerial.Name())
-
-	}
-
-	if len(mappings) > 0 {
-		pmap := strings.Split(mappings, ",")
-		for i, p := range pmap {
-			pmapIndividual := strings.Split(p, ":")
-			x, _ := strconv.Atoi(pmapIndividual[0])
-
-			// TCP ...
-			cmD = exec.Command("vboxmanage", "modifyvm", name, "--natpf"+strconv.Itoa(x+1), fmt.Sprintf("nat%dtcp,tcp,,%s,,%s", i, pmapIndividual[1], pmapIndividual[2]))
-			err = cmD.Run()
-
-			// UDP ...
-			cmD = exec.Command("vboxmanage", "modifyvm", name, "--natpf"+strconv.Itoa(x+1), fmt.Sprintf("nat%dudp,udp,,%s,,%s", i, pmapIndividual[1], pmapIndividual[2]))
-			err = cmD.Run()
-		}
-	}
+// track registers the running machine so it can be managed with commands like
+// 'vcli snapshot'.
+func (cmd *Command) track(cfg *hypervisor.Config, name string, process *os.Process) *hypervisor.Record {
 
-	for i := 1; i <= numberOfNetworkCards; i++ {
-		args = append(args, "--nic"+strconv.Itoa(i), "nat", "--nictype"+strconv.Itoa(i), "82540EM", "--cableconnected"+strconv.Itoa(i), "on")
+	r := cfg.Record(name)
+	r.KeepDisk = true
+	if process != nil && cfg.PIDFile == "" {
+		r.PID = process.Pid
 	}
 
-	cmD = exec.Command("vboxmanage", args...)
-	err = cmD.Run()
+	err := r.Save()
 	if err != nil {
-		return nil, err
+		fmt.Printf("WARNING: could not register instance: %v\n", err)
+		return nil
 	}
 
-	cmD = exec.Command("vboxmanage", "storagectl", name, "--name", "SATA", "--add", "sata", "--portcount", "4", "--bootable", "on")
-	err = cmD.Run()
-	if err != nil {
-		return nil, err
-	}
+	fmt.Printf("Instance: %s\n", name)
 
-	cmD = exec.Command("vboxmanage", "storageattach", name, "--storagectl", "SATA", "--port", "0", "--device", "0", "--type", "hdd", "--medium", disk)
-	err = cmD.Run()
-	if err != nil {
-		return nil, err
-	}
+	return r
 
-	if !cmd.headless {
-		args = args[:0]
-		args = append(args, "--startvm", name, "--start-running")
-		if cmd.debug {
-			args = append(args, "--debug")
-		}
-		args = append(args, "--type", "sdl")
-	}
-
-	return args, nil
 }
 
-func paramsKVMCloud(cores, memory, disk string, numberOfNetworkCards int, mappings string) []string {
-	args := []string{"-cpu", "IvyBridge", "-no-reboot"}
-	args = append(args, "-machine", "pc", "-smp", cores, "-m", memory, "-enable-kvm")
-
-	for i := 0; i < numberOfNetworkCards; i++ {
-		args = append(args, "-device", "virtio-net-pci,id=network"+strconv.Itoa(i))
-	}
-
-	args = append(args, "-device", "virtio-scsi-pci,id=scsi", "-device", "scsi-hd,drive=hd0")
-	args = append(args, "-s", "-drive", "if=none,file="+disk+",format=vmdk,id=hd0")
+// printer returns the printer the serial console is echoed with.
+func (cmd *Command) printer() *serial.Printer {
 
-	// split pmap
-	if len(mappings) > 0 {
-		pmap := strings.Split(mappings, ",")
-		for _, p := range pmap {
-			pmapIndividual := strings.Split(p, ":")
-			args = append(args, "-redir", fmt.Sprintf("tcp:%s::%s", pmapIndividual[0], pmapIndividual[1]))
-		}
+	return &serial.Printer{
+		W:          os.Stdout,
+		Level:      cmd.minLevel,
+		Timestamps: cmd.timestamps,
+		JSON:       cmd.json,
+		Sink:       cmd.sink,
+		Tags:       cmd.tags,
 	}
 
-	return args
 }
 
-func (cmd *Command) paramsKVM(cores, memory, disk string, numberOfNetworkCards int, mappings string) []string {
-	args := []string{"-cpu", "host", "-no-reboot"}
-	args = append(args, "-machine", "q35", "-smp", cores, "-m", memory, "-enable-kvm")
-
-	//Start as headless
-	if cmd.headless {
-		args = append(args, "-nographic", "-display", "none", "-daemonize")
-	}
-
-	if cmd.debug {
-		args = append(args, "-s")
-	}
-
-	if cmd.hypervisor != shared.KVM {
-		// Adds new AHCI SATA drive
-		args = append(args, "-drive", "id=disk,file="+disk+",if=none")
-		args = append(args, "-device", "ide-drive,drive=disk,bus=ide.0,id=hd0")
-	}
-
-	if cmd.echo && !cmd.headless {
-		args = append(args, "-serial", "stdio")
-	}
-
-	start := 0xa
-
-	if cmd.hypervisor == shared.KVM {
-		// KVM
-
-		// virtio scsi hd
-		args = append(args, "-device", "virtio-scsi-pci,id=scsi", "-device", "scsi-hd,drive=hd0")
-		args = append(args, "-drive", "if=none,file="+disk+",format=vmdk,id=hd0")
-
-		// virtio net pci
-		for i := 0; i < numberOfNetworkCards; i++ {
-
-			var card string
-			istr := strconv.Itoa(i)
-			pmap := strings.Split(mappings, ",")
+// streamConsole feeds the guest's serial console to console as it runs,
+// echoing it and forwarding it to the log sink if asked to, and returns a
+// channel that is closed once the stream ends. Anything typed is sent to the
+// guest while it's echoed.
+func (cmd *Command) streamConsole(cfg *hypervisor.Config, console *hypervisor.Console, stopped <-chan struct{}) <-chan struct{} {
 
-			if len(mappings) > 0 {
+	streamed := make(chan struct{})
 
-				// loop through port maps and assign where appropriate
-				for j := 0; j < len(pmap); j++ {
+	go func() {
 
-					pmapInd := strings.Split(pmap[j], ":")
-					if pmapInd[0] == istr {
-						// TCP ...
-						// args = append(args, "-redir", fmt.Sprintf("tcp:%s::%s", pmapInd[1], pmapInd[2]))
-						card += fmt.Sprintf(",hostfwd=tcp::%s-:%s", pmapInd[1], pmapInd[2])
-
-						// UDP ...
-						// args = append(args, "-redir", fmt.Sprintf("udp:%s::%s", pmapInd[1], pmapInd[2]))
-						card += fmt.Sprintf(",hostfwd=udp::%s-:%s", pmapInd[1], pmapInd[2])
-					}
-
-				}
-
-			}
-
-			args = append(args, "-netdev", "user,id=network"+istr+card, "-device", "virtio-net-pci,netdev=network"+istr+",id=virtio"+istr+",mac="+fmt.Sprintf("26:10:05:00:00:0%x", start+(i*0x1)))
+		defer close(streamed)
 
+		stream, err := hypervisor.OpenConsole(cfg.Console, stopped)
+		if err != nil {
+			fmt.Printf("WARNING: %v\n", err)
+			return
 		}
+		defer stream.Close()
 
-	} else {
-		for i := 0; i < numberOfNetworkCards; i++ {
-
-			istr := strconv.Itoa(i)
-			var card string
-			pmap := strings.Split(mappings, ",")
-
-			if len(mappings) > 0 {
-
-				// loop through port maps and assign where appropriate
-				for j := 0; j < len(pmap); j++ {
-
-					pmapInd := strings.Split(pmap[j], ":")
-					if pmapInd[0] == istr {
-						// TCP ...
-						card += fmt.Sprintf(",hostfwd=tcp::%s-:%s", pmapInd[1], pmapInd[2])
-
-						// UDP ...
-						card += fmt.Sprintf(",hostfwd=udp::%s-:%s", pmapInd[1], pmapInd[2])
-					}
-
-				}
-
-			}
-
-			args = append(args, "-netdev", "user,id=network"+istr+card, "-device", "e1000,netdev=network"+istr+",mac="+fmt.Sprintf("26:10:05:00:00:0%x", start+(i*0x1)))
-
+		if !cmd.echo && cmd.sink == nil {
+			io.Copy(console, stream)
+			return
 		}
-	}
-
-	return args
-}
-
-func (cmd *Command) addPorts(mappings string) string {
-	var out string
-	if len(mappings) > 0 {
-		pmap := strings.Split(mappings, ",")
-		for _, p := range pmap {
-			pmapIndividual := strings.Split(p, ":")
-			out += ",hostfwd=tcp::" + pmapIndividual[0] + "-:" + pmapIndividual[1]
 
+		p := cmd.printer()
+		if !cmd.echo {
+			p.W = ioutil.Discard
+		} else if w, ok := stream.(io.Writer); ok {
+			go io.Copy(w, os.Stdin)
 		}
-	}
-	return out
-}
 
-func (cmd *Command) paramsQemu(cores, memory, disk, debugFile string, numberOfNetworkCards int, mappings string) []string {
+		p.Copy(io.TeeReader(stream, console))
 
-	args := []string{"-cpu", "qemu64,+rdtscp,+fsgsbase,+ssse3,+sse4.1,+sse4.2,+x2apic,+invtsc", "-no-reboot"}
-	args = append(args, "-machine", "q35", "-smp", cores, "-m", memory)
-	args = append(args, "-device", "ahci,id=ahci0", "-device", "ide-drive,bus=ahci0.0,drive=drive-sata0-0-0,id=sata0-0-0")
-	args = append(args, "-drive", "if=none,file="+disk+",format=vmdk,id=drive-sata0-0-0")
-	if cmd.echo && !cmd.headless {
-		args = append(args, "-serial", "stdio")
-	}
-
-	start := 0xa
-
-	for i := 0; i < numberOfNetworkCards; i++ {
-
-		istr := strconv.Itoa(i)
-		var card string
-		pmap := strings.Split(mappings, ",")
-
-		if len(mappings) > 0 {
-
-			// loop through port maps and assign where appropriate
-			for j := 0; j < len(pmap); j++ {
-
-				pmapInd := strings.Split(pmap[j], ":")
-				if pmapInd[0] == istr {
-					card += fmt.Sprintf(",hostfwd=tcp::%s-:%s", pmapInd[1], pmapInd[2])
-					card += fmt.Sprintf(",hostfwd=udp::%s-:%s", pmapInd[1], pmapInd[2])
-				}
-
-			}
-
-		}
-
-		args = append(args, "-netdev", "user,id=network"+istr+card, "-device", "e1000,netdev=network"+istr+",mac="+fmt.Sprintf("26:10:05:00:00:0%x", start+(i*0x1)))
-
-	}
-
-	if len(debugFile) > 0 {
-		args = append(args, "-d", "int,guest_errors,cpu,in_asm,exec", "-D", debugFile)
-	}
-	if cmd.debug {
-		args = append(args, "-s")
-	}
-
-	if cmd.headless {
-		args = append(args, "-nographic", "-display", "none", "-daemonize")
-	}
+	}()
 
-	return args
+	return streamed
 
 }
@@ -0,0 +1,110 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrun
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+// writeGDBScript writes a gdb init script that loads the app's symbols at the
+// load address recorded in the disk's image header and connects to the
+// gdbstub, and returns its path.
+func (cmd *Command) writeGDBScript(disk string) (string, error) {
+
+	host, port, err := hypervisor.GDBAddress(cmd.gdb)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# generated by 'vcli run --gdb %v'\n", cmd.gdb)
+	fmt.Fprintln(buf, "set architecture i386:x86-64")
+
+	var script string
+
+	if shared.IsELF(cmd.binary) {
+
+		app, err := filepath.Abs(cmd.binary)
+		if err != nil {
+			return "", err
+		}
+
+		f, err := elf.Open(app)
+		if err != nil {
+			return "", err
+		}
+		typ := f.Type
+		f.Close()
+
+		// statically linked apps are loaded at their linked addresses,
+		// position independent ones wherever the image header says
+		if typ == elf.ET_DYN {
+			offset := cmd.gdbOffset
+			if offset == 0 {
+				offset, err = compiler.ReadLoadAddressFromVMDK(disk)
+				if err != nil {
+					return "", err
+				}
+			}
+			if offset == 0 {
+				fmt.Println("WARNING: the disk doesn't record where position independent apps are loaded; use '--gdb-offset' to give the load address so symbols resolve.")
+			}
+			fmt.Fprintf(buf, "add-symbol-file %v -o %#x\n", app, offset)
+		} else {
+			fmt.Fprintf(buf, "symbol-file %v\n", app)
+		}
+
+		script = app + ".gdbinit"
+
+	} else {
+
+		fmt.Println("WARNING: app symbols are only loaded for ELF targets; load them manually with 'symbol-file'.")
+		script = shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix)) + ".gdbinit"
+
+	}
+
+	fmt.Fprintf(buf, "target remote %v:%v\n", host, port)
+
+	err = ioutil.WriteFile(script, buf.Bytes(), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return script, nil
+
+}
+
+// announceGDB writes the gdb init script for the disk and tells the user how
+// to attach to the gdbstub.
+func (cmd *Command) announceGDB(disk string) error {
+
+	script, err := cmd.writeGDBScript(disk)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("gdbstub listening on %v; attach with 'gdb -x %v'\n", cmd.gdb, script)
+
+	return nil
+
+}
@@ -0,0 +1,253 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdrun
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/sisatech/vcli/hypervisor"
+)
+
+const (
+	watchInterval = 500 * time.Millisecond
+	watchDebounce = time.Second
+)
+
+type stamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchLoop rebuilds and restarts the application every time its inputs
+// change, until interrupted.
+func (cmd *Command) watchLoop() error {
+
+	if cmd.tempdir {
+		defer os.Remove(cmd.files)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	changes := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go cmd.watchFiles(changes, stop)
+
+	for {
+
+		inst, disk, err := cmd.launch()
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			fmt.Println("Waiting for changes...")
+		}
+
+		var exited <-chan struct{}
+		if inst != nil {
+			exited = inst.Done()
+		}
+
+		select {
+		case <-interrupt:
+			cmd.shutdown(inst, disk)
+			return nil
+		case <-exited:
+			if err := inst.Status(); err != nil {
+				fmt.Printf("%v\n", err)
+			}
+			fmt.Println("Application stopped. Waiting for changes...")
+			select {
+			case <-interrupt:
+				cmd.shutdown(inst, disk)
+				return nil
+			case <-changes:
+			}
+		case <-changes:
+		}
+
+		fmt.Println("Change detected. Rebuilding...")
+		cmd.shutdown(inst, disk)
+
+	}
+
+}
+
+// launch compiles the application and boots it headless, streaming its
+// serial console to stdout.
+func (cmd *Command) launch() (*hypervisor.Instance, string, error) {
+
+	disk, err := cmd.compile()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cmd.gdb != "" {
+		err = cmd.announceGDB(disk)
+		if err != nil {
+			os.Remove(disk)
+			return nil, "", err
+		}
+	}
+
+	cfg, err := hypervisor.NewConfig(cmd.hypervisor, disk)
+	if err != nil {
+		os.Remove(disk)
+		return nil, "", err
+	}
+
+	if cmd.cpus != 0 {
+		cfg.CPUs = cmd.cpus
+	}
+
+	if cmd.memory != 0 {
+		cfg.Memory = cmd.memory
+	}
+
+	cfg.PortMap = cmd.pmap
+	cfg.Debug = cmd.debug
+	cfg.Persist = cmd.persist
+	cfg.GDB = cmd.gdb
+	cfg.GDBPause = cmd.gdbPause
+	cfg.PCAP = cmd.pcap
+
+	err = cfg.AttachNetworks(cmd.networks)
+	if err != nil {
+		os.Remove(disk)
+		return nil, "", err
+	}
+
+	inst, err := hypervisor.Start(cfg)
+	if err != nil {
+		os.Remove(disk)
+		return nil, "", err
+	}
+
+	go cmd.echoConsole(inst)
+
+	return inst, disk, nil
+
+}
+
+func (cmd *Command) shutdown(inst *hypervisor.Instance, disk string) {
+
+	if inst == nil {
+		return
+	}
+
+	inst.Shutdown()
+	os.Remove(inst.Config.SerialLog)
+
+	if cmd.persist == "" {
+		os.Remove(disk)
+	}
+
+}
+
+// echoConsole prints the instance's serial console until the instance stops
+// and everything it wrote has been printed.
+func (cmd *Command) echoConsole(inst *hypervisor.Instance) {
+
+	stream, err := inst.OpenConsole()
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+	defer stream.Close()
+
+	cmd.printer().Copy(stream)
+
+}
+
+// watchFiles polls the application's inputs and signals on changes once they
+// have settled, so a burst of writes from a build only triggers one restart.
+func (cmd *Command) watchFiles(changes chan<- struct{}, stop <-chan struct{}) {
+
+	last := cmd.snapshot()
+	var pending time.Time
+
+	for {
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(watchInterval):
+		}
+
+		current := cmd.snapshot()
+		if !sameSnapshot(last, current) {
+			last = current
+			pending = time.Now()
+			continue
+		}
+
+		if !pending.IsZero() && time.Since(pending) >= watchDebounce {
+			pending = time.Time{}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+
+	}
+
+}
+
+func (cmd *Command) snapshot() map[string]stamp {
+
+	snap := make(map[string]stamp)
+
+	add := func(path string, info os.FileInfo) {
+		snap[path] = stamp{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	for _, path := range []string{cmd.binary, cmd.binary + ".vcfg"} {
+		if info, err := os.Stat(path); err == nil {
+			add(path, info)
+		}
+	}
+
+	if cmd.files != "" && !cmd.tempdir {
+		filepath.Walk(cmd.files, func(path string, info os.FileInfo, err error) error {
+			if err == nil {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+
+	return snap
+
+}
+
+func sameSnapshot(a, b map[string]stamp) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w.size != v.size || !w.modTime.Equal(v.modTime) {
+			return false
+		}
+	}
+
+	return true
+
+}
@@ -43,6 +43,10 @@ func (cmd *Command) Attach(parent command.Node) {
 	newAuthorCmd().Attach(cmd)
 	newHypervisorsCommand().Attach(cmd)
 	newKernelCommand().Attach(cmd)
+	newKeysCommand().Attach(cmd)
+	newMirrorCmd().Attach(cmd)
+	newStorageCmd().Attach(cmd)
+	newCompressionCmd().Attach(cmd)
 
 }
 
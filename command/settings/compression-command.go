@@ -0,0 +1,85 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+	"github.com/sisatech/vcli/vml/archiver"
+)
+
+type cmdCompression struct {
+	*kingpin.CmdClause
+	arg         string
+	argProvided bool
+}
+
+// New ...
+func newCompressionCmd() *cmdCompression {
+
+	return &cmdCompression{}
+
+}
+
+// Attach ...
+func (cmd *cmdCompression) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("compression", shared.Catenate(`The
+		compression setting defines how the local repository compresses
+		the blobs it stores: 'zstd', the default, 'gzip' or 'none'.
+		Each blob is only kept compressed if that makes it smaller, and
+		blobs stored any other way can still be read. Use 'vcli
+		repository gc --compress' to recompress existing blobs. If no
+		arguments are supplied the command prints the currently stored
+		value to stdout.`))
+
+	clause := cmd.Arg("new-value", shared.Catenate(`New compression to
+		save.`))
+
+	clause.PreAction(cmd.preaction)
+
+	clause.StringVar(&cmd.arg)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdCompression) preaction(ctx *kingpin.ParseContext) error {
+
+	encoding, err := archiver.ParseEncoding(cmd.arg)
+	if err != nil {
+		return err
+	}
+
+	cmd.argProvided = true
+	home.GlobalDefaults.Compression = cmd.arg
+	vml.Compression = encoding
+	return nil
+
+}
+
+func (cmd *cmdCompression) action(ctx *kingpin.ParseContext) error {
+
+	if !cmd.argProvided {
+		fmt.Println(vml.Compression)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKernelAdd struct {
+	*kingpin.CmdClause
+	image     string
+	version   string
+	signature string
+	debug     bool
+	force     bool
+}
+
+// New ...
+func newKernelAddCmd() *cmdKernelAdd {
+
+	return &cmdKernelAdd{}
+
+}
+
+// Attach ...
+func (cmd *cmdKernelAdd) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("add", shared.Catenate(`The add kernel
+		command installs a kernel image built locally, so that it can
+		be selected by version like a downloaded kernel. Unless a
+		signature made with a trusted key is supplied, builds only use
+		the kernel when given the --allow-unsigned-kernel flag. Use
+		'vcli settings keys add' to trust another key.`))
+
+	cmd.Arg("image", "Kernel image to add.").Required().ExistingFileVar(&cmd.image)
+
+	flag := cmd.Flag("version", shared.Catenate(`Version to install the
+		kernel as, in the form major.minor.patch.`))
+	flag.Required()
+	flag.StringVar(&cmd.version)
+
+	flag = cmd.Flag("signature", shared.Catenate(`Detached, armored OpenPGP
+		signature of the kernel image.`))
+	flag.ExistingFileVar(&cmd.signature)
+
+	flag = cmd.Flag("debug", shared.Catenate(`Install the image as the debug
+		kernel of an existing version.`))
+	flag.Short('d')
+	flag.BoolVar(&cmd.debug)
+
+	flag = cmd.Flag("force", shared.Catenate(`Replace a kernel already
+		installed with the same version.`))
+	flag.Short('f')
+	flag.BoolVar(&cmd.force)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKernelAdd) action(ctx *kingpin.ParseContext) error {
+
+	name, err := compiler.AddKernel(cmd.image, cmd.version, cmd.signature, cmd.debug, cmd.force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %v\n", name)
+	if cmd.signature == "" {
+		fmt.Println("WARNING: kernel is unsigned; build with --allow-unsigned-kernel to use it")
+	}
+
+	// set global default to the new kernel if it was previously blank
+	if home.GlobalDefaults.Kernel == "" && !cmd.debug {
+		home.GlobalDefaults.Kernel = cmd.version
+	}
+
+	return nil
+
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKernelExportBundle struct {
+	*kingpin.CmdClause
+	file     string
+	versions []string
+}
+
+// New ...
+func newKernelExportBundleCmd() *cmdKernelExportBundle {
+
+	return &cmdKernelExportBundle{}
+
+}
+
+// Attach ...
+func (cmd *cmdKernelExportBundle) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("export-bundle", shared.Catenate(`The
+		export-bundle command writes locally downloaded kernels, the
+		boot assets released with them, and their signatures to a
+		single gzipped tarball that can be carried to an offline
+		machine and installed with import-bundle, or used directly as a
+		mirror. Files without a valid signature are left out.`))
+
+	cmd.Arg("file", "Path to write the bundle to.").Required().StringVar(&cmd.file)
+
+	clause := cmd.Arg("version", shared.Catenate(`Kernel versions to export.
+		Defaults to every locally downloaded kernel.`))
+
+	clause.HintOptions(home.ListLocalKernels()...)
+
+	clause.StringsVar(&cmd.versions)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKernelExportBundle) action(ctx *kingpin.ParseContext) error {
+
+	versions := cmd.versions
+	if len(versions) == 0 {
+		versions = home.ListLocalKernels()
+	}
+
+	if len(versions) == 0 {
+		return errors.New("no local kernels to export")
+	}
+
+	f, err := os.Create(cmd.file)
+	if err != nil {
+		return err
+	}
+
+	files, err := compiler.ExportBundle(f, versions)
+	if err != nil {
+		f.Close()
+		os.Remove(cmd.file)
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		fmt.Printf("Exported %s\n", name)
+	}
+
+	return nil
+
+}
+
+type cmdKernelImportBundle struct {
+	*kingpin.CmdClause
+	file string
+}
+
+// New ...
+func newKernelImportBundleCmd() *cmdKernelImportBundle {
+
+	return &cmdKernelImportBundle{}
+
+}
+
+// Attach ...
+func (cmd *cmdKernelImportBundle) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("import-bundle", shared.Catenate(`The
+		import-bundle command installs the kernels and boot assets in a
+		bundle written by export-bundle. Every file is checked against
+		its signature, and nothing is installed if any check fails.`))
+
+	cmd.Arg("file", "Path of the bundle to import.").Required().ExistingFileVar(&cmd.file)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKernelImportBundle) action(ctx *kingpin.ParseContext) error {
+
+	files, err := compiler.ImportBundle(cmd.file)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		fmt.Printf("Imported %s\n", name)
+	}
+
+	return nil
+
+}
@@ -15,7 +15,6 @@ package cmdsettings
 
 import (
 	"errors"
-	"os"
 	"strconv"
 	"strings"
 
@@ -72,68 +71,10 @@ func (cmd *cmdKernelDownload) action(ctx *kingpin.ParseContext) error {
 		}
 	}
 
-	// TODO: robust filesystem verification
-
-	// retrieve vtramp if not already found
-	_, err := os.Stat(home.Path(home.Kernel + "/vtramp.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vtramp.img", "vtramp")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve vboot if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vboot.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vboot.img", "vboot")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve prod kernel
-	prod := "vkernel-PROD-" + cmd.arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + prod))
+	// retrieve the kernel and the boot assets released with it
+	err := compiler.FetchKernel(cmd.arg)
 	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(prod, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve debug kernel
-	debug := "vkernel-DEBUG-" + cmd.arg + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + debug))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(debug, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
+		return errors.New("unexpected error: " + err.Error())
 	}
 
 	// set global default to the new kernel if it was previously blank
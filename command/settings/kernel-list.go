@@ -14,8 +14,11 @@
 package cmdsettings
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin"
@@ -23,6 +26,7 @@ import (
 	"github.com/sisatech/vcli/compiler"
 	"github.com/sisatech/vcli/home"
 	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
 )
 
 type cmdKernelList struct {
@@ -43,7 +47,9 @@ func (cmd *cmdKernelList) Attach(parent command.Node) {
 	cmd.CmdClause = parent.Command("list", shared.Catenate(`The list kernels
 		command returns a list of all vorteil kernels on the local
 		system as well as more available to download from the Sisa-Tech
-		official kernel repository.`))
+		official kernel repository. It also lists which local kernels
+		each app in the repository can be built with, given its kernel
+		constraint and the image header features it uses.`))
 
 	flag := cmd.Flag("local", shared.Catenate(`Only list kernels
 		found locally.`))
@@ -64,9 +70,11 @@ func (cmd *cmdKernelList) action(ctx *kingpin.ParseContext) error {
 			return errors.New("no kernels found locally")
 		}
 
+		table := [][]string{{"Version", "Boot Assets"}}
 		for _, x := range vals {
-			fmt.Println(x)
+			table = append(table, []string{x, bootAssetStatus(x)})
 		}
+		shared.PrettyTable(table)
 
 	} else {
 
@@ -77,15 +85,15 @@ func (cmd *cmdKernelList) action(ctx *kingpin.ParseContext) error {
 
 		var vals [][]string
 		vals = append(vals, []string{"Release Date", "Version",
-			"Downloaded"})
+			"Downloaded", "Boot Assets"})
 		for _, x := range ret {
 			var row []string
 			row = append(row, x.Created.Format(time.RFC822))
 			row = append(row, x.Name)
 			if x.Local {
-				row = append(row, "✓")
+				row = append(row, "✓", bootAssetStatus(x.Name))
 			} else {
-				row = append(row, "")
+				row = append(row, "", "")
 			}
 			vals = append(vals, row)
 		}
@@ -93,6 +101,182 @@ func (cmd *cmdKernelList) action(ctx *kingpin.ParseContext) error {
 
 	}
 
+	return appKernelTable()
+
+}
+
+// appKernelTable prints the local kernels each app in the repository can be
+// built with.
+func appKernelTable() error {
+
+	apps, cfgs, err := repositoryConfigs(false)
+	if err != nil {
+		return err
+	}
+
+	if len(apps) == 0 {
+		return nil
+	}
+
+	table := [][]string{{"App", "Kernel Constraint", "Compatible Kernels"}}
+	for i, app := range apps {
+
+		cfg := cfgs[i]
+		if cfg == nil {
+			table = append(table, []string{app, "", "invalid config"})
+			continue
+		}
+
+		kernels := strings.Join(compiler.CompatibleKernels(cfg), ", ")
+		if kernels == "" {
+			kernels = "none"
+		}
+
+		table = append(table, []string{app, cfg.Kernel, kernels})
+
+	}
+
+	fmt.Println()
+	shared.PrettyTable(table)
+
 	return nil
 
 }
+
+// repositoryConfigs returns the path and latest config of every app in the
+// repository, or with all set, the config of every version of every app,
+// named PATH@REF. Configs that can't be parsed are nil.
+func repositoryConfigs(all bool) ([]string, []*shared.BuildConfig, error) {
+
+	repo, err := vml.NewTinyRepo(home.Path(home.Repository))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer repo.Close()
+
+	apps, err := repositoryApps(repo, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// versions often share a config, so each is only read once
+	parsed := make(map[string]*shared.BuildConfig)
+	parse := func(sum string) (*shared.BuildConfig, error) {
+
+		if cfg, ok := parsed[sum]; ok {
+			return cfg, nil
+		}
+
+		r, err := repo.GetBlob(sum)
+		if err != nil {
+			return nil, err
+		}
+
+		var data []byte
+		if r != nil {
+			data, err = ioutil.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		cfg := new(shared.BuildConfig)
+		err = json.Unmarshal(data, cfg)
+		if err != nil {
+			cfg = nil
+		}
+
+		parsed[sum] = cfg
+
+		return cfg, nil
+
+	}
+
+	var names []string
+	var cfgs []*shared.BuildConfig
+	for _, app := range apps {
+
+		records, err := repo.Records(app)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !all && len(records) > 0 {
+			records = records[len(records)-1:]
+		}
+
+		for _, rec := range records {
+
+			cfg, err := parse(rec.Config)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			name := app
+			if all {
+				name = app + "@" + rec.Ref
+			}
+
+			names = append(names, name)
+			cfgs = append(cfgs, cfg)
+
+		}
+
+	}
+
+	return names, cfgs, nil
+
+}
+
+// repositoryApps returns the paths of every app beneath a repository
+// directory.
+func repositoryApps(repo *vml.TinyRepo, dir string) ([]string, error) {
+
+	list, err := repo.ListDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []string
+	for _, entry := range list {
+
+		path := entry.Name
+		if dir != "" {
+			path = dir + "/" + entry.Name
+		}
+
+		switch entry.Type {
+		case "app":
+			apps = append(apps, path)
+		case "dir":
+			children, err := repositoryApps(repo, path)
+			if err != nil {
+				return nil, err
+			}
+			apps = append(apps, children...)
+		}
+
+	}
+
+	return apps, nil
+
+}
+
+// bootAssetStatus summarises which of the boot assets built with a kernel are
+// available locally.
+func bootAssetStatus(version string) string {
+
+	missing := home.MissingBootAssets(version)
+	if len(missing) > 0 {
+		return "missing " + strings.Join(missing, ", ")
+	}
+
+	var names []string
+	for _, asset := range home.BootAssets() {
+		names = append(names, asset.Name)
+	}
+
+	return strings.Join(names, ", ")
+
+}
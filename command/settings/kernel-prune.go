@@ -0,0 +1,102 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKernelPrune struct {
+	*kingpin.CmdClause
+	dryRun bool
+}
+
+// New ...
+func newKernelPruneCmd() *cmdKernelPrune {
+
+	return &cmdKernelPrune{}
+
+}
+
+// Attach ...
+func (cmd *cmdKernelPrune) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("prune", shared.Catenate(`The prune
+		kernel command removes local kernels, along with their boot
+		assets and signatures, that neither the default kernel setting
+		nor any version of an app in the repository refers to. A
+		version refers to the kernel it would be built with by default,
+		given its kernel constraint.`))
+
+	flag := cmd.Flag("dry-run", shared.Catenate(`Only print the kernels that
+		would be removed.`))
+	flag.Short('n')
+	flag.BoolVar(&cmd.dryRun)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKernelPrune) action(ctx *kingpin.ParseContext) error {
+
+	apps, cfgs, err := repositoryConfigs(true)
+	if err != nil {
+		return err
+	}
+
+	keep := map[string]bool{
+		home.GlobalDefaults.Kernel: true,
+	}
+
+	for i, cfg := range cfgs {
+
+		if cfg == nil {
+			return fmt.Errorf("can't tell which kernel %v needs: invalid config", apps[i])
+		}
+
+		keep[compiler.AppKernel(cfg)] = true
+
+	}
+
+	for _, version := range home.ListLocalKernels() {
+
+		if keep[version] {
+			continue
+		}
+
+		if cmd.dryRun {
+			fmt.Printf("Would remove kernel %v\n", version)
+			continue
+		}
+
+		files, err := compiler.RemoveKernel(version)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range files {
+			fmt.Printf("Removed %v\n", name)
+		}
+
+	}
+
+	return nil
+
+}
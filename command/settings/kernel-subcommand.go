@@ -42,7 +42,12 @@ func (cmd *cmdKernel) Attach(parent command.Node) {
 	newKernelDefaultCmd().Attach(cmd)
 	newKernelListCmd().Attach(cmd)
 	newKernelDownloadCmd().Attach(cmd)
+	newKernelAddCmd().Attach(cmd)
 	newKernelUpdateCmd().Attach(cmd)
+	newKernelVerifyCmd().Attach(cmd)
+	newKernelPruneCmd().Attach(cmd)
+	newKernelExportBundleCmd().Attach(cmd)
+	newKernelImportBundleCmd().Attach(cmd)
 
 }
 
@@ -16,7 +16,6 @@ package cmdsettings
 import (
 	"errors"
 	"fmt"
-	"os"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/sisatech/vcli/command"
@@ -63,70 +62,12 @@ func (cmd *cmdKernelUpdate) action(ctx *kingpin.ParseContext) error {
 	version := ret[0].Name
 
 	// download
-
-	// TODO: robust filesystem verification
 	fmt.Printf("Downloading Vorteil kernel: %v...\n", version)
 
-	// retrieve vtramp if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vtramp.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vtramp.img", "vtramp")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve vboot if not already found
-	_, err = os.Stat(home.Path(home.Kernel + "/vboot.img"))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile("vboot.img", "vboot")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve prod kernel
-	prod := "vkernel-PROD-" + version + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + prod))
+	// retrieve the kernel and the boot assets released with it
+	err = compiler.FetchKernel(version)
 	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(prod, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-	}
-
-	// retrieve debug kernel
-	debug := "vkernel-DEBUG-" + version + ".img"
-	_, err = os.Stat(home.Path(home.Kernel + "/" + debug))
-	if err != nil {
-
-		if !os.IsNotExist(err) {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
-		err = compiler.DownloadVorteilFile(debug, "vkernel")
-		if err != nil {
-			return errors.New("unexpected error: " + err.Error())
-		}
-
+		return errors.New("unexpected error: " + err.Error())
 	}
 
 	// set global default to the new kernel
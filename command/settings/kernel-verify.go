@@ -0,0 +1,83 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKernelVerify struct {
+	*kingpin.CmdClause
+}
+
+// New ...
+func newKernelVerifyCmd() *cmdKernelVerify {
+
+	return &cmdKernelVerify{}
+
+}
+
+// Attach ...
+func (cmd *cmdKernelVerify) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("verify", shared.Catenate(`The verify
+		kernel command checks every local kernel and boot asset against
+		the signature downloaded with it, using the Sisa-Tech keyring
+		built into vcli. Files that fail can be replaced with 'vcli
+		settings kernel download'.`))
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKernelVerify) action(ctx *kingpin.ParseContext) error {
+
+	vals := home.ListLocalKernels()
+	if len(vals) == 0 {
+		return errors.New("no kernels found locally")
+	}
+
+	var failed int
+	table := [][]string{{"Version", "File", "Status"}}
+	for _, version := range vals {
+		for _, name := range compiler.KernelFiles(version) {
+
+			status := "ok"
+			err := compiler.VerifyLocalFile(name)
+			if err != nil {
+				status = err.Error()
+				failed++
+			}
+
+			table = append(table, []string{version, name, status})
+
+		}
+	}
+
+	shared.PrettyTable(table)
+
+	if failed > 0 {
+		return fmt.Errorf("%d files failed verification", failed)
+	}
+
+	return nil
+
+}
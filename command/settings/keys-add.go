@@ -0,0 +1,63 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKeysAdd struct {
+	*kingpin.CmdClause
+	file string
+}
+
+// New ...
+func newKeysAddCmd() *cmdKeysAdd {
+
+	return &cmdKeysAdd{}
+
+}
+
+// Attach ...
+func (cmd *cmdKeysAdd) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("add", shared.Catenate(`The add keys
+		command trusts the OpenPGP public keys in a file, armored or
+		binary, to sign kernels and boot assets.`))
+
+	cmd.Arg("file", "File containing the keys to trust.").Required().ExistingFileVar(&cmd.file)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKeysAdd) action(ctx *kingpin.ParseContext) error {
+
+	keys, err := compiler.AddKeys(cmd.file)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		fmt.Printf("Trusted %v\n", key.Fingerprint)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKeysList struct {
+	*kingpin.CmdClause
+}
+
+// New ...
+func newKeysListCmd() *cmdKeysList {
+
+	return &cmdKeysList{}
+
+}
+
+// Attach ...
+func (cmd *cmdKeysList) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("list", shared.Catenate(`The list keys
+		command prints every key that kernels and boot assets are
+		verified against.`))
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKeysList) action(ctx *kingpin.ParseContext) error {
+
+	keys, err := compiler.TrustedKeys()
+	if err != nil {
+		return err
+	}
+
+	table := [][]string{{"Fingerprint", "Identities", "Source"}}
+	for _, key := range keys {
+		source := "added"
+		if key.BuiltIn {
+			source = "built-in"
+		}
+		table = append(table, []string{key.Fingerprint, strings.Join(key.Identities, ", "), source})
+	}
+	shared.PrettyTable(table)
+
+	return nil
+
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKeysRemove struct {
+	*kingpin.CmdClause
+	id string
+}
+
+// New ...
+func newKeysRemoveCmd() *cmdKeysRemove {
+
+	return &cmdKeysRemove{}
+
+}
+
+// Attach ...
+func (cmd *cmdKeysRemove) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("remove", shared.Catenate(`The remove
+		keys command stops trusting a key added with 'vcli settings keys
+		add'. The built-in Sisa-Tech key can't be removed.`))
+
+	cmd.Arg("key", "Fingerprint or key ID of the key to remove.").Required().StringVar(&cmd.id)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdKeysRemove) action(ctx *kingpin.ParseContext) error {
+
+	key, err := compiler.RemoveKey(cmd.id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %v\n", key.Fingerprint)
+
+	return nil
+
+}
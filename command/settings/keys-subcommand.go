@@ -0,0 +1,52 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdKeys struct {
+	*kingpin.CmdClause
+}
+
+func newKeysCommand() *cmdKeys {
+
+	return &cmdKeys{}
+
+}
+
+// Attach ...
+func (cmd *cmdKeys) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("keys", shared.Catenate(`The keys
+		subcommand contains commands to manage the OpenPGP keys that
+		kernels and boot assets are verified against, alongside the
+		Sisa-Tech key built into vcli.`))
+
+	cmd.PreAction(cmd.preaction)
+
+	newKeysAddCmd().Attach(cmd)
+	newKeysListCmd().Attach(cmd)
+	newKeysRemoveCmd().Attach(cmd)
+
+}
+
+func (cmd *cmdKeys) preaction(ctx *kingpin.ParseContext) error {
+
+	return command.NodeOnlyCheck(cmd, ctx)
+
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdMirror struct {
+	*kingpin.CmdClause
+	arg         string
+	argProvided bool
+}
+
+// New ...
+func newMirrorCmd() *cmdMirror {
+
+	return &cmdMirror{}
+
+}
+
+// Attach ...
+func (cmd *cmdMirror) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("mirror", shared.Catenate(`The mirror
+		setting defines where kernels and boot assets are downloaded
+		from instead of the official Sisa-Tech repository. It may be an
+		http(s) URL, an s3://bucket/prefix URL, a local directory, or a
+		bundle written by 'settings kernel export-bundle'. Files are
+		expected at PACKAGE/FILE with a detached signature at
+		PACKAGE/FILE.asc, where PACKAGE is 'vkernel' or the name of a
+		boot asset. HTTP mirrors must also serve PACKAGE/index.json
+		listing their files. If no arguments are supplied the command
+		prints the currently stored value to stdout. An empty string
+		restores the official repository.`))
+
+	clause := cmd.Arg("new-value", shared.Catenate(`New mirror location to
+		save.`))
+
+	clause.PreAction(cmd.preaction)
+
+	clause.StringVar(&cmd.arg)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdMirror) preaction(ctx *kingpin.ParseContext) error {
+
+	_, err := compiler.OpenSource(cmd.arg)
+	if err != nil {
+		return err
+	}
+
+	cmd.argProvided = true
+	home.GlobalDefaults.Mirror = cmd.arg
+	return nil
+
+}
+
+func (cmd *cmdMirror) action(ctx *kingpin.ParseContext) error {
+
+	if !cmd.argProvided {
+		fmt.Println(home.GlobalDefaults.Mirror)
+	}
+
+	return nil
+
+}
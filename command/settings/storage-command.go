@@ -0,0 +1,91 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsettings
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+	"github.com/sisatech/vcli/vml/archiver"
+)
+
+type cmdStorage struct {
+	*kingpin.CmdClause
+	arg         string
+	argProvided bool
+}
+
+// New ...
+func newStorageCmd() *cmdStorage {
+
+	return &cmdStorage{}
+
+}
+
+// Attach ...
+func (cmd *cmdStorage) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("storage", shared.Catenate(`The storage
+		setting defines where the local repository keeps the blobs that
+		hold app binaries, configs, icons and files. It may be an
+		s3://bucket/prefix URL, optionally followed by
+		?endpoint=URL&region=REGION for S3-compatible stores, or a
+		local directory such as a network share. Credentials for S3 are
+		read from the standard AWS environment variables. Blobs in S3
+		or a storage directory may be shared by several repositories,
+		so they are not deleted when apps are. Changing this setting
+		doesn't move any blobs; use 'vcli repository migrate' to copy
+		them and switch storage in one step. If no arguments are
+		supplied the command prints the currently stored value to
+		stdout. An empty string keeps blobs in the repository
+		directory.`))
+
+	clause := cmd.Arg("new-value", shared.Catenate(`New storage location to
+		save.`))
+
+	clause.PreAction(cmd.preaction)
+
+	clause.StringVar(&cmd.arg)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdStorage) preaction(ctx *kingpin.ParseContext) error {
+
+	location, err := archiver.CleanLocation(cmd.arg)
+	if err != nil {
+		return err
+	}
+
+	cmd.argProvided = true
+	home.GlobalDefaults.Storage = location
+	vml.Storage = location
+	return nil
+
+}
+
+func (cmd *cmdStorage) action(ctx *kingpin.ParseContext) error {
+
+	if !cmd.argProvided {
+		fmt.Println(home.GlobalDefaults.Storage)
+	}
+
+	return nil
+
+}
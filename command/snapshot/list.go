@@ -0,0 +1,94 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsnapshot
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdList struct {
+	*kingpin.CmdClause
+	instance string
+}
+
+func newListCmd() *cmdList {
+
+	return &cmdList{}
+
+}
+
+// Attach ...
+func (cmd *cmdList) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("list", shared.Catenate(`List the
+		snapshots of every instance, or of a single instance.`))
+	cmd.Alias("ls")
+
+	arg := cmd.Arg("instance", shared.Catenate(`Only list snapshots of
+		this instance.`))
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.instance)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdList) action(ctx *kingpin.ParseContext) error {
+
+	var records []*hypervisor.Record
+
+	if cmd.instance != "" {
+		r, err := hypervisor.LoadRecord(cmd.instance)
+		if err != nil {
+			return err
+		}
+		records = append(records, r)
+	} else {
+		var err error
+		records, err = hypervisor.ListRecords()
+		if err != nil {
+			return err
+		}
+	}
+
+	vals := [][]string{{"Instance", "Hypervisor", "Snapshot", "Created"}}
+	for _, r := range records {
+
+		snapshots, err := r.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		for _, s := range snapshots {
+			vals = append(vals, []string{r.Name, r.Hypervisor, s.Name,
+				s.Created.Format(time.RFC822)})
+		}
+
+	}
+
+	if len(vals) == 1 {
+		return errors.New("no snapshots found; try 'vcli snapshot save'")
+	}
+
+	shared.PrettyTable(vals)
+
+	return nil
+
+}
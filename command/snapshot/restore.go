@@ -0,0 +1,75 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdsnapshot
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+)
+
+type cmdRestore struct {
+	*kingpin.CmdClause
+	instance string
+	name     string
+}
+
+func newRestoreCmd() *cmdRestore {
+
+	return &cmdRestore{}
+
+}
+
+// Attach ...
+func (cmd *cmdRestore) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("restore", shared.Catenate(`Return a
+		running virtual machine to a saved snapshot. VirtualBox can't
+		restore a running machine, so it is powered off and started
+		again.`))
+
+	arg := cmd.Arg("instance", shared.Catenate(`Name of the instance to
+		restore.`))
+	arg.Required()
+	arg.HintAction(hypervisor.ListInstanceNames)
+	arg.StringVar(&cmd.instance)
+
+	arg = cmd.Arg("name", shared.Catenate(`Name of the snapshot.`))
+	arg.Required()
+	arg.StringVar(&cmd.name)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *cmdRestore) action(ctx *kingpin.ParseContext) error {
+
+	r, err := hypervisor.LoadRecord(cmd.instance)
+	if err != nil {
+		return err
+	}
+
+	err = r.RestoreSnapshot(cmd.name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %v to snapshot '%v'\n", cmd.instance, cmd.name)
+
+	return nil
+
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sisatech/vcli/hypervisor"
+)
+
+const pollInterval = 500 * time.Millisecond
+
+// instance is the part of a running virtual machine the checks watch. It is
+// satisfied by *hypervisor.Instance.
+type instance interface {
+	Done() <-chan struct{}
+	Status() error
+	ExitCode() int
+}
+
+// machine is the booted virtual machine the checks are run against. Every
+// check's timeout is measured from boot rather than from when the check
+// before it finished, so one slow check doesn't delay the rest.
+type machine struct {
+	inst   instance
+	serial string
+	boot   time.Time
+}
+
+func (vm *machine) deadline(timeout time.Duration) time.Time {
+
+	return vm.boot.Add(timeout)
+
+}
+
+type result struct {
+	name     string
+	kind     string
+	duration time.Duration
+	err      error
+}
+
+func (l *Log) run(vm *machine) result {
+
+	start := time.Now()
+	res := result{name: l.Name, kind: "serial"}
+
+	deadline := vm.deadline(l.timeout)
+	for {
+
+		data, err := ioutil.ReadFile(vm.serial)
+		if err == nil && l.re.Match(data) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			res.err = fmt.Errorf("pattern '%v' not found in serial output within %v of boot", l.Pattern, l.timeout)
+			break
+		}
+
+		select {
+		case <-vm.inst.Done():
+			// one last look now that the console has been flushed
+			data, err = ioutil.ReadFile(vm.serial)
+			if err != nil || !l.re.Match(data) {
+				res.err = fmt.Errorf("virtual machine stopped before pattern '%v' appeared in serial output", l.Pattern)
+			}
+		case <-time.After(pollInterval):
+			continue
+		}
+
+		break
+
+	}
+
+	res.duration = time.Since(start)
+	return res
+
+}
+
+func (p *Probe) run(vm *machine) result {
+
+	start := time.Now()
+	res := result{name: p.Name}
+
+	var check func() error
+	if p.HTTP != "" {
+		res.kind = "http"
+		check = p.checkHTTP
+	} else {
+		res.kind = "tcp"
+		check = p.checkTCP
+	}
+
+	deadline := vm.deadline(p.timeout)
+	for {
+
+		res.err = check()
+		if res.err == nil || time.Now().After(deadline) {
+			break
+		}
+
+		select {
+		case <-vm.inst.Done():
+			res.err = fmt.Errorf("virtual machine stopped: %v", res.err)
+		case <-time.After(pollInterval):
+			continue
+		}
+
+		break
+
+	}
+
+	res.duration = time.Since(start)
+	return res
+
+}
+
+func (p *Probe) checkTCP() error {
+
+	conn, err := net.DialTimeout("tcp", p.TCP, pollInterval)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+
+}
+
+func (p *Probe) checkHTTP() error {
+
+	client := &http.Client{Timeout: p.timeout}
+	resp, err := client.Get(p.HTTP)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if p.Status != 0 && resp.StatusCode != p.Status {
+		return fmt.Errorf("expected status %d, got %d", p.Status, resp.StatusCode)
+	}
+
+	if p.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if p.re != nil {
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		if !p.re.Match(body) {
+			return fmt.Errorf("response body does not match '%v'", p.Body)
+		}
+
+	}
+
+	return nil
+
+}
+
+func checkExitCode(vm *machine, expect int, timeout time.Duration) result {
+
+	start := time.Now()
+	res := result{name: "exit-code", kind: "exit"}
+
+	select {
+	case <-vm.inst.Done():
+	case <-time.After(vm.deadline(timeout).Sub(time.Now())):
+	}
+
+	// the deadline may already have passed by the time earlier checks
+	// finish, so a machine that has stopped is always checked
+	select {
+	case <-vm.inst.Done():
+		code := vm.inst.ExitCode()
+		status := vm.inst.Status()
+		if e, ok := status.(*hypervisor.ExitError); (ok && e.Panic) || (status != nil && !ok) {
+			res.err = status
+		} else if code != expect {
+			res.err = fmt.Errorf("expected exit code %d, got %d", expect, code)
+		}
+	default:
+		res.err = fmt.Errorf("virtual machine did not exit within %v of boot", timeout)
+	}
+
+	res.duration = time.Since(start)
+	return res
+
+}
@@ -0,0 +1,286 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdtest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sisatech/vcli/hypervisor"
+)
+
+// fakeInstance stands in for a running virtual machine. It has stopped if
+// done is closed.
+type fakeInstance struct {
+	done   chan struct{}
+	status error
+	code   int
+}
+
+func newFakeInstance(stopped bool) *fakeInstance {
+
+	inst := &fakeInstance{done: make(chan struct{})}
+	if stopped {
+		close(inst.done)
+	}
+
+	return inst
+
+}
+
+func (inst *fakeInstance) Done() <-chan struct{} {
+
+	return inst.done
+
+}
+
+func (inst *fakeInstance) Status() error {
+
+	return inst.status
+
+}
+
+func (inst *fakeInstance) ExitCode() int {
+
+	return inst.code
+
+}
+
+// newMachine returns a machine that booted the given time ago, with serial
+// written to its console.
+func newMachine(t *testing.T, inst *fakeInstance, serial string, ago time.Duration) *machine {
+
+	f, err := ioutil.TempFile("", "serial-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &machine{inst: inst, serial: f.Name(), boot: time.Now().Add(-ago)}
+
+}
+
+func expectResult(t *testing.T, name string, res result, kind, err string) {
+
+	if res.kind != kind {
+		t.Errorf("%s: got kind %q, expected %q", name, res.kind, kind)
+	}
+
+	if err == "" && res.err != nil {
+		t.Errorf("%s: unexpected error: %v", name, res.err)
+	}
+
+	if err != "" && (res.err == nil || !strings.Contains(res.err.Error(), err)) {
+		t.Errorf("%s: got %v, expected an error containing %q", name, res.err, err)
+	}
+
+}
+
+func TestLogCheck(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		pattern string
+		serial  string
+		stopped bool
+		ago     time.Duration
+		err     string
+	}{
+		{"found", "listening on [0-9]+", "booting\nlistening on 8080\n", false, 0, ""},
+		{"found after exit", "done", "done\n", true, 0, ""},
+		{"expired", "ready", "booting\n", false, 2 * time.Second, "not found in serial output within 1s of boot"},
+		{"stopped", "ready", "booting\n", true, 0, "virtual machine stopped before pattern 'ready'"},
+	}
+
+	for _, test := range tests {
+
+		vm := newMachine(t, newFakeInstance(test.stopped), test.serial, test.ago)
+
+		l := &Log{Name: test.name, Pattern: test.pattern, re: regexp.MustCompile(test.pattern), timeout: time.Second}
+		res := l.run(vm)
+		os.Remove(vm.serial)
+
+		expectResult(t, test.name, res, "serial", test.err)
+
+	}
+
+}
+
+func TestProbeCheck(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "hello world")
+	}))
+	defer srv.Close()
+
+	// a port nothing is listening on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name    string
+		probe   Probe
+		kind    string
+		stopped bool
+		err     string
+	}{
+		{"http", Probe{HTTP: srv.URL}, "http", false, ""},
+		{"http body", Probe{HTTP: srv.URL, Body: "wor.d"}, "http", false, ""},
+		{"http body mismatch", Probe{HTTP: srv.URL, Body: "^bye"}, "http", false, "response body does not match '^bye'"},
+		{"http status", Probe{HTTP: srv.URL + "/missing", Status: 404}, "http", false, ""},
+		{"http unexpected status", Probe{HTTP: srv.URL + "/missing"}, "http", false, "unexpected status 404"},
+		{"http wrong status", Probe{HTTP: srv.URL, Status: 201}, "http", false, "expected status 201, got 200"},
+		{"tcp", Probe{TCP: srv.Listener.Addr().String()}, "tcp", false, ""},
+		{"tcp refused", Probe{TCP: closed}, "tcp", false, "refused"},
+		{"stopped", Probe{TCP: closed}, "tcp", true, "virtual machine stopped"},
+	}
+
+	for _, test := range tests {
+
+		inst := newFakeInstance(test.stopped)
+
+		// expire every probe at once except the one waiting on the machine
+		ago := 2 * time.Second
+		if test.stopped {
+			ago = 0
+		}
+		vm := newMachine(t, inst, "", ago)
+
+		p := test.probe
+		p.timeout = time.Second
+		if p.Body != "" {
+			p.re = regexp.MustCompile(p.Body)
+		}
+
+		res := p.run(vm)
+		os.Remove(vm.serial)
+
+		expectResult(t, test.name, res, test.kind, test.err)
+
+	}
+
+}
+
+func TestExitCodeCheck(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		stopped bool
+		code    int
+		status  error
+		err     string
+	}{
+		{"clean", true, 0, nil, ""},
+		{"expected failure", true, 3, &hypervisor.ExitError{Code: 3}, ""},
+		{"wrong code", true, 1, &hypervisor.ExitError{Code: 1}, "expected exit code 3, got 1"},
+		{"panic", true, hypervisor.PanicExitCode, &hypervisor.ExitError{Code: hypervisor.PanicExitCode, Panic: true}, "guest kernel panic"},
+		{"hypervisor failed", true, -1, errors.New("QEMU exited with status 1"), "QEMU exited with status 1"},
+		{"running", false, 0, nil, "did not exit within 1s of boot"},
+	}
+
+	for _, test := range tests {
+
+		inst := newFakeInstance(test.stopped)
+		inst.code = test.code
+		inst.status = test.status
+
+		expect := 3
+		if test.name == "clean" {
+			expect = 0
+		}
+
+		vm := newMachine(t, inst, "", 2*time.Second)
+		res := checkExitCode(vm, expect, time.Second)
+		os.Remove(vm.serial)
+
+		expectResult(t, test.name, res, "exit", test.err)
+
+	}
+
+}
+
+func TestTimeoutsFromBoot(t *testing.T) {
+
+	spec := &Spec{timeout: 300 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		spec.Serial = append(spec.Serial, Log{
+			Name:    fmt.Sprintf("serial-%d", i),
+			Pattern: "never",
+			re:      regexp.MustCompile("never"),
+			timeout: spec.timeout,
+		})
+	}
+
+	vm := newMachine(t, newFakeInstance(false), "booting\n", 0)
+	defer os.Remove(vm.serial)
+
+	start := time.Now()
+	results := spec.run(vm)
+	elapsed := time.Since(start)
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, expected 3", len(results))
+	}
+
+	for _, res := range results {
+		if res.err == nil {
+			t.Errorf("%s: expected the check to time out", res.name)
+		}
+	}
+
+	// checks run one after another would take at least 3*timeout
+	if elapsed >= 3*spec.timeout {
+		t.Errorf("checks took %v; expected every timeout to be measured from boot", elapsed)
+	}
+
+}
+
+func TestRunReportsPanic(t *testing.T) {
+
+	inst := newFakeInstance(true)
+	inst.status = &hypervisor.ExitError{Code: hypervisor.PanicExitCode, Panic: true}
+
+	vm := newMachine(t, inst, "kernel panic\n", 0)
+	defer os.Remove(vm.serial)
+
+	spec := &Spec{Serial: []Log{{Name: "boot", Pattern: "panic", re: regexp.MustCompile("panic"), timeout: time.Second}}}
+
+	results := spec.run(vm)
+	if len(results) != 2 || results[1].kind != "panic" || results[1].err == nil {
+		t.Errorf("expected a failed panic result after the serial check, got %v", results)
+	}
+
+}
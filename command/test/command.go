@@ -0,0 +1,330 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdtest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/sisatech/sherlock"
+	"github.com/sisatech/vcli/command"
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/compiler/converter"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/hypervisor"
+	"github.com/sisatech/vcli/shared"
+	"github.com/sisatech/vcli/vml"
+)
+
+// Command ...
+type Command struct {
+	*kingpin.CmdClause
+	binary     string
+	spec       string
+	files      string
+	hypervisor string
+	kernel     string
+	memory     uint32
+	cpus       uint16
+	junit      string
+	serial     string
+}
+
+// New ...
+func New() *Command {
+
+	return &Command{}
+
+}
+
+// Attach ...
+func (cmd *Command) Attach(parent command.Node) {
+
+	cmd.CmdClause = parent.Command("test", shared.Catenate(`The test command
+		boots a Vorteil application headless on a local hypervisor and
+		runs the checks described in its test spec against it. The spec
+		is a YAML file listing HTTP and TCP probes, patterns expected on
+		the serial console, an expected exit code and timeouts. The
+		virtual machine is always torn down afterwards, and the command
+		fails if any check fails.`))
+
+	clause := cmd.Arg("application", shared.Catenate(`Target application to
+		test.`))
+	clause.Required()
+	clause.StringVar(&cmd.binary)
+
+	flag := cmd.Flag("spec", shared.Catenate(`Test spec to run. Defaults to
+		the application's path with a '`+specExtension+`' extension,
+		next to its .vcfg file.`))
+	flag.StringVar(&cmd.spec)
+
+	flag = cmd.Flag("files", shared.Catenate(`Directory to clone for use as
+		the root directory when compiling the Vorteil application.`))
+	flag.ExistingDirVar(&cmd.files)
+
+	flag = cmd.Flag("hypervisor", shared.Catenate(`Hypervisor to launch the
+		Vorteil application in. Use 'vcli settings hypervisors list' to
+		see what options are available on your system.`))
+	flag.Default(home.GlobalDefaults.Hypervisor)
+	flag.HintAction(shared.ListDetectedHypervisors)
+	flag.StringVar(&cmd.hypervisor)
+
+	flag = cmd.Flag("kernel", shared.Catenate(`Version of the Vorteil kernel
+		to use when building the Vorteil application.`))
+	flag.Default(home.GlobalDefaults.Kernel)
+	flag.HintAction(home.ListLocalKernels)
+	flag.StringVar(&cmd.kernel)
+
+	flag = cmd.Flag("allow-unsigned-kernel", shared.Catenate(`Allow building
+		with a kernel that has no signature, such as one added with 'vcli
+		settings kernel add'. Kernels with an invalid signature are
+		always refused.`))
+	flag.BoolVar(&compiler.AllowUnsignedKernel)
+
+	flag = cmd.Flag("ram", shared.Catenate(`RAM in megabytes to assign to
+		the VM, overriding the 'vm' section of the app's configuration.
+		Defaults to 64.`))
+	flag.Uint32Var(&cmd.memory)
+
+	flag = cmd.Flag("cpus", shared.Catenate(`Number of virtual cpus to
+		assign to the VM, overriding the 'vm' section of the app's
+		configuration. Defaults to 1.`))
+	flag.Uint16Var(&cmd.cpus)
+	flag.Hidden()
+
+	flag = cmd.Flag("junit", shared.Catenate(`Write the results as a JUnit
+		XML report to this file.`))
+	flag.StringVar(&cmd.junit)
+
+	flag = cmd.Flag("serial-log", shared.Catenate(`Keep the application's
+		serial console output in this file rather than discarding
+		it.`))
+	flag.StringVar(&cmd.serial)
+
+	cmd.Action(cmd.action)
+
+}
+
+func (cmd *Command) validateArgs() error {
+
+	if cmd.spec == "" {
+		cmd.spec = strings.TrimPrefix(cmd.binary, shared.RepoPrefix) + specExtension
+	}
+
+	if _, err := os.Stat(cmd.spec); os.IsNotExist(err) {
+		return fmt.Errorf("no test spec at \"%v\"; use '--spec' to point to one", cmd.spec)
+	}
+
+	if cmd.kernel == "" {
+		return errors.New("empty string for kernel version; try 'vcli settings kernel --help' to define a default")
+	}
+
+	if !home.ValidLocalKernel(cmd.kernel) {
+		return fmt.Errorf("kernel %v not found locally; try 'vcli settings kernel download %v'", cmd.kernel, cmd.kernel)
+	}
+
+	cmd.hypervisor = strings.ToUpper(cmd.hypervisor)
+
+	if !shared.ValidHypervisorWithHidden(cmd.hypervisor) {
+		return fmt.Errorf("invalid hypervisor '%v'", cmd.hypervisor)
+	}
+
+	if cmd.hypervisor == shared.VMwarePlayer {
+		return errors.New("VMWARE_PLAYER does not support headless mode")
+	}
+
+	if cmd.memory != 0 && cmd.memory < 16 {
+		return errors.New("insufficient RAM. Must be at least 16 MB.")
+	}
+
+	if cmd.memory%4 != 0 {
+		return errors.New("RAM must be a multiple of 4")
+	}
+
+	return nil
+
+}
+
+func (cmd *Command) load() (converter.Convertible, func(), error) {
+
+	if strings.HasPrefix(cmd.binary, shared.RepoPrefix) {
+
+		repo, err := vml.NewTinyRepo(home.Path(home.Repository))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		in, err := repo.Export(strings.TrimPrefix(cmd.binary, shared.RepoPrefix), "")
+		if err != nil {
+			repo.Close()
+			return nil, nil, err
+		}
+
+		return in, func() {
+			in.Close()
+			repo.Close()
+		}, nil
+
+	}
+
+	if _, err := os.Stat(cmd.binary); os.IsNotExist(err) {
+		return nil, nil, errors.New("target not found")
+	}
+
+	var in converter.Convertible
+	var err error
+
+	if shared.IsELF(cmd.binary) {
+		in, err = converter.LoadLoose(cmd.binary, cmd.binary+".vcfg", "", cmd.files)
+	} else if shared.IsZip(cmd.binary) {
+		in, err = converter.LoadZipFile(cmd.binary)
+	} else {
+		err = errors.New("target not a valid ELF or zip archive")
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return in, func() { in.Close() }, nil
+
+}
+
+func (cmd *Command) action(ctx *kingpin.ParseContext) error {
+
+	return sherlock.Try(func() {
+
+		sherlock.Check(cmd.validateArgs())
+
+		spec, err := loadSpec(cmd.spec)
+		sherlock.Check(err)
+
+		if spec.Name == "" {
+			spec.Name = shared.NodeName(strings.TrimPrefix(cmd.binary, shared.RepoPrefix))
+		}
+
+		err = hypervisor.ValidatePortMap(spec.PortMap)
+		sherlock.Check(err)
+
+		in, closer, err := cmd.load()
+		sherlock.Check(err)
+
+		disk, err := converter.ExportSparseVMDK(in, "", cmd.kernel, false)
+		closer()
+		sherlock.Check(err)
+
+		disk.Close()
+		defer os.Remove(disk.Name())
+
+		cfg, err := hypervisor.NewConfig(cmd.hypervisor, disk.Name())
+		sherlock.Check(err)
+
+		if cmd.cpus != 0 {
+			cfg.CPUs = cmd.cpus
+		}
+
+		if cmd.memory != 0 {
+			cfg.Memory = cmd.memory
+		}
+
+		cfg.PortMap = spec.PortMap
+		cfg.SerialLog = cmd.serial
+
+		fmt.Printf("Booting %v on %v\n", spec.Name, cmd.hypervisor)
+
+		start := time.Now()
+		inst, err := hypervisor.Start(cfg)
+		sherlock.Check(err)
+
+		vm := &machine{inst: inst, serial: cfg.SerialLog, boot: time.Now()}
+
+		if cmd.serial == "" {
+			defer os.Remove(cfg.SerialLog)
+		}
+
+		results := spec.run(vm)
+
+		inst.Stop()
+		elapsed := time.Since(start)
+
+		var failed int
+		rows := [][]string{{"CHECK", "TYPE", "RESULT", "TIME", "DETAIL"}}
+		for _, res := range results {
+
+			status, detail := "PASS", ""
+			if res.err != nil {
+				failed++
+				status, detail = "FAIL", res.err.Error()
+			}
+
+			rows = append(rows, []string{res.name, res.kind, status,
+				res.duration.String(), detail})
+
+		}
+
+		shared.PrettyLeftTable(rows)
+
+		if cmd.junit != "" {
+			serial, _ := ioutil.ReadFile(cfg.SerialLog)
+			err = writeJUnit(cmd.junit, spec.Name, results, elapsed, string(serial))
+			sherlock.Check(err)
+		}
+
+		if failed > 0 {
+			if stderr := inst.Stderr(); stderr != "" {
+				fmt.Fprintf(os.Stderr, "%v\n", strings.TrimSpace(stderr))
+			}
+			sherlock.Throw(fmt.Errorf("%d of %d checks failed", failed, len(results)))
+		}
+
+		fmt.Printf("%d checks passed in %v\n", len(results), elapsed)
+
+	})
+
+}
+
+func (spec *Spec) run(vm *machine) []result {
+
+	var results []result
+
+	for i := range spec.Serial {
+		results = append(results, spec.Serial[i].run(vm))
+	}
+
+	for i := range spec.Probes {
+		results = append(results, spec.Probes[i].run(vm))
+	}
+
+	if spec.ExitCode != nil {
+		results = append(results, checkExitCode(vm, *spec.ExitCode, spec.timeout))
+		return results
+	}
+
+	// a panic fails the run even if nothing was waiting for the guest to exit
+	select {
+	case <-vm.inst.Done():
+		if e, ok := vm.inst.Status().(*hypervisor.ExitError); ok && e.Panic {
+			results = append(results, result{name: "kernel", kind: "panic", err: e})
+		}
+	default:
+	}
+
+	return results
+
+}
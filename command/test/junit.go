@@ -0,0 +1,87 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+type junitSuite struct {
+	XMLName   xml.Name    `xml:"testsuite"`
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      string      `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	SystemOut string      `xml:"system-out,omitempty"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+func seconds(d time.Duration) string {
+
+	return fmt.Sprintf("%.3f", d.Seconds())
+
+}
+
+func writeJUnit(path, name string, results []result, elapsed time.Duration, serial string) error {
+
+	suite := junitSuite{
+		Name:      name,
+		Tests:     len(results),
+		Time:      seconds(elapsed),
+		SystemOut: serial,
+	}
+
+	for _, res := range results {
+
+		tc := junitCase{
+			Name:      res.name,
+			Classname: name + "." + res.kind,
+			Time:      seconds(res.duration),
+		}
+
+		if res.err != nil {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: res.err.Error(),
+				Type:    res.kind,
+			}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+
+	}
+
+	out, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append([]byte(xml.Header), out...), 0644)
+
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdtest
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJUnit(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "vcli-junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	results := []result{
+		{name: "boot", kind: "serial", duration: 1500 * time.Millisecond},
+		{name: "web", kind: "http", duration: 250 * time.Millisecond, err: errors.New("unexpected status 500")},
+		{name: "exit-code", kind: "exit", duration: time.Second},
+	}
+
+	path := filepath.Join(dir, "report.xml")
+	err = writeJUnit(path, "app", results, 2750*time.Millisecond, "booting <app>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("report is missing the XML header")
+	}
+
+	suite := new(junitSuite)
+	err = xml.Unmarshal(data, suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if suite.Name != "app" || suite.Tests != 3 || suite.Failures != 1 || suite.Time != "2.750" {
+		t.Errorf("got suite %q with %d tests, %d failures in %v", suite.Name, suite.Tests, suite.Failures, suite.Time)
+	}
+
+	if suite.SystemOut != "booting <app>\n" {
+		t.Errorf("got system-out %q", suite.SystemOut)
+	}
+
+	cases := []struct {
+		name      string
+		classname string
+		time      string
+		failure   string
+	}{
+		{"boot", "app.serial", "1.500", ""},
+		{"web", "app.http", "0.250", "unexpected status 500"},
+		{"exit-code", "app.exit", "1.000", ""},
+	}
+
+	if len(suite.Cases) != len(cases) {
+		t.Fatalf("got %d test cases, expected %d", len(suite.Cases), len(cases))
+	}
+
+	for i, expect := range cases {
+
+		tc := suite.Cases[i]
+		if tc.Name != expect.name || tc.Classname != expect.classname || tc.Time != expect.time {
+			t.Errorf("case %d: got %q (%v) in %v", i, tc.Name, tc.Classname, tc.Time)
+		}
+
+		if expect.failure == "" && tc.Failure != nil {
+			t.Errorf("case %d: unexpected failure %q", i, tc.Failure.Message)
+		}
+
+		if expect.failure != "" && (tc.Failure == nil || tc.Failure.Message != expect.failure || tc.Failure.Type != "http") {
+			t.Errorf("case %d: got failure %+v, expected %q", i, tc.Failure, expect.failure)
+		}
+
+	}
+
+}
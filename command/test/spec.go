@@ -0,0 +1,177 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdtest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	specExtension  = ".vtest"
+	defaultTimeout = "60s"
+)
+
+// Spec describes the checks run against a booted Vorteil application. Each
+// check must pass within its timeout of the machine booting, and uses the
+// spec's timeout if it doesn't set its own.
+type Spec struct {
+	Name     string  `yaml:"name"`
+	Timeout  string  `yaml:"timeout"`
+	PortMap  string  `yaml:"port-map"`
+	Serial   []Log   `yaml:"serial"`
+	Probes   []Probe `yaml:"probes"`
+	ExitCode *int    `yaml:"exit-code"`
+
+	timeout time.Duration
+}
+
+// Log is a pattern expected to appear on the application's serial console.
+type Log struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	Timeout string `yaml:"timeout"`
+
+	re      *regexp.Regexp
+	timeout time.Duration
+}
+
+// Probe is an HTTP or TCP check made against the running application. HTTP
+// probes may also check the response status and body.
+type Probe struct {
+	Name    string `yaml:"name"`
+	HTTP    string `yaml:"http"`
+	TCP     string `yaml:"tcp"`
+	Status  int    `yaml:"status"`
+	Body    string `yaml:"body"`
+	Timeout string `yaml:"timeout"`
+
+	re      *regexp.Regexp
+	timeout time.Duration
+}
+
+func loadSpec(path string) (*Spec, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := new(Spec)
+	err = yaml.Unmarshal(data, spec)
+	if err != nil {
+		return nil, fmt.Errorf("test spec %v is invalid yaml: %v", path, err)
+	}
+
+	err = spec.validate()
+	if err != nil {
+		return nil, fmt.Errorf("test spec %v: %v", path, err)
+	}
+
+	return spec, nil
+
+}
+
+func parseTimeout(s, def string) (time.Duration, error) {
+
+	if s == "" {
+		s = def
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if d <= 0 {
+		return 0, errors.New("timeout must be positive")
+	}
+
+	return d, nil
+
+}
+
+func (spec *Spec) validate() error {
+
+	var err error
+
+	spec.timeout, err = parseTimeout(spec.Timeout, defaultTimeout)
+	if err != nil {
+		return fmt.Errorf("timeout: %v", err)
+	}
+
+	if len(spec.Serial) == 0 && len(spec.Probes) == 0 && spec.ExitCode == nil {
+		return errors.New("no checks defined")
+	}
+
+	for i := range spec.Serial {
+
+		l := &spec.Serial[i]
+		if l.Name == "" {
+			l.Name = fmt.Sprintf("serial-%d", i)
+		}
+
+		if l.Pattern == "" {
+			return fmt.Errorf("serial check '%v' has no pattern", l.Name)
+		}
+
+		l.re, err = regexp.Compile(l.Pattern)
+		if err != nil {
+			return fmt.Errorf("serial check '%v': %v", l.Name, err)
+		}
+
+		l.timeout, err = parseTimeout(l.Timeout, spec.timeout.String())
+		if err != nil {
+			return fmt.Errorf("serial check '%v': %v", l.Name, err)
+		}
+
+	}
+
+	for i := range spec.Probes {
+
+		p := &spec.Probes[i]
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("probe-%d", i)
+		}
+
+		if (p.HTTP == "") == (p.TCP == "") {
+			return fmt.Errorf("probe '%v' must define exactly one of 'http' or 'tcp'", p.Name)
+		}
+
+		if p.TCP != "" && (p.Status != 0 || p.Body != "") {
+			return fmt.Errorf("probe '%v': 'status' and 'body' only apply to http probes", p.Name)
+		}
+
+		if p.Body != "" {
+			p.re, err = regexp.Compile(p.Body)
+			if err != nil {
+				return fmt.Errorf("probe '%v': %v", p.Name, err)
+			}
+		}
+
+		p.timeout, err = parseTimeout(p.Timeout, spec.timeout.String())
+		if err != nil {
+			return fmt.Errorf("probe '%v': %v", p.Name, err)
+		}
+
+	}
+
+	return nil
+
+}
@@ -0,0 +1,166 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmdtest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSpec(t *testing.T, data string) string {
+
+	dir, err := ioutil.TempDir("", "vcli-spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "app"+specExtension)
+	err = ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+
+}
+
+func TestLoadSpec(t *testing.T) {
+
+	path := writeSpec(t, `
+name: web
+timeout: 30s
+port-map: "0:8080:80"
+serial:
+  - pattern: "listening on [0-9]+"
+  - name: ready
+    pattern: ready
+    timeout: 5s
+probes:
+  - http: http://localhost:8080/
+    status: 200
+    body: hello
+  - name: port
+    tcp: localhost:8080
+    timeout: 10s
+exit-code: 0
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	spec, err := loadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.Name != "web" || spec.PortMap != "0:8080:80" || spec.timeout.String() != "30s" {
+		t.Errorf("got name %q, port map %q and timeout %v", spec.Name, spec.PortMap, spec.timeout)
+	}
+
+	if spec.ExitCode == nil || *spec.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %v", spec.ExitCode)
+	}
+
+	serial := []struct {
+		name    string
+		timeout string
+	}{
+		{"serial-0", "30s"},
+		{"ready", "5s"},
+	}
+
+	if len(spec.Serial) != len(serial) {
+		t.Fatalf("got %d serial checks, expected %d", len(spec.Serial), len(serial))
+	}
+
+	for i, expect := range serial {
+		l := spec.Serial[i]
+		if l.Name != expect.name || l.timeout.String() != expect.timeout || l.re == nil {
+			t.Errorf("serial check %d: got %q with timeout %v", i, l.Name, l.timeout)
+		}
+	}
+
+	probes := []struct {
+		name    string
+		timeout string
+		body    bool
+	}{
+		{"probe-0", "30s", true},
+		{"port", "10s", false},
+	}
+
+	if len(spec.Probes) != len(probes) {
+		t.Fatalf("got %d probes, expected %d", len(spec.Probes), len(probes))
+	}
+
+	for i, expect := range probes {
+		p := spec.Probes[i]
+		if p.Name != expect.name || p.timeout.String() != expect.timeout || (p.re != nil) != expect.body {
+			t.Errorf("probe %d: got %q with timeout %v", i, p.Name, p.timeout)
+		}
+	}
+
+}
+
+func TestLoadSpecDefaultTimeout(t *testing.T) {
+
+	path := writeSpec(t, "exit-code: 3\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	spec, err := loadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.timeout.String() != "1m0s" || *spec.ExitCode != 3 {
+		t.Errorf("got timeout %v and exit code %d", spec.timeout, *spec.ExitCode)
+	}
+
+}
+
+func TestLoadSpecInvalid(t *testing.T) {
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"yaml", "serial: [", "invalid yaml"},
+		{"empty", "name: app\n", "no checks defined"},
+		{"timeout", "timeout: soon\nexit-code: 0\n", "timeout"},
+		{"negative timeout", "timeout: -1s\nexit-code: 0\n", "timeout must be positive"},
+		{"no pattern", "serial:\n  - name: boot\n", "serial check 'boot' has no pattern"},
+		{"bad pattern", "serial:\n  - pattern: \"(\"\n", "serial check 'serial-0'"},
+		{"serial timeout", "serial:\n  - pattern: x\n    timeout: 0s\n", "timeout must be positive"},
+		{"no target", "probes:\n  - name: web\n", "probe 'web' must define exactly one of 'http' or 'tcp'"},
+		{"two targets", "probes:\n  - http: http://x/\n    tcp: x:80\n", "probe 'probe-0' must define exactly one"},
+		{"tcp status", "probes:\n  - tcp: x:80\n    status: 200\n", "only apply to http probes"},
+		{"tcp body", "probes:\n  - tcp: x:80\n    body: ok\n", "only apply to http probes"},
+		{"bad body", "probes:\n  - http: http://x/\n    body: \"[\"\n", "probe 'probe-0'"},
+	}
+
+	for _, test := range tests {
+
+		path := writeSpec(t, test.data)
+		_, err := loadSpec(path)
+		os.RemoveAll(filepath.Dir(path))
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, expected an error containing %q", test.name, err, test.err)
+		}
+
+	}
+
+}
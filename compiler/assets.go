@@ -0,0 +1,171 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/sisatech/vcli/home"
+)
+
+// ValidateBootAssets makes sure every boot asset needed to build with the
+// kernel is available and carries a valid signature, fetching any that are
+// missing. Assets kept from before they were versioned have no signature and
+// are only used if a release can't be fetched.
+func ValidateBootAssets(kernel string) error {
+
+	dir := home.Path(home.Kernel)
+
+	for _, asset := range home.BootAssets() {
+
+		path := filepath.Join(dir, asset.File(kernel))
+
+		_, err := os.Stat(path)
+		if err == nil {
+			err = VerifySignature(path, path+".asc")
+			if err != nil {
+				return fmt.Errorf("%v for kernel %v failed verification: %v", asset.Name, kernel, err)
+			}
+			continue
+		}
+
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		err = FetchBootAsset(asset, kernel)
+		if err == nil {
+			continue
+		}
+
+		legacy := filepath.Join(dir, asset.LegacyFile())
+		if _, lerr := os.Stat(legacy); lerr == nil {
+			fmt.Printf("WARNING: using unverified %v: %v\n", asset.LegacyFile(), err)
+			continue
+		}
+
+		return err
+
+	}
+
+	return nil
+
+}
+
+// FetchBootAssets downloads every boot asset for the kernel that isn't already
+// available, replacing local copies that fail verification.
+func FetchBootAssets(kernel string) error {
+
+	for _, asset := range home.BootAssets() {
+
+		err := VerifyLocalFile(asset.File(kernel))
+		if err == nil {
+			continue
+		}
+
+		if !os.IsNotExist(err) {
+			fmt.Printf("WARNING: replacing %v: %v\n", asset.File(kernel), err)
+		}
+
+		err = FetchBootAsset(asset, kernel)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// FetchBootAsset downloads the release of the asset published with the kernel
+// version and keeps it in the kernel directory along with its signature once
+// it has been verified. Releases made for other kernels aren't used, since
+// they might not boot it.
+func FetchBootAsset(asset *home.BootAsset, kernel string) error {
+
+	src, err := DefaultSource()
+	if err != nil {
+		return err
+	}
+
+	files, err := src.List(asset.Name)
+	if err != nil {
+		return fmt.Errorf("could not look up %v releases: %v", asset.Name, err)
+	}
+
+	var release *DownloadFile
+	for i, f := range files {
+
+		if f.Version == kernel && !strings.HasSuffix(f.Name, ".asc") {
+			release = &files[i]
+			break
+		}
+
+	}
+
+	if release == nil {
+		return fmt.Errorf("no release of %v found for kernel %v", asset.Name, kernel)
+	}
+
+	return install(src, *release, asset.File(kernel))
+
+}
+
+// VerifySignature checks a file against a detached, armored signature made
+// with the Vorteil release key or another trusted key.
+func VerifySignature(file, signature string) error {
+
+	keyring, err := Keyring()
+	if err != nil {
+		return err
+	}
+
+	sig, err := os.Open(signature)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("signature %v not found", filepath.Base(signature))
+		}
+		return err
+	}
+	defer sig.Close()
+
+	target, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, target, sig)
+
+	return err
+
+}
+
+func copyFile(src, dst string) error {
+
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dst, data, 0644)
+
+}
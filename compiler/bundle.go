@@ -0,0 +1,249 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package compiler
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sisatech/vcli/download"
+	"github.com/sisatech/vcli/home"
+)
+
+// bundlePackages returns the packages a bundle can hold.
+func bundlePackages() []string {
+
+	pkgs := []string{KernelPackage}
+	for _, asset := range home.BootAssets() {
+		pkgs = append(pkgs, asset.Name)
+	}
+
+	return pkgs
+
+}
+
+// bundleFiles returns the local files of each package for a kernel version.
+func bundleFiles(version string) map[string][]string {
+
+	files := map[string][]string{
+		KernelPackage: {"vkernel-PROD-" + version + ".img", "vkernel-DEBUG-" + version + ".img"},
+	}
+
+	for _, asset := range home.BootAssets() {
+		files[asset.Name] = []string{asset.File(version)}
+	}
+
+	return files
+
+}
+
+// ExportBundle writes the kernels with the given versions, the boot assets
+// released with them and all of their signatures to w as a gzipped tarball,
+// laid out as a mirror that OpenSource can read. Every file is verified before
+// it's written, and files without signatures are left out. It returns the
+// names of the files written.
+func ExportBundle(w io.Writer, versions []string) ([]string, error) {
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	dir := home.Path(home.Kernel)
+
+	var written []string
+	index := make(map[string][]DownloadFile)
+
+	for _, version := range versions {
+
+		if !home.ValidLocalKernel(version) {
+			return nil, fmt.Errorf("kernel %v not found locally", version)
+		}
+
+		files := bundleFiles(version)
+		for _, pkg := range bundlePackages() {
+			for _, name := range files[pkg] {
+
+				path := filepath.Join(dir, name)
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					continue
+				}
+
+				err := VerifySignature(path, path+".asc")
+				if err != nil {
+					fmt.Printf("WARNING: leaving out %v: %v\n", name, err)
+					continue
+				}
+
+				for _, file := range []string{name, name + ".asc"} {
+					entry, err := addToBundle(tw, pkg, filepath.Join(dir, file))
+					if err != nil {
+						return nil, err
+					}
+					index[pkg] = append(index[pkg], entry)
+				}
+
+				written = append(written, name)
+
+			}
+		}
+
+	}
+
+	if len(written) == 0 {
+		return nil, errors.New("nothing to export; no signed kernels or boot assets found")
+	}
+
+	for _, pkg := range bundlePackages() {
+
+		if len(index[pkg]) == 0 {
+			continue
+		}
+
+		data, err := json.MarshalIndent(index[pkg], "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeToBundle(tw, pkg+"/"+IndexFile, data)
+		if err != nil {
+			return nil, err
+		}
+
+	}
+
+	err := tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return written, gz.Close()
+
+}
+
+func addToBundle(tw *tar.Writer, pkg, path string) (DownloadFile, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return DownloadFile{}, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return DownloadFile{}, err
+	}
+
+	entry := describe(pkg, info.Name(), info.Size(), info.ModTime())
+
+	hdr := &tar.Header{
+		Name:    entry.Path,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: info.ModTime(),
+	}
+
+	err = tw.WriteHeader(hdr)
+	if err != nil {
+		return entry, err
+	}
+
+	_, err = tw.Write(data)
+
+	return entry, err
+
+}
+
+func writeToBundle(tw *tar.Writer, name string, data []byte) error {
+
+	err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(data)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+
+	return err
+
+}
+
+// ImportBundle installs the kernels and boot assets in a bundle written by
+// ExportBundle. Nothing is installed unless every file in the bundle has a
+// valid signature. It returns the names of the files installed.
+func ImportBundle(path string) ([]string, error) {
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	src := bundleSource(path)
+
+	ctx, cancel := download.OnInterrupt(context.Background())
+	defer cancel()
+
+	tmp, err := ioutil.TempDir("", "vcli-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	var names, staged []string
+	for _, pkg := range bundlePackages() {
+
+		files, err := src.List(pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+
+			if strings.HasSuffix(f.Name, ".asc") {
+				continue
+			}
+
+			file, err := stage(ctx, src, f, f.Name, tmp)
+			if err != nil {
+				return nil, err
+			}
+
+			names = append(names, f.Name)
+			staged = append(staged, file)
+
+		}
+
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no kernels or boot assets found in %v", path)
+	}
+
+	for i, name := range names {
+		err = keep(staged[i], name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return names, nil
+
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+type buffered struct {
+	Convertible
+	cfg []byte
+}
+
+func (b *buffered) Config() io.Reader {
+
+	return bytes.NewReader(b.cfg)
+
+}
+
+// ReadBuildConfig parses the configuration of in. Reading it consumes in's
+// configuration, so the returned Convertible should be exported instead.
+func ReadBuildConfig(in Convertible) (*shared.BuildConfig, Convertible, error) {
+
+	data, err := ioutil.ReadAll(in.Config())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := &buffered{
+		Convertible: in,
+		cfg:         data,
+	}
+
+	cfg := new(shared.BuildConfig)
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, out, err
+	}
+
+	return cfg, out, nil
+
+}
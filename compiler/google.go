@@ -20,7 +20,12 @@ import "errors"
 // The caller should move the file to a non-temporary location.
 func BuildGoogleCloudDisk(binary, config, files, kernel string, debug bool) (string, error) {
 
-	err := FullValidation(binary, config, files, "", kernel)
+	kernel, err := ResolveKernel(config, kernel)
+	if err != nil {
+		return "", err
+	}
+
+	err = FullValidation(binary, config, files, "", kernel)
 	if err != nil {
 		return "", err
 	}
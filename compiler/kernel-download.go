@@ -14,21 +14,15 @@
 package compiler
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
 	"os"
-	"runtime"
+	"path/filepath"
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/openpgp"
-
-	"github.com/cavaliercoder/grab"
-	"github.com/sisatech/vcli/automation"
+	"github.com/sisatech/vcli/download"
 	"github.com/sisatech/vcli/home"
 )
 
@@ -105,184 +99,120 @@ func ListKernels() ([]*ListTuple, error) {
 
 }
 
-// LookupListKernels lists all kernels available on bintray.
+// LookupListKernels lists all kernels available from the artifact source.
 func LookupListKernels() ([]DownloadFile, error) {
 
-	// TODO: cleanup and/or remove this function
-
-	response, err := http.Get(fmt.Sprintf(kernelAPIURL, "vkernel"))
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
+	return LookupListVorteilFiles(KernelPackage)
 
-	jsonList := new(bytes.Buffer)
-	_, err = io.Copy(jsonList, response.Body)
-	if err != nil {
-		return nil, err
-	}
+}
 
-	var dat []DownloadFile
+// LookupListVorteilFiles lists the files in a package of the artifact source.
+func LookupListVorteilFiles(dlType string) ([]DownloadFile, error) {
 
-	if err := json.Unmarshal(jsonList.Bytes(), &dat); err != nil {
+	src, err := DefaultSource()
+	if err != nil {
 		return nil, err
 	}
 
-	return dat, nil
+	return src.List(dlType)
 
 }
 
-// LookupListVorteilFiles lists all kernels available on bintray.
-func LookupListVorteilFiles(dlType string) ([]DownloadFile, error) {
-
-	// TODO: cleanup and/or remove this function
+// DownloadVorteilFile fetches a file from a package of the artifact source and
+// keeps it in the kernel directory along with its signature once verified.
+func DownloadVorteilFile(dlFile, dlType string) error {
 
-	response, err := http.Get(fmt.Sprintf(kernelAPIURL, dlType))
+	src, err := DefaultSource()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer response.Body.Close()
 
-	jsonList := new(bytes.Buffer)
-	_, err = io.Copy(jsonList, response.Body)
+	files, err := src.List(dlType)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var dat []DownloadFile
-
-	if err := json.Unmarshal(jsonList.Bytes(), &dat); err != nil {
-		return nil, err
+	for _, f := range files {
+		if f.Name == dlFile {
+			return install(src, f, dlFile)
+		}
 	}
 
-	return dat, nil
+	return fmt.Errorf("%v not found in package %v", dlFile, dlType)
 
 }
 
-// DownloadVorteilFile ...
-func DownloadVorteilFile(dlFile, dlType string) error {
+// install fetches a file and its signature from the source, and keeps them in
+// the kernel directory under name if the signature is valid. Interrupted
+// downloads are resumed the next time the same file is installed.
+func install(src Source, f DownloadFile, name string) error {
 
-	// TODO: cleanup and/or remove this function
-
-	kernels, err := LookupListVorteilFiles(dlType)
+	ctx, cancel := download.OnInterrupt(context.Background())
+	defer cancel()
 
+	file, err := stage(ctx, src, f, name, home.Path(home.Downloads))
 	if err != nil {
 		return err
 	}
+	defer os.Remove(file)
+	defer os.Remove(file + ".asc")
 
-	for _, k := range kernels {
-
-		// found kernel: download and check signature
-		if k.Name == dlFile {
-
-			urls := make([]string, 2)
-			urls[0] = kernelDownloadURL + k.Path
-			urls[1] = kernelDownloadURL + k.Path + ".asc"
-
-			// delete exisiting files, if downloaded already
-			os.Remove(os.TempDir() + "/" + dlFile)
-			os.Remove(os.TempDir() + "/" + dlFile + ".asc")
-			respch, err := grab.GetBatch(2, os.TempDir(), urls...)
-			if err != nil {
-				return err
-			}
-			t := time.NewTicker(10 * time.Millisecond)
-			completed := 0
-			completedSuccessful := 0
-			inProgress := 0
-			var responses []*grab.Response
-
-			for completed < len(urls) {
-				select {
-				case resp := <-respch:
-					if resp != nil {
-						responses = append(responses, resp)
-					}
-
-				case <-t.C:
-					if runtime.GOOS == "linux" {
-						if inProgress > 0 {
-							fmt.Printf("\033[%dA\033[K", inProgress)
-						}
-					}
-
-					for i, resp := range responses {
-						if resp != nil && resp.IsComplete() {
-							if resp.Error != nil {
-								fmt.Fprintf(os.Stderr, "Error downloading %s: %v\n", resp.Request.URL(), resp.Error)
-							} else {
-								completedSuccessful++
-								fmt.Printf("Finished %s %d / %d bytes (%d%%)\n", resp.Filename, resp.BytesTransferred(), resp.Size, int(100*resp.Progress()))
-							}
-
-							responses[i] = nil
-							completed++
-						}
-					}
-
-					inProgress = 0
-					for _, resp := range responses {
-						if resp != nil {
-							inProgress++
-							if runtime.GOOS == "linux" {
-								fmt.Printf("Downloading %s %d / %d bytes (%d%%)\033[K\n", resp.Filename, resp.BytesTransferred(), resp.Size, int(100*resp.Progress()))
-							}
-						}
-					}
-				}
-			}
-
-			t.Stop()
-
-			if completedSuccessful < 2 {
-				return fmt.Errorf("Could not download file %s", dlFile)
-			}
-
-			vorteilHome := strings.TrimSuffix(os.Getenv("VORTEIL_HOME"), "/")
-
-			krrData, err := build.Asset("vorteil.gpg")
-			if err != nil {
-				return err
-			}
-
-			krr := bytes.NewReader(krrData)
-
-			sig, err := os.Open(os.TempDir() + "/" + dlFile + ".asc")
-			defer sig.Close()
-			if err != nil {
-				return err
-			}
-			target, err := os.Open(os.TempDir() + "/" + dlFile)
-			defer target.Close()
-			if err != nil {
-				return err
-			}
-			kr, err := openpgp.ReadArmoredKeyRing(krr)
-			if err != nil {
-				return err
-			}
-
-			fmt.Print("Verifying signature")
-			_, err = openpgp.CheckArmoredDetachedSignature(kr, target, sig)
-			if err != nil {
-				return err
-			}
-			fmt.Print(": Ok\n")
-
-			// copy to kernel folder. Reading full file. Its max 2MB
-			data, err := ioutil.ReadFile(os.TempDir() + "/" + dlFile)
-			if err != nil {
-				return err
-			}
-
-			err = ioutil.WriteFile(vorteilHome+"/kernel/"+dlFile, data, 0644)
-			if err != nil {
-				return err
-			}
+	return keep(file, name)
 
-		}
+}
 
+// stage fetches a file and its signature from the source into dir, checks the
+// file against the size and checksum the source lists for it, and verifies
+// the signature, returning the path of the file.
+func stage(ctx context.Context, src Source, f DownloadFile, name, dir string) (string, error) {
+
+	file := filepath.Join(dir, name)
+
+	m := &download.Manager{Progress: download.NewProgress(os.Stdout)}
+	err := m.Run(ctx, &download.Job{
+		Name:    name,
+		Fetcher: fetcher(src, f.Path),
+		Dest:    file,
+		Size:    int64(f.Size),
+		SHA256:  f.SHA256,
+	}, &download.Job{
+		Name:    name + ".asc",
+		Fetcher: fetcher(src, f.Path+".asc"),
+		Dest:    file + ".asc",
+	})
+	if ctx.Err() != nil {
+		return "", errors.New("download interrupted; run the command again to resume it")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Print("Verifying signature")
+	err = VerifySignature(file, file+".asc")
+	if err != nil {
+		fmt.Print(": Failed\n")
+		os.Remove(file)
+		os.Remove(file + ".asc")
+		return "", fmt.Errorf("%v failed verification: %v", name, err)
+	}
+	fmt.Print(": Ok\n")
+
+	return file, nil
+
+}
+
+// keep copies a staged file and its signature into the kernel directory. The
+// signature goes first so the file is never kept without it.
+func keep(file, name string) error {
+
+	dst := home.Path(home.Kernel + "/" + name)
+	for _, suffix := range []string{".asc", ""} {
+		err := copyFile(file+suffix, dst+suffix)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
+
 }
@@ -0,0 +1,394 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/semver"
+	"github.com/sisatech/vcli/shared"
+)
+
+// KernelFeature is a part of the image header that not every kernel reads,
+// along with a test for whether an app's config uses it.
+//
+// No kernel release version is tied to a feature. The header extensions were
+// added to vcli ahead of the kernels that read them, so a kernel that reads
+// one says so by embedding its Tag in the kernel image, and support is
+// detected from the local image rather than from its version.
+type KernelFeature struct {
+	Name string
+	Tag  string
+	Used func(cfg *shared.BuildConfig) bool
+}
+
+// KernelFeatures lists the image header features added since the original
+// layout. An app using any of them can't be built with a kernel whose image
+// lacks the feature's tag.
+var KernelFeatures = []KernelFeature{
+	{
+		Name: "vm section",
+		Tag:  "vorteil-header:vm",
+		Used: func(cfg *shared.BuildConfig) bool {
+			return cfg.VM != nil && *cfg.VM != shared.VMConfig{}
+		},
+	},
+}
+
+// KernelCompatible returns an error explaining why an app can't be built with
+// a kernel version, or nil if it can.
+func KernelCompatible(cfg *shared.BuildConfig, version string) error {
+
+	_, err := semver.Parse(version)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Kernel != "" {
+
+		c, err := semver.ParseConstraint(cfg.Kernel)
+		if err != nil {
+			return err
+		}
+
+		if !c.Check(version) {
+			return fmt.Errorf("kernel %v doesn't satisfy the app's kernel constraint '%v'", version, c)
+		}
+
+	}
+
+	var image []byte
+	for _, f := range KernelFeatures {
+
+		if !f.Used(cfg) {
+			continue
+		}
+
+		if image == nil {
+			image, err = ioutil.ReadFile(home.Path(home.Kernel + "/vkernel-PROD-" + version + ".img"))
+			if os.IsNotExist(err) {
+				return fmt.Errorf("kernel %v is not installed; try 'vcli settings kernel download %v'", version, version)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if !bytes.Contains(image, []byte(f.Tag)) {
+			return fmt.Errorf("kernel %v doesn't support the %v the app uses", version, f.Name)
+		}
+
+	}
+
+	return nil
+
+}
+
+// CompatibleKernels returns the local kernels an app can be built with,
+// newest first.
+func CompatibleKernels(cfg *shared.BuildConfig) []string {
+
+	var versions semver.Versions
+	for _, x := range home.ListLocalKernels() {
+		v, err := semver.Parse(x)
+		if err == nil && KernelCompatible(cfg, x) == nil {
+			versions = append(versions, v)
+		}
+	}
+
+	sort.Sort(sort.Reverse(versions))
+
+	var list []string
+	for _, v := range versions {
+		list = append(list, v.String())
+	}
+
+	return list
+
+}
+
+// ResolveKernel picks the kernel to build an app with. A kernel the app can't
+// use is an error, unless it's the default kernel, in which case the newest
+// local kernel the app can use is picked instead.
+func ResolveKernel(config, kernel string) (string, error) {
+
+	cfg, err := readVCFG(config)
+	if err != nil {
+		// left for FullValidation to report
+		return kernel, nil
+	}
+
+	err = KernelCompatible(cfg, kernel)
+	if err == nil || kernel != home.GlobalDefaults.Kernel {
+		return kernel, err
+	}
+
+	alt := AppKernel(cfg)
+	if alt == "" {
+		return kernel, fmt.Errorf("%v, and no local kernel is compatible; try 'vcli settings kernel download'", err)
+	}
+
+	fmt.Printf("Default kernel %v can't build this app; using kernel %v\n", kernel, alt)
+
+	return alt, nil
+
+}
+
+// ValidateKernel checks an app can be built with a kernel version.
+func ValidateKernel(config, kernel string) error {
+
+	cfg, err := readVCFG(config)
+	if err != nil {
+		return err
+	}
+
+	return KernelCompatible(cfg, kernel)
+
+}
+
+func readVCFG(config string) (*shared.BuildConfig, error) {
+
+	data, err := ioutil.ReadFile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(shared.BuildConfig)
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+
+}
+
+// VerifyLocalFile checks a file in the kernel directory against the signature
+// kept beside it.
+func VerifyLocalFile(name string) error {
+
+	path := home.Path(home.Kernel + "/" + name)
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return VerifySignature(path, path+".asc")
+
+}
+
+// KernelFiles returns the names of the local files belonging to a kernel
+// version: its production and debug kernels and the boot assets released
+// with it.
+func KernelFiles(version string) []string {
+
+	var names []string
+
+	files := bundleFiles(version)
+	for _, pkg := range bundlePackages() {
+		for _, name := range files[pkg] {
+			_, err := os.Stat(home.Path(home.Kernel + "/" + name))
+			if err == nil {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+
+}
+
+// AllowUnsignedKernel lets builds use a kernel that has no signature, such as
+// one added with AddKernel. A kernel whose signature doesn't match is still
+// refused.
+var AllowUnsignedKernel bool
+
+// VerifyKernel checks the local production kernel of a version, and its debug
+// kernel if there is one, against their signatures.
+func VerifyKernel(version string) error {
+
+	for _, name := range []string{"vkernel-PROD-" + version + ".img", "vkernel-DEBUG-" + version + ".img"} {
+
+		// the image is checked on its own first, as a missing signature
+		// looks the same to VerifyLocalFile
+		_, err := os.Stat(home.Path(home.Kernel + "/" + name))
+		if os.IsNotExist(err) && strings.Contains(name, "DEBUG") {
+			continue
+		}
+
+		if os.IsNotExist(err) {
+			return fmt.Errorf("kernel %v is not installed; try 'vcli settings kernel download %v'", version, version)
+		}
+
+		err = VerifyLocalFile(name)
+		if err != nil && AllowUnsignedKernel && !signed(name) {
+			fmt.Printf("WARNING: using unsigned kernel %v\n", name)
+			continue
+		}
+
+		if err != nil && !signed(name) {
+			return fmt.Errorf("kernel %v is unsigned; build with --allow-unsigned-kernel to use it anyway", version)
+		}
+
+		if err != nil {
+			return fmt.Errorf("kernel %v failed verification: %v; try 'vcli settings kernel download %v' to replace it", version, err, version)
+		}
+
+	}
+
+	return nil
+
+}
+
+// signed returns true if a file in the kernel directory has a signature kept
+// beside it.
+func signed(name string) bool {
+
+	_, err := os.Stat(home.Path(home.Kernel + "/" + name + ".asc"))
+	return err == nil
+
+}
+
+// AddKernel copies a kernel image built outside of the official releases into
+// the kernel directory as the production kernel of a version, or its debug
+// kernel. If a signature is given it must be valid and is kept with the
+// kernel; otherwise builds only use the kernel when AllowUnsignedKernel is set.
+func AddKernel(image, version, signature string, debug, force bool) (string, error) {
+
+	v, err := semver.Parse(version)
+	if err != nil {
+		return "", err
+	}
+	version = v.String()
+
+	info, err := os.Stat(image)
+	if err != nil {
+		return "", err
+	}
+
+	if info.IsDir() {
+		return "", fmt.Errorf("%v is a directory, not a kernel image", image)
+	}
+
+	name := "vkernel-PROD-" + version + ".img"
+	if debug {
+		if !home.ValidLocalKernel(version) {
+			return "", fmt.Errorf("no production kernel %v to add a debug kernel to", version)
+		}
+		name = "vkernel-DEBUG-" + version + ".img"
+	}
+
+	dst := home.Path(home.Kernel + "/" + name)
+	if _, err = os.Stat(dst); err == nil && !force {
+		return "", fmt.Errorf("%v already exists; use --force to replace it", name)
+	}
+
+	if signature != "" {
+		err = VerifySignature(image, signature)
+		if err != nil {
+			return "", fmt.Errorf("%v failed verification: %v", image, err)
+		}
+		err = copyFile(signature, dst+".asc")
+	} else {
+		err = os.Remove(dst + ".asc")
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return name, copyFile(image, dst)
+
+}
+
+// FetchKernel downloads the production and debug kernels of a version and the
+// boot assets released with it, replacing local copies that fail verification.
+func FetchKernel(version string) error {
+
+	err := FetchBootAssets(version)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"vkernel-PROD-" + version + ".img", "vkernel-DEBUG-" + version + ".img"} {
+
+		err = VerifyLocalFile(name)
+		if err == nil {
+			continue
+		}
+
+		if !os.IsNotExist(err) {
+			fmt.Printf("WARNING: replacing %v: %v\n", name, err)
+		}
+
+		err = DownloadVorteilFile(name, KernelPackage)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// AppKernel returns the local kernel an app is built with when no kernel is
+// given, or an empty string if no local kernel can build it.
+func AppKernel(cfg *shared.BuildConfig) string {
+
+	if home.GlobalDefaults.Kernel != "" && KernelCompatible(cfg, home.GlobalDefaults.Kernel) == nil {
+		return home.GlobalDefaults.Kernel
+	}
+
+	list := CompatibleKernels(cfg)
+	if len(list) == 0 {
+		return ""
+	}
+
+	return list[0]
+
+}
+
+// RemoveKernel deletes the local files of a kernel version and their
+// signatures, returning the names of the files removed.
+func RemoveKernel(version string) ([]string, error) {
+
+	var removed []string
+	for _, name := range KernelFiles(version) {
+
+		path := home.Path(home.Kernel + "/" + name)
+		for _, file := range []string{path, path + ".asc"} {
+			err := os.Remove(file)
+			if err != nil && !os.IsNotExist(err) {
+				return removed, err
+			}
+		}
+
+		removed = append(removed, name)
+
+	}
+
+	return removed, nil
+
+}
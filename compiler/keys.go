@@ -0,0 +1,244 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package compiler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	"github.com/sisatech/vcli/automation"
+	"github.com/sisatech/vcli/home"
+)
+
+// TrustedKey describes a key that kernels and boot assets are verified
+// against.
+type TrustedKey struct {
+	Fingerprint string
+	Identities  []string
+	BuiltIn     bool
+}
+
+func describeKey(e *openpgp.Entity, builtIn bool) TrustedKey {
+
+	key := TrustedKey{
+		Fingerprint: fmt.Sprintf("%X", e.PrimaryKey.Fingerprint),
+		BuiltIn:     builtIn,
+	}
+
+	for name := range e.Identities {
+		key.Identities = append(key.Identities, name)
+	}
+	sort.Strings(key.Identities)
+
+	return key
+
+}
+
+// readKeys parses keys in either armored or binary form.
+func readKeys(data []byte) (openpgp.EntityList, error) {
+
+	keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err == nil {
+		return keys, nil
+	}
+
+	keys, berr := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if berr != nil {
+		return nil, err
+	}
+
+	return keys, nil
+
+}
+
+func builtInKeys() (openpgp.EntityList, error) {
+
+	data, err := build.Asset("vorteil.gpg")
+	if err != nil {
+		return nil, err
+	}
+
+	return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+
+}
+
+func addedKeys() (openpgp.EntityList, error) {
+
+	var keys openpgp.EntityList
+
+	files, err := filepath.Glob(filepath.Join(home.Path(home.Keys), "*.asc"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		list, err := readKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", filepath.Base(file), err)
+		}
+
+		keys = append(keys, list...)
+
+	}
+
+	return keys, nil
+
+}
+
+// Keyring returns the keys kernels and boot assets are verified against: the
+// Sisa-Tech key built into vcli and any added with AddKeys.
+func Keyring() (openpgp.EntityList, error) {
+
+	keys, err := builtInKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := addedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(keys, added...), nil
+
+}
+
+// TrustedKeys describes every key in the keyring.
+func TrustedKeys() ([]TrustedKey, error) {
+
+	var list []TrustedKey
+
+	keys, err := builtInKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range keys {
+		list = append(list, describeKey(e, true))
+	}
+
+	keys, err = addedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range keys {
+		list = append(list, describeKey(e, false))
+	}
+
+	return list, nil
+
+}
+
+// AddKeys trusts the public keys in a file, which may be armored or binary.
+func AddKeys(path string) ([]TrustedKey, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := readKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("no OpenPGP keys found in %v: %v", path, err)
+	}
+
+	var list []TrustedKey
+	for _, e := range keys {
+
+		key := describeKey(e, false)
+
+		buf := new(bytes.Buffer)
+		w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return list, err
+		}
+
+		err = e.Serialize(w)
+		if err != nil {
+			return list, err
+		}
+
+		err = w.Close()
+		if err != nil {
+			return list, err
+		}
+
+		err = ioutil.WriteFile(keyFile(key.Fingerprint), buf.Bytes(), 0644)
+		if err != nil {
+			return list, err
+		}
+
+		list = append(list, key)
+
+	}
+
+	return list, nil
+
+}
+
+func keyFile(fingerprint string) string {
+
+	return filepath.Join(home.Path(home.Keys), fingerprint+".asc")
+
+}
+
+// RemoveKey stops trusting a key added with AddKeys. The key may be given by
+// its fingerprint or a key ID.
+func RemoveKey(id string) (TrustedKey, error) {
+
+	id = strings.ToUpper(strings.Replace(strings.TrimPrefix(id, "0x"), " ", "", -1))
+	if id == "" {
+		return TrustedKey{}, errors.New("no key given")
+	}
+
+	keys, err := TrustedKeys()
+	if err != nil {
+		return TrustedKey{}, err
+	}
+
+	var matches []TrustedKey
+	for _, key := range keys {
+		if strings.HasSuffix(key.Fingerprint, id) {
+			matches = append(matches, key)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return TrustedKey{}, fmt.Errorf("no trusted key matches %v", id)
+	case len(matches) > 1:
+		return TrustedKey{}, fmt.Errorf("%v matches more than one key; give the full fingerprint", id)
+	case matches[0].BuiltIn:
+		return TrustedKey{}, fmt.Errorf("key %v is built into vcli and can't be removed", matches[0].Fingerprint)
+	}
+
+	return matches[0], os.Remove(keyFile(matches[0].Fingerprint))
+
+}
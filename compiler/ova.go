@@ -28,7 +28,12 @@ import (
 // folder. The caller should move the file to a non-temporary location.
 func BuildOVA(binary, config, files, kernel, destination string, debug bool) (*os.File, error) {
 
-	err := FullValidation(binary, config, files, "", kernel)
+	kernel, err := ResolveKernel(config, kernel)
+	if err != nil {
+		return nil, err
+	}
+
+	err = FullValidation(binary, config, files, "", kernel)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +52,13 @@ func BuildOVA(binary, config, files, kernel, destination string, debug bool) (*o
 
 	// TODO: implement
 
+	if cfg.VM != nil {
+		err = cfg.VM.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// build stream optimized vmdk
 	vmdk, err := BuildStreamOptimizedVMDK(binary, config, files, kernel, "", debug)
 	if err != nil {
@@ -65,7 +77,7 @@ func BuildOVA(binary, config, files, kernel, destination string, debug bool) (*o
 	}
 
 	// build ovf file
-	ovf, err := generateOVF(vmdk.Name(), strconv.FormatInt(int64(cfg.Disk.DiskSize), 10))
+	ovf, err := generateOVF(vmdk.Name(), strconv.FormatInt(int64(cfg.Disk.DiskSize), 10), cfg.VM)
 	if err != nil {
 		return nil, err
 	}
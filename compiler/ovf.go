@@ -18,8 +18,10 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/sisatech/sherlock"
+	"github.com/sisatech/vcli/shared"
 )
 
 // TODO: proper xml generation, rather than this hack
@@ -62,26 +64,26 @@ const (
   <Item>
     <rasd:AllocationUnits>hertz * 10^6</rasd:AllocationUnits>
     <rasd:Description>Number of Virtual CPUs</rasd:Description>
-    <rasd:ElementName>2 virtual CPU(s)</rasd:ElementName>
+    <rasd:ElementName>{{cpus}} virtual CPU(s)</rasd:ElementName>
     <rasd:InstanceID>1</rasd:InstanceID>
     <rasd:ResourceType>3</rasd:ResourceType>
-    <rasd:VirtualQuantity>2</rasd:VirtualQuantity>
+    <rasd:VirtualQuantity>{{cpus}}</rasd:VirtualQuantity>
   </Item>
   <Item>
     <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
     <rasd:Description>Memory Size</rasd:Description>
-    <rasd:ElementName>384MB of memory</rasd:ElementName>
+    <rasd:ElementName>{{memory}}MB of memory</rasd:ElementName>
     <rasd:InstanceID>2</rasd:InstanceID>
     <rasd:ResourceType>4</rasd:ResourceType>
-    <rasd:VirtualQuantity>384</rasd:VirtualQuantity>
+    <rasd:VirtualQuantity>{{memory}}</rasd:VirtualQuantity>
   </Item>
   <Item>
     <rasd:Address>0</rasd:Address>
-    <rasd:Description>SATA Controller</rasd:Description>
-    <rasd:ElementName>SATA controller 0</rasd:ElementName>
+    <rasd:Description>{{controller}} Controller</rasd:Description>
+    <rasd:ElementName>{{controller}} controller 0</rasd:ElementName>
     <rasd:InstanceID>3</rasd:InstanceID>
-    <rasd:ResourceSubType>vmware.sata.ahci</rasd:ResourceSubType>
-    <rasd:ResourceType>20</rasd:ResourceType>
+    <rasd:ResourceSubType>{{controllerType}}</rasd:ResourceSubType>
+    <rasd:ResourceType>{{controllerResource}}</rasd:ResourceType>
     <vmw:Config ovf:required="false" vmw:key="slotInfo.pciSlotNumber" vmw:value="34"/>
   </Item>
   <Item ovf:required="false">
@@ -123,10 +125,10 @@ const (
     <rasd:AddressOnParent>7</rasd:AddressOnParent>
     <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
     <rasd:Connection>VM Network</rasd:Connection>
-    <rasd:Description>E1000 ethernet adapter on &quot;VM Network&quot;</rasd:Description>
+    <rasd:Description>{{nic}} ethernet adapter on &quot;VM Network&quot;</rasd:Description>
     <rasd:ElementName>Network adapter 1</rasd:ElementName>
     <rasd:InstanceID>11</rasd:InstanceID>
-    <rasd:ResourceSubType>E1000</rasd:ResourceSubType>
+    <rasd:ResourceSubType>{{nic}}</rasd:ResourceSubType>
     <rasd:ResourceType>10</rasd:ResourceType>
     <vmw:Config ovf:required="false" vmw:key="slotInfo.pciSlotNumber" vmw:value="32"/>
     <vmw:Config ovf:required="false" vmw:key="wakeOnLanEnabled" vmw:value="true"/>
@@ -157,7 +159,12 @@ const (
 // folder. The caller should move the file to a non-temporary location.
 func BuildOVF(binary, config, files, kernel string, debug bool) (string, error) {
 
-	err := FullValidation(binary, config, files, "", kernel)
+	kernel, err := ResolveKernel(config, kernel)
+	if err != nil {
+		return "", err
+	}
+
+	err = FullValidation(binary, config, files, "", kernel)
 	if err != nil {
 		return "", err
 	}
@@ -168,7 +175,41 @@ func BuildOVF(binary, config, files, kernel string, debug bool) (string, error)
 
 }
 
-func generateOVF(path, diskSize string) (*os.File, error) {
+// ovfHardware returns the replacements for the virtual hardware placeholders
+// in the OVF template, taken from the app's vm section.
+func ovfHardware(vm *shared.VMConfig) *strings.Replacer {
+
+	cpus, memory := "2", "384"
+	controller, controllerType, controllerResource := "SATA", "vmware.sata.ahci", "20"
+	nic := "E1000"
+
+	if vm != nil {
+
+		if vm.VCPUs != 0 {
+			cpus = strconv.Itoa(int(vm.VCPUs))
+		}
+
+		if vm.Memory != 0 {
+			memory = strconv.Itoa(int(vm.Memory))
+		}
+
+		if vm.DiskBus == shared.DiskBusSCSI {
+			controller, controllerType, controllerResource = "SCSI", "VirtualSCSI", "6"
+		}
+
+		if vm.NICModel == shared.NICModelVirtio {
+			nic = "VmxNet3"
+		}
+
+	}
+
+	return strings.NewReplacer("{{cpus}}", cpus, "{{memory}}", memory,
+		"{{controller}}", controller, "{{controllerType}}", controllerType,
+		"{{controllerResource}}", controllerResource, "{{nic}}", nic)
+
+}
+
+func generateOVF(path, diskSize string, vm *shared.VMConfig) (*os.File, error) {
 	var err error
 	var ovf string
 	var f *os.File
@@ -185,7 +226,7 @@ func generateOVF(path, diskSize string) (*os.File, error) {
 		info, err = os.Stat(path)
 		sherlock.Check(err)
 		size = strconv.FormatInt(info.Size(), 10)
-		ovf = ovfA + name + ovfB + size + ovfC + diskSize + ovfD + expandedSize + ovfE
+		ovf = ovfA + name + ovfB + size + ovfC + diskSize + ovfD + expandedSize + ovfHardware(vm).Replace(ovfE)
 		f, err = ioutil.TempFile("", "")
 		sherlock.Check(err)
 		f.Close()
@@ -29,7 +29,12 @@ import (
 // temporary folder. The caller should move the file to a non-temporary location.
 func BuildRawSparse(binary, config, files, kernel, destination string, debug bool) (string, error) {
 
-	err := FullValidation(binary, config, files, "", kernel)
+	kernel, err := ResolveKernel(config, kernel)
+	if err != nil {
+		return "", err
+	}
+
+	err = FullValidation(binary, config, files, "", kernel)
 	if err != nil {
 		return "", err
 	}
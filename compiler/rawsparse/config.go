@@ -44,6 +44,21 @@ type Redirect struct {
 	Protocol [64]byte
 }
 
+// ImageHeaderVM holds the vm section of the app's configuration, so
+// hypervisors launching the disk can read what it expects of them.
+type ImageHeaderVM struct {
+	Memory     uint32
+	CPUs       uint32
+	NICModel   [16]byte
+	DiskBus    [16]byte
+	KernelArgs [256]byte
+}
+
+// AppLoadAddress is the address position independent apps are loaded at. It
+// is recorded in the image header so that debuggers can find the app's
+// symbols. Statically linked apps are loaded at their linked addresses.
+const AppLoadAddress = 0x400000
+
 // ImageHeader ...
 // we write partition length and start into config later we need to adjust the
 // offset in writeConfig, if we add stuff before those entries
@@ -65,6 +80,8 @@ type ImageHeader struct {
 	tsHost             [64]byte
 	tsServers          [4][64]byte
 	fileRedirects      [4]Redirect
+	VM                 ImageHeaderVM
+	LoadAddress        uint64
 }
 
 func (build *builder) writeConfig() error {
@@ -151,6 +168,23 @@ func (build *builder) writeConfig() error {
 		cards[i] = *card
 	}
 
+	// virtual machine
+	var vm ImageHeaderVM
+	if build.config.VM != nil {
+
+		err := build.config.VM.Validate()
+		if err != nil {
+			return err
+		}
+
+		vm.Memory = build.config.VM.Memory
+		vm.CPUs = uint32(build.config.VM.VCPUs)
+		convertStringToBytes(build.config.VM.NICModel, vm.NICModel[:])
+		convertStringToBytes(build.config.VM.DiskBus, vm.DiskBus[:])
+		convertStringToBytes(build.config.VM.KernelArgs, vm.KernelArgs[:])
+
+	}
+
 	// image header
 	ih := &ImageHeader{
 		lbaKernelStart:     uint32(build.content.kernel.first),
@@ -168,6 +202,8 @@ func (build *builder) writeConfig() error {
 		tsHost:             ntpHost,
 		tsServers:          ntpServers,
 		fileRedirects:      fileRedirects,
+		VM:                 vm,
+		LoadAddress:        AppLoadAddress,
 	}
 
 	convertStringToBytes(build.config.Name, ih.Name[:])
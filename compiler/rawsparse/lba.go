@@ -83,30 +83,14 @@ func (build *builder) calculateLBAs() error {
 		build.content.kernel.length - 1
 
 	// trampoline
-	path = fmt.Sprintf("%s/%s", build.env.path, "vtramp.img")
+	path, err = build.bootAsset("vtramp")
+	if err != nil {
+		return err
+	}
 
 	info, err = os.Stat(path)
 	if err != nil {
-
-		if os.IsNotExist(err) {
-
-			// TODO
-			// try to download trampoline
-			// build.Log("%s not found locally. Trying to download.", kernel)
-			// err := DownloadVorteilFile("vtramp.img", "vtramp")
-			if err != nil {
-				return err
-			}
-
-			info, err = os.Stat(path)
-			if err != nil {
-				return err
-			}
-
-		} else {
-			return err
-		}
-
+		return err
 	}
 
 	build.content.trampoline.first = build.content.kernel.last + 1
@@ -0,0 +1,337 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package compiler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sisatech/vcli/download"
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/s3"
+)
+
+// IndexFile lists the files of a package on an HTTP mirror, in the same form
+// as the official repository's API.
+const IndexFile = "index.json"
+
+// KernelPackage is the package kernels are published in. Boot assets are
+// published in packages named after them.
+const KernelPackage = "vkernel"
+
+// Source is somewhere kernels and boot assets are fetched from. Files are
+// grouped into packages, and kept under PACKAGE/FILE along with their
+// detached signatures in PACKAGE/FILE.asc.
+type Source interface {
+	List(pkg string) ([]DownloadFile, error)
+	Open(path string) (io.ReadCloser, error)
+}
+
+// OpenSource returns the source at location, which is an HTTP(S) mirror, an
+// s3://BUCKET/PREFIX URL, a local directory or a bundle written by
+// ExportBundle. The official repository is used if location is empty.
+func OpenSource(location string) (Source, error) {
+
+	switch {
+	case location == "":
+		return bintraySource{}, nil
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		return httpSource(strings.TrimSuffix(location, "/")), nil
+	case strings.HasPrefix(location, "s3://"):
+		client, prefix, err := s3.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		return &s3Source{client: client, prefix: prefix}, nil
+	}
+
+	location = strings.TrimPrefix(location, "file://")
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("artifact source: %v", err)
+	}
+
+	if info.IsDir() {
+		return dirSource(location), nil
+	}
+
+	return bundleSource(location), nil
+
+}
+
+// DefaultSource returns the source configured with 'vcli settings mirror'.
+func DefaultSource() (Source, error) {
+
+	return OpenSource(home.GlobalDefaults.Mirror)
+
+}
+
+var fileVersion = regexp.MustCompile(`-(\d+\.\d+\.\d+)\.img(\.asc)?$`)
+
+// describe returns the listing entry for a file found in a package.
+func describe(pkg, name string, size int64, modified time.Time) DownloadFile {
+
+	f := DownloadFile{
+		Name:    name,
+		Path:    pkg + "/" + name,
+		Package: pkg,
+		Created: modified,
+		Size:    int(size),
+	}
+
+	if m := fileVersion.FindStringSubmatch(name); m != nil {
+		f.Version = m[1]
+	}
+
+	return f
+
+}
+
+func httpGet(url string) (io.ReadCloser, error) {
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error downloading %v: %v", url, resp.Status)
+	}
+
+	return resp.Body, nil
+
+}
+
+func decodeIndex(r io.ReadCloser, err error) ([]DownloadFile, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var files []DownloadFile
+	err = json.NewDecoder(r).Decode(&files)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package index: %v", err)
+	}
+
+	return files, nil
+
+}
+
+// bintraySource is the official repository.
+type bintraySource struct{}
+
+func (bintraySource) List(pkg string) ([]DownloadFile, error) {
+
+	return decodeIndex(httpGet(fmt.Sprintf(kernelAPIURL, pkg)))
+
+}
+
+func (bintraySource) Open(path string) (io.ReadCloser, error) {
+
+	return httpGet(kernelDownloadURL + path)
+
+}
+
+// httpSource is a mirror served over HTTP, with an index for each package.
+type httpSource string
+
+func (base httpSource) List(pkg string) ([]DownloadFile, error) {
+
+	return decodeIndex(httpGet(string(base) + "/" + pkg + "/" + IndexFile))
+
+}
+
+func (base httpSource) Open(path string) (io.ReadCloser, error) {
+
+	return httpGet(string(base) + "/" + path)
+
+}
+
+// dirSource is a mirror on the local filesystem, such as an extracted bundle.
+type dirSource string
+
+func (dir dirSource) List(pkg string) ([]DownloadFile, error) {
+
+	infos, err := ioutil.ReadDir(filepath.Join(string(dir), pkg))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []DownloadFile
+	for _, info := range infos {
+		if info.IsDir() || info.Name() == IndexFile {
+			continue
+		}
+		files = append(files, describe(pkg, info.Name(), info.Size(), info.ModTime()))
+	}
+
+	return files, nil
+
+}
+
+func (dir dirSource) Open(path string) (io.ReadCloser, error) {
+
+	return os.Open(filepath.Join(string(dir), filepath.FromSlash(path)))
+
+}
+
+// bundleSource is a gzipped tarball written by ExportBundle.
+type bundleSource string
+
+// walk calls fn for every file in the bundle until it returns false.
+func (bundle bundleSource) walk(fn func(hdr *tar.Header, r io.Reader) bool) error {
+
+	f, err := os.Open(string(bundle))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%v is not a bundle: %v", bundle, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v is corrupt: %v", bundle, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		if !fn(hdr, tr) {
+			return nil
+		}
+
+	}
+
+}
+
+func (bundle bundleSource) List(pkg string) ([]DownloadFile, error) {
+
+	var files []DownloadFile
+	err := bundle.walk(func(hdr *tar.Header, r io.Reader) bool {
+		dir, name := path.Split(hdr.Name)
+		if dir == pkg+"/" && name != IndexFile {
+			files = append(files, describe(pkg, name, hdr.Size, hdr.ModTime))
+		}
+		return true
+	})
+
+	return files, err
+
+}
+
+func (bundle bundleSource) Open(name string) (io.ReadCloser, error) {
+
+	var data []byte
+	var found bool
+
+	err := bundle.walk(func(hdr *tar.Header, r io.Reader) bool {
+		if hdr.Name != name {
+			return true
+		}
+		var err error
+		data, err = ioutil.ReadAll(r)
+		found = err == nil
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%v not found in %v", name, bundle)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+
+}
+
+// s3Source is a mirror in an S3-compatible bucket.
+type s3Source struct {
+	client *s3.Client
+	prefix string
+}
+
+func (src *s3Source) List(pkg string) ([]DownloadFile, error) {
+
+	objects, err := src.client.List(src.prefix + pkg + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []DownloadFile
+	for _, o := range objects {
+		name := path.Base(o.Key)
+		if name == IndexFile {
+			continue
+		}
+		files = append(files, describe(pkg, name, o.Size, o.Modified))
+	}
+
+	return files, nil
+
+}
+
+func (src *s3Source) Open(path string) (io.ReadCloser, error) {
+
+	return src.client.Get(src.prefix + path)
+
+}
+
+// fetcher returns a download.Fetcher for a file in the source, which resumes
+// partial downloads if the source is served over HTTP.
+func fetcher(src Source, path string) download.Fetcher {
+
+	switch s := src.(type) {
+	case bintraySource:
+		return &download.HTTP{URL: kernelDownloadURL + path}
+	case httpSource:
+		return &download.HTTP{URL: string(s) + "/" + path}
+	}
+
+	return download.Opener(func() (io.ReadCloser, error) {
+		return src.Open(path)
+	})
+
+}
@@ -21,8 +21,8 @@ import (
 	"image/png"
 	"io/ioutil"
 	"os"
-	"strings"
 
+	"github.com/sisatech/vcli/semver"
 	"github.com/sisatech/vcli/shared"
 )
 
@@ -33,7 +33,7 @@ func FullValidation(binary, config, files, icon, kernel string) error {
 		return errors.New("must specify a kernel")
 	}
 
-	if s := strings.Split(kernel, "."); len(s) != 3 {
+	if _, err := semver.Parse(kernel); err != nil {
 		return errors.New("invalid kernel argument")
 	}
 
@@ -42,6 +42,21 @@ func FullValidation(binary, config, files, icon, kernel string) error {
 		return err
 	}
 
+	err = ValidateKernel(config, kernel)
+	if err != nil {
+		return err
+	}
+
+	err = VerifyKernel(kernel)
+	if err != nil {
+		return err
+	}
+
+	err = ValidateBootAssets(kernel)
+	if err != nil {
+		return err
+	}
+
 	if files != "" {
 
 		// err = ValidateFiles(files, config)
@@ -119,6 +134,13 @@ func ValidateVCFG(target string) error {
 		return errors.New("disk size set to 0 in config file")
 	}
 
+	if vcfg.Kernel != "" {
+		_, err = semver.ParseConstraint(vcfg.Kernel)
+		if err != nil {
+			return fmt.Errorf("kernel constraint in config file: %v", err)
+		}
+	}
+
 	return nil
 
 }
@@ -14,6 +14,7 @@
 package compiler
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"os"
@@ -21,6 +22,7 @@ import (
 
 	"github.com/sisatech/sherlock"
 	"github.com/sisatech/vcli/compiler/vmdk"
+	"github.com/sisatech/vcli/shared"
 )
 
 // ReadAppNameFromVMDK reads the app name stored within a Vorteil VMDK file.
@@ -93,3 +95,69 @@ func ReadNetworkCardCountFromVMDK(filepath string) (int, error) {
 
 	return count, err
 }
+
+// ReadVMConfigFromVMDK reads the vm section of the app's configuration from
+// within a Vorteil VMDK file. Disks built before the section existed return an
+// empty configuration.
+func ReadVMConfigFromVMDK(filepath string) (*shared.VMConfig, error) {
+	var err error
+	vm := new(shared.VMConfig)
+
+	err = sherlock.Try(func() {
+
+		f, e := os.Open(filepath)
+		sherlock.Check(e)
+		defer f.Close()
+		sherlock.Check(f.Seek(int64(64), 0))
+		var overhead uint64
+		sherlock.Check(binary.Read(f, binary.LittleEndian, &overhead))
+		overhead = vmdk.SectorSize*overhead + (512 * 34)
+		imageHeader := &vmdk.ImageHeader{}
+		offset := unsafe.Offsetof(imageHeader.VM)
+		sherlock.Check(f.Seek(int64(overhead+uint64(offset)), 0))
+		header := new(vmdk.ImageHeaderVM)
+		sherlock.Check(binary.Read(f, binary.LittleEndian, header))
+
+		cstring := func(b []byte) string {
+			if i := bytes.IndexByte(b, 0); i >= 0 {
+				return string(b[:i])
+			}
+			return string(b)
+		}
+
+		vm.Memory = header.Memory
+		vm.VCPUs = uint16(header.CPUs)
+		vm.NICModel = cstring(header.NICModel[:])
+		vm.DiskBus = cstring(header.DiskBus[:])
+		vm.KernelArgs = cstring(header.KernelArgs[:])
+
+	})
+
+	return vm, err
+}
+
+// ReadLoadAddressFromVMDK reads the address position independent apps are
+// loaded at from within a Vorteil VMDK file. Disks built before the address
+// was recorded return zero.
+func ReadLoadAddressFromVMDK(filepath string) (uint64, error) {
+	var err error
+	var addr uint64
+
+	err = sherlock.Try(func() {
+
+		f, e := os.Open(filepath)
+		sherlock.Check(e)
+		defer f.Close()
+		sherlock.Check(f.Seek(int64(64), 0))
+		var overhead uint64
+		sherlock.Check(binary.Read(f, binary.LittleEndian, &overhead))
+		overhead = vmdk.SectorSize*overhead + (512 * 34)
+		imageHeader := &vmdk.ImageHeader{}
+		offset := unsafe.Offsetof(imageHeader.LoadAddress)
+		sherlock.Check(f.Seek(int64(overhead+uint64(offset)), 0))
+		sherlock.Check(binary.Read(f, binary.LittleEndian, &addr))
+
+	})
+
+	return addr, err
+}
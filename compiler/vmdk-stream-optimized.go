@@ -25,7 +25,12 @@ import (
 // to a non-temporary location.
 func BuildStreamOptimizedVMDK(binary, config, files, kernel, destination string, debug bool) (*os.File, error) {
 
-	err := FullValidation(binary, config, files, "", kernel)
+	kernel, err := ResolveKernel(config, kernel)
+	if err != nil {
+		return nil, err
+	}
+
+	err = FullValidation(binary, config, files, "", kernel)
 	if err != nil {
 		return nil, err
 	}
@@ -16,9 +16,7 @@ package vmdk
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"io/ioutil"
-	"os"
 )
 
 type protectiveMBREntry struct {
@@ -39,18 +37,11 @@ func (build *builder) writeMBR() error {
 	var err error
 
 	// write bootloader at start of sector
-	path := fmt.Sprintf("%s/%s", build.env.path, "vboot.img")
-	build.log("MBR: %s", path)
-
-	if _, err = os.Stat(path); os.IsNotExist(err) {
-
-		// TODO
-		// err = DownloadVorteilFile("vboot.img", "vboot")
-		if err != nil {
-			return err
-		}
-
+	path, err := build.bootAsset("vboot")
+	if err != nil {
+		return err
 	}
+	build.log("MBR: %s", path)
 
 	mbr, err := ioutil.ReadFile(path)
 	if err != nil {
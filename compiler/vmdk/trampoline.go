@@ -15,21 +15,15 @@ package vmdk
 
 import (
 	"io/ioutil"
-	"os"
+
+	"github.com/sisatech/vcli/home"
 )
 
 func (build *builder) writeTrampoline() error {
 
-	path := build.env.path + "/vtramp.img"
-
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-
-		// TODO
-		// err = DownloadVorteilFile("vtramp.img", "vtramp")
-		if err != nil {
-			return err
-		}
-
+	path, err := build.bootAsset("vtramp")
+	if err != nil {
+		return err
 	}
 
 	trampoline, err := ioutil.ReadFile(path)
@@ -47,3 +41,15 @@ func (build *builder) writeTrampoline() error {
 	return nil
 
 }
+
+// bootAsset returns the file of the boot asset built with the kernel.
+func (build *builder) bootAsset(name string) (string, error) {
+
+	asset, err := home.LookupBootAsset(name)
+	if err != nil {
+		return "", err
+	}
+
+	return asset.Path(build.env.path, build.args.Kernel)
+
+}
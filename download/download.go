@@ -0,0 +1,414 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package download fetches files concurrently, resuming partial downloads and
+// checking them against their expected size and SHA256 before they're used.
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PartSuffix is added to the name of a file while it's being downloaded. A
+// file with the suffix is resumed by the next download to the same place.
+const PartSuffix = ".part"
+
+// Fetcher opens a file for download. It starts at offset if it can, and
+// returns where the body actually starts along with the file's total size, or
+// -1 if the size isn't known.
+type Fetcher interface {
+	Fetch(ctx context.Context, offset int64) (body io.ReadCloser, start, size int64, err error)
+}
+
+// Opener is a Fetcher for sources that can't resume, which always start from
+// the beginning of the file.
+type Opener func() (io.ReadCloser, error)
+
+// Fetch ...
+func (fn Opener) Fetch(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+
+	r, err := fn()
+
+	return r, 0, -1, err
+
+}
+
+// HTTP fetches a URL, resuming with range requests.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+// Fetch ...
+func (h *HTTP) Fetch(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error) {
+
+	req, err := http.NewRequest("GET", h.URL, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	req = req.WithContext(ctx)
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	switch resp.StatusCode {
+
+	case http.StatusOK:
+		return resp.Body, 0, resp.ContentLength, nil
+
+	case http.StatusPartialContent:
+		start, size, err := contentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			resp.Body.Close()
+			return nil, 0, 0, err
+		}
+		return resp.Body, start, size, nil
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is no shorter than the remote one, so it can't
+		// be trusted
+		resp.Body.Close()
+		if offset > 0 {
+			return h.Fetch(ctx, 0)
+		}
+
+	}
+
+	resp.Body.Close()
+
+	return nil, 0, 0, fmt.Errorf("error downloading %v: %v", h.URL, resp.Status)
+
+}
+
+// contentRange parses a header of the form 'bytes START-END/SIZE'.
+func contentRange(header string) (int64, int64, error) {
+
+	bad := fmt.Errorf("invalid Content-Range '%v'", header)
+
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, bad
+	}
+
+	spec := strings.SplitN(strings.TrimPrefix(header, "bytes "), "/", 2)
+	if len(spec) != 2 {
+		return 0, 0, bad
+	}
+
+	dash := strings.Index(spec[0], "-")
+	if dash < 0 {
+		return 0, 0, bad
+	}
+
+	start, err := strconv.ParseInt(spec[0][:dash], 10, 64)
+	if err != nil {
+		return 0, 0, bad
+	}
+
+	size := int64(-1)
+	if spec[1] != "*" {
+		size, err = strconv.ParseInt(spec[1], 10, 64)
+		if err != nil {
+			return 0, 0, bad
+		}
+	}
+
+	return start, size, nil
+
+}
+
+// Job is a file to download.
+type Job struct {
+	Name    string
+	Fetcher Fetcher
+	Dest    string
+
+	// Size and SHA256 are checked once the download completes, unless
+	// they're zero.
+	Size   int64
+	SHA256 string
+}
+
+// Manager runs download jobs.
+type Manager struct {
+	// Concurrency limits how many jobs run at once. Zero means four.
+	Concurrency int
+
+	// Progress is told how each job is going, if it isn't nil.
+	Progress Progress
+}
+
+// Run downloads every job, stopping them all if ctx is cancelled or any of
+// them fails.
+func (m *Manager) Run(ctx context.Context, jobs ...*Job) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := m.Concurrency
+	if n <= 0 {
+		n = 4
+	}
+
+	sem := make(chan bool, n)
+	errs := make([]error, len(jobs))
+
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+
+		wg.Add(1)
+		go func(i int, job *Job) {
+
+			defer wg.Done()
+
+			select {
+			case sem <- true:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			errs[i] = m.get(ctx, job)
+			if errs[i] != nil {
+				cancel()
+			}
+
+		}(i, job)
+
+	}
+
+	wg.Wait()
+
+	// prefer the error that stopped the others
+	var first error
+	for _, err := range errs {
+		if err != nil && err != context.Canceled {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+
+	return first
+
+}
+
+func (m *Manager) get(ctx context.Context, job *Job) error {
+
+	part := job.Dest + PartSuffix
+
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	if job.Size > 0 && offset > job.Size {
+		offset = 0
+	}
+
+	err := m.transfer(ctx, job, part, offset)
+	if err == nil {
+		err = check(job, part)
+	}
+
+	if err != nil && offset > 0 && ctx.Err() == nil {
+		// the partial file may have been what was wrong
+		err = m.transfer(ctx, job, part, 0)
+		if err == nil {
+			err = check(job, part)
+		}
+	}
+
+	if m.Progress != nil {
+		m.Progress.Finish(job, err)
+	}
+
+	if err != nil {
+		if _, ok := err.(*checkError); ok {
+			os.Remove(part)
+		}
+		return err
+	}
+
+	return os.Rename(part, job.Dest)
+
+}
+
+// transfer writes the file to part, starting at offset if the fetcher allows.
+func (m *Manager) transfer(ctx context.Context, job *Job, part string, offset int64) error {
+
+	if job.Size > 0 && offset == job.Size {
+		if m.Progress != nil {
+			m.Progress.Start(job, offset, job.Size)
+		}
+		return nil
+	}
+
+	body, start, size, err := job.Fetcher.Fetch(ctx, offset)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if size < 0 && job.Size > 0 {
+		size = job.Size
+	}
+
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = f.Truncate(start)
+	if err == nil {
+		_, err = f.Seek(start, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if m.Progress != nil {
+		m.Progress.Start(job, start, size)
+	}
+
+	w := &progressWriter{w: f, job: job, progress: m.Progress}
+	_, err = io.Copy(w, &contextReader{ctx: ctx, r: body})
+	if err != nil {
+		f.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("error downloading %v: %v", job.Name, err)
+	}
+
+	return f.Close()
+
+}
+
+// checkError means a downloaded file isn't what was expected.
+type checkError struct {
+	msg string
+}
+
+func (e *checkError) Error() string {
+
+	return e.msg
+
+}
+
+func check(job *Job, part string) error {
+
+	f, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+
+	if job.Size > 0 && n != job.Size {
+		return &checkError{fmt.Sprintf("%v is %d bytes; expected %d", job.Name, n, job.Size)}
+	}
+
+	if job.SHA256 != "" && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), job.SHA256) {
+		return &checkError{fmt.Sprintf("%v doesn't match its SHA256 checksum", job.Name)}
+	}
+
+	return nil
+
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+
+}
+
+type progressWriter struct {
+	w        io.Writer
+	job      *Job
+	progress Progress
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+
+	n, err := w.w.Write(p)
+	if w.progress != nil && n > 0 {
+		w.progress.Advance(w.job, int64(n))
+	}
+
+	return n, err
+
+}
+
+// OnInterrupt returns a context that is cancelled when the process receives
+// an interrupt, such as from Ctrl-C, until cancel is called.
+func OnInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithCancel(parent)
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(ch)
+	}()
+
+	return ctx, cancel
+
+}
@@ -0,0 +1,308 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var content = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+
+func checksum(data []byte) string {
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+
+}
+
+// server serves content, honouring range requests unless ranges is false, and
+// records the Range header of each request.
+type server struct {
+	sync.Mutex
+	ranges  bool
+	headers []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.Lock()
+	s.headers = append(s.headers, r.Header.Get("Range"))
+	s.Unlock()
+
+	if !s.ranges {
+		w.Write(content)
+		return
+	}
+
+	http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+
+}
+
+func tempDir(t *testing.T) string {
+
+	dir, err := ioutil.TempDir("", "vcli-download-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+
+}
+
+func TestResume(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	srv := &server{ranges: true}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dest := filepath.Join(dir, "file")
+	err := ioutil.WriteFile(dest+PartSuffix, content[:1000], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	m := &Manager{Progress: NewPlainProgress(buf)}
+	err = m.Run(context.Background(), &Job{
+		Name:    "file",
+		Fetcher: &HTTP{URL: ts.URL},
+		Dest:    dest,
+		Size:    int64(len(content)),
+		SHA256:  checksum(content),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, content) {
+		t.Error("resumed file doesn't match")
+	}
+
+	if len(srv.headers) != 1 || srv.headers[0] != "bytes=1000-" {
+		t.Errorf("range headers %q", srv.headers)
+	}
+
+	if !strings.HasPrefix(buf.String(), "Resuming file at 1000 B") || !strings.Contains(buf.String(), "Finished file") {
+		t.Errorf("progress:\n%s", buf)
+	}
+
+	if _, err := os.Stat(dest + PartSuffix); !os.IsNotExist(err) {
+		t.Error("partial file left behind")
+	}
+
+}
+
+func TestNoRangeSupport(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(&server{})
+	defer ts.Close()
+
+	dest := filepath.Join(dir, "file")
+	err := ioutil.WriteFile(dest+PartSuffix, []byte("garbage that isn't the start of the file"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = new(Manager).Run(context.Background(), &Job{
+		Name:    "file",
+		Fetcher: &HTTP{URL: ts.URL},
+		Dest:    dest,
+		SHA256:  checksum(content),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(dest)
+	if !bytes.Equal(data, content) {
+		t.Error("restarted file doesn't match")
+	}
+
+}
+
+func TestCorruptPartial(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	srv := &server{ranges: true}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dest := filepath.Join(dir, "file")
+	corrupt := append([]byte("XXXX"), content[4:2000]...)
+	err := ioutil.WriteFile(dest+PartSuffix, corrupt, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = new(Manager).Run(context.Background(), &Job{
+		Name:    "file",
+		Fetcher: &HTTP{URL: ts.URL},
+		Dest:    dest,
+		SHA256:  checksum(content),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(srv.headers) != 2 || srv.headers[1] != "" {
+		t.Errorf("expected a resume then a full download, got %q", srv.headers)
+	}
+
+	data, _ := ioutil.ReadFile(dest)
+	if !bytes.Equal(data, content) {
+		t.Error("file doesn't match")
+	}
+
+}
+
+func TestChecksumMismatch(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(&server{ranges: true})
+	defer ts.Close()
+
+	dest := filepath.Join(dir, "file")
+	err := new(Manager).Run(context.Background(), &Job{
+		Name:    "file",
+		Fetcher: &HTTP{URL: ts.URL},
+		Dest:    dest,
+		SHA256:  checksum([]byte("something else")),
+	})
+	if err == nil || !strings.Contains(err.Error(), "SHA256") {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+
+	for _, path := range []string{dest, dest + PartSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%v should not exist", filepath.Base(path))
+		}
+	}
+
+}
+
+func TestCancel(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	release := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100000")
+		w.Write(content[:100])
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	dest := filepath.Join(dir, "file")
+	err := new(Manager).Run(ctx, &Job{
+		Name:    "file",
+		Fetcher: &HTTP{URL: ts.URL},
+		Dest:    dest,
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+
+	// the partial download is kept to resume later
+	info, err := os.Stat(dest + PartSuffix)
+	if err != nil || info.Size() != 100 {
+		t.Errorf("partial file: %v", err)
+	}
+
+}
+
+func TestOpener(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	jobs := []*Job{}
+	for _, name := range []string{"a", "b", "c"} {
+		jobs = append(jobs, &Job{
+			Name: name,
+			Fetcher: Opener(func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(content)), nil
+			}),
+			Dest: filepath.Join(dir, name),
+			Size: int64(len(content)),
+		})
+	}
+
+	err := (&Manager{Concurrency: 2}).Run(context.Background(), jobs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, job := range jobs {
+		info, err := os.Stat(job.Dest)
+		if err != nil || info.Size() != int64(len(content)) {
+			t.Errorf("%v: %v", job.Name, err)
+		}
+	}
+
+}
+
+func TestContentRange(t *testing.T) {
+
+	start, size, err := contentRange("bytes 100-199/1000")
+	if err != nil || start != 100 || size != 1000 {
+		t.Errorf("got %d, %d, %v", start, size, err)
+	}
+
+	_, size, err = contentRange("bytes 100-199/*")
+	if err != nil || size != -1 {
+		t.Errorf("got %d, %v", size, err)
+	}
+
+	if _, _, err = contentRange("items 1-2/3"); err == nil {
+		t.Error("expected error")
+	}
+
+}
@@ -0,0 +1,264 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package download
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Progress is told how downloads are going. Its methods may be called from
+// several goroutines at once.
+type Progress interface {
+	Start(job *Job, offset, size int64)
+	Advance(job *Job, n int64)
+	Finish(job *Job, err error)
+}
+
+// NewProgress returns a Progress that redraws a line for each download in
+// place when f is an interactive terminal, and otherwise writes plain log
+// lines suited to CI.
+func NewProgress(f *os.File) Progress {
+
+	if interactive(f) {
+		return &terminalProgress{w: f, states: make(map[*Job]*state)}
+	}
+
+	return NewPlainProgress(f)
+
+}
+
+// NewPlainProgress returns a Progress that writes a line when each download
+// starts and finishes, and at every quarter of the way in between.
+func NewPlainProgress(w io.Writer) Progress {
+
+	return &plainProgress{w: w, states: make(map[*Job]*state)}
+
+}
+
+func interactive(f *os.File) bool {
+
+	if runtime.GOOS == "windows" || os.Getenv("CI") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+
+}
+
+type state struct {
+	name string
+	done int64
+	size int64
+}
+
+func (s *state) String() string {
+
+	if s.size <= 0 {
+		return fmt.Sprintf("Downloading %s %s", s.name, humanBytes(s.done))
+	}
+
+	return fmt.Sprintf("Downloading %s %s / %s (%d%%)", s.name, humanBytes(s.done), humanBytes(s.size), s.percent())
+
+}
+
+func (s *state) percent() int {
+
+	if s.size <= 0 {
+		return 0
+	}
+
+	return int(100 * s.done / s.size)
+
+}
+
+func humanBytes(n int64) string {
+
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for x := n / unit; x >= unit; x /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+
+}
+
+func finished(s *state, err error) string {
+
+	if err != nil {
+		return fmt.Sprintf("Failed %s: %v", s.name, err)
+	}
+
+	return fmt.Sprintf("Finished %s %s", s.name, humanBytes(s.done))
+
+}
+
+type terminalProgress struct {
+	sync.Mutex
+	w      io.Writer
+	states map[*Job]*state
+	order  []*Job
+	drawn  int
+	last   time.Time
+}
+
+func (p *terminalProgress) Start(job *Job, offset, size int64) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if _, ok := p.states[job]; !ok {
+		p.order = append(p.order, job)
+	}
+	p.states[job] = &state{name: job.Name, done: offset, size: size}
+
+	p.redraw("")
+
+}
+
+func (p *terminalProgress) Advance(job *Job, n int64) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if s, ok := p.states[job]; ok {
+		s.done += n
+	}
+
+	if time.Since(p.last) > 100*time.Millisecond {
+		p.redraw("")
+	}
+
+}
+
+func (p *terminalProgress) Finish(job *Job, err error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	s, ok := p.states[job]
+	if !ok {
+		s = &state{name: job.Name}
+	}
+
+	delete(p.states, job)
+	for i, x := range p.order {
+		if x == job {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+
+	p.redraw(finished(s, err))
+
+}
+
+// redraw replaces the lines drawn last time with a finished line, if there is
+// one, followed by a line for each download still running.
+func (p *terminalProgress) redraw(done string) {
+
+	if p.drawn > 0 {
+		fmt.Fprintf(p.w, "\033[%dA", p.drawn)
+	}
+
+	if done != "" {
+		fmt.Fprintf(p.w, "\033[K%s\n", done)
+	}
+
+	for _, job := range p.order {
+		fmt.Fprintf(p.w, "\033[K%s\n", p.states[job])
+	}
+
+	fmt.Fprint(p.w, "\033[J")
+
+	p.drawn = len(p.order)
+	p.last = time.Now()
+
+}
+
+type plainProgress struct {
+	sync.Mutex
+	w      io.Writer
+	states map[*Job]*state
+}
+
+func (p *plainProgress) Start(job *Job, offset, size int64) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	s := &state{name: job.Name, done: offset, size: size}
+	p.states[job] = s
+
+	switch {
+	case offset > 0 && size > 0:
+		fmt.Fprintf(p.w, "Resuming %s at %s / %s\n", s.name, humanBytes(offset), humanBytes(size))
+	case offset > 0:
+		fmt.Fprintf(p.w, "Resuming %s at %s\n", s.name, humanBytes(offset))
+	case size > 0:
+		fmt.Fprintf(p.w, "Downloading %s (%s)\n", s.name, humanBytes(size))
+	default:
+		fmt.Fprintf(p.w, "Downloading %s\n", s.name)
+	}
+
+}
+
+func (p *plainProgress) Advance(job *Job, n int64) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	s, ok := p.states[job]
+	if !ok {
+		return
+	}
+
+	before := s.percent() / 25
+	s.done += n
+
+	if after := s.percent() / 25; after > before && after < 4 {
+		fmt.Fprintln(p.w, s)
+	}
+
+}
+
+func (p *plainProgress) Finish(job *Job, err error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	s, ok := p.states[job]
+	if !ok {
+		s = &state{name: job.Name}
+	}
+	delete(p.states, job)
+
+	fmt.Fprintln(p.w, finished(s, err))
+
+}
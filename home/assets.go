@@ -0,0 +1,118 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package home
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BootAsset is a file written into every disk alongside the kernel, such as
+// the bootloader. Assets are published as packages of the same name in the
+// kernel repository, and kept in the kernel directory with a release for each
+// kernel version.
+type BootAsset struct {
+	Name        string
+	Description string
+}
+
+var bootAssets []*BootAsset
+
+func init() {
+
+	RegisterBootAsset(&BootAsset{Name: "vboot", Description: "bootloader"})
+	RegisterBootAsset(&BootAsset{Name: "vtramp", Description: "trampoline"})
+
+}
+
+// RegisterBootAsset adds an asset that every build needs.
+func RegisterBootAsset(asset *BootAsset) {
+
+	bootAssets = append(bootAssets, asset)
+
+}
+
+// BootAssets returns every registered boot asset.
+func BootAssets() []*BootAsset {
+
+	return bootAssets
+
+}
+
+// File returns the name the asset is kept under for a kernel version.
+func (asset *BootAsset) File(kernel string) string {
+
+	return asset.Name + "-" + kernel + ".img"
+
+}
+
+// LegacyFile returns the name the asset was kept under before assets were
+// versioned alongside kernels.
+func (asset *BootAsset) LegacyFile() string {
+
+	return asset.Name + ".img"
+
+}
+
+// Path returns the asset's file in dir for a kernel version, falling back to
+// its legacy file.
+func (asset *BootAsset) Path(dir, kernel string) (string, error) {
+
+	for _, name := range []string{asset.File(kernel), asset.LegacyFile()} {
+
+		path := filepath.Join(dir, name)
+
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+	}
+
+	return "", fmt.Errorf("%v (%v) for kernel %v not found; try 'vcli settings kernel download %v'", asset.Name, asset.Description, kernel, kernel)
+
+}
+
+// LookupBootAsset returns the registered asset with the given name.
+func LookupBootAsset(name string) (*BootAsset, error) {
+
+	for _, asset := range bootAssets {
+		if asset.Name == name {
+			return asset, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown boot asset '%v'", name)
+
+}
+
+// MissingBootAssets returns the names of the assets that aren't available
+// locally for a kernel version.
+func MissingBootAssets(kernel string) []string {
+
+	var missing []string
+	for _, asset := range bootAssets {
+		if _, err := asset.Path(Path(Kernel), kernel); err != nil {
+			missing = append(missing, asset.Name)
+		}
+	}
+
+	return missing
+
+}
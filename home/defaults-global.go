@@ -30,11 +30,22 @@ const (
 )
 
 type globalDefaults struct {
-	Author              string   `yaml:"author"`
-	Hypervisor          string   `yaml:"hypervisor"`
-	Kernel              string   `yaml:"kernel"`
-	KnownKernelVersions []string `yaml:"known_kernel_versions"`
-	Infrastructure      string   `yaml:"infrastructure"`
+	Author              string             `yaml:"author"`
+	Hypervisor          string             `yaml:"hypervisor"`
+	Kernel              string             `yaml:"kernel"`
+	KnownKernelVersions []string           `yaml:"known_kernel_versions"`
+	Infrastructure      string             `yaml:"infrastructure"`
+	Mirror              string             `yaml:"mirror,omitempty"`
+	Remotes             map[string]*Remote `yaml:"remotes,omitempty"`
+	Storage             string             `yaml:"storage,omitempty"`
+	Compression         string             `yaml:"compression,omitempty"`
+}
+
+// Remote is a repository served by 'vcli repository serve' that the local
+// repository can push to and pull from.
+type Remote struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token,omitempty"`
 }
 
 // GlobalDefaults contains all global fallback values to use when an alternative
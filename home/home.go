@@ -52,6 +52,21 @@ var (
 	// Repository ...
 	Repository = "repo"
 
+	// Networks is the internal path to the local virtual network definitions
+	Networks = "networks"
+
+	// Instances is the internal path to records of virtual machines left
+	// running in the background
+	Instances = "instances"
+
+	// Keys is the internal path to the OpenPGP keys trusted alongside the
+	// Sisa-Tech key built into vcli
+	Keys = "keys"
+
+	// Downloads is the internal path to kernels and boot assets while
+	// they're being downloaded, so interrupted downloads can be resumed
+	Downloads = "downloads"
+
 	SafeMode = false
 )
 
@@ -129,6 +144,22 @@ func Initialize() error {
 		return err
 	}
 
+	if err := setupDir(Path(Networks)); err != nil {
+		return err
+	}
+
+	if err := setupDir(Path(Instances)); err != nil {
+		return err
+	}
+
+	if err := setupDir(Path(Keys)); err != nil {
+		return err
+	}
+
+	if err := setupDir(Path(Downloads)); err != nil {
+		return err
+	}
+
 	// create global defaults file
 	err := initGlobalDefaults()
 	if err != nil {
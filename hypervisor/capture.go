@@ -0,0 +1,51 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PCAPFile returns the file traffic on a network card is captured to. The
+// first card uses PCAP itself, and later cards add their index to the name,
+// so 'out.pcap' becomes 'out-1.pcap' for the second card.
+func (cfg *Config) PCAPFile(card int) string {
+
+	if card == 0 {
+		return cfg.PCAP
+	}
+
+	ext := filepath.Ext(cfg.PCAP)
+
+	return strings.TrimSuffix(cfg.PCAP, ext) + "-" + strconv.Itoa(card) + ext
+
+}
+
+// PCAPFiles returns the capture files of every network card.
+func (cfg *Config) PCAPFiles() []string {
+
+	if cfg.PCAP == "" {
+		return nil
+	}
+
+	var files []string
+	for i := 0; i < cfg.NetworkCards; i++ {
+		files = append(files, cfg.PCAPFile(i))
+	}
+
+	return files
+
+}
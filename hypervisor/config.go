@@ -0,0 +1,282 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sisatech/vcli/compiler"
+	"github.com/sisatech/vcli/shared"
+)
+
+// Config describes a Vorteil virtual machine to be launched on one of the
+// supported local hypervisors.
+type Config struct {
+	Hypervisor   string
+	Name         string
+	Disk         string
+	CPUs         uint16
+	Memory       uint32
+	NetworkCards int
+	PortMap      string
+	Headless     bool
+	Echo         bool
+	Debug        bool
+	DebugFile    string
+	Persist      string
+
+	// NICModel and DiskBus select the virtual hardware the app's network
+	// cards and disk are attached with, using the shared.NICModel* and
+	// shared.DiskBus* constants. Each hypervisor has its own default.
+	NICModel string
+	DiskBus  string
+
+	// SerialLog is the file the guest's serial console is written to. If it
+	// is left empty the console is either discarded or, when echoing is
+	// enabled, routed wherever the hypervisor normally sends it.
+	SerialLog string
+
+	// Foreground keeps the hypervisor process attached to vcli in headless
+	// mode rather than letting QEMU daemonize itself.
+	Foreground bool
+
+	// Detach starts the hypervisor in its own process group so the machine
+	// keeps running after vcli exits.
+	Detach bool
+
+	// GDB is the address QEMU's gdbstub listens on, in the form
+	// "[host]:port". GDBPause halts the CPU at boot until a debugger
+	// continues it.
+	GDB      string
+	GDBPause bool
+
+	// Networks maps network card indices to the private networks they are
+	// attached to. Cards not listed use the hypervisor's NAT networking.
+	Networks map[int]*Network
+
+	// Monitor is the local address QEMU's QMP monitor listens on, which is
+	// picked by Command if left empty. PIDFile is where QEMU writes its
+	// process ID, and Overlay is a QCOW2 image layered over Disk, which
+	// QEMU boots from instead so the machine's state can be snapshotted.
+	// Both are set by Track.
+	Monitor string
+	PIDFile string
+	Overlay string
+
+	// Console is where the guest's serial console can be streamed from, as
+	// "tcp:ADDR", "unix:PATH", "pipe:PATH" or "file:PATH". It is assigned by
+	// Command; see OpenConsole.
+	Console string
+
+	// PCAP is the file guest network traffic is captured to. Cards after
+	// the first are captured to their own files; see PCAPFile.
+	PCAP string
+
+	// Dir is the working directory used for hypervisors that need to
+	// generate their own machine definitions (VMware and VirtualBox).
+	Dir string
+
+	// VMX is the machine definition generated for VMware hypervisors.
+	VMX string
+}
+
+// NewConfig returns a Config for the given hypervisor and disk, reading the
+// app name, network card requirements and vm section of its configuration
+// from the compiled disk itself.
+func NewConfig(hypervisor, disk string) (*Config, error) {
+
+	name, err := compiler.ReadAppNameFromVMDK(disk)
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := compiler.ReadNetworkCardCountFromVMDK(disk)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := compiler.ReadVMConfigFromVMDK(disk)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		Hypervisor:   strings.ToUpper(hypervisor),
+		Name:         name,
+		Disk:         disk,
+		CPUs:         1,
+		Memory:       64,
+		NetworkCards: cards,
+		NICModel:     vm.NICModel,
+		DiskBus:      vm.DiskBus,
+	}
+
+	if vm.VCPUs != 0 {
+		cfg.CPUs = vm.VCPUs
+	}
+
+	if vm.Memory != 0 {
+		cfg.Memory = vm.Memory
+	}
+
+	return cfg, nil
+
+}
+
+// Command returns the executable and arguments that launch the virtual
+// machine. For VMware and VirtualBox this also registers the machine with the
+// hypervisor, so Teardown should be called once the machine has stopped.
+func (cfg *Config) Command() (string, []string, error) {
+
+	var err error
+	var args []string
+	executable := shared.BinaryQEMU
+
+	switch cfg.Hypervisor {
+	case shared.QEMU, shared.KVM, shared.KVMClassic:
+		if cfg.Monitor == "" {
+			cfg.Monitor, err = freeAddress()
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		if cfg.Console == "" {
+			addr, err := freeAddress()
+			if err != nil {
+				return "", nil, err
+			}
+			cfg.Console = "tcp:" + addr
+		}
+		args = cfg.qemuArgs()
+	case shared.VirtualBox:
+		args, err = cfg.virtualBoxArgs()
+		if err != nil {
+			return "", nil, err
+		}
+		if cfg.Headless {
+			executable = "VBoxManage"
+			args = []string{"startvm", cfg.Name, "--type", "headless"}
+		} else {
+			executable = shared.BinaryVirtualBox
+		}
+	case shared.VMwarePlayer:
+		executable = shared.BinaryVMwarePlayer
+		args, err = cfg.vmwareArgs(true)
+		if err != nil {
+			return "", nil, fmt.Errorf("error preparing vmware-player workstation: %v", err)
+		}
+	case shared.VMwareWorkstation, shared.VMwareTest:
+		executable = shared.BinaryVMwareWorkstation
+		args, err = cfg.vmwareArgs(false)
+		if err != nil {
+			return "", nil, fmt.Errorf("error preparing vmware-workstation workstation: %v", err)
+		}
+	default:
+		return "", nil, errors.New("unsupported hypervisor")
+	}
+
+	return executable, args, nil
+
+}
+
+// ValidatePortMap checks that a port map string is formatted as a comma
+// separated list of "card:host:guest" triples.
+func ValidatePortMap(mappings string) error {
+
+	if mappings == "" {
+		return nil
+	}
+
+	pmap := strings.Split(mappings, ",")
+	for i, p := range pmap {
+
+		pmapIndividual := strings.Split(p, ":")
+		if len(pmapIndividual) != 3 {
+			return errors.New("argument '--port-map' formatted incorrectly. Correct format should be '<cardNumber:hostPort:guestPort>', with additional mappings separated by commas.")
+		}
+
+		a, err := strconv.Atoi(pmapIndividual[1])
+		if err != nil {
+			return errors.New("unexpected value in port-map flag. Values should be numeric only.")
+		}
+
+		b, err := strconv.Atoi(pmapIndividual[2])
+		if err != nil {
+			return errors.New("unexpected value in port-map flag. Values should be numeric only.")
+		}
+
+		if a < 0 || a > 65535 {
+			return fmt.Errorf("port-map %v: host port out of bounds. Must be between 0-65535.", i)
+		} else if b < 0 || b > 65535 {
+			return fmt.Errorf("port-map %v: guest port out of bounds. Must be between 0-65535.", i)
+		}
+
+	}
+
+	return nil
+
+}
+
+// GDBAddress splits a gdbstub listen address into the host and port,
+// defaulting the host to localhost.
+func GDBAddress(addr string) (string, string, error) {
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid gdb address '%v': %v", addr, err)
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("invalid gdb port '%v'", port)
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	return host, port, nil
+
+}
+
+// hostForwards returns the QEMU user-mode networking hostfwd options for the
+// network card with the given index.
+func (cfg *Config) hostForwards(card int) string {
+
+	var out string
+
+	if len(cfg.PortMap) == 0 {
+		return out
+	}
+
+	istr := strconv.Itoa(card)
+
+	// loop through port maps and assign where appropriate
+	for _, p := range strings.Split(cfg.PortMap, ",") {
+
+		pmapInd := strings.Split(p, ":")
+		if pmapInd[0] == istr {
+			out += fmt.Sprintf(",hostfwd=tcp::%s-:%s", pmapInd[1], pmapInd[2])
+			out += fmt.Sprintf(",hostfwd=udp::%s-:%s", pmapInd[1], pmapInd[2])
+		}
+
+	}
+
+	return out
+
+}
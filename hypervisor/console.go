@@ -0,0 +1,137 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hpcloud/tail"
+)
+
+// ConsoleTimeout is how long OpenConsole waits for the hypervisor to start
+// serving a virtual machine's serial console.
+const ConsoleTimeout = 10 * time.Second
+
+// OpenConsole connects to the serial console stream at endpoint; see
+// Config.Console. Streams served on a socket or pipe can also be written to,
+// which sends input to the guest. They end when the hypervisor closes them,
+// while streams read from a log file follow it until done is closed.
+func OpenConsole(endpoint string, done <-chan struct{}) (io.ReadCloser, error) {
+
+	i := strings.Index(endpoint, ":")
+	if i < 0 {
+		return nil, errors.New("virtual machine has no serial console")
+	}
+	scheme, addr := endpoint[:i], endpoint[i+1:]
+
+	if scheme == "file" {
+		return followFile(addr, done)
+	}
+
+	deadline := time.Now().Add(ConsoleTimeout)
+	for {
+
+		var rc io.ReadCloser
+		var err error
+
+		switch scheme {
+		case "tcp", "unix":
+			rc, err = net.Dial(scheme, addr)
+		case "pipe":
+			rc, err = os.OpenFile(addr, os.O_RDWR, 0)
+		default:
+			return nil, fmt.Errorf("unsupported serial console '%v'", endpoint)
+		}
+
+		if err == nil {
+			return rc, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("could not connect to serial console: %v", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+	}
+
+}
+
+// OpenConsole connects to the instance's serial console, which is followed
+// until the virtual machine stops.
+func (inst *Instance) OpenConsole() (io.ReadCloser, error) {
+
+	return OpenConsole(inst.Config.Console, inst.done)
+
+}
+
+// tailReader follows a serial log as it is written.
+type tailReader struct {
+	t   *tail.Tail
+	buf []byte
+}
+
+func followFile(path string, done <-chan struct{}) (io.ReadCloser, error) {
+
+	t, err := tail.TailFile(path, tail.Config{Follow: true, Logger: tail.DiscardingLogger})
+	if err != nil {
+		return nil, err
+	}
+
+	if done != nil {
+		go func() {
+			<-done
+			t.StopAtEOF()
+		}()
+	}
+
+	return &tailReader{t: t}, nil
+
+}
+
+func (r *tailReader) Read(p []byte) (int, error) {
+
+	for len(r.buf) == 0 {
+
+		line, ok := <-r.t.Lines
+		if !ok {
+			return 0, io.EOF
+		}
+
+		if line.Err != nil {
+			return 0, line.Err
+		}
+
+		r.buf = append([]byte(line.Text), '\n')
+
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+
+}
+
+func (r *tailReader) Close() error {
+
+	return r.t.Stop()
+
+}
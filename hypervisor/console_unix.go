@@ -0,0 +1,30 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !windows
+
+package hypervisor
+
+import (
+	"path"
+)
+
+// VirtualBox serves its serial port on a unix socket everywhere but Windows.
+const pipeScheme = "unix"
+
+func consolePipe(dir, name string) string {
+
+	return path.Join(dir, name+".sock")
+
+}
@@ -0,0 +1,223 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+const (
+	// PanicExitCode is reported when the guest kernel panics. Guest exit
+	// codes are limited to 0-127 by the QEMU debug exit device, so this
+	// never collides with a status set by the application itself.
+	PanicExitCode = 128
+
+	// debugExitPort is the I/O port of the isa-debug-exit device the kernel
+	// writes its shutdown status to. QEMU exits with (status << 1) | 1.
+	debugExitPort = "0xf4"
+)
+
+var (
+	// both markers are only matched at the start of a line, optionally
+	// after the kernel's timestamp, so an app mentioning them in passing
+	// isn't mistaken for the kernel
+	exitMarker  = regexp.MustCompile(`^(\[ *[0-9.]+\] *)?vorteil: exit status (-?[0-9]+)`)
+	panicMarker = regexp.MustCompile(`^(\[ *[0-9.]+\] *)?Kernel panic - not syncing`)
+)
+
+// ExitError reports that the guest shut down with a non-zero status, or that
+// its kernel panicked.
+type ExitError struct {
+	Code  int
+	Panic bool
+}
+
+func (e *ExitError) Error() string {
+
+	if e.Panic {
+		return "guest kernel panic"
+	}
+
+	return fmt.Sprintf("guest exited with status %d", e.Code)
+
+}
+
+// ExitCode returns the status vcli should exit with.
+func (e *ExitError) ExitCode() int {
+
+	return e.Code
+
+}
+
+// Console watches a guest's serial console for the shutdown status and panic
+// messages printed by the kernel. It can be used as the destination for the
+// console, or fed a serial log after the fact with ScanConsole.
+type Console struct {
+	mu       sync.Mutex
+	partial  []byte
+	code     int
+	exited   bool
+	panicked bool
+}
+
+func (c *Console) Write(p []byte) (int, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.partial = append(c.partial, p...)
+	for {
+
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		c.scan(c.partial[:i])
+		c.partial = c.partial[i+1:]
+
+	}
+
+	return len(p), nil
+
+}
+
+func (c *Console) scan(line []byte) {
+
+	if panicMarker.Match(line) {
+		c.panicked = true
+	}
+
+	m := exitMarker.FindSubmatch(line)
+	if m != nil {
+		code, err := strconv.Atoi(string(m[2]))
+		if err == nil {
+			c.code = code
+			c.exited = true
+		}
+	}
+
+}
+
+func (c *Console) flush() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.partial) > 0 {
+		c.scan(c.partial)
+		c.partial = nil
+	}
+
+}
+
+// ScanConsole reads a serial log written by the hypervisor.
+func ScanConsole(path string) (*Console, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := new(Console)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		c.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+
+	return c, nil
+
+}
+
+// Status works out how the guest shut down, preferring whatever the kernel
+// reported on its console over the hypervisor's own exit status. It returns
+// nil if the guest exited cleanly or its status cannot be determined, an
+// *ExitError if the guest failed, and any other error if the hypervisor
+// itself failed.
+func (cfg *Config) Status(state *os.ProcessState, console *Console) error {
+
+	if console != nil {
+
+		console.flush()
+
+		if console.panicked {
+			return &ExitError{Code: PanicExitCode, Panic: true}
+		}
+
+		if console.exited {
+			if console.code == 0 {
+				return nil
+			}
+			return &ExitError{Code: console.code}
+		}
+
+	}
+
+	if state == nil {
+		return nil
+	}
+
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Exited() || ws.ExitStatus() == 0 {
+		return nil
+	}
+
+	if !cfg.isQEMU() {
+		return fmt.Errorf("hypervisor exited with status %d", ws.ExitStatus())
+	}
+
+	code, ok := debugExitStatus(ws.ExitStatus())
+	if !ok {
+		return fmt.Errorf("QEMU exited with status %d", ws.ExitStatus())
+	}
+
+	return &ExitError{Code: code}
+
+}
+
+// debugExitStatus decodes the status the guest wrote to the debug exit device
+// from QEMU's exit code, which is (status << 1) | 1. QEMU exits with 1 when
+// it fails itself, the same as a guest writing 0, so that code is treated as
+// a failure; a clean guest exit is recognised from the console marker
+// instead. Even codes never come from the device.
+func debugExitStatus(code int) (int, bool) {
+
+	if code&1 == 0 || code == 1 {
+		return 0, false
+	}
+
+	return code >> 1, true
+
+}
+
+func (cfg *Config) isQEMU() bool {
+
+	return cfg.Hypervisor == shared.QEMU || cfg.Hypervisor == shared.KVM ||
+		cfg.Hypervisor == shared.KVMClassic
+
+}
@@ -0,0 +1,212 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+func TestConsole(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		writes   []string
+		code     int
+		exited   bool
+		panicked bool
+	}{
+		{"clean", []string{"booting\n", "vorteil: exit status 0\n"}, 0, true, false},
+		{"status", []string{"vorteil: exit status 3\n"}, 3, true, false},
+		{"negative", []string{"vorteil: exit status -1\n"}, -1, true, false},
+		{"split", []string{"vorteil: exit st", "atus 42\n"}, 42, true, false},
+		{"unterminated", []string{"vorteil: exit status 5"}, 5, true, false},
+		{"last wins", []string{"vorteil: exit status 1\nvorteil: exit status 2\n"}, 2, true, false},
+		{"timestamped", []string{"[    1.204] vorteil: exit status 4\r\n"}, 4, true, false},
+		{"panic", []string{"Kernel panic - not syncing: Attempted to kill init!\n"}, 0, false, true},
+		{"timestamped panic", []string{"[    0.010000] Kernel panic - not syncing: VFS\n"}, 0, false, true},
+		{"silent", []string{"hello\n", "exit status 9\n"}, 0, false, false},
+		{"app output", []string{"app: avoiding a kernel panic\n", "echo vorteil: exit status 9\n"}, 0, false, false},
+	}
+
+	for _, test := range tests {
+
+		c := new(Console)
+		for _, w := range test.writes {
+			c.Write([]byte(w))
+		}
+		c.flush()
+
+		if c.code != test.code || c.exited != test.exited || c.panicked != test.panicked {
+			t.Errorf("%s: got code %d, exited %v, panicked %v; expected %d, %v, %v", test.name,
+				c.code, c.exited, c.panicked, test.code, test.exited, test.panicked)
+		}
+
+	}
+
+}
+
+func TestScanConsole(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "vcli-console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "serial.log")
+	err = ioutil.WriteFile(path, []byte("booting\r\nvorteil: exit status 7"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ScanConsole(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{Hypervisor: shared.QEMU}
+	err = cfg.Status(nil, c)
+	if e, ok := err.(*ExitError); !ok || e.Code != 7 {
+		t.Errorf("got %v, expected exit status 7", err)
+	}
+
+}
+
+func TestStatus(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		console string
+		code    int
+		panic   bool
+	}{
+		{"clean", "vorteil: exit status 0\n", 0, false},
+		{"failed", "vorteil: exit status 12\n", 12, false},
+		{"panic", "Kernel panic - not syncing\n", PanicExitCode, true},
+		{"panic after status", "vorteil: exit status 0\nKernel panic - not syncing\n", PanicExitCode, true},
+		{"unknown", "hello\n", 0, false},
+	}
+
+	cfg := &Config{Hypervisor: shared.KVM}
+
+	for _, test := range tests {
+
+		c := new(Console)
+		c.Write([]byte(test.console))
+
+		err := cfg.Status(nil, c)
+		if test.code == 0 {
+			if err != nil {
+				t.Errorf("%s: got %v, expected no error", test.name, err)
+			}
+			continue
+		}
+
+		e, ok := err.(*ExitError)
+		if !ok || e.Code != test.code || e.Panic != test.panic {
+			t.Errorf("%s: got %#v, expected code %d and panic %v", test.name, err, test.code, test.panic)
+		}
+
+	}
+
+}
+
+func TestDebugExitStatus(t *testing.T) {
+
+	tests := []struct {
+		code   int
+		status int
+		ok     bool
+	}{
+		{0, 0, false},
+		{1, 0, false},
+		{2, 0, false},
+		{3, 1, true},
+		{7, 3, true},
+		{255, 127, true},
+	}
+
+	for _, test := range tests {
+		status, ok := debugExitStatus(test.code)
+		if status != test.status || ok != test.ok {
+			t.Errorf("%d: got %d, %v; expected %d, %v", test.code, status, ok, test.status, test.ok)
+		}
+	}
+
+}
+
+func exitState(t *testing.T, code int) *os.ProcessState {
+
+	cmd := exec.Command("sh", "-c", "exit "+strconv.Itoa(code))
+	cmd.Run()
+	if cmd.ProcessState == nil {
+		t.Skip("no shell to run")
+	}
+
+	return cmd.ProcessState
+
+}
+
+func TestStatusFromHypervisor(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		hypervisor string
+		code       int
+		console    string
+		guest      int
+		failed     bool
+	}{
+		{"clean", shared.QEMU, 0, "", 0, false},
+		{"guest status", shared.KVM, 7, "", 3, false},
+		{"qemu failed", shared.QEMU, 1, "", 0, true},
+		{"qemu even status", shared.QEMU, 2, "", 0, true},
+		{"clean with marker", shared.QEMU, 1, "vorteil: exit status 0\n", 0, false},
+		{"virtualbox failed", shared.VirtualBox, 1, "", 0, true},
+	}
+
+	for _, test := range tests {
+
+		cfg := &Config{Hypervisor: test.hypervisor}
+		c := new(Console)
+		c.Write([]byte(test.console))
+
+		err := cfg.Status(exitState(t, test.code), c)
+		e, guest := err.(*ExitError)
+
+		switch {
+		case test.failed:
+			if err == nil || guest {
+				t.Errorf("%s: got %v, expected a hypervisor failure", test.name, err)
+			}
+		case test.guest != 0:
+			if !guest || e.Code != test.guest {
+				t.Errorf("%s: got %v, expected guest status %d", test.name, err, test.guest)
+			}
+		default:
+			if err != nil {
+				t.Errorf("%s: got %v, expected no error", test.name, err)
+			}
+		}
+
+	}
+
+}
@@ -0,0 +1,280 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"time"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+// ShutdownTimeout is how long a guest is given to shut down cleanly before its
+// virtual machine is powered off.
+const ShutdownTimeout = 10 * time.Second
+
+// Instance is a virtual machine running headless in the background and
+// supervised by vcli, for use wherever no user interaction is needed.
+type Instance struct {
+	Config *Config
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	done   chan struct{}
+	err    error
+	status error
+}
+
+// Start launches the virtual machine described by cfg without a GUI. The
+// guest's serial console is always captured to cfg.SerialLog, which is created
+// as a temporary file if it was left empty.
+func Start(cfg *Config) (*Instance, error) {
+
+	cfg.Headless = true
+	cfg.Foreground = true
+
+	if cfg.SerialLog == "" {
+		f, err := ioutil.TempFile("", "serial-")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		cfg.SerialLog = f.Name()
+	}
+
+	executable, args, err := cfg.Command()
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &Instance{
+		Config: cfg,
+		done:   make(chan struct{}),
+	}
+
+	inst.cmd = exec.Command(executable, args...)
+	if cfg.Detach {
+		// nothing will be left to read stderr once vcli exits
+		SetProcessGroup(inst.cmd)
+	} else {
+		inst.cmd.Stderr = &inst.stderr
+	}
+
+	err = inst.cmd.Start()
+	if err != nil {
+		cfg.Teardown()
+		return nil, err
+	}
+
+	go inst.supervise()
+
+	return inst, nil
+
+}
+
+func (inst *Instance) supervise() {
+
+	inst.err = inst.cmd.Wait()
+
+	// VMware and VirtualBox return as soon as the machine has been started,
+	// so keep polling until the hypervisor reports it has stopped.
+	if inst.err == nil {
+		for inst.Config.running() {
+			time.Sleep(time.Second)
+		}
+	}
+
+	console, err := ScanConsole(inst.Config.SerialLog)
+	if err != nil {
+		console = nil
+	}
+
+	inst.status = inst.Config.Status(inst.cmd.ProcessState, console)
+
+	close(inst.done)
+
+}
+
+// Done returns a channel that is closed once the virtual machine has stopped.
+func (inst *Instance) Done() <-chan struct{} {
+
+	return inst.done
+
+}
+
+// Wait blocks until the virtual machine has stopped and returns any error
+// reported by the hypervisor process.
+func (inst *Instance) Wait() error {
+
+	<-inst.done
+	return inst.err
+
+}
+
+// Status returns an *ExitError if the guest exited with a non-zero status or
+// its kernel panicked, or another error if the hypervisor failed, once the
+// virtual machine has stopped.
+func (inst *Instance) Status() error {
+
+	<-inst.done
+	return inst.status
+
+}
+
+// ExitCode returns the guest's exit status, or -1 if it is still running or
+// the hypervisor failed.
+func (inst *Instance) ExitCode() int {
+
+	select {
+	case <-inst.done:
+	default:
+		return -1
+	}
+
+	if e, ok := inst.status.(*ExitError); ok {
+		return e.Code
+	}
+
+	if inst.status != nil {
+		return -1
+	}
+
+	return 0
+
+}
+
+// Stderr returns everything the hypervisor process has written to stderr.
+func (inst *Instance) Stderr() string {
+
+	return inst.stderr.String()
+
+}
+
+// Stop forcibly powers off the virtual machine if it is still running and
+// cleans up anything registered with the hypervisor.
+func (inst *Instance) Stop() error {
+
+	select {
+	case <-inst.done:
+	default:
+		inst.Config.powerOff(inst.cmd)
+	}
+
+	<-inst.done
+
+	inst.Config.Teardown()
+
+	return nil
+
+}
+
+// Shutdown asks the guest to shut down cleanly and waits for it, powering the
+// virtual machine off if it hasn't stopped within ShutdownTimeout. It then
+// cleans up anything registered with the hypervisor.
+func (inst *Instance) Shutdown() error {
+
+	inst.Config.Stop(inst.cmd, inst.done)
+
+	inst.Config.Teardown()
+
+	return nil
+
+}
+
+// Stop shuts down a virtual machine started from cmd, which is expected to
+// close done once the machine has stopped. The guest is asked to shut down
+// cleanly first, and powered off if it hasn't within ShutdownTimeout.
+func (cfg *Config) Stop(cmd *exec.Cmd, done <-chan struct{}) {
+
+	select {
+	case <-done:
+		return
+	default:
+	}
+
+	if cfg.Shutdown() == nil {
+		select {
+		case <-done:
+			return
+		case <-time.After(ShutdownTimeout):
+		}
+	}
+
+	cfg.powerOff(cmd)
+
+	<-done
+
+}
+
+// Shutdown presses the virtual machine's ACPI power button, asking the guest
+// to shut down. It returns an error if the hypervisor has no way to do so.
+func (cfg *Config) Shutdown() error {
+
+	switch cfg.Hypervisor {
+	case shared.VirtualBox:
+		return run("VBoxManage", "controlvm", cfg.Name, "acpipowerbutton")
+	case shared.QEMU, shared.KVM, shared.KVMClassic:
+		q, err := DialQMP(cfg.Monitor)
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+		return q.Powerdown()
+	}
+
+	return errors.New("clean shutdown not supported by " + cfg.Hypervisor)
+
+}
+
+// Teardown removes anything the hypervisor registered for the virtual machine.
+func (cfg *Config) Teardown() {
+
+	switch cfg.Hypervisor {
+	case shared.VirtualBox:
+		cfg.virtualBoxTeardown()
+	case shared.VMwarePlayer, shared.VMwareWorkstation, shared.VMwareTest:
+		cfg.vmwareTeardown()
+	}
+
+}
+
+func (cfg *Config) powerOff(cmd *exec.Cmd) {
+
+	switch cfg.Hypervisor {
+	case shared.VirtualBox:
+		exec.Command("VBoxManage", "controlvm", cfg.Name, "poweroff").Run()
+	case shared.VMwarePlayer, shared.VMwareWorkstation, shared.VMwareTest:
+		exec.Command("vmrun", "stop", cfg.VMX, "hard").Run()
+	default:
+		if cmd != nil && cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+	}
+
+}
+
+func (cfg *Config) running() bool {
+
+	switch cfg.Hypervisor {
+	case shared.VirtualBox:
+		return cfg.virtualBoxRunning()
+	case shared.VMwarePlayer, shared.VMwareWorkstation, shared.VMwareTest:
+		return cfg.vmwareRunning()
+	}
+
+	return false
+
+}
@@ -0,0 +1,295 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sisatech/vcli/home"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	networkExtension = ".yaml"
+	multicastGroup   = "230.0.0.1"
+	multicastBase    = 20000
+	multicastRange   = 10000
+
+	// VMware numbers its networks vmnet0 to vmnet19, and reserves vmnet0,
+	// vmnet1 and vmnet8 for its bridged, host-only and NAT networks.
+	vmnetCount = 20
+)
+
+var networkName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Network is a private network shared by local virtual machines. Each
+// hypervisor implements it differently: QEMU and KVM join a multicast socket,
+// VirtualBox an internal network and VMware an existing host-only vmnet.
+type Network struct {
+	Name      string    `yaml:"name"`
+	Multicast string    `yaml:"multicast"`
+	Internal  string    `yaml:"virtualbox-intnet"`
+	VMnet     string    `yaml:"vmware-vmnet"`
+	Created   time.Time `yaml:"created"`
+}
+
+func networkPath(name string) string {
+
+	return filepath.Join(home.Path(home.Networks), name+networkExtension)
+
+}
+
+// CreateNetwork defines a new network and saves it in the vcli home
+// directory. Each network needs a VMware vmnet of its own to stay separate
+// from the others, so if vmnet is empty the first one no other network uses
+// is picked.
+func CreateNetwork(name, vmnet string) (*Network, error) {
+
+	if !networkName.MatchString(name) {
+		return nil, fmt.Errorf("invalid network name '%v'", name)
+	}
+
+	if _, err := os.Stat(networkPath(name)); err == nil {
+		return nil, fmt.Errorf("network '%v' already exists", name)
+	}
+
+	existing, err := ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	vmnets := make(map[string]string)
+	for _, n := range existing {
+		used[n.Multicast] = true
+		vmnets[n.VMnet] = n.Name
+	}
+
+	if vmnet == "" {
+		vmnet, err = freeVMnet(vmnets)
+		if err != nil {
+			return nil, err
+		}
+	} else if other, ok := vmnets[vmnet]; ok {
+		return nil, fmt.Errorf("%v is already used by network '%v'", vmnet, other)
+	}
+
+	// derive the port from the name so networks keep the same address if
+	// they are recreated, stepping past any already taken
+	offset := int(crc32.ChecksumIEEE([]byte(name)) % multicastRange)
+	var addr string
+	for i := 0; i < multicastRange; i++ {
+		addr = fmt.Sprintf("%s:%d", multicastGroup, multicastBase+(offset+i)%multicastRange)
+		if !used[addr] {
+			break
+		}
+	}
+
+	n := &Network{
+		Name:      name,
+		Multicast: addr,
+		Internal:  "vcli-" + name,
+		VMnet:     vmnet,
+		Created:   time.Now(),
+	}
+
+	data, err := yaml.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(networkPath(name), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return n, nil
+
+}
+
+// freeVMnet returns the first vmnet that VMware doesn't reserve and that isn't
+// in used.
+func freeVMnet(used map[string]string) (string, error) {
+
+	for i := 2; i < vmnetCount; i++ {
+
+		if i == 8 {
+			continue
+		}
+
+		vmnet := fmt.Sprintf("vmnet%d", i)
+		if _, ok := used[vmnet]; !ok {
+			return vmnet, nil
+		}
+
+	}
+
+	return "", errors.New("every VMware vmnet is already used by a network")
+
+}
+
+// LoadNetwork returns the network with the given name.
+func LoadNetwork(name string) (*Network, error) {
+
+	data, err := ioutil.ReadFile(networkPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("network '%v' not found; try 'vcli network create %v'", name, name)
+		}
+		return nil, err
+	}
+
+	n := new(Network)
+	err = yaml.Unmarshal(data, n)
+	if err != nil {
+		return nil, fmt.Errorf("network '%v' is corrupt: %v", name, err)
+	}
+
+	return n, nil
+
+}
+
+// ListNetworks returns all networks defined in the vcli home directory.
+func ListNetworks() ([]*Network, error) {
+
+	ls, err := ioutil.ReadDir(home.Path(home.Networks))
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []*Network
+	for _, fi := range ls {
+
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), networkExtension) {
+			continue
+		}
+
+		n, err := LoadNetwork(strings.TrimSuffix(fi.Name(), networkExtension))
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, n)
+
+	}
+
+	return networks, nil
+
+}
+
+// ListNetworkNames returns the names of all networks, for command line
+// completion.
+func ListNetworkNames() []string {
+
+	var names []string
+
+	networks, err := ListNetworks()
+	if err != nil {
+		return names
+	}
+
+	for _, n := range networks {
+		names = append(names, n.Name)
+	}
+
+	return names
+
+}
+
+// RemoveNetwork deletes a network definition. Machines already attached to it
+// are unaffected until they are restarted.
+func RemoveNetwork(name string) error {
+
+	err := os.Remove(networkPath(name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("network '%v' not found", name)
+	}
+
+	return err
+
+}
+
+// ParseNetworkFlag parses a "[card:]name" network attachment. Without a card
+// number the next card after the previous attachment is used.
+func ParseNetworkFlag(s string, next int) (int, string, error) {
+
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return next, s, nil
+	}
+
+	card, err := strconv.Atoi(s[:i])
+	if err != nil || card < 0 {
+		return 0, "", errors.New("invalid network attachment '" + s + "'; expected '[card:]name'")
+	}
+
+	return card, s[i+1:], nil
+
+}
+
+// randomMAC returns a locally administered unicast MAC address, so machines
+// sharing a network don't collide.
+func randomMAC() string {
+
+	b := make([]byte, 6)
+	rand.Read(b)
+	b[0] = (b[0] | 0x02) &^ 0x01
+
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", b[0], b[1], b[2], b[3], b[4], b[5])
+
+}
+
+// AttachNetworks connects network cards to the networks named in a list of
+// "[card:]name" attachments.
+func (cfg *Config) AttachNetworks(attachments []string) error {
+
+	next := 0
+	for _, a := range attachments {
+
+		card, name, err := ParseNetworkFlag(a, next)
+		if err != nil {
+			return err
+		}
+
+		if card >= cfg.NetworkCards {
+			return fmt.Errorf("cannot attach network '%v' to card %d: app only has %d network cards", name, card, cfg.NetworkCards)
+		}
+
+		n, err := LoadNetwork(name)
+		if err != nil {
+			return err
+		}
+
+		if cfg.Networks == nil {
+			cfg.Networks = make(map[int]*Network)
+		}
+
+		cfg.Networks[card] = n
+		next = card + 1
+
+	}
+
+	return nil
+
+}
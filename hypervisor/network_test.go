@@ -0,0 +1,39 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFreeVMnet(t *testing.T) {
+
+	used := map[string]string{"vmnet2": "a", "vmnet3": "b", "vmnet4": "c",
+		"vmnet5": "d", "vmnet6": "e", "vmnet7": "f"}
+
+	vmnet, err := freeVMnet(used)
+	if err != nil || vmnet != "vmnet9" {
+		t.Errorf("got %v (%v), expected vmnet9 since vmnet8 is VMware's NAT", vmnet, err)
+	}
+
+	for i := 9; i < vmnetCount; i++ {
+		used[fmt.Sprintf("vmnet%d", i)] = "x"
+	}
+
+	if _, err := freeVMnet(used); err == nil {
+		t.Errorf("expected an error once every vmnet is used")
+	}
+
+}
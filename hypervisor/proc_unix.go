@@ -0,0 +1,36 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !windows
+
+package hypervisor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// SetProcessGroup starts the process in its own process group, so it isn't killed
+// along with vcli by an interrupt from the terminal.
+func SetProcessGroup(cmd *exec.Cmd) {
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+}
+
+func processAlive(pid int) bool {
+
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+
+}
@@ -0,0 +1,247 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+const macStart = 0xa
+
+func (cfg *Config) qemuArgs() []string {
+
+	if cfg.Hypervisor == shared.QEMU {
+		return cfg.paramsQemu()
+	}
+
+	return cfg.paramsKVM()
+
+}
+
+func (cfg *Config) headlessArgs() []string {
+
+	args := []string{"-nographic", "-display", "none"}
+	if !cfg.Foreground {
+		args = append(args, "-daemonize")
+	}
+
+	return args
+
+}
+
+// serialArgs serves the serial port on the console socket, so it can be
+// attached to while QEMU also logs it to cfg.SerialLog. Machines run in the
+// foreground wait for vcli to connect so nothing printed at boot is missed.
+func (cfg *Config) serialArgs() []string {
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(cfg.Console, "tcp:"))
+
+	chardev := "socket,id=serial0,host=" + host + ",port=" + port + ",server"
+	if cfg.Headless {
+		chardev += ",nowait"
+	}
+	if cfg.SerialLog != "" {
+		chardev += ",logfile=" + cfg.SerialLog
+	}
+
+	return []string{"-chardev", chardev, "-serial", "chardev:serial0"}
+
+}
+
+func (cfg *Config) debugArgs() []string {
+
+	var args []string
+
+	if cfg.GDB != "" {
+		host, port, _ := GDBAddress(cfg.GDB)
+		args = append(args, "-gdb", "tcp:"+host+":"+port)
+		if cfg.GDBPause {
+			args = append(args, "-S")
+		}
+	} else if cfg.Debug {
+		args = append(args, "-s")
+	}
+
+	return args
+
+}
+
+// drive returns the image QEMU boots from and its format.
+func (cfg *Config) drive() (string, string) {
+
+	if cfg.Overlay != "" {
+		return cfg.Overlay, "qcow2"
+	}
+
+	return cfg.Disk, "vmdk"
+
+}
+
+func (cfg *Config) monitorArgs() []string {
+
+	var args []string
+
+	if cfg.Monitor != "" {
+		args = append(args, "-qmp", "tcp:"+cfg.Monitor+",server,nowait")
+	}
+
+	if cfg.PIDFile != "" {
+		args = append(args, "-pidfile", cfg.PIDFile)
+	}
+
+	return args
+
+}
+
+// diskArgs attaches the boot disk on the bus the app asks for, or on bus if
+// it doesn't ask for one.
+func (cfg *Config) diskArgs(bus string) []string {
+
+	if cfg.DiskBus != "" {
+		bus = cfg.DiskBus
+	}
+
+	file, format := cfg.drive()
+	drive := "if=none,file=" + file + ",format=" + format + ",id=hd0"
+
+	if bus == shared.DiskBusSCSI {
+		return []string{"-device", "virtio-scsi-pci,id=scsi", "-device", "scsi-hd,drive=hd0", "-drive", drive}
+	}
+
+	// AHCI SATA drive
+	return []string{"-device", "ahci,id=ahci0", "-device", "ide-drive,bus=ahci0.0,drive=hd0,id=sata0-0-0", "-drive", drive}
+
+}
+
+// nicArgs adds the app's network cards using the model it asks for, or model
+// if it doesn't ask for one.
+func (cfg *Config) nicArgs(model string) []string {
+
+	if cfg.NICModel != "" {
+		model = cfg.NICModel
+	}
+
+	var args []string
+	for i := 0; i < cfg.NetworkCards; i++ {
+
+		istr := strconv.Itoa(i)
+		netdev, mac := cfg.netdev(i)
+
+		device := "e1000,netdev=network" + istr + ",mac=" + mac
+		if model == shared.NICModelVirtio {
+			device = "virtio-net-pci,netdev=network" + istr + ",id=virtio" + istr + ",mac=" + mac
+		}
+
+		args = append(args, "-netdev", netdev, "-device", device)
+
+		if cfg.PCAP != "" {
+			args = append(args, "-object", "filter-dump,id=dump"+istr+",netdev=network"+istr+",file="+cfg.PCAPFile(i))
+		}
+
+	}
+
+	return args
+
+}
+
+// netdev returns the backend and MAC address for a network card. Cards on a
+// private network get a random MAC so they don't clash with other machines
+// on the same network.
+func (cfg *Config) netdev(card int) (string, string) {
+
+	id := "id=network" + strconv.Itoa(card)
+
+	if n, ok := cfg.Networks[card]; ok {
+		return "socket," + id + ",mcast=" + n.Multicast, randomMAC()
+	}
+
+	return "user," + id + cfg.hostForwards(card), fmt.Sprintf("26:10:05:00:00:0%x", macStart+card)
+
+}
+
+// exitArgs adds the device the kernel uses to hand its shutdown status back
+// to QEMU.
+func (cfg *Config) exitArgs() []string {
+
+	return []string{"-device", "isa-debug-exit,iobase=" + debugExitPort + ",iosize=0x04"}
+
+}
+
+func (cfg *Config) paramsKVM() []string {
+
+	cores := strconv.FormatUint(uint64(cfg.CPUs), 10)
+	memory := strconv.FormatUint(uint64(cfg.Memory), 10)
+
+	args := []string{"-cpu", "host", "-no-reboot"}
+	args = append(args, "-machine", "q35", "-smp", cores, "-m", memory, "-enable-kvm")
+
+	//Start as headless
+	if cfg.Headless {
+		args = append(args, cfg.headlessArgs()...)
+	}
+
+	args = append(args, cfg.debugArgs()...)
+	args = append(args, cfg.monitorArgs()...)
+
+	if cfg.Hypervisor == shared.KVM {
+		// KVM uses virtio scsi and virtio net pci unless told otherwise
+		args = append(args, cfg.diskArgs(shared.DiskBusSCSI)...)
+	} else {
+		args = append(args, cfg.diskArgs(shared.DiskBusSATA)...)
+	}
+
+	args = append(args, cfg.serialArgs()...)
+	args = append(args, cfg.exitArgs()...)
+
+	if cfg.Hypervisor == shared.KVM {
+		args = append(args, cfg.nicArgs(shared.NICModelVirtio)...)
+	} else {
+		args = append(args, cfg.nicArgs(shared.NICModelE1000)...)
+	}
+
+	return args
+
+}
+
+func (cfg *Config) paramsQemu() []string {
+
+	cores := strconv.FormatUint(uint64(cfg.CPUs), 10)
+	memory := strconv.FormatUint(uint64(cfg.Memory), 10)
+
+	args := []string{"-cpu", "qemu64,+rdtscp,+fsgsbase,+ssse3,+sse4.1,+sse4.2,+x2apic,+invtsc", "-no-reboot"}
+	args = append(args, "-machine", "q35", "-smp", cores, "-m", memory)
+	args = append(args, cfg.diskArgs(shared.DiskBusSATA)...)
+	args = append(args, cfg.serialArgs()...)
+	args = append(args, cfg.exitArgs()...)
+	args = append(args, cfg.nicArgs(shared.NICModelE1000)...)
+
+	if len(cfg.DebugFile) > 0 {
+		args = append(args, "-d", "int,guest_errors,cpu,in_asm,exec", "-D", cfg.DebugFile)
+	}
+
+	args = append(args, cfg.debugArgs()...)
+	args = append(args, cfg.monitorArgs()...)
+
+	if cfg.Headless {
+		args = append(args, cfg.headlessArgs()...)
+	}
+
+	return args
+
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+func TestSerialArgs(t *testing.T) {
+
+	tests := []struct {
+		cfg     Config
+		chardev string
+	}{
+		{Config{Console: "tcp:127.0.0.1:4000"},
+			"socket,id=serial0,host=127.0.0.1,port=4000,server"},
+		{Config{Console: "tcp:127.0.0.1:4000", Headless: true},
+			"socket,id=serial0,host=127.0.0.1,port=4000,server,nowait"},
+		{Config{Console: "tcp:localhost:4000", Headless: true, SerialLog: "/tmp/serial.log"},
+			"socket,id=serial0,host=localhost,port=4000,server,nowait,logfile=/tmp/serial.log"},
+	}
+
+	for _, test := range tests {
+
+		expected := []string{"-chardev", test.chardev, "-serial", "chardev:serial0"}
+		args := test.cfg.serialArgs()
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("got %q, expected %q", args, expected)
+		}
+
+	}
+
+}
+
+func TestNICArgs(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		model    string
+		expected []string
+	}{
+		{"none", Config{}, shared.NICModelE1000, nil},
+		{"e1000", Config{NetworkCards: 1}, shared.NICModelE1000, []string{
+			"-netdev", "user,id=network0",
+			"-device", "e1000,netdev=network0,mac=26:10:05:00:00:0a",
+		}},
+		{"virtio", Config{NetworkCards: 1}, shared.NICModelVirtio, []string{
+			"-netdev", "user,id=network0",
+			"-device", "virtio-net-pci,netdev=network0,id=virtio0,mac=26:10:05:00:00:0a",
+		}},
+		{"app model", Config{NetworkCards: 1, NICModel: shared.NICModelE1000}, shared.NICModelVirtio, []string{
+			"-netdev", "user,id=network0",
+			"-device", "e1000,netdev=network0,mac=26:10:05:00:00:0a",
+		}},
+		{"port map", Config{NetworkCards: 2, PortMap: "1:8080:80"}, shared.NICModelE1000, []string{
+			"-netdev", "user,id=network0",
+			"-device", "e1000,netdev=network0,mac=26:10:05:00:00:0a",
+			"-netdev", "user,id=network1,hostfwd=tcp::8080-:80,hostfwd=udp::8080-:80",
+			"-device", "e1000,netdev=network1,mac=26:10:05:00:00:0b",
+		}},
+		{"capture", Config{NetworkCards: 2, PCAP: "out.pcap"}, shared.NICModelE1000, []string{
+			"-netdev", "user,id=network0",
+			"-device", "e1000,netdev=network0,mac=26:10:05:00:00:0a",
+			"-object", "filter-dump,id=dump0,netdev=network0,file=out.pcap",
+			"-netdev", "user,id=network1",
+			"-device", "e1000,netdev=network1,mac=26:10:05:00:00:0b",
+			"-object", "filter-dump,id=dump1,netdev=network1,file=out-1.pcap",
+		}},
+	}
+
+	for _, test := range tests {
+		args := test.cfg.nicArgs(test.model)
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%s: got %q, expected %q", test.name, args, test.expected)
+		}
+	}
+
+}
+
+func TestNetdevPrivateNetwork(t *testing.T) {
+
+	cfg := &Config{
+		NetworkCards: 2,
+		Networks:     map[int]*Network{1: {Multicast: "230.0.0.1:5000"}},
+	}
+
+	netdev, mac := cfg.netdev(1)
+	if netdev != "socket,id=network1,mcast=230.0.0.1:5000" {
+		t.Errorf("got netdev %q", netdev)
+	}
+
+	// locally administered and unicast
+	if !regexp.MustCompile(`^[0-9a-f][26ae](:[0-9a-f]{2}){5}$`).MatchString(mac) {
+		t.Errorf("got MAC %q, expected a random locally administered address", mac)
+	}
+
+	netdev, _ = cfg.netdev(0)
+	if netdev != "user,id=network0" {
+		t.Errorf("got netdev %q for a card without a network", netdev)
+	}
+
+}
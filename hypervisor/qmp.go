@@ -0,0 +1,247 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const qmpTimeout = 30 * time.Second
+
+// QMP is a connection to the QEMU Machine Protocol monitor of a running QEMU
+// virtual machine. QEMU only serves one client at a time, so connections
+// should be closed as soon as they are no longer needed.
+type QMP struct {
+	conn net.Conn
+	dec  *json.Decoder
+}
+
+type qmpResponse struct {
+	Return json.RawMessage `json:"return"`
+	Event  string          `json:"event"`
+	Error  *struct {
+		Class string `json:"class"`
+		Desc  string `json:"desc"`
+	} `json:"error"`
+}
+
+// freeAddress returns a local TCP address nothing is listening on.
+func freeAddress() (string, error) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+
+	return l.Addr().String(), nil
+
+}
+
+// DialQMP connects to the QMP monitor listening on addr and negotiates
+// command mode.
+func DialQMP(addr string) (*QMP, error) {
+
+	if addr == "" {
+		return nil, errors.New("virtual machine has no QMP monitor")
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to QMP monitor: %v", err)
+	}
+
+	q := &QMP{
+		conn: conn,
+		dec:  json.NewDecoder(conn),
+	}
+
+	// QEMU greets every client before accepting commands
+	conn.SetDeadline(time.Now().Add(qmpTimeout))
+	var greeting map[string]interface{}
+	err = q.dec.Decode(&greeting)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading QMP greeting: %v", err)
+	}
+
+	_, err = q.Execute("qmp_capabilities", nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return q, nil
+
+}
+
+// Execute runs a QMP command and returns its result, skipping any events QEMU
+// sends in the meantime.
+func (q *QMP) Execute(command string, args interface{}) (json.RawMessage, error) {
+
+	req := map[string]interface{}{"execute": command}
+	if args != nil {
+		req["arguments"] = args
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	q.conn.SetDeadline(time.Now().Add(qmpTimeout))
+
+	_, err = q.conn.Write(append(data, '\n'))
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+
+		resp := new(qmpResponse)
+		err = q.dec.Decode(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.Event != "" {
+			continue
+		}
+
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%v: %v", command, resp.Error.Desc)
+		}
+
+		return resp.Return, nil
+
+	}
+
+}
+
+// HMP runs a human monitor command and returns its output.
+func (q *QMP) HMP(line string) (string, error) {
+
+	ret, err := q.Execute("human-monitor-command", map[string]string{"command-line": line})
+	if err != nil {
+		return "", err
+	}
+
+	var out string
+	err = json.Unmarshal(ret, &out)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out), nil
+
+}
+
+// hmp runs a human monitor command that only prints anything when it fails.
+func (q *QMP) hmp(line string) error {
+
+	out, err := q.HMP(line)
+	if err != nil {
+		return err
+	}
+
+	if out != "" {
+		return errors.New(out)
+	}
+
+	return nil
+
+}
+
+// Powerdown presses the virtual machine's ACPI power button, asking the guest
+// to shut down cleanly.
+func (q *QMP) Powerdown() error {
+
+	_, err := q.Execute("system_powerdown", nil)
+	return err
+
+}
+
+// Pause stops the virtual machine's CPUs.
+func (q *QMP) Pause() error {
+
+	_, err := q.Execute("stop", nil)
+	return err
+
+}
+
+// Resume restarts the virtual machine's CPUs after Pause.
+func (q *QMP) Resume() error {
+
+	_, err := q.Execute("cont", nil)
+	return err
+
+}
+
+// Status returns QEMU's run state for the virtual machine, such as "running"
+// or "paused".
+func (q *QMP) Status() (string, error) {
+
+	ret, err := q.Execute("query-status", nil)
+	if err != nil {
+		return "", err
+	}
+
+	status := new(struct {
+		Status string `json:"status"`
+	})
+
+	err = json.Unmarshal(ret, status)
+	if err != nil {
+		return "", err
+	}
+
+	return status.Status, nil
+
+}
+
+// AddHostForward forwards a host port to a guest port on a network card using
+// QEMU's user-mode networking, while the machine is running.
+func (q *QMP) AddHostForward(card int, protocol string, host, guest int) error {
+
+	return q.hmp(fmt.Sprintf("hostfwd_add network%d %s::%d-:%d", card, protocol, host, guest))
+
+}
+
+// RemoveHostForward removes a forward added with AddHostForward or at boot.
+func (q *QMP) RemoveHostForward(card int, protocol string, host int) error {
+
+	out, err := q.HMP(fmt.Sprintf("hostfwd_remove network%d %s::%d", card, protocol, host))
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(out, "removed") {
+		return errors.New(out)
+	}
+
+	return nil
+
+}
+
+// Close closes the connection to the monitor.
+func (q *QMP) Close() error {
+
+	return q.conn.Close()
+
+}
@@ -0,0 +1,379 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sisatech/vcli/home"
+	"gopkg.in/yaml.v2"
+)
+
+const recordExtension = ".yaml"
+
+// Record describes a virtual machine started by vcli that outlives the
+// command that started it, so later commands can find and manage it.
+type Record struct {
+	Name       string    `yaml:"name"`
+	Project    string    `yaml:"project,omitempty"`
+	Service    string    `yaml:"service,omitempty"`
+	Hypervisor string    `yaml:"hypervisor"`
+	PID        int       `yaml:"pid"`
+	VMName     string    `yaml:"vm-name"`
+	VMX        string    `yaml:"vmx,omitempty"`
+	Disk       string    `yaml:"disk"`
+	KeepDisk   bool      `yaml:"keep-disk,omitempty"`
+	Overlay    string    `yaml:"overlay,omitempty"`
+	SerialLog  string    `yaml:"serial-log"`
+	Console    string    `yaml:"console,omitempty"`
+	PortMap    string    `yaml:"port-map,omitempty"`
+	Networks   []string  `yaml:"networks,omitempty"`
+	PCAPs      []string  `yaml:"pcaps,omitempty"`
+	Headless   bool      `yaml:"headless,omitempty"`
+	Monitor    string    `yaml:"monitor,omitempty"`
+	PIDFile    string    `yaml:"pid-file,omitempty"`
+	Started    time.Time `yaml:"started"`
+}
+
+func recordPath(name string) string {
+
+	return filepath.Join(home.Path(home.Instances), name+recordExtension)
+
+}
+
+// UniqueName returns base, or base with a numeric suffix if a record with
+// that name already exists.
+func UniqueName(base string) string {
+
+	name := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(recordPath(name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+
+}
+
+// Track prepares the virtual machine to be managed through the registry under
+// the given name once it is running. For QEMU this writes a pid file and,
+// unless the disk is being persisted, layers a QCOW2 overlay over the disk so
+// snapshots can be taken. Snapshots are optional, so without qemu-img the disk
+// is used directly. Other hypervisors need nothing.
+func (cfg *Config) Track(name string) error {
+
+	if !cfg.isQEMU() {
+		return nil
+	}
+
+	cfg.PIDFile = filepath.Join(home.Path(home.Instances), name+".pid")
+
+	if cfg.Persist != "" {
+		return nil
+	}
+
+	if _, err := exec.LookPath("qemu-img"); err != nil {
+		return nil
+	}
+
+	base, err := filepath.Abs(cfg.Disk)
+	if err != nil {
+		return err
+	}
+
+	overlay := filepath.Join(home.Path(home.Instances), name+".qcow2")
+	out, err := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", "vmdk", "-b", base, overlay).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("creating disk overlay: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	cfg.Overlay = overlay
+
+	return nil
+
+}
+
+// Record returns a registry record for the virtual machine under the given
+// name.
+func (cfg *Config) Record(name string) *Record {
+
+	r := &Record{
+		Name:       name,
+		Hypervisor: cfg.Hypervisor,
+		VMName:     cfg.Name,
+		VMX:        cfg.VMX,
+		Disk:       cfg.Disk,
+		Overlay:    cfg.Overlay,
+		SerialLog:  cfg.SerialLog,
+		Console:    cfg.Console,
+		PortMap:    cfg.PortMap,
+		Headless:   cfg.Headless,
+		Monitor:    cfg.Monitor,
+		PIDFile:    cfg.PIDFile,
+		Started:    time.Now(),
+	}
+
+	for card, n := range cfg.Networks {
+		r.Networks = append(r.Networks, fmt.Sprintf("%d:%s", card, n.Name))
+	}
+
+	r.PCAPs = cfg.PCAPFiles()
+
+	return r
+
+}
+
+// Record returns a registry record for the instance under the given name.
+func (inst *Instance) Record(name string) *Record {
+
+	r := inst.Config.Record(name)
+
+	if inst.cmd.Process != nil {
+		r.PID = inst.cmd.Process.Pid
+	}
+
+	return r
+
+}
+
+// Save writes the record to the vcli home directory.
+func (r *Record) Save() error {
+
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(recordPath(r.Name), data, 0644)
+
+}
+
+// LoadRecord returns the record with the given name.
+func LoadRecord(name string) (*Record, error) {
+
+	data, err := ioutil.ReadFile(recordPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("instance '%v' not found", name)
+		}
+		return nil, err
+	}
+
+	r := new(Record)
+	err = yaml.Unmarshal(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("instance record '%v' is corrupt: %v", name, err)
+	}
+
+	return r, nil
+
+}
+
+// ListRecords returns every record in the vcli home directory.
+func ListRecords() ([]*Record, error) {
+
+	ls, err := ioutil.ReadDir(home.Path(home.Instances))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for _, fi := range ls {
+
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), recordExtension) {
+			continue
+		}
+
+		r, err := LoadRecord(strings.TrimSuffix(fi.Name(), recordExtension))
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+
+	}
+
+	return records, nil
+
+}
+
+// ListInstanceNames returns the names of every record in the vcli home
+// directory.
+func ListInstanceNames() []string {
+
+	records, err := ListRecords()
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, r := range records {
+		names = append(names, r.Name)
+	}
+
+	return names
+
+}
+
+// Prune removes the records of standalone virtual machines that have stopped.
+func Prune() {
+
+	records, err := ListRecords()
+	if err != nil {
+		return
+	}
+
+	for _, r := range records {
+		if r.Project == "" && !r.Running() {
+			r.Remove()
+		}
+	}
+
+}
+
+func (r *Record) config() *Config {
+
+	return &Config{
+		Hypervisor: r.Hypervisor,
+		Name:       r.VMName,
+		VMX:        r.VMX,
+		Monitor:    r.Monitor,
+	}
+
+}
+
+// OpenConsole connects to the serial console of the virtual machine, which is
+// followed until it stops.
+func (r *Record) OpenConsole() (io.ReadCloser, error) {
+
+	done := make(chan struct{})
+	go func() {
+		for r.Running() {
+			time.Sleep(time.Second)
+		}
+		close(done)
+	}()
+
+	return OpenConsole(r.Console, done)
+
+}
+
+// Running reports whether the virtual machine is still running.
+func (r *Record) Running() bool {
+
+	cfg := r.config()
+	if cfg.isQEMU() {
+		return processAlive(r.pid())
+	}
+
+	return cfg.running()
+
+}
+
+// QMP connects to the QMP monitor of a QEMU virtual machine.
+func (r *Record) QMP() (*QMP, error) {
+
+	if !r.config().isQEMU() {
+		return nil, fmt.Errorf("instance '%v' is running on %v; this is only supported on QEMU and KVM", r.Name, r.Hypervisor)
+	}
+
+	return DialQMP(r.Monitor)
+
+}
+
+// pid returns the process ID of QEMU, which has to be read from its pid file
+// if QEMU daemonized itself.
+func (r *Record) pid() int {
+
+	if r.PID != 0 || r.PIDFile == "" {
+		return r.PID
+	}
+
+	data, err := ioutil.ReadFile(r.PIDFile)
+	if err != nil {
+		return 0
+	}
+
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+
+	return pid
+
+}
+
+// Stop shuts down the virtual machine, powering it off if it doesn't stop
+// within ShutdownTimeout, removes the files vcli created for it and deletes
+// the record.
+func (r *Record) Stop() error {
+
+	cfg := r.config()
+
+	if r.Running() && cfg.Shutdown() == nil {
+		deadline := time.Now().Add(ShutdownTimeout)
+		for r.Running() && time.Now().Before(deadline) {
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+
+	if cfg.isQEMU() {
+		if pid := r.pid(); processAlive(pid) {
+			p, err := os.FindProcess(pid)
+			if err == nil {
+				p.Kill()
+			}
+		}
+	} else {
+		cfg.powerOff(nil)
+	}
+
+	cfg.Teardown()
+
+	if !r.KeepDisk {
+		os.Remove(r.Disk)
+	}
+	os.Remove(r.SerialLog)
+
+	return r.Remove()
+
+}
+
+// Remove deletes the record along with the overlay, pid file and snapshot
+// metadata created for it, leaving the virtual machine itself alone.
+func (r *Record) Remove() error {
+
+	if r.Overlay != "" {
+		os.Remove(r.Overlay)
+	}
+
+	if r.PIDFile != "" {
+		os.Remove(r.PIDFile)
+	}
+
+	os.Remove(snapshotsPath(r.Name))
+
+	err := os.Remove(recordPath(r.Name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+
+}
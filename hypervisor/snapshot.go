@@ -0,0 +1,213 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/shared"
+	"gopkg.in/yaml.v2"
+)
+
+const snapshotsExtension = ".snapshots"
+
+var snapshotName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Snapshot is a saved state of a virtual machine in the registry.
+type Snapshot struct {
+	Name    string    `yaml:"name"`
+	Created time.Time `yaml:"created"`
+}
+
+func snapshotsPath(name string) string {
+
+	return filepath.Join(home.Path(home.Instances), name+snapshotsExtension)
+
+}
+
+// Snapshots returns the snapshots taken of the virtual machine, oldest first.
+func (r *Record) Snapshots() ([]*Snapshot, error) {
+
+	data, err := ioutil.ReadFile(snapshotsPath(r.Name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*Snapshot
+	err = yaml.Unmarshal(data, &snapshots)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot metadata for '%v' is corrupt: %v", r.Name, err)
+	}
+
+	return snapshots, nil
+
+}
+
+func (r *Record) findSnapshot(name string) (*Snapshot, []*Snapshot, error) {
+
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range snapshots {
+		if s.Name == name {
+			return s, snapshots, nil
+		}
+	}
+
+	return nil, snapshots, nil
+
+}
+
+// SaveSnapshot saves the state of the running virtual machine under name.
+func (r *Record) SaveSnapshot(name string) error {
+
+	if !snapshotName.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name '%v'; use letters, numbers, '.', '_' and '-'", name)
+	}
+
+	s, snapshots, err := r.findSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	if s != nil {
+		return fmt.Errorf("instance '%v' already has a snapshot named '%v'", r.Name, name)
+	}
+
+	if !r.Running() {
+		return fmt.Errorf("instance '%v' is not running", r.Name)
+	}
+
+	switch r.Hypervisor {
+	case shared.QEMU, shared.KVM, shared.KVMClassic:
+		if r.Overlay == "" {
+			return fmt.Errorf("instance '%v' has no disk overlay to snapshot; it was started with a persisted disk or without qemu-img installed", r.Name)
+		}
+		err = r.monitor("savevm " + name)
+	case shared.VirtualBox:
+		err = run("VBoxManage", "snapshot", r.VMName, "take", name, "--live")
+	case shared.VMwarePlayer, shared.VMwareWorkstation, shared.VMwareTest:
+		err = run("vmrun", "snapshot", r.VMX, name)
+	default:
+		err = errors.New("unsupported hypervisor")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	snapshots = append(snapshots, &Snapshot{
+		Name:    name,
+		Created: time.Now(),
+	})
+
+	data, err := yaml.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(snapshotsPath(r.Name), data, 0644)
+
+}
+
+// RestoreSnapshot returns the virtual machine to the state saved under name.
+// VirtualBox can't restore a running machine, so it is powered off and
+// started again.
+func (r *Record) RestoreSnapshot(name string) error {
+
+	s, _, err := r.findSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		return fmt.Errorf("instance '%v' has no snapshot named '%v'", r.Name, name)
+	}
+
+	if !r.Running() {
+		return fmt.Errorf("instance '%v' is not running", r.Name)
+	}
+
+	switch r.Hypervisor {
+	case shared.QEMU, shared.KVM, shared.KVMClassic:
+		return r.monitor("loadvm " + name)
+	case shared.VirtualBox:
+		exec.Command("VBoxManage", "controlvm", r.VMName, "poweroff").Run()
+		err = run("VBoxManage", "snapshot", r.VMName, "restore", name)
+		if err != nil {
+			return err
+		}
+		mode := "gui"
+		if r.Headless {
+			mode = "headless"
+		}
+		return run("VBoxManage", "startvm", r.VMName, "--type", mode)
+	case shared.VMwarePlayer, shared.VMwareWorkstation, shared.VMwareTest:
+		err = run("vmrun", "revertToSnapshot", r.VMX, name)
+		if err != nil {
+			return err
+		}
+		// reverting leaves the machine suspended
+		args := []string{"start", r.VMX}
+		if r.Headless {
+			args = append(args, "nogui")
+		}
+		return run("vmrun", args...)
+	}
+
+	return errors.New("unsupported hypervisor")
+
+}
+
+func (r *Record) monitor(line string) error {
+
+	q, err := r.QMP()
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	return q.hmp(line)
+
+}
+
+// run runs a hypervisor management tool, returning its output as the error
+// if it fails.
+func run(name string, args ...string) error {
+
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return err
+		}
+		return fmt.Errorf("%v: %v", name, msg)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+func (cfg *Config) virtualBoxArgs() ([]string, error) {
+
+	cores := strconv.FormatUint(uint64(cfg.CPUs), 10)
+	memory := strconv.FormatUint(uint64(cfg.Memory), 10)
+	name := cfg.Name
+
+	// delete vm
+	tmpDir := os.TempDir()
+	cfg.Dir = path.Join(tmpDir, "vorteil")
+
+	os.RemoveAll(cfg.Dir)
+
+	cmD := exec.Command("VBoxManage", "controlvm", name, "poweroff")
+	cmD.Run()
+
+	// if image by specified name already exists, remove image before creating new one
+	cmD = exec.Command("VBoxManage", "unregistervm", name)
+	cmD.Run()
+
+	// create vm
+	cmD = exec.Command("vboxmanage", "createvm", "--basefolder", cfg.Dir, "--name", name, "--register")
+	err := cmD.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"modifyvm", name, "--memory", memory, "--acpi", "on", "--ioapic", "on", "--cpus", cores, "--pae", "on"}
+	args = append(args, "--longmode", "on", "--largepages", "on", "--chipset", "ich9", "--bioslogofadein", "off")
+	args = append(args, "--bioslogofadeout", "off", "--bioslogodisplaytime", "1", "--biosbootmenu", "disabled", "--rtcuseutc", "on")
+
+	// VirtualBox can only send the serial port to one place, so it's served
+	// on a pipe unless a log file was asked for
+	if cfg.SerialLog != "" {
+		args = append(args, "--uart1", "0x3F8", "4", "--uartmode1", "file", cfg.SerialLog)
+		cfg.Console = "file:" + cfg.SerialLog
+	} else {
+		pipe := consolePipe(cfg.Dir, name)
+		args = append(args, "--uart1", "0x3F8", "4", "--uartmode1", "server", pipe)
+		cfg.Console = pipeScheme + ":" + pipe
+	}
+
+	if len(cfg.PortMap) > 0 {
+		pmap := strings.Split(cfg.PortMap, ",")
+		for i, p := range pmap {
+			pmapIndividual := strings.Split(p, ":")
+			x, _ := strconv.Atoi(pmapIndividual[0])
+
+			// TCP ...
+			cmD = exec.Command("vboxmanage", "modifyvm", name, "--natpf"+strconv.Itoa(x+1), fmt.Sprintf("nat%dtcp,tcp,,%s,,%s", i, pmapIndividual[1], pmapIndividual[2]))
+			cmD.Run()
+
+			// UDP ...
+			cmD = exec.Command("vboxmanage", "modifyvm", name, "--natpf"+strconv.Itoa(x+1), fmt.Sprintf("nat%dudp,udp,,%s,,%s", i, pmapIndividual[1], pmapIndividual[2]))
+			cmD.Run()
+		}
+	}
+
+	nictype := "82540EM"
+	if cfg.NICModel == shared.NICModelVirtio {
+		nictype = "virtio"
+	}
+
+	for i := 1; i <= cfg.NetworkCards; i++ {
+		istr := strconv.Itoa(i)
+		if n, ok := cfg.Networks[i-1]; ok {
+			args = append(args, "--nic"+istr, "intnet", "--intnet"+istr, n.Internal, "--macaddress"+istr, strings.Replace(randomMAC(), ":", "", -1))
+		} else {
+			args = append(args, "--nic"+istr, "nat")
+		}
+		args = append(args, "--nictype"+istr, nictype, "--cableconnected"+istr, "on")
+		if cfg.PCAP != "" {
+			args = append(args, "--nictrace"+istr, "on", "--nictracefile"+istr, cfg.PCAPFile(i-1))
+		}
+	}
+
+	cmD = exec.Command("vboxmanage", args...)
+	err = cmD.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	controller := []string{"--name", "SATA", "--add", "sata", "--portcount", "4"}
+	if cfg.DiskBus == shared.DiskBusSCSI {
+		controller = []string{"--name", "SCSI", "--add", "scsi", "--controller", "LSILogic"}
+	}
+
+	cmD = exec.Command("vboxmanage", append(append([]string{"storagectl", name}, controller...), "--bootable", "on")...)
+	err = cmD.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	cmD = exec.Command("vboxmanage", "storageattach", name, "--storagectl", controller[1], "--port", "0", "--device", "0", "--type", "hdd", "--medium", cfg.Disk)
+	err = cmD.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Headless {
+		args = args[:0]
+		args = append(args, "--startvm", name, "--start-running")
+		if cfg.Debug {
+			args = append(args, "--debug")
+		}
+		args = append(args, "--type", "sdl")
+	}
+
+	return args, nil
+
+}
+
+func (cfg *Config) virtualBoxRunning() bool {
+
+	out, err := exec.Command("VBoxManage", "list", "runningvms").Output()
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(string(out), "\""+cfg.Name+"\"")
+
+}
+
+func (cfg *Config) virtualBoxTeardown() {
+
+	exec.Command("VBoxManage", "controlvm", cfg.Name, "poweroff").Run()
+	exec.Command("VBoxManage", "unregistervm", cfg.Name).Run()
+
+}
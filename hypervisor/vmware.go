@@ -0,0 +1,230 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+func (cfg *Config) vmwareArgs(vmplayer bool) ([]string, error) {
+
+	tmpDir := os.TempDir()
+	var sub string
+
+	if vmplayer {
+		sub = "player"
+	} else {
+		sub = "workstation"
+	}
+	name := strings.Replace(cfg.Name, " ", "", -1)
+	cfg.Dir = path.Join(tmpDir, "vorteil", sub, name)
+	os.RemoveAll(cfg.Dir)
+
+	vmxName := filepath.Join(cfg.Dir, name+".vmx")
+
+	// just in case
+	os.MkdirAll(cfg.Dir, os.ModePerm)
+
+	// delete if we ran it before
+	cmD := exec.Command("vmrun", "stop", vmxName)
+	cmD.Run()
+
+	cmD = exec.Command("vmrun", "deleteVM", vmxName)
+	cmD.Run()
+
+	cfg.VMX = vmxName
+
+	// the serial port is logged into the machine's directory unless the
+	// caller asked for a file of its own; VMware resolves relative paths
+	// against the vmx, so the caller's path is made absolute
+	if cfg.SerialLog == "" {
+		cfg.SerialLog = cfg.Dir + "/serial.log"
+	} else {
+		serial, err := filepath.Abs(cfg.SerialLog)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SerialLog = serial
+	}
+	cfg.Console = "file:" + cfg.SerialLog
+
+	// handles vmx disk name for persist or not persist
+	var diskname string
+	if cfg.Persist != "" {
+		diskname = strings.Split(cfg.Disk, "/")[len(strings.Split(cfg.Disk, "/"))-1]
+	} else {
+		diskname = cfg.Disk
+	}
+
+	vmx := shared.NewVMX(name, int(cfg.CPUs), int(cfg.Memory), diskname)
+	vmx.Dir = cfg.Dir
+	vmx.Serial = &shared.VMXSerial{Type: shared.SerialFile, Path: cfg.SerialLog}
+	vmx.DiskBus = cfg.DiskBus
+
+	// VMware's paravirtual card stands in for virtio
+	nictype := shared.NICTypeE1000
+	if cfg.NICModel == shared.NICModelVirtio {
+		nictype = shared.NICTypeVMXNet3
+	}
+
+	for i := 0; i < cfg.NetworkCards; i++ {
+		nic := shared.VMXNetworkCard{
+			Type:       nictype,
+			Connection: shared.NICConnectionNAT,
+		}
+		if n, ok := cfg.Networks[i]; ok {
+			nic.Connection = shared.NICConnectionCustom
+			nic.VNet = n.VMnet
+		}
+		vmx.NICs = append(vmx.NICs, nic)
+	}
+
+	forwards, err := cfg.natForwards()
+	if err != nil {
+		return nil, err
+	}
+	vmx.PortForwards = forwards
+
+	data, err := vmx.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(vmxName, data, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	// VMware's NAT service is shared by every machine and needs the guest's
+	// address, so the forwards are handed to the user to apply
+	if len(vmx.PortForwards) > 0 {
+		fmt.Printf("VMware forwards ports through its NAT service. Add the following to its nat.conf, replacing APP_ADDRESS with the app's address, and restart VMware's networking:\n%s", vmx.NATConfig("APP_ADDRESS"))
+	}
+
+	var args []string
+	if vmplayer {
+		args = []string{vmxName}
+	} else {
+		args = []string{"start", vmxName}
+		if cfg.Headless {
+			args = append(args, "nogui")
+		}
+	}
+
+	// Handle Persists
+	if cfg.Persist != "" {
+
+		old, err := filepath.Abs(cfg.Persist)
+		if err != nil {
+			fmt.Println(err)
+		}
+		pers := filepath.SplitList(cfg.Persist)
+		new := cfg.Dir + "/" + pers[len(pers)-1]
+
+		err = os.Symlink(old, new)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	return args, nil
+
+}
+
+// natForwards turns the port map into forwards through VMware's NAT service,
+// which only reaches the cards that aren't attached to a private network.
+// Like QEMU's, each mapping forwards both TCP and UDP.
+func (cfg *Config) natForwards() ([]shared.VMXPortForward, error) {
+
+	if cfg.PortMap == "" {
+		return nil, nil
+	}
+
+	var forwards []shared.VMXPortForward
+
+	for _, p := range strings.Split(cfg.PortMap, ",") {
+
+		pmapInd := strings.Split(p, ":")
+		if len(pmapInd) != 3 {
+			return nil, fmt.Errorf("invalid port map '%v'", p)
+		}
+
+		card, err := strconv.Atoi(pmapInd[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port map '%v'", p)
+		}
+
+		if _, ok := cfg.Networks[card]; ok || card >= cfg.NetworkCards {
+			continue
+		}
+
+		host, err := strconv.Atoi(pmapInd[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port map '%v'", p)
+		}
+
+		guest, err := strconv.Atoi(pmapInd[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port map '%v'", p)
+		}
+
+		for _, proto := range []string{"tcp", "udp"} {
+			forwards = append(forwards, shared.VMXPortForward{Protocol: proto, HostPort: host, GuestPort: guest})
+		}
+
+	}
+
+	return forwards, nil
+
+}
+
+func (cfg *Config) vmwareRunning() bool {
+
+	var out bytes.Buffer
+	checklist := exec.Command("vmrun", "list")
+	checklist.Stdout = &out
+	err := checklist.Run()
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	str := strings.Split(out.String(), "\n")
+	for _, ln := range str {
+		if ln == cfg.VMX {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+func (cfg *Config) vmwareTeardown() {
+
+	if runtime.GOOS != "darwin" {
+		exec.Command("vmrun", "stop", cfg.VMX, "hard").Run()
+	}
+
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hypervisor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sisatech/vcli/shared"
+)
+
+func vmwareSerial(t *testing.T, cfg *Config) string {
+
+	_, err := cfg.vmwareArgs(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.Dir)
+
+	f, err := os.Open(cfg.VMX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	vmx, err := shared.ParseVMX(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vmx.Serial == nil || vmx.Serial.Path != cfg.SerialLog {
+		t.Errorf("vmx serial port %+v does not log to %v", vmx.Serial, cfg.SerialLog)
+	}
+
+	return cfg.SerialLog
+
+}
+
+func TestVMwareSerialLog(t *testing.T) {
+
+	cfg := &Config{Name: "vmware-serial-test", CPUs: 1, Memory: 64, Disk: "app.vmdk"}
+	serial := vmwareSerial(t, cfg)
+	if serial != filepath.Join(cfg.Dir, "serial.log") {
+		t.Errorf("got serial log %v, expected one in %v", serial, cfg.Dir)
+	}
+
+	// a log the caller asked for is kept, made absolute so VMware doesn't
+	// resolve it against the vmx
+	cfg = &Config{Name: "vmware-serial-test", CPUs: 1, Memory: 64, Disk: "app.vmdk", SerialLog: "serial.log"}
+	serial = vmwareSerial(t, cfg)
+	abs, _ := filepath.Abs("serial.log")
+	if serial != abs {
+		t.Errorf("got serial log %v, expected %v", serial, abs)
+	}
+
+}
+
+func TestNATForwards(t *testing.T) {
+
+	cfg := &Config{
+		NetworkCards: 2,
+		PortMap:      "0:8080:80,1:2222:22,2:53:53",
+		Networks:     map[int]*Network{1: {VMnet: "vmnet2"}},
+	}
+
+	forwards, err := cfg.natForwards()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second card is on a private network and the third doesn't exist
+	expected := []shared.VMXPortForward{
+		{Protocol: "tcp", HostPort: 8080, GuestPort: 80},
+		{Protocol: "udp", HostPort: 8080, GuestPort: 80},
+	}
+
+	if !reflect.DeepEqual(forwards, expected) {
+		t.Errorf("got %v, expected %v", forwards, expected)
+	}
+
+}
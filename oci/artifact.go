@@ -0,0 +1,365 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Descriptor points to a blob in a registry.
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Manifest is an OCI image manifest.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// blob is a part of an app spooled to a temporary file.
+type blob struct {
+	Descriptor
+	f *os.File
+}
+
+func spool(mediaType string, r io.Reader) (*blob, error) {
+
+	f, err := ioutil.TempFile("", "vcli-oci-")
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hasher), r)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &blob{
+		Descriptor: Descriptor{
+			MediaType: mediaType,
+			Digest:    "sha256:" + hex.EncodeToString(hasher.Sum(nil)),
+			Size:      n,
+		},
+		f: f,
+	}, nil
+
+}
+
+func (b *blob) reader() io.Reader {
+
+	if b == nil {
+		return nil
+	}
+
+	return b.f
+
+}
+
+func (b *blob) close() {
+
+	if b != nil {
+		b.f.Close()
+		os.Remove(b.f.Name())
+	}
+
+}
+
+// Push uploads an app to the registry and tags it, skipping blobs the
+// registry already has. The config is required; the icon and files may be
+// nil. Push returns the digest of the manifest.
+func (c *Client) Push(ref *Reference, app, cfg, icon, files io.Reader) (string, error) {
+
+	if app == nil || cfg == nil {
+		return "", errors.New("an app needs a binary and a build config")
+	}
+
+	if ref.Tag == "" {
+		return "", errors.New("apps must be pushed to a tag")
+	}
+
+	parts := []struct {
+		mediaType string
+		r         io.Reader
+	}{
+		{ConfigMediaType, cfg},
+		{AppMediaType, app},
+		{IconMediaType, icon},
+		{FilesMediaType, files},
+	}
+
+	manifest := &Manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+	}
+
+	for i, part := range parts {
+
+		if part.r == nil {
+			continue
+		}
+
+		b, err := spool(part.mediaType, part.r)
+		if err != nil {
+			return "", err
+		}
+
+		err = c.upload(ref, b)
+		b.close()
+		if err != nil {
+			return "", err
+		}
+
+		if i == 0 {
+			manifest.Config = b.Descriptor
+		} else {
+			manifest.Layers = append(manifest.Layers, b.Descriptor)
+		}
+
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	header := http.Header{"Content-Type": {ManifestMediaType}}
+	resp, err := c.do("PUT", ref.baseURL()+"/manifests/"+ref.Tag, header,
+		bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return digestOf(data), nil
+
+}
+
+// upload sends a blob as a single monolithic upload unless the registry has
+// it already.
+func (c *Client) upload(ref *Reference, b *blob) error {
+
+	resp, err := c.do("HEAD", ref.baseURL()+"/blobs/"+b.Digest, nil, nil, 0)
+	if err == nil {
+		resp.Body.Close()
+		return nil
+	}
+	if !IsNotExist(err) {
+		return err
+	}
+
+	resp, err = c.do("POST", ref.baseURL()+"/blobs/uploads/", nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	base, _ := url.Parse(ref.baseURL())
+	location, err := base.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return errors.New("registry didn't say where to upload to")
+	}
+
+	q := location.Query()
+	q.Set("digest", b.Digest)
+	location.RawQuery = q.Encode()
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.do("PUT", location.String(), header, b.f, b.Size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+
+}
+
+// App is an app pulled from a registry, held in temporary files until it is
+// closed. It satisfies converter.Convertible.
+type App struct {
+	Digest string
+
+	app, cfg, icon, files *blob
+}
+
+// App returns the app's binary.
+func (a *App) App() io.Reader {
+
+	return a.app.reader()
+
+}
+
+// Config returns the app's build config.
+func (a *App) Config() io.Reader {
+
+	return a.cfg.reader()
+
+}
+
+// Icon returns the app's icon, or nil if it has none.
+func (a *App) Icon() io.Reader {
+
+	return a.icon.reader()
+
+}
+
+// FilesTar returns the tar of the app's files, or nil if it has none.
+func (a *App) FilesTar() io.Reader {
+
+	return a.files.reader()
+
+}
+
+// Close removes the temporary files.
+func (a *App) Close() error {
+
+	a.app.close()
+	a.cfg.close()
+	a.icon.close()
+	a.files.close()
+
+	return nil
+
+}
+
+// Pull downloads an app from the registry, checking every blob against its
+// digest.
+func (c *Client) Pull(ref *Reference) (*App, error) {
+
+	header := http.Header{"Accept": {ManifestMediaType}}
+	resp, err := c.do("GET", ref.baseURL()+"/manifests/"+ref.manifestRef(),
+		header, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &App{Digest: digestOf(data)}
+
+	if ref.Digest != "" && a.Digest != ref.Digest {
+		return nil, fmt.Errorf("manifest doesn't match digest %v", ref.Digest)
+	}
+
+	manifest := new(Manifest)
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Config.MediaType != ConfigMediaType {
+		return nil, fmt.Errorf("%v is not a Vorteil app", ref)
+	}
+
+	descriptors := append([]Descriptor{manifest.Config}, manifest.Layers...)
+
+	for _, d := range descriptors {
+
+		var target **blob
+
+		switch d.MediaType {
+		case ConfigMediaType:
+			target = &a.cfg
+		case AppMediaType:
+			target = &a.app
+		case IconMediaType:
+			target = &a.icon
+		case FilesMediaType:
+			target = &a.files
+		default:
+			continue
+		}
+
+		if *target != nil {
+			continue
+		}
+
+		*target, err = c.download(ref, d)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+
+	}
+
+	if a.app == nil {
+		a.Close()
+		return nil, fmt.Errorf("%v has no app binary", ref)
+	}
+
+	return a, nil
+
+}
+
+func (c *Client) download(ref *Reference, d Descriptor) (*blob, error) {
+
+	if !digestPattern.MatchString(d.Digest) {
+		return nil, fmt.Errorf("unsupported digest: '%v'", d.Digest)
+	}
+
+	resp, err := c.do("GET", ref.baseURL()+"/blobs/"+d.Digest, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := spool(d.MediaType, io.LimitReader(resp.Body, d.Size+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Digest != d.Digest || b.Size != d.Size {
+		b.close()
+		return nil, fmt.Errorf("blob %v is corrupt", shortDigest(d.Digest))
+	}
+
+	_, err = b.f.Seek(0, io.SeekStart)
+	if err != nil {
+		b.close()
+		return nil, err
+	}
+
+	return b, nil
+
+}
+
+func shortDigest(digest string) string {
+
+	return strings.TrimPrefix(digest, "sha256:")[:12]
+
+}
@@ -0,0 +1,404 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package oci stores Vorteil apps in OCI registries. An app is pushed as an
+// artifact whose config blob is the app's build config and whose layers are
+// its binary, icon and files tar, each with its own media type.
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Prefix marks an app address as a reference to an OCI registry.
+const Prefix = "oci://"
+
+// Media types used for the manifest and each part of an app.
+const (
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ConfigMediaType   = "application/vnd.vorteil.app.config.v1+json"
+	AppMediaType      = "application/vnd.vorteil.app.binary.v1"
+	IconMediaType     = "application/vnd.vorteil.app.icon.v1"
+	FilesMediaType    = "application/vnd.vorteil.app.files.v1.tar"
+)
+
+var (
+	repositoryPattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
+	tagPattern        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digestPattern     = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+)
+
+// Reference identifies an app in a registry by tag or by manifest digest.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses a reference of the form
+// [oci://]REGISTRY/REPOSITORY[:TAG][@DIGEST]. The tag defaults to 'latest' if
+// neither a tag nor a digest is given.
+func ParseReference(s string) (*Reference, error) {
+
+	ref := new(Reference)
+	rest := strings.TrimPrefix(s, Prefix)
+
+	i := strings.Index(rest, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("'%v' is not a REGISTRY/REPOSITORY[:TAG] reference", s)
+	}
+	ref.Registry, rest = rest[:i], rest[i+1:]
+
+	if i = strings.Index(rest, "@"); i != -1 {
+		ref.Digest, rest = rest[i+1:], rest[:i]
+		if !digestPattern.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid digest: '%v'", ref.Digest)
+		}
+	}
+
+	if i = strings.LastIndex(rest, ":"); i != -1 && !strings.Contains(rest[i:], "/") {
+		ref.Tag, rest = rest[i+1:], rest[:i]
+		if !tagPattern.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("invalid tag: '%v'", ref.Tag)
+		}
+	}
+
+	ref.Repository = rest
+	if !repositoryPattern.MatchString(ref.Repository) {
+		return nil, fmt.Errorf("invalid repository name: '%v'", ref.Repository)
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	return ref, nil
+
+}
+
+func (ref *Reference) String() string {
+
+	s := Prefix + ref.Registry + "/" + ref.Repository
+	if ref.Tag != "" {
+		s += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		s += "@" + ref.Digest
+	}
+
+	return s
+
+}
+
+// Name returns the last element of the repository name.
+func (ref *Reference) Name() string {
+
+	return ref.Repository[strings.LastIndex(ref.Repository, "/")+1:]
+
+}
+
+// manifestRef is what the manifest is requested by, preferring the digest.
+func (ref *Reference) manifestRef() string {
+
+	if ref.Digest != "" {
+		return ref.Digest
+	}
+
+	return ref.Tag
+
+}
+
+// baseURL uses plain http for registries on the loopback interface, as
+// docker does, and https for everything else.
+func (ref *Reference) baseURL() string {
+
+	host := ref.Registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	scheme := "https"
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		scheme = "http"
+	}
+
+	return scheme + "://" + ref.Registry + "/v2/" + ref.Repository
+
+}
+
+// Client pushes and pulls apps. Registries that require authentication are
+// sent the username and password, or are asked for a bearer token with them.
+type Client struct {
+	Username string
+	Password string
+	HTTP     *http.Client
+
+	auth string
+}
+
+// NewClient returns a client that authenticates with the credentials in the
+// VCLI_OCI_USERNAME and VCLI_OCI_PASSWORD environment variables, if set.
+func NewClient() *Client {
+
+	return &Client{
+		Username: os.Getenv("VCLI_OCI_USERNAME"),
+		Password: os.Getenv("VCLI_OCI_PASSWORD"),
+	}
+
+}
+
+// Error is returned for requests the registry rejects.
+type Error struct {
+	Status int
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (e *Error) Error() string {
+
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("registry: %v", http.StatusText(e.Status))
+	}
+
+	return fmt.Sprintf("registry: %v: %v", e.Errors[0].Code, e.Errors[0].Message)
+
+}
+
+// IsNotExist reports whether err is the registry saying something doesn't
+// exist.
+func IsNotExist(err error) bool {
+
+	e, ok := err.(*Error)
+
+	return ok && e.Status == http.StatusNotFound
+
+}
+
+func (c *Client) client() *http.Client {
+
+	if c.HTTP == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTP
+
+}
+
+// do sends a request, authenticating and sending it again if the registry
+// asks for credentials. The body must be seekable so that it can be resent.
+func (c *Client) do(method, u string, header http.Header, body io.ReadSeeker, size int64) (*http.Response, error) {
+
+	for attempt := 0; ; attempt++ {
+
+		var r io.Reader
+		if body != nil {
+			_, err := body.Seek(0, io.SeekStart)
+			if err != nil {
+				return nil, err
+			}
+			r = body
+		}
+
+		req, err := http.NewRequest(method, u, r)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		if body != nil {
+			req.ContentLength = size
+		}
+
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+
+		resp, err := c.client().Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			err = c.authenticate(challenge)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode/100 != 2 {
+			defer resp.Body.Close()
+			e := &Error{Status: resp.StatusCode}
+			data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+			json.Unmarshal(data, e)
+			return nil, e
+		}
+
+		return resp, nil
+
+	}
+
+}
+
+// authenticate answers a WWW-Authenticate challenge, either with basic
+// credentials or by fetching a bearer token from the realm it names.
+func (c *Client) authenticate(challenge string) error {
+
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+
+	case "basic":
+
+		if c.Username == "" {
+			return errors.New("registry requires credentials; set VCLI_OCI_USERNAME and VCLI_OCI_PASSWORD")
+		}
+
+		c.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+
+	case "bearer":
+
+		u, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return fmt.Errorf("registry sent an invalid token realm: '%v'", params["realm"])
+		}
+
+		q := u.Query()
+		if params["service"] != "" {
+			q.Set("service", params["service"])
+		}
+		if params["scope"] != "" {
+			q.Set("scope", params["scope"])
+		}
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		if c.Username != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+
+		resp, err := c.client().Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("registry refused a token: %v", resp.Status)
+		}
+
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&token)
+		if err != nil {
+			return err
+		}
+
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+
+		c.auth = "Bearer " + token.Token
+
+	default:
+		return errors.New("registry requires unsupported authentication")
+
+	}
+
+	return nil
+
+}
+
+// parseChallenge splits a header like 'Bearer realm="x",service="y"'.
+func parseChallenge(challenge string) (string, map[string]string) {
+
+	params := make(map[string]string)
+
+	i := strings.Index(challenge, " ")
+	if i == -1 {
+		return challenge, params
+	}
+
+	scheme, rest := challenge[:i], challenge[i+1:]
+
+	for rest != "" {
+
+		rest = strings.TrimLeft(rest, " ,")
+
+		eq := strings.Index(rest, "=")
+		if eq == -1 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end == -1 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+
+		params[key] = value
+
+	}
+
+	return scheme, params
+
+}
+
+func digestOf(data []byte) string {
+
+	sum := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+
+}
@@ -0,0 +1,312 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package oci
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// registry is a minimal in-memory OCI distribution server. If token is set it
+// hands out that bearer token from /token and requires it everywhere else.
+type registry struct {
+	sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+	token     string
+	url       string
+}
+
+func newRegistry(token string) (*registry, *httptest.Server) {
+
+	reg := &registry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		token:     token,
+	}
+
+	srv := httptest.NewServer(reg)
+	reg.url = srv.URL
+
+	return reg, srv
+
+}
+
+func (reg *registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	reg.Lock()
+	defer reg.Unlock()
+
+	if r.URL.Path == "/token" {
+		fmt.Fprintf(w, `{"token": %q}`, reg.token)
+		return
+	}
+
+	if reg.token != "" && r.Header.Get("Authorization") != "Bearer "+reg.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%v/token",service="test"`, reg.url))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/vorteil/app")
+
+	switch {
+
+	case strings.HasPrefix(path, "/blobs/uploads/") && r.Method == "POST":
+		w.Header().Set("Location", "/v2/vorteil/app/blobs/uploads/1?state=x")
+		w.WriteHeader(http.StatusAccepted)
+
+	case strings.HasPrefix(path, "/blobs/uploads/") && r.Method == "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		digest := r.URL.Query().Get("digest")
+		if digestOf(data) != digest || r.URL.Query().Get("state") != "x" {
+			http.Error(w, `{"errors":[{"code":"DIGEST_INVALID","message":"bad digest"}]}`, http.StatusBadRequest)
+			return
+		}
+		reg.blobs[digest] = data
+		reg.uploads++
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.HasPrefix(path, "/blobs/"):
+		data, ok := reg.blobs[strings.TrimPrefix(path, "/blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+
+	case strings.HasPrefix(path, "/manifests/") && r.Method == "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		reg.manifests[strings.TrimPrefix(path, "/manifests/")] = data
+		reg.manifests[digestOf(data)] = data
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.HasPrefix(path, "/manifests/"):
+		data, ok := reg.manifests[strings.TrimPrefix(path, "/manifests/")]
+		if !ok {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ManifestMediaType)
+		w.Write(data)
+
+	default:
+		http.NotFound(w, r)
+
+	}
+
+}
+
+func reference(t *testing.T, srv *httptest.Server, tag string) *Reference {
+
+	ref, err := ParseReference(Prefix + strings.TrimPrefix(srv.URL, "http://") + "/vorteil/app:" + tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ref
+
+}
+
+func readAll(t *testing.T, r io.Reader) string {
+
+	if r == nil {
+		return "<nil>"
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+
+}
+
+func TestPushPull(t *testing.T) {
+
+	for _, token := range []string{"", "secret"} {
+
+		reg, srv := newRegistry(token)
+		defer srv.Close()
+
+		c := new(Client)
+		ref := reference(t, srv, "v1")
+
+		digest, err := c.Push(ref, strings.NewReader("binary"),
+			strings.NewReader(`{"name":"app"}`), nil, strings.NewReader("tar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reg.uploads != 3 {
+			t.Errorf("uploaded %v blobs, expected 3", reg.uploads)
+		}
+
+		// pushing the same app again only uploads the manifest
+		_, err = c.Push(reference(t, srv, "v2"), strings.NewReader("binary"),
+			strings.NewReader(`{"name":"app"}`), nil, strings.NewReader("tar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reg.uploads != 3 {
+			t.Errorf("uploaded %v blobs after pushing again, expected 3", reg.uploads)
+		}
+
+		ref.Tag = ""
+		ref.Digest = digest
+
+		app, err := new(Client).Pull(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s := readAll(t, app.App()); s != "binary" {
+			t.Errorf("app is '%v'", s)
+		}
+		if s := readAll(t, app.Config()); s != `{"name":"app"}` {
+			t.Errorf("config is '%v'", s)
+		}
+		if s := readAll(t, app.Icon()); s != "<nil>" {
+			t.Errorf("icon is '%v'", s)
+		}
+		if s := readAll(t, app.FilesTar()); s != "tar" {
+			t.Errorf("files are '%v'", s)
+		}
+
+		app.Close()
+
+	}
+
+}
+
+func TestPullCorrupt(t *testing.T) {
+
+	reg, srv := newRegistry("")
+	defer srv.Close()
+
+	c := new(Client)
+	ref := reference(t, srv, "latest")
+
+	_, err := c.Push(ref, strings.NewReader("binary"),
+		strings.NewReader("{}"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg.blobs[digestOf([]byte("binary"))] = []byte("bad")
+
+	_, err = c.Pull(ref)
+	if err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("expected a corrupt blob error, got %v", err)
+	}
+
+}
+
+func TestPullNotAnApp(t *testing.T) {
+
+	reg, srv := newRegistry("")
+	defer srv.Close()
+
+	reg.manifests["latest"] = []byte(`{"schemaVersion":2,"config":{"mediaType":"application/vnd.oci.image.config.v1+json"}}`)
+
+	_, err := new(Client).Pull(reference(t, srv, "latest"))
+	if err == nil || !strings.Contains(err.Error(), "not a Vorteil app") {
+		t.Errorf("expected an error for a container image, got %v", err)
+	}
+
+	_, err = new(Client).Pull(reference(t, srv, "missing"))
+	if !IsNotExist(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+}
+
+func TestParseReference(t *testing.T) {
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		in   string
+		want Reference
+	}{
+		{"oci://registry.io/ns/app:1.0", Reference{"registry.io", "ns/app", "1.0", ""}},
+		{"registry.io/app", Reference{"registry.io", "app", "latest", ""}},
+		{"localhost:5000/a/b/c", Reference{"localhost:5000", "a/b/c", "latest", ""}},
+		{"oci://localhost:5000/app@" + digest, Reference{"localhost:5000", "app", "", digest}},
+		{"r.io/app:v2@" + digest, Reference{"r.io", "app", "v2", digest}},
+	}
+
+	for _, test := range tests {
+
+		ref, err := ParseReference(test.in)
+		if err != nil {
+			t.Errorf("%v: %v", test.in, err)
+			continue
+		}
+
+		if *ref != test.want {
+			t.Errorf("%v: got %+v, expected %+v", test.in, *ref, test.want)
+		}
+
+	}
+
+	for _, bad := range []string{"app", "oci://r.io/App", "r.io/app:", "r.io/app@sha256:zz", "/app"} {
+		if _, err := ParseReference(bad); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+
+}
+
+func TestBaseURL(t *testing.T) {
+
+	for in, want := range map[string]string{
+		"localhost:5000/app": "http://localhost:5000/v2/app",
+		"127.0.0.1/app":      "http://127.0.0.1/v2/app",
+		"registry.io/ns/app": "https://registry.io/v2/ns/app",
+	} {
+
+		ref, err := ParseReference(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ref.baseURL(); got != want {
+			t.Errorf("%v: got %v, expected %v", in, got, want)
+		}
+
+	}
+
+}
+
+func TestParseChallenge(t *testing.T) {
+
+	scheme, params := parseChallenge(`Bearer realm="https://auth.io/token",service="registry.io",scope="repository:ns/app:pull,push"`)
+
+	if scheme != "Bearer" || params["realm"] != "https://auth.io/token" ||
+		params["service"] != "registry.io" ||
+		params["scope"] != "repository:ns/app:pull,push" {
+		t.Errorf("parsed %v %v", scheme, params)
+	}
+
+}
@@ -0,0 +1,325 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeARP  = 0x0806
+	etherTypeVLAN = 0x8100
+	etherTypeIPv6 = 0x86dd
+
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpRST = 0x04
+)
+
+var httpMethods = []string{"GET ", "POST ", "PUT ", "DELETE ", "HEAD ", "OPTIONS ", "PATCH "}
+
+// Flow summarises the packets exchanged between two endpoints. The client is
+// whichever endpoint sent the first packet seen.
+type Flow struct {
+	Protocol string
+	Client   string
+	Server   string
+	Packets  int
+	Bytes    int
+	First    time.Time
+	Last     time.Time
+
+	// Info notes anything recognised about the flow, such as TCP connection
+	// state or the first HTTP request and response.
+	Info string
+
+	syn, fin, rst bool
+	request       string
+	response      string
+}
+
+// Summary is the result of decoding a capture.
+type Summary struct {
+	Packets int
+	Bytes   int
+	Flows   []*Flow
+}
+
+type packetInfo struct {
+	protocol string
+	src, dst string
+	flags    byte
+	payload  []byte
+}
+
+// Flows decodes every packet in a capture and groups them into flows, in the
+// order the flows started.
+func Flows(r io.Reader) (*Summary, error) {
+
+	pr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr.LinkType != LinkTypeEthernet {
+		return nil, fmt.Errorf("unsupported pcap link type %d", pr.LinkType)
+	}
+
+	summary := new(Summary)
+	flows := make(map[string]*Flow)
+
+	for {
+
+		p, err := pr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		summary.Packets++
+		summary.Bytes += p.Length
+
+		info := decodeEthernet(p.Data)
+		if info == nil {
+			continue
+		}
+
+		a, b := info.src, info.dst
+		if b < a {
+			a, b = b, a
+		}
+		key := info.protocol + " " + a + " " + b
+
+		f, ok := flows[key]
+		if !ok {
+			f = &Flow{
+				Protocol: info.protocol,
+				Client:   info.src,
+				Server:   info.dst,
+				First:    p.Time,
+			}
+			flows[key] = f
+			summary.Flows = append(summary.Flows, f)
+		}
+
+		f.Packets++
+		f.Bytes += p.Length
+		f.Last = p.Time
+		f.observe(info)
+
+	}
+
+	for _, f := range summary.Flows {
+		f.Info = f.describe()
+	}
+
+	sort.Sort(byFirst(summary.Flows))
+
+	return summary, nil
+
+}
+
+type byFirst []*Flow
+
+func (s byFirst) Len() int           { return len(s) }
+func (s byFirst) Less(i, j int) bool { return s[i].First.Before(s[j].First) }
+func (s byFirst) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (f *Flow) observe(info *packetInfo) {
+
+	f.syn = f.syn || info.flags&tcpSYN != 0
+	f.fin = f.fin || info.flags&tcpFIN != 0
+	f.rst = f.rst || info.flags&tcpRST != 0
+
+	if info.protocol != "TCP" || len(info.payload) == 0 {
+		return
+	}
+
+	line := info.payload
+	if i := bytes.Index(line, []byte("\r\n")); i >= 0 {
+		line = line[:i]
+	}
+
+	if f.request == "" {
+		for _, m := range httpMethods {
+			if bytes.HasPrefix(line, []byte(m)) {
+				f.request = string(line)
+			}
+		}
+	}
+
+	if f.response == "" && bytes.HasPrefix(line, []byte("HTTP/1.")) {
+		f.response = string(line)
+	}
+
+}
+
+func (f *Flow) describe() string {
+
+	var notes []string
+
+	if f.request != "" {
+		notes = append(notes, f.request)
+	}
+
+	if f.response != "" {
+		if i := strings.Index(f.response, " "); i >= 0 {
+			notes = append(notes, "-> "+f.response[i+1:])
+		} else {
+			notes = append(notes, "-> "+f.response)
+		}
+	}
+
+	if f.Protocol == "TCP" {
+		switch {
+		case f.rst:
+			notes = append(notes, "[reset]")
+		case f.fin:
+			notes = append(notes, "[closed]")
+		case f.syn:
+			notes = append(notes, "[open]")
+		}
+	}
+
+	if f.Protocol == "UDP" && (strings.HasSuffix(f.Server, ":53") || strings.HasSuffix(f.Client, ":53")) {
+		notes = append(notes, "DNS")
+	}
+
+	return strings.Join(notes, " ")
+
+}
+
+func decodeEthernet(data []byte) *packetInfo {
+
+	if len(data) < 14 {
+		return nil
+	}
+
+	etherType := binary.BigEndian.Uint16(data[12:14])
+	data = data[14:]
+
+	if etherType == etherTypeVLAN && len(data) >= 4 {
+		etherType = binary.BigEndian.Uint16(data[2:4])
+		data = data[4:]
+	}
+
+	switch etherType {
+	case etherTypeIPv4:
+		return decodeIPv4(data)
+	case etherTypeIPv6:
+		return decodeIPv6(data)
+	case etherTypeARP:
+		if len(data) < 28 {
+			return nil
+		}
+		return &packetInfo{
+			protocol: "ARP",
+			src:      net.IP(data[14:18]).String(),
+			dst:      net.IP(data[24:28]).String(),
+		}
+	}
+
+	return nil
+
+}
+
+func decodeIPv4(data []byte) *packetInfo {
+
+	if len(data) < 20 {
+		return nil
+	}
+
+	ihl := int(data[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(data[2:4]))
+	if ihl < 20 || len(data) < ihl {
+		return nil
+	}
+
+	if total >= ihl && total < len(data) {
+		// drop ethernet padding
+		data = data[:total]
+	}
+
+	return decodeTransport(data[9], net.IP(data[12:16]), net.IP(data[16:20]), data[ihl:])
+
+}
+
+func decodeIPv6(data []byte) *packetInfo {
+
+	if len(data) < 40 {
+		return nil
+	}
+
+	return decodeTransport(data[6], net.IP(data[8:24]), net.IP(data[24:40]), data[40:])
+
+}
+
+func decodeTransport(proto byte, src, dst net.IP, data []byte) *packetInfo {
+
+	info := &packetInfo{
+		src: src.String(),
+		dst: dst.String(),
+	}
+
+	switch proto {
+	case protoTCP, protoUDP:
+
+		if len(data) < 8 {
+			return nil
+		}
+
+		info.src = net.JoinHostPort(info.src, strconv.Itoa(int(binary.BigEndian.Uint16(data[0:2]))))
+		info.dst = net.JoinHostPort(info.dst, strconv.Itoa(int(binary.BigEndian.Uint16(data[2:4]))))
+
+		if proto == protoUDP {
+			info.protocol = "UDP"
+			info.payload = data[8:]
+			return info
+		}
+
+		if len(data) < 20 {
+			return nil
+		}
+
+		info.protocol = "TCP"
+		info.flags = data[13]
+		offset := int(data[12]>>4) * 4
+		if offset >= 20 && offset <= len(data) {
+			info.payload = data[offset:]
+		}
+
+	case protoICMP, protoICMPv6:
+		info.protocol = "ICMP"
+	default:
+		info.protocol = "IP/" + strconv.Itoa(int(proto))
+	}
+
+	return info
+
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// LinkTypeEthernet is the only link type hypervisors capture with.
+const LinkTypeEthernet = 1
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+)
+
+// Packet is a single captured frame.
+type Packet struct {
+	Time   time.Time
+	Length int // length of the frame on the wire
+	Data   []byte
+}
+
+// Reader reads packets from a classic libpcap capture file.
+type Reader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	LinkType uint32
+}
+
+// NewReader reads the file header of a capture.
+func NewReader(r io.Reader) (*Reader, error) {
+
+	var hdr [24]byte
+	_, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return nil, fmt.Errorf("reading pcap header: %v", err)
+	}
+
+	pr := &Reader{r: r}
+
+	switch {
+	case binary.LittleEndian.Uint32(hdr[:4]) == magicMicroseconds:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[:4]) == magicMicroseconds:
+		pr.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[:4]) == magicNanoseconds:
+		pr.order = binary.LittleEndian
+		pr.nanos = true
+	case binary.BigEndian.Uint32(hdr[:4]) == magicNanoseconds:
+		pr.order = binary.BigEndian
+		pr.nanos = true
+	default:
+		return nil, errors.New("not a pcap file")
+	}
+
+	pr.LinkType = pr.order.Uint32(hdr[20:24])
+
+	return pr, nil
+
+}
+
+// Next returns the next packet, or io.EOF at the end of the capture. A capture
+// cut short while the VM was still writing it ends cleanly as well.
+func (pr *Reader) Next() (*Packet, error) {
+
+	var hdr [16]byte
+	_, err := io.ReadFull(pr.r, hdr[:])
+	if err == io.ErrUnexpectedEOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	sec := int64(pr.order.Uint32(hdr[0:4]))
+	frac := int64(pr.order.Uint32(hdr[4:8]))
+	if !pr.nanos {
+		frac *= 1000
+	}
+
+	captured := pr.order.Uint32(hdr[8:12])
+	if captured > 1<<18 {
+		return nil, fmt.Errorf("pcap record too large: %d bytes", captured)
+	}
+
+	p := &Packet{
+		Time:   time.Unix(sec, frac),
+		Length: int(pr.order.Uint32(hdr[12:16])),
+		Data:   make([]byte, captured),
+	}
+
+	_, err = io.ReadFull(pr.r, p.Data)
+	if err == io.ErrUnexpectedEOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+
+}
@@ -0,0 +1,118 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+type capture struct {
+	bytes.Buffer
+	t time.Time
+}
+
+func newCapture() *capture {
+
+	c := &capture{t: time.Unix(1500000000, 0)}
+	binary.Write(c, binary.LittleEndian, []uint32{magicMicroseconds, 0x00040002, 0, 0, 65535, LinkTypeEthernet})
+	return c
+
+}
+
+func (c *capture) frame(etherType uint16, payload []byte) {
+
+	data := make([]byte, 14, 14+len(payload))
+	binary.BigEndian.PutUint16(data[12:], etherType)
+	data = append(data, payload...)
+
+	c.t = c.t.Add(time.Millisecond)
+	binary.Write(c, binary.LittleEndian, []uint32{uint32(c.t.Unix()), uint32(c.t.Nanosecond() / 1000), uint32(len(data)), uint32(len(data))})
+	c.Write(data)
+
+}
+
+func (c *capture) ipv4(proto byte, src, dst string, transport []byte) {
+
+	ip := make([]byte, 20, 20+len(transport))
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(transport)))
+	ip[9] = proto
+	copy(ip[12:], net.ParseIP(src).To4())
+	copy(ip[16:], net.ParseIP(dst).To4())
+	c.frame(etherTypeIPv4, append(ip, transport...))
+
+}
+
+func (c *capture) tcp(src, dst string, sport, dport uint16, flags byte, payload string) {
+
+	seg := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(seg[0:], sport)
+	binary.BigEndian.PutUint16(seg[2:], dport)
+	seg[12] = 5 << 4
+	seg[13] = flags
+	c.ipv4(protoTCP, src, dst, append(seg, payload...))
+
+}
+
+func (c *capture) udp(src, dst string, sport, dport uint16) {
+
+	dgram := make([]byte, 8)
+	binary.BigEndian.PutUint16(dgram[0:], sport)
+	binary.BigEndian.PutUint16(dgram[2:], dport)
+	c.ipv4(protoUDP, src, dst, dgram)
+
+}
+
+func TestFlows(t *testing.T) {
+
+	c := newCapture()
+	c.udp("10.0.2.15", "10.0.2.3", 5353, 53)
+	c.tcp("10.0.2.2", "10.0.2.15", 40000, 80, tcpSYN, "")
+	c.tcp("10.0.2.15", "10.0.2.2", 80, 40000, tcpSYN|0x10, "")
+	c.tcp("10.0.2.2", "10.0.2.15", 40000, 80, 0x10, "GET /health HTTP/1.1\r\nHost: x\r\n\r\n")
+	c.tcp("10.0.2.15", "10.0.2.2", 80, 40000, 0x10, "HTTP/1.1 200 OK\r\n\r\n")
+	c.tcp("10.0.2.2", "10.0.2.15", 40000, 80, tcpFIN, "")
+	c.udp("10.0.2.3", "10.0.2.15", 53, 5353)
+
+	// a capture cut short mid-record still decodes
+	c.Write([]byte{1, 2, 3})
+
+	s, err := Flows(bytes.NewReader(c.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Packets != 7 {
+		t.Errorf("expected 7 packets, got %d", s.Packets)
+	}
+
+	if len(s.Flows) != 2 {
+		t.Fatalf("expected 2 flows, got %d", len(s.Flows))
+	}
+
+	dns := s.Flows[0]
+	if dns.Protocol != "UDP" || dns.Client != "10.0.2.15:5353" || dns.Packets != 2 || dns.Info != "DNS" {
+		t.Errorf("unexpected dns flow: %+v", dns)
+	}
+
+	http := s.Flows[1]
+	if http.Client != "10.0.2.2:40000" || http.Server != "10.0.2.15:80" || http.Packets != 5 {
+		t.Errorf("unexpected http flow: %+v", http)
+	}
+
+	if http.Info != "GET /health HTTP/1.1 -> 200 OK [closed]" {
+		t.Errorf("unexpected http flow info: %q", http.Info)
+	}
+
+}
+
+func TestNotPcap(t *testing.T) {
+
+	_, err := Flows(bytes.NewReader(make([]byte, 24)))
+	if err == nil {
+		t.Error("expected an error for a file that isn't a capture")
+	}
+
+}
@@ -0,0 +1,445 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package s3 is a minimal client for S3-compatible object stores, signing
+// requests with AWS Signature Version 4 when credentials are available.
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client accesses a single bucket using path-style requests.
+type Client struct {
+	Endpoint string // ie. https://s3.amazonaws.com
+	Region   string
+	Bucket   string
+
+	// Requests are sent unsigned if AccessKey is empty, which is enough for
+	// public buckets.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	HTTP *http.Client
+}
+
+// Object describes an object in a listing.
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Parse returns a client for a URL of the form
+// s3://BUCKET[/PREFIX][?endpoint=URL&region=REGION], along with the prefix.
+// Credentials are taken from the standard AWS environment variables.
+func Parse(rawurl string) (*Client, string, error) {
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if u.Scheme != "s3" || u.Host == "" {
+		return nil, "", fmt.Errorf("'%v' is not an s3://BUCKET/PREFIX URL", rawurl)
+	}
+
+	c := &Client{
+		Endpoint:     u.Query().Get("endpoint"),
+		Region:       u.Query().Get("region"),
+		Bucket:       u.Host,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if c.Region == "" {
+		c.Region = os.Getenv("AWS_REGION")
+	}
+
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+
+	if c.Endpoint == "" {
+		c.Endpoint = "https://s3.amazonaws.com"
+		if c.Region != "us-east-1" {
+			c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+		}
+	}
+
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/")
+
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return c, prefix, nil
+
+}
+
+// Error is returned for requests the store rejects.
+type Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+
+	if e.Code == "" {
+		return fmt.Sprintf("s3: %v", http.StatusText(e.Status))
+	}
+
+	return fmt.Sprintf("s3: %v: %v", e.Code, e.Message)
+
+}
+
+// IsNotExist reports whether err is the store saying an object doesn't exist.
+func IsNotExist(err error) bool {
+
+	e, ok := err.(*Error)
+
+	return ok && (e.Status == http.StatusNotFound || e.Code == "NoSuchKey")
+
+}
+
+func (c *Client) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/" + c.Bucket + "/" + key
+	u.RawPath = encodePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	var r io.Reader
+	if body != nil {
+		r = strings.NewReader(string(body))
+	}
+
+	req, err := http.NewRequest(method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.ContentLength = int64(len(body))
+	}
+
+	c.sign(req, body, time.Now())
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		e := &Error{Status: resp.StatusCode}
+		data, _ := ioutil.ReadAll(resp.Body)
+		xml.Unmarshal(data, e)
+		return nil, e
+	}
+
+	return resp, nil
+
+}
+
+// Get returns the contents of an object.
+func (c *Client) Get(key string) (io.ReadCloser, error) {
+
+	resp, err := c.do("GET", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+
+}
+
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns every object whose key starts with prefix.
+func (c *Client) List(prefix string) ([]Object, error) {
+
+	var objects []Object
+	var token string
+
+	for {
+
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		result := new(listResult)
+		err = xml.NewDecoder(resp.Body).Decode(result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range result.Contents {
+			objects = append(objects, Object{Key: o.Key, Size: o.Size, Modified: o.LastModified})
+		}
+
+		if !result.IsTruncated {
+			return objects, nil
+		}
+
+		if result.NextContinuationToken == "" {
+			return nil, errors.New("s3: truncated listing without a continuation token")
+		}
+		token = result.NextContinuationToken
+
+	}
+
+}
+
+// Put stores data as an object in a single request.
+func (c *Client) Put(key string, data []byte) error {
+
+	if data == nil {
+		data = []byte{}
+	}
+
+	resp, err := c.do("PUT", key, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+
+}
+
+// Head returns an object's size and modification time without its contents.
+func (c *Client) Head(key string) (Object, error) {
+
+	resp, err := c.do("HEAD", key, nil, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	resp.Body.Close()
+
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return Object{
+		Key:      key,
+		Size:     resp.ContentLength,
+		Modified: modified,
+	}, nil
+
+}
+
+// Delete removes an object. Deleting an object that doesn't exist isn't an
+// error.
+func (c *Client) Delete(key string) error {
+
+	resp, err := c.do("DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+
+}
+
+// MinPartSize is the smallest part S3 accepts in a multipart upload, other
+// than the last.
+const MinPartSize = 5 << 20
+
+// Upload stores everything read from r as an object. Objects larger than
+// partSize are sent as a multipart upload with parts of that size, which is
+// aborted if anything goes wrong.
+func (c *Client) Upload(key string, r io.Reader, partSize int) error {
+
+	if partSize < MinPartSize {
+		partSize = MinPartSize
+	}
+
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return c.Put(key, buf[:n])
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := c.createMultipartUpload(key)
+	if err != nil {
+		return err
+	}
+
+	var etags []string
+
+	for n > 0 {
+
+		etag, err := c.uploadPart(key, id, len(etags)+1, buf[:n])
+		if err != nil {
+			c.abortMultipartUpload(key, id)
+			return err
+		}
+		etags = append(etags, etag)
+
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			c.abortMultipartUpload(key, id)
+			return err
+		}
+
+	}
+
+	err = c.completeMultipartUpload(key, id, etags)
+	if err != nil {
+		c.abortMultipartUpload(key, id)
+		return err
+	}
+
+	return nil
+
+}
+
+func (c *Client) createMultipartUpload(key string) (string, error) {
+
+	resp, err := c.do("POST", key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", err
+	}
+
+	if result.UploadID == "" {
+		return "", errors.New("s3: no upload id in response")
+	}
+
+	return result.UploadID, nil
+
+}
+
+func (c *Client) uploadPart(key, id string, number int, data []byte) (string, error) {
+
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {id}}
+
+	resp, err := c.do("PUT", key, query, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("s3: no etag for part %v", number)
+	}
+
+	return etag, nil
+
+}
+
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeRequest struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+func (c *Client) completeMultipartUpload(key, id string, etags []string) error {
+
+	req := new(completeRequest)
+	for i, etag := range etags {
+		req.Parts = append(req.Parts, completePart{PartNumber: i + 1, ETag: etag})
+	}
+
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do("POST", key, url.Values{"uploadId": {id}}, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 can report a failure after it has started sending a 200
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(string(data), "<Error>") {
+		e := &Error{Status: resp.StatusCode}
+		xml.Unmarshal(data, e)
+		return e
+	}
+
+	return nil
+
+}
+
+func (c *Client) abortMultipartUpload(key, id string) error {
+
+	resp, err := c.do("DELETE", key, url.Values{"uploadId": {id}}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package s3test provides an in-memory stand-in for an S3-compatible object
+// store, for testing code that uses package s3.
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server serves objects from memory using path-style requests. Any bucket
+// can be used without being created, and requests aren't authenticated.
+type Server struct {
+	*httptest.Server
+
+	// PageSize is how many objects are returned in each page of a listing.
+	PageSize int
+
+	lock      sync.Mutex
+	objects   map[string][]byte
+	uploads   map[string]map[int][]byte
+	nextID    int
+	downloads int
+}
+
+// NewServer starts a server. It should be closed when no longer needed.
+func NewServer() *Server {
+
+	s := &Server{
+		PageSize: 1000,
+		objects:  make(map[string][]byte),
+		uploads:  make(map[string]map[int][]byte),
+	}
+	s.Server = httptest.NewServer(s)
+
+	return s
+
+}
+
+// Object returns the contents of an object.
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, ok := s.objects[bucket+"/"+key]
+
+	return data, ok
+
+}
+
+// SetObject replaces the contents of an object.
+func (s *Server) SetObject(bucket, key string, data []byte) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.objects[bucket+"/"+key] = data
+
+}
+
+// Uploads returns how many multipart uploads have been started but neither
+// completed nor aborted.
+func (s *Server) Uploads() int {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.uploads)
+
+}
+
+// Downloads returns how many objects have been read with GET requests.
+func (s *Server) Downloads() int {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.downloads
+
+}
+
+func etag(data []byte) string {
+
+	sum := md5.Sum(data)
+
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+
+}
+
+func fail(w http.ResponseWriter, status int, code string) {
+
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%v</Code><Message>%v</Message></Error>", code,
+		http.StatusText(status))
+
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+
+	i := strings.Index(path, "/")
+	if i == -1 {
+		fail(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+	bucket, key := path[:i], path[i+1:]
+
+	switch {
+
+	case key == "" && r.Method == "GET":
+		s.list(w, bucket, query.Get("prefix"), query.Get("continuation-token"))
+
+	case r.Method == "POST" && query["uploads"] != nil:
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%v</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == "PUT" && query.Get("uploadId") != "":
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := ioutil.ReadAll(r.Body)
+		parts[n] = data
+		w.Header().Set("ETag", etag(data))
+
+	case r.Method == "POST" && query.Get("uploadId") != "":
+		s.complete(w, r, bucket+"/"+key, query.Get("uploadId"))
+
+	case r.Method == "DELETE" && query.Get("uploadId") != "":
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		s.objects[bucket+"/"+key] = data
+		w.Header().Set("ETag", etag(data))
+
+	case r.Method == "GET" || r.Method == "HEAD":
+		data, ok := s.objects[bucket+"/"+key]
+		if !ok {
+			fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == "GET" {
+			s.downloads++
+		}
+		w.Write(data)
+
+	case r.Method == "DELETE":
+		delete(s.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+
+	}
+
+}
+
+func (s *Server) list(w http.ResponseWriter, bucket, prefix, token string) {
+
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, bucket+"/"+prefix) {
+			keys = append(keys, strings.TrimPrefix(k, bucket+"/"))
+		}
+	}
+	sort.Strings(keys)
+
+	start := sort.SearchStrings(keys, token)
+	if token != "" && start < len(keys) && keys[start] == token {
+		start++
+	}
+	keys = keys[start:]
+
+	truncated := len(keys) > s.PageSize
+	if truncated {
+		keys = keys[:s.PageSize]
+	}
+
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, k := range keys {
+		fmt.Fprint(w, "<Contents><Key>")
+		xml.EscapeText(w, []byte(k))
+		fmt.Fprintf(w, "</Key><Size>%v</Size></Contents>", len(s.objects[bucket+"/"+k]))
+	}
+	if truncated {
+		fmt.Fprint(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>")
+		xml.EscapeText(w, []byte(keys[len(keys)-1]))
+		fmt.Fprint(w, "</NextContinuationToken>")
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+
+}
+
+func (s *Server) complete(w http.ResponseWriter, r *http.Request, name, id string) {
+
+	parts, ok := s.uploads[id]
+	if !ok {
+		fail(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+
+	err := xml.NewDecoder(r.Body).Decode(&req)
+	if err != nil || len(req.Parts) == 0 {
+		fail(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	var data []byte
+	for i, part := range req.Parts {
+		p, ok := parts[part.PartNumber]
+		if !ok || part.PartNumber != i+1 || part.ETag != etag(p) {
+			fail(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		data = append(data, p...)
+	}
+
+	delete(s.uploads, id)
+	s.objects[name] = data
+
+	fmt.Fprintf(w, "<CompleteMultipartUploadResult><ETag>%v</ETag></CompleteMultipartUploadResult>", etag(data))
+
+}
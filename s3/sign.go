@@ -0,0 +1,150 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDate    = "20060102T150405Z"
+	amzDay     = "20060102"
+	emptyHash  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	signScheme = "AWS4-HMAC-SHA256"
+)
+
+// sign adds Signature Version 4 headers to req, signing the host, any range
+// and every x-amz header.
+func (c *Client) sign(req *http.Request, body []byte, t time.Time) {
+
+	if c.AccessKey == "" {
+		return
+	}
+
+	t = t.UTC()
+
+	hash := emptyHash
+	if body != nil {
+		sum := sha256.Sum256(body)
+		hash = hex.EncodeToString(sum[:])
+	}
+
+	req.Header.Set("x-amz-date", t.Format(amzDate))
+	req.Header.Set("x-amz-content-sha256", hash)
+	if c.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "range" || k == "content-md5" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders string
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		encodePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signed,
+		hash,
+	}, "\n")
+
+	scope := t.Format(amzDay) + "/" + c.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := signScheme + "\n" + t.Format(amzDate) + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), t.Format(amzDay))
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", signScheme+" Credential="+c.AccessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+signature)
+
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+
+}
+
+// encode escapes everything but the characters AWS leaves unreserved.
+func encode(s string) string {
+
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' ||
+			b == '-' || b == '.' || b == '_' || b == '~' {
+			out = append(out, b)
+			continue
+		}
+		out = append(out, '%', "0123456789ABCDEF"[b>>4], "0123456789ABCDEF"[b&15])
+	}
+
+	return string(out)
+
+}
+
+func encodePath(path string) string {
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = encode(s)
+	}
+
+	return strings.Join(segments, "/")
+
+}
+
+func canonicalQuery(query url.Values) string {
+
+	var pairs []string
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, encode(k)+"="+encode(v))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+
+}
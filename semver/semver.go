@@ -0,0 +1,258 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package semver parses major.minor.patch version numbers and the constraints
+// used to select them.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a major.minor.patch version number.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Parse parses a version of the form 1.2.3, with an optional leading v.
+func Parse(s string) (Version, error) {
+
+	v, n, err := parsePartial(s)
+	if err != nil {
+		return v, err
+	}
+
+	if n != 3 {
+		return v, fmt.Errorf("invalid version '%v'; expected major.minor.patch", s)
+	}
+
+	return v, nil
+
+}
+
+// parsePartial parses 1, 1.2 or 1.2.3, returning how many parts were
+// given. Missing parts are zero.
+func parsePartial(s string) (Version, int, error) {
+
+	var v Version
+
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) > 3 {
+		return v, 0, fmt.Errorf("invalid version '%v'", s)
+	}
+
+	fields := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		x, err := strconv.Atoi(part)
+		if err != nil || x < 0 {
+			return v, 0, fmt.Errorf("invalid version '%v'", s)
+		}
+		*fields[i] = x
+	}
+
+	return v, len(parts), nil
+
+}
+
+// Compare returns -1, 0 or 1 if v is older than, the same as, or newer than o.
+func (v Version) Compare(o Version) int {
+
+	a := []int{v.Major, v.Minor, v.Patch}
+	b := []int{o.Major, o.Minor, o.Patch}
+
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+
+	return 0
+
+}
+
+func (v Version) String() string {
+
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+
+}
+
+type comparator struct {
+	op      string
+	version Version
+}
+
+func (c comparator) check(v Version) bool {
+
+	x := v.Compare(c.version)
+
+	switch c.op {
+	case "=":
+		return x == 0
+	case "!=":
+		return x != 0
+	case ">":
+		return x > 0
+	case ">=":
+		return x >= 0
+	case "<":
+		return x < 0
+	case "<=":
+		return x <= 0
+	}
+
+	return false
+
+}
+
+// Constraint is a set of version ranges, such as '>=1.4.0 <2.0.0'.
+// Comparisons separated by spaces or commas must all hold, and '||' separates
+// alternatives. Besides =, !=, >, >=, < and <=, a version may be prefixed by ^
+// to allow changes that keep its leftmost non-zero part, or by ~ to allow
+// patch releases. A bare version matches itself, and a partial one such as
+// 1.4 matches any release it prefixes.
+type Constraint struct {
+	text string
+	sets [][]comparator
+}
+
+// ParseConstraint parses a constraint as described by Constraint.
+func ParseConstraint(s string) (*Constraint, error) {
+
+	c := &Constraint{text: strings.TrimSpace(s)}
+
+	for _, alt := range strings.Split(s, "||") {
+
+		var set []comparator
+		for _, term := range strings.FieldsFunc(alt, func(r rune) bool {
+			return r == ' ' || r == ',' || r == '\t'
+		}) {
+
+			cmps, err := parseComparator(term)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, cmps...)
+
+		}
+
+		if len(set) == 0 {
+			return nil, fmt.Errorf("invalid version constraint '%v'", s)
+		}
+
+		c.sets = append(c.sets, set)
+
+	}
+
+	return c, nil
+
+}
+
+func parseComparator(term string) ([]comparator, error) {
+
+	var op string
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(term, prefix) {
+			op = prefix
+			break
+		}
+	}
+
+	v, n, err := parsePartial(strings.TrimPrefix(term, op))
+	if err != nil {
+		return nil, err
+	}
+
+	// upper is the first release outside the range the term describes
+	upper := v
+	switch {
+	case op == "^" && (v.Major > 0 || n == 1):
+		upper = Version{Major: v.Major + 1}
+	case op == "^" && (v.Minor > 0 || n == 2):
+		upper = Version{Major: v.Major, Minor: v.Minor + 1}
+	case op == "^":
+		upper = Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	case op == "~" && n == 1:
+		upper = Version{Major: v.Major + 1}
+	case op == "~", op == "" && n == 2:
+		upper = Version{Major: v.Major, Minor: v.Minor + 1}
+	case op == "" && n == 1:
+		upper = Version{Major: v.Major + 1}
+	}
+
+	if op == "^" || op == "~" || (op == "" && n < 3) {
+		return []comparator{{">=", v}, {"<", upper}}, nil
+	}
+
+	if op == "" {
+		op = "="
+	}
+
+	return []comparator{{op, v}}, nil
+
+}
+
+// Check returns true if version satisfies the constraint.
+func (c *Constraint) Check(version string) bool {
+
+	v, err := Parse(version)
+	if err != nil {
+		return false
+	}
+
+	return c.Matches(v)
+
+}
+
+// Matches returns true if v satisfies the constraint.
+func (c *Constraint) Matches(v Version) bool {
+
+	for _, set := range c.sets {
+
+		ok := true
+		for _, cmp := range set {
+			if !cmp.check(v) {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			return true
+		}
+
+	}
+
+	return false
+
+}
+
+func (c *Constraint) String() string {
+
+	return c.text
+
+}
+
+// Versions sorts versions from oldest to newest.
+type Versions []Version
+
+func (s Versions) Len() int           { return len(s) }
+func (s Versions) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s Versions) Less(i, j int) bool { return s[i].Compare(s[j]) < 0 }
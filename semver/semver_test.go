@@ -0,0 +1,97 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package semver
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	v, err := Parse("v1.4.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v != (Version{1, 4, 10}) || v.String() != "1.4.10" {
+		t.Errorf("parsed %v", v)
+	}
+
+	for _, bad := range []string{"", "1.4", "1.4.x", "1.-4.0", "1.2.3.4"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("'%v' should not parse", bad)
+		}
+	}
+
+}
+
+func TestConstraint(t *testing.T) {
+
+	tests := []struct {
+		constraint string
+		match      []string
+		reject     []string
+	}{
+		{">=1.4.0 <2.0.0", []string{"1.4.0", "1.9.9"}, []string{"1.3.9", "2.0.0"}},
+		{">=1.4.0, !=1.5.2", []string{"1.5.1", "3.0.0"}, []string{"1.5.2"}},
+		{"1.4.2", []string{"1.4.2"}, []string{"1.4.3"}},
+		{"1.4", []string{"1.4.0", "1.4.7"}, []string{"1.5.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.5.2", []string{"0.5.9"}, []string{"0.6.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.2.3", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"<1.0.0 || >=2.1.0", []string{"0.9.0", "2.1.0"}, []string{"1.5.0", "2.0.9"}},
+	}
+
+	for _, test := range tests {
+
+		c, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Errorf("%v: %v", test.constraint, err)
+			continue
+		}
+
+		for _, v := range test.match {
+			if !c.Check(v) {
+				t.Errorf("'%v' should match %v", test.constraint, v)
+			}
+		}
+
+		for _, v := range test.reject {
+			if c.Check(v) {
+				t.Errorf("'%v' should not match %v", test.constraint, v)
+			}
+		}
+
+	}
+
+	for _, bad := range []string{"", ">=", ">=1.4.0 ||", "=>1.0.0"} {
+		if _, err := ParseConstraint(bad); err == nil {
+			t.Errorf("'%v' should not parse", bad)
+		}
+	}
+
+}
+
+func TestVersionsSort(t *testing.T) {
+
+	list := Versions{{1, 10, 0}, {0, 9, 9}, {1, 2, 0}}
+	sort.Sort(list)
+
+	if list[0].String() != "0.9.9" || list[2].String() != "1.10.0" {
+		t.Errorf("sorted %v", list)
+	}
+
+}
@@ -0,0 +1,164 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sync"
+)
+
+const (
+	// gelfChunkSize is the most payload put in a UDP datagram before the
+	// message is split into chunks.
+	gelfChunkSize = 8192 - 12
+	gelfMaxChunks = 128
+)
+
+var gelfInvalidField = regexp.MustCompile(`[^\w\.\-]`)
+
+type gelfSink struct {
+	conn   net.Conn
+	stream bool
+	host   string
+	mu     sync.Mutex
+}
+
+func dialGELF(network, addr, host string) (Sink, error) {
+
+	conn, err := dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gelfSink{
+		conn:   conn,
+		stream: network == "tcp",
+		host:   host,
+	}, nil
+
+}
+
+func (s *gelfSink) Send(line *Line, tags *Tags) error {
+
+	data, err := json.Marshal(formatGELF(line, tags, s.host))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// messages sent over TCP are delimited by a null byte
+	if s.stream {
+		_, err = s.conn.Write(append(data, 0))
+		return err
+	}
+
+	if len(data) <= gelfChunkSize {
+		_, err = s.conn.Write(data)
+		return err
+	}
+
+	return s.writeChunks(data)
+
+}
+
+// writeChunks splits a message too big for one datagram into GELF chunks.
+func (s *gelfSink) writeChunks(data []byte) error {
+
+	count := (len(data) + gelfChunkSize - 1) / gelfChunkSize
+	if count > gelfMaxChunks {
+		return fmt.Errorf("log record of %d bytes is too big for GELF over UDP", len(data))
+	}
+
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+
+		end := (i + 1) * gelfChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunk := append([]byte{0x1e, 0x0f}, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*gelfChunkSize:end]...)
+
+		_, err = s.conn.Write(chunk)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+func (s *gelfSink) Close() error {
+
+	return s.conn.Close()
+
+}
+
+// formatGELF returns a GELF 1.1 message for line, with the tags and any
+// fields as additional fields.
+func formatGELF(line *Line, tags *Tags, host string) map[string]interface{} {
+
+	msg := line.Message
+	if msg == "" {
+		msg = "-"
+	}
+
+	m := map[string]interface{}{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": msg,
+		"timestamp":     float64(line.Time.UnixNano()/int64(1e6)) / 1e3,
+		"level":         severity(line.Level),
+	}
+
+	for k, v := range line.Fields {
+		k = "_" + gelfInvalidField.ReplaceAllString(k, "_")
+		if k == "_id" {
+			continue
+		}
+		if _, ok := v.(float64); !ok {
+			v = fieldValue(v)
+		}
+		m[k] = v
+	}
+
+	// the tags win over fields the app logged with the same names
+	if tags.App != "" {
+		m["_app"] = tags.App
+	}
+	if tags.Version != "" {
+		m["_app_version"] = tags.Version
+	}
+	if tags.Instance != "" {
+		m["_instance"] = tags.Instance
+	}
+
+	return m
+
+}
@@ -0,0 +1,216 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Level is the severity of a console line.
+type Level int
+
+// Levels in increasing order of severity.
+const (
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+func (l Level) String() string {
+
+	if l < LevelTrace || l > LevelFatal {
+		return "unknown"
+	}
+
+	return levelNames[l]
+
+}
+
+// ParseLevel returns the level with the given name. Common aliases such as
+// "warning", "err", "crit" and "panic" are accepted.
+func ParseLevel(s string) (Level, error) {
+
+	switch strings.ToLower(s) {
+	case "trace", "trc":
+		return LevelTrace, nil
+	case "debug", "dbg":
+		return LevelDebug, nil
+	case "info", "inf", "notice":
+		return LevelInfo, nil
+	case "warn", "warning", "wrn":
+		return LevelWarn, nil
+	case "error", "err", "erro":
+		return LevelError, nil
+	case "fatal", "crit", "critical", "panic", "emerg", "alert":
+		return LevelFatal, nil
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level '%v'", s)
+
+}
+
+// Line is a single line of a guest's serial console.
+type Line struct {
+	Time    time.Time
+	Level   Level
+	Message string
+
+	// Fields holds the remaining members of a line logged as a JSON object.
+	Fields map[string]interface{}
+
+	decoded bool
+}
+
+var (
+	levelKeys   = []string{"level", "lvl", "severity"}
+	messageKeys = []string{"msg", "message"}
+	timeKeys    = []string{"time", "ts", "timestamp"}
+
+	levelPrefix = regexp.MustCompile(`(?i)^\s*\[?(trace|debug|info|notice|warn|warning|error|err|fatal|crit|critical|panic)\]?[:\s]`)
+	levelField  = regexp.MustCompile(`(?i)\blevel=(\w+)`)
+)
+
+// Decode parses a console line received at t. Lines without a recognisable
+// level count as info. With decodeJSON, lines holding a JSON object have their
+// level and message taken from its common logging keys, and keep the rest as
+// fields.
+func Decode(text string, t time.Time, decodeJSON bool) *Line {
+
+	line := &Line{Time: t, Level: LevelInfo, Message: text}
+
+	trimmed := strings.TrimSpace(text)
+	if decodeJSON && strings.HasPrefix(trimmed, "{") {
+		fields := make(map[string]interface{})
+		if json.Unmarshal([]byte(trimmed), &fields) == nil {
+			line.decodeFields(fields)
+			return line
+		}
+	}
+
+	m := levelPrefix.FindStringSubmatch(text)
+	if m == nil {
+		m = levelField.FindStringSubmatch(text)
+	}
+
+	if m != nil {
+		if l, err := ParseLevel(m[1]); err == nil {
+			line.Level = l
+		}
+	}
+
+	return line
+
+}
+
+func (line *Line) decodeFields(fields map[string]interface{}) {
+
+	line.Message = ""
+	line.decoded = true
+
+	if v, ok := take(fields, levelKeys); ok {
+		if l, err := ParseLevel(fmt.Sprint(v)); err == nil {
+			line.Level = l
+		}
+	}
+
+	if v, ok := take(fields, messageKeys); ok {
+		line.Message = fmt.Sprint(v)
+	}
+
+	// keep the time the line was received unless the guest logged an RFC
+	// 3339 timestamp of its own
+	if v, ok := take(fields, timeKeys); ok {
+		if s, ok := v.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				line.Time = t
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		line.Fields = fields
+	}
+
+}
+
+// take removes the first of keys found in fields and returns its value.
+func take(fields map[string]interface{}, keys []string) (interface{}, bool) {
+
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			delete(fields, k)
+			return v, true
+		}
+	}
+
+	return nil, false
+
+}
+
+// fieldString formats the line's fields as sorted key=value pairs.
+func (line *Line) fieldString() string {
+
+	keys := line.sortedFields()
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		v := fieldValue(line.Fields[k])
+		if strings.ContainsAny(v, " \t\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		pairs[i] = k + "=" + v
+	}
+
+	return strings.Join(pairs, " ")
+
+}
+
+// sortedFields returns the names of the line's fields in order.
+func (line *Line) sortedFields() []string {
+
+	keys := make([]string, 0, len(line.Fields))
+	for k := range line.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+
+}
+
+// fieldValue formats a decoded JSON value, leaving strings as they are.
+func fieldValue(v interface{}) string {
+
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+
+}
@@ -0,0 +1,254 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	otlpPath      = "/v1/logs"
+	otlpBatchSize = 100
+	otlpInterval  = time.Second
+)
+
+// otlpSink posts batches of records to an OTLP/HTTP logs endpoint, encoded as
+// JSON.
+type otlpSink struct {
+	url    string
+	host   string
+	client *http.Client
+
+	mu    sync.Mutex
+	batch []otlpEntry
+	err   error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type otlpEntry struct {
+	line *Line
+	tags Tags
+}
+
+func newOTLP(scheme string, u *url.URL, host string) Sink {
+
+	path := u.Path
+	if path == "" || path == "/" {
+		path = otlpPath
+	}
+
+	s := &otlpSink{
+		url:    scheme + "://" + u.Host + path,
+		host:   host,
+		client: &http.Client{Timeout: 10 * time.Second},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go s.loop()
+
+	return s
+
+}
+
+// Send queues the line to be posted with the next batch, and returns any error
+// from posting an earlier one.
+func (s *otlpSink) Send(line *Line, tags *Tags) error {
+
+	s.mu.Lock()
+	s.batch = append(s.batch, otlpEntry{line: line, tags: *tags})
+	full := len(s.batch) >= otlpBatchSize
+	err := s.err
+	s.err = nil
+	s.mu.Unlock()
+
+	if full {
+		return s.flush()
+	}
+
+	return err
+
+}
+
+func (s *otlpSink) loop() {
+
+	defer close(s.done)
+
+	ticker := time.NewTicker(otlpInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			err := s.flush()
+			if err != nil {
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+			}
+		}
+	}
+
+}
+
+func (s *otlpSink) Close() error {
+
+	close(s.stop)
+	<-s.done
+
+	err := s.flush()
+	if err == nil {
+		err = s.err
+	}
+
+	return err
+
+}
+
+func (s *otlpSink) flush() error {
+
+	s.mu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(s.request(batch))
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not send logs: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("log sink responded with %v", resp.Status)
+	}
+
+	return nil
+
+}
+
+// request builds an ExportLogsServiceRequest, with one resource for each set
+// of tags in the batch.
+func (s *otlpSink) request(batch []otlpEntry) map[string]interface{} {
+
+	var order []Tags
+	records := make(map[Tags][]interface{})
+
+	for _, e := range batch {
+
+		if _, ok := records[e.tags]; !ok {
+			order = append(order, e.tags)
+		}
+
+		records[e.tags] = append(records[e.tags], otlpRecord(e.line))
+
+	}
+
+	logs := make([]interface{}, len(order))
+	for i, tags := range order {
+
+		attrs := []interface{}{otlpAttribute("host.name", s.host)}
+		if tags.App != "" {
+			attrs = append(attrs, otlpAttribute("service.name", tags.App))
+		}
+		if tags.Version != "" {
+			attrs = append(attrs, otlpAttribute("service.version", tags.Version))
+		}
+		if tags.Instance != "" {
+			attrs = append(attrs, otlpAttribute("service.instance.id", tags.Instance))
+		}
+
+		logs[i] = map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": attrs},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]interface{}{"name": "vcli"},
+				"logRecords": records[tags],
+			}},
+		}
+
+	}
+
+	return map[string]interface{}{"resourceLogs": logs}
+
+}
+
+func otlpRecord(line *Line) map[string]interface{} {
+
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	r := map[string]interface{}{
+		"timeUnixNano":         strconv.FormatInt(line.Time.UnixNano(), 10),
+		"observedTimeUnixNano": now,
+		"severityNumber":       1 + 4*int(line.Level),
+		"severityText":         strings.ToUpper(line.Level.String()),
+		"body":                 otlpValue(line.Message),
+	}
+
+	var attrs []interface{}
+	for _, k := range line.sortedFields() {
+		attrs = append(attrs, otlpAttribute(k, line.Fields[k]))
+	}
+
+	if len(attrs) > 0 {
+		r["attributes"] = attrs
+	}
+
+	return r
+
+}
+
+func otlpAttribute(key string, value interface{}) map[string]interface{} {
+
+	return map[string]interface{}{"key": key, "value": otlpValue(value)}
+
+}
+
+// otlpValue converts a decoded JSON value to an OTLP AnyValue.
+func otlpValue(v interface{}) map[string]interface{} {
+
+	switch x := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": x}
+	case bool:
+		return map[string]interface{}{"boolValue": x}
+	case float64:
+		if x == float64(int64(x)) {
+			return map[string]interface{}{"intValue": strconv.FormatInt(int64(x), 10)}
+		}
+		return map[string]interface{}{"doubleValue": x}
+	}
+
+	return map[string]interface{}{"stringValue": fieldValue(v)}
+
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeFormat is how timestamps are printed in front of console lines.
+const TimeFormat = "15:04:05.000"
+
+// Printer writes a guest's console lines to W, leaving out any below Level.
+type Printer struct {
+	W          io.Writer
+	Level      Level
+	Timestamps bool
+
+	// JSON decodes lines logged as JSON objects; see Decode.
+	JSON bool
+
+	// Prefix is written in front of every line, such as the name of the app
+	// it came from.
+	Prefix string
+
+	// Sink, if set, is forwarded every line regardless of its level, tagged
+	// with Tags. Lines are always decoded for it.
+	Sink Sink
+	Tags Tags
+
+	mu         sync.Mutex
+	sinkFailed bool
+}
+
+// Print decodes and writes a single line, which was received now.
+func (p *Printer) Print(text string) {
+
+	now := time.Now()
+	line := Decode(text, now, p.JSON)
+
+	if p.Sink != nil {
+		decoded := line
+		if !p.JSON {
+			decoded = Decode(text, now, true)
+		}
+		p.forward(decoded)
+	}
+
+	if line.Level < p.Level {
+		return
+	}
+
+	var fields []string
+	if p.Timestamps {
+		fields = append(fields, line.Time.Format(TimeFormat))
+	}
+
+	if line.decoded {
+		fields = append(fields, fmt.Sprintf("%-5s", strings.ToUpper(line.Level.String())))
+		if line.Message != "" {
+			fields = append(fields, line.Message)
+		}
+		if len(line.Fields) > 0 {
+			fields = append(fields, line.fieldString())
+		}
+	} else {
+		fields = append(fields, line.Message)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintf(p.W, "%s%s\n", p.Prefix, strings.Join(fields, " "))
+
+}
+
+// forward sends the line to the sink, warning about the first failure only so
+// a collector that goes away doesn't drown out the console. The warning goes
+// to stderr, since W may be discarding the console.
+func (p *Printer) forward(line *Line) {
+
+	err := p.Sink.Send(line, &p.Tags)
+	if err == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.sinkFailed {
+		p.sinkFailed = true
+		fmt.Fprintf(os.Stderr, "%sWARNING: log sink: %v\n", p.Prefix, err)
+	}
+
+}
+
+// Copy prints every line read from r until it is exhausted.
+func (p *Printer) Copy(r io.Reader) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		p.Print(strings.TrimRight(scanner.Text(), "\r"))
+	}
+
+	return scanner.Err()
+
+}
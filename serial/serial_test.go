@@ -0,0 +1,103 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+
+	now := time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		text    string
+		json    bool
+		level   Level
+		message string
+	}{
+		{"hello world", false, LevelInfo, "hello world"},
+		{"[ERROR] disk full", false, LevelError, "[ERROR] disk full"},
+		{"warning: low memory", false, LevelWarn, "warning: low memory"},
+		{"ts=1 level=debug msg=x", false, LevelDebug, "ts=1 level=debug msg=x"},
+		{`{"level":"warn","msg":"slow"}`, false, LevelInfo, `{"level":"warn","msg":"slow"}`},
+		{`{"level":"warn","msg":"slow"}`, true, LevelWarn, "slow"},
+		{`{"severity":"CRITICAL","message":"down"}`, true, LevelFatal, "down"},
+		{`{not json`, true, LevelInfo, `{not json`},
+	}
+
+	for _, test := range tests {
+		line := Decode(test.text, now, test.json)
+		if line.Level != test.level || line.Message != test.message {
+			t.Errorf("Decode(%q) = %v %q, want %v %q", test.text, line.Level, line.Message, test.level, test.message)
+		}
+	}
+
+}
+
+func TestDecodeFields(t *testing.T) {
+
+	now := time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	line := Decode(`{"time":"2017-03-01T09:00:00Z","level":"info","msg":"served","path":"/","status":200}`, now, true)
+	if !line.Time.Equal(time.Date(2017, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the guest's timestamp, got %v", line.Time)
+	}
+
+	if s := line.fieldString(); s != "path=/ status=200" {
+		t.Errorf("unexpected fields: %q", s)
+	}
+
+}
+
+func TestPrinter(t *testing.T) {
+
+	buf := new(bytes.Buffer)
+	p := &Printer{W: buf, Level: LevelWarn, JSON: true, Prefix: "app | "}
+
+	input := strings.Join([]string{
+		"booting",
+		"WARN: clock skew",
+		`{"level":"error","msg":"failed","code":3}`,
+		`{"level":"debug","msg":"noise"}`,
+	}, "\r\n")
+
+	err := p.Copy(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "app | WARN: clock skew\napp | ERROR failed code=3\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+
+}
+
+func TestParseLevel(t *testing.T) {
+
+	_, err := ParseLevel("loud")
+	if err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+
+	l, err := ParseLevel("Warning")
+	if err != nil || l != LevelWarn {
+		t.Errorf("ParseLevel(\"Warning\") = %v, %v", l, err)
+	}
+
+}
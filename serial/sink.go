@@ -0,0 +1,109 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+const dialTimeout = 5 * time.Second
+
+// Tags identify where the records forwarded to a sink came from.
+type Tags struct {
+	App      string
+	Version  string
+	Instance string
+}
+
+// Sink forwards console lines to a log collector as structured records.
+type Sink interface {
+	Send(line *Line, tags *Tags) error
+	Close() error
+}
+
+// OpenSink connects to the log collector at rawurl, which is one of:
+//
+//	syslog://HOST:PORT      RFC 5424 syslog over UDP (syslog+tcp for TCP)
+//	gelf://HOST:PORT        GELF over UDP (gelf+tcp for TCP)
+//	otlp://HOST:PORT[/PATH] OTLP/HTTP logs (otlps for HTTPS)
+func OpenSink(rawurl string) (Sink, error) {
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("log sink '%v' has no host", rawurl)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	switch u.Scheme {
+	case "syslog", "syslog+udp":
+		return dialSyslog("udp", u.Host, host)
+	case "syslog+tcp":
+		return dialSyslog("tcp", u.Host, host)
+	case "gelf", "gelf+udp":
+		return dialGELF("udp", u.Host, host)
+	case "gelf+tcp":
+		return dialGELF("tcp", u.Host, host)
+	case "otlp", "otlp+http":
+		return newOTLP("http", u, host), nil
+	case "otlps", "otlp+https":
+		return newOTLP("https", u, host), nil
+	case "":
+		return nil, errors.New("log sink must be a URL such as 'syslog://localhost:514'")
+	}
+
+	return nil, fmt.Errorf("unsupported log sink '%v'", u.Scheme)
+
+}
+
+// severity maps a level onto the syslog severities also used by GELF.
+func severity(l Level) int {
+
+	switch l {
+	case LevelFatal:
+		return 2
+	case LevelError:
+		return 3
+	case LevelWarn:
+		return 4
+	case LevelInfo:
+		return 6
+	}
+
+	return 7
+
+}
+
+func dial(network, addr string) (net.Conn, error) {
+
+	conn, err := net.DialTimeout(network, addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to log sink: %v", err)
+	}
+
+	return conn, nil
+
+}
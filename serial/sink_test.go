@@ -0,0 +1,234 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sinkTags = &Tags{App: "hello", Version: "1.2.0", Instance: "hello-1"}
+
+func sinkLine() *Line {
+
+	return Decode(`{"level":"warn","msg":"slow request","path":"/a b"}`, time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC), true)
+
+}
+
+func listenUDP(t *testing.T) *net.UDPConn {
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return conn
+
+}
+
+func sendLine(t *testing.T, url string) {
+
+	sink, err := OpenSink(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sink.Send(sinkLine(), sinkTags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sink.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestSyslogSink(t *testing.T) {
+
+	conn := listenUDP(t)
+	defer conn.Close()
+
+	sendLine(t, "syslog://"+conn.LocalAddr().String())
+
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := string(buf[:n])
+	expected := `<12>1 2017-03-01T10:30:00.000000Z `
+	if !strings.HasPrefix(msg, expected) {
+		t.Errorf("unexpected header: %s", msg)
+	}
+
+	expected = ` hello - - [vorteil@32473 version="1.2.0" instance="hello-1" level="warn" path="/a b"] slow request`
+	if !strings.HasSuffix(msg, expected) {
+		t.Errorf("unexpected message: %s", msg)
+	}
+
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	sendLine(t, "syslog+tcp://"+l.Addr().String())
+
+	// octet counting puts the length of the message in front of it
+	msg := <-received
+	i := strings.Index(msg, " ")
+	if i < 0 || msg[:i] != strconv.Itoa(len(msg)-i-1) {
+		t.Errorf("message not framed: %q", msg)
+	}
+
+}
+
+func TestGELFSink(t *testing.T) {
+
+	conn := listenUDP(t)
+	defer conn.Close()
+
+	sendLine(t, "gelf://"+conn.LocalAddr().String())
+
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := make(map[string]interface{})
+	err = json.Unmarshal(buf[:n], &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"version":       "1.1",
+		"short_message": "slow request",
+		"level":         4.0,
+		"timestamp":     1488364200.0,
+		"_app":          "hello",
+		"_app_version":  "1.2.0",
+		"_instance":     "hello-1",
+		"_path":         "/a b",
+	}
+
+	for k, v := range expected {
+		if m[k] != v {
+			t.Errorf("%v: expected %v, got %v", k, v, m[k])
+		}
+	}
+
+}
+
+func TestOTLPSink(t *testing.T) {
+
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	sendLine(t, "otlp://"+strings.TrimPrefix(server.URL, "http://"))
+
+	var req struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value map[string]interface{}
+				}
+			}
+			ScopeLogs []struct {
+				LogRecords []struct {
+					TimeUnixNano   string
+					SeverityNumber int
+					SeverityText   string
+					Body           map[string]interface{}
+				}
+			}
+		}
+	}
+
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+
+	if len(req.ResourceLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("unexpected request: %s", body)
+	}
+
+	attrs := make(map[string]interface{})
+	for _, a := range req.ResourceLogs[0].Resource.Attributes {
+		attrs[a.Key] = a.Value["stringValue"]
+	}
+
+	if attrs["service.name"] != "hello" || attrs["service.version"] != "1.2.0" {
+		t.Errorf("unexpected resource: %v", attrs)
+	}
+
+	records := req.ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	r := records[0]
+	if r.SeverityNumber != 13 || r.SeverityText != "WARN" || r.Body["stringValue"] != "slow request" || r.TimeUnixNano != "1488364200000000000" {
+		t.Errorf("unexpected record: %+v", r)
+	}
+
+}
+
+func TestOpenSinkInvalid(t *testing.T) {
+
+	for _, url := range []string{"localhost:514", "kafka://localhost:9092", "syslog://"} {
+		if _, err := OpenSink(url); err == nil {
+			t.Errorf("expected an error opening %v", url)
+		}
+	}
+
+}
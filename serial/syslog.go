@@ -0,0 +1,141 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package serial
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// syslogID is the structured data element records are tagged with, under the
+// enterprise number reserved for documentation by RFC 5612.
+const syslogID = "vorteil@32473"
+
+// syslogTime is an RFC 3339 timestamp limited to the precision RFC 5424
+// allows.
+const syslogTime = "2006-01-02T15:04:05.000000Z07:00"
+
+type syslogSink struct {
+	conn   net.Conn
+	stream bool
+	host   string
+	mu     sync.Mutex
+}
+
+func dialSyslog(network, addr, host string) (Sink, error) {
+
+	conn, err := dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{
+		conn:   conn,
+		stream: network == "tcp",
+		host:   host,
+	}, nil
+
+}
+
+func (s *syslogSink) Send(line *Line, tags *Tags) error {
+
+	msg := formatSyslog(line, tags, s.host)
+
+	// messages sent over TCP are framed by octet counting (RFC 6587)
+	if s.stream {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.conn.Write([]byte(msg))
+
+	return err
+
+}
+
+func (s *syslogSink) Close() error {
+
+	return s.conn.Close()
+
+}
+
+// formatSyslog renders line as an RFC 5424 message from the user facility,
+// with the tags and any fields as structured data.
+func formatSyslog(line *Line, tags *Tags, host string) string {
+
+	pri := 8 + severity(line.Level)
+
+	app := syslogName(tags.App, 48)
+	if app == "-" {
+		app = "vorteil"
+	}
+
+	sd := "[" + syslogID
+	if tags.Version != "" {
+		sd += syslogParam("version", tags.Version)
+	}
+	if tags.Instance != "" {
+		sd += syslogParam("instance", tags.Instance)
+	}
+	sd += syslogParam("level", line.Level.String())
+	for _, k := range line.sortedFields() {
+		sd += syslogParam(k, fieldValue(line.Fields[k]))
+	}
+	sd += "]"
+
+	msg := "<" + strconv.Itoa(pri) + ">1 " + line.Time.UTC().Format(syslogTime)
+	msg += " " + syslogName(host, 255) + " " + app + " - - " + sd
+	if line.Message != "" {
+		msg += " " + line.Message
+	}
+
+	return msg
+
+}
+
+// syslogName limits s to the printable characters RFC 5424 allows in header
+// fields and structured data names, or returns the nil value "-" if nothing
+// is left.
+func syslogName(s string, max int) string {
+
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, s)
+
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	if s == "" {
+		return "-"
+	}
+
+	return s
+
+}
+
+func syslogParam(name, value string) string {
+
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+
+	return " " + syslogName(name, 32) + "=\"" + value + "\""
+
+}
@@ -21,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sisatech/vcli/oci"
 	"github.com/sisatech/vcli/vml"
 )
 
@@ -32,10 +33,12 @@ type BuildConfig struct {
 	ReleaseDate time.Time `yaml:"release-date" json:"release-date"`
 	Version     string    `yaml:"version" json:"version"`
 	AppURL      string    `yaml:"url" json:"appurl"`
+	Kernel      string    `yaml:"kernel" json:"kernel,omitempty"`
 
 	App     *BuildAppConfig `yaml:"app,flow" json:"app,flow"`
 	Network *NetworkConfig  `yaml:"network,flow" json:"network,flow,omitempty"`
 	Disk    *DiskConfig     `yaml:"disk,flow" json:"disk,flow"`
+	VM      *VMConfig       `yaml:"vm,flow" json:"vm,flow,omitempty"`
 
 	Redirects *RedirectConfig
 	NTP       *NTPConfig
@@ -48,6 +51,57 @@ type DiskConfig struct {
 	DiskSize   int    `yaml:"disk-size" json:"disksize"`
 }
 
+// Virtual hardware models supported in the vm section of a BuildConfig.
+const (
+	NICModelE1000  = "e1000"
+	NICModelVirtio = "virtio"
+
+	DiskBusSATA = "sata"
+	DiskBusSCSI = "scsi"
+)
+
+// KernelArgsMax is the longest kernel command line extension that fits in
+// the image header's 256 byte field along with its NUL terminator.
+const KernelArgsMax = 255
+
+// VMConfig describes the virtual machine an app should run on. Fields left
+// empty fall back to the defaults of whatever launches the app, and flags on
+// the command line override them.
+type VMConfig struct {
+	Memory     uint32 `yaml:"memory" json:"memory,omitempty"`
+	VCPUs      uint16 `yaml:"vcpus" json:"vcpus,omitempty"`
+	NICModel   string `yaml:"nic-model" json:"nicmodel,omitempty"`
+	DiskBus    string `yaml:"disk-bus" json:"diskbus,omitempty"`
+	KernelArgs string `yaml:"kernel-args" json:"kernelargs,omitempty"`
+}
+
+// Validate checks the vm section for values no hypervisor supports.
+func (vm *VMConfig) Validate() error {
+
+	if vm.Memory != 0 && (vm.Memory < 16 || vm.Memory%4 != 0) {
+		return fmt.Errorf("vm memory must be at least 16 MB and a multiple of 4, not %d", vm.Memory)
+	}
+
+	switch vm.NICModel {
+	case "", NICModelE1000, NICModelVirtio:
+	default:
+		return fmt.Errorf("unsupported vm nic-model '%v'; use '%v' or '%v'", vm.NICModel, NICModelE1000, NICModelVirtio)
+	}
+
+	switch vm.DiskBus {
+	case "", DiskBusSATA, DiskBusSCSI:
+	default:
+		return fmt.Errorf("unsupported vm disk-bus '%v'; use '%v' or '%v'", vm.DiskBus, DiskBusSATA, DiskBusSCSI)
+	}
+
+	if len(vm.KernelArgs) > KernelArgsMax {
+		return fmt.Errorf("vm kernel-args too long: %d; maximum %d", len(vm.KernelArgs), KernelArgsMax)
+	}
+
+	return nil
+
+}
+
 // BuildAppConfig contains app specific build information
 type BuildAppConfig struct {
 	// BinaryType string   `yaml:"type" json:"type"`
@@ -94,6 +148,11 @@ func VCFGHealthCheck(home, path string, force bool) error {
 	var repo bool
 	buf := make([]byte, 0)
 	var err error
+	// configs in a registry can't be fixed up
+	if strings.HasPrefix(path, oci.Prefix) {
+		return nil
+	}
+
 	// IF REPO CONFIG FILE, DO APPROPRIATE LOGIC
 	if strings.HasPrefix(path, RepoPrefix) {
 		repo = true
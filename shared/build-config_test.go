@@ -0,0 +1,34 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVMConfigKernelArgs(t *testing.T) {
+
+	vm := &VMConfig{KernelArgs: strings.Repeat("x", KernelArgsMax)}
+	if err := vm.Validate(); err != nil {
+		t.Errorf("longest kernel args rejected: %v", err)
+	}
+
+	// the field in the image header needs room for a NUL terminator
+	vm.KernelArgs += "x"
+	if err := vm.Validate(); err == nil || len(vm.KernelArgs) != 256 {
+		t.Errorf("256 bytes of kernel args accepted")
+	}
+
+}
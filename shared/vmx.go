@@ -13,150 +13,630 @@
 // limitations under the License.
 package shared
 
-import "strings"
-
-// TODO: cleanup this file
-
-const vmxFile = `#!/usr/bin/vmware
-debugStub.listen.guest64 = "TRUE"
-debugStub.hideBreakpoints= "TRUE"
-debugStub.listen.guest64.remote = "TRUE"
-.encoding = "UTF-8"
-config.version = "8"
-bios.bootdelay = "0"
-virtualHW.version = "11"
-vcpu.hotadd = "FALSE"
-scsi0.present = "FALSE"
-scsi0.virtualDev = "lsilogic"
-sata0.present = "TRUE"
-memsize = "(MEM)"
-mem.hotadd = "FALSE"
-sata0:0.present = "TRUE"
-sata0:0.fileName = "(VMDK)"
-ethernet0.present = "(ETH0)"
-ethernet0.connectionType = "nat"
-ethernet0.virtualDev = "e1000"
-ethernet0.wakeOnPcktRcv = "FALSE"
-ethernet0.addressType = "generated"
-ethernet0.pciSlotNumber = "1024"
-ethernet1.present = "(ETH1)"
-ethernet1.connectionType = "nat"
-ethernet1.virtualDev = "e1000"
-ethernet1.wakeOnPcktRcv = "FALSE"
-ethernet1.addressType = "generated"
-ethernet1.pciSlotNumber = "1025"
-ethernet2.present = "(ETH2)"
-ethernet2.connectionType = "nat"
-ethernet2.virtualDev = "e1000"
-ethernet2.wakeOnPcktRcv = "FALSE"
-ethernet2.addressType = "generated"
-ethernet2.pciSlotNumber = "1026"
-ethernet3.present = "(ETH3)"
-ethernet3.connectionType = "nat"
-ethernet3.virtualDev = "e1000"
-ethernet3.wakeOnPcktRcv = "FALSE"
-ethernet3.addressType = "generated"
-ethernet3.pciSlotNumber = "1027"
-usb.present = "FALSE"
-mks.enable3d = "FALSE"
-svga.graphicsMemoryKB = "786432"
-pciBridge0.present = "TRUE"
-pciBridge4.present = "TRUE"
-pciBridge4.virtualDev = "pcieRootPort"
-pciBridge4.functions = "8"
-pciBridge5.present = "TRUE"
-pciBridge5.virtualDev = "pcieRootPort"
-pciBridge5.functions = "8"
-pciBridge6.present = "TRUE"
-pciBridge6.virtualDev = "pcieRootPort"
-pciBridge6.functions = "8"
-pciBridge7.present = "TRUE"
-hpet0.present = "FALSE"
-usb.vbluetooth.startConnected = "TRUE"
-displayName = "(NAME)"
-guestOS = "Other"
-nvram = "(NVRAM)"
-virtualHW.productCompatibility = "hosted"
-powerType.powerOff = "soft"
-powerType.powerOn = "soft"
-powerType.suspend = "soft"
-powerType.reset = "soft"
-replay.supported = "FALSE"
-replay.filename = ""
-sata0:0.redo = ""
-pciBridge0.pciSlotNumber = "17"
-pciBridge4.pciSlotNumber = "21"
-pciBridge5.pciSlotNumber = "22"
-pciBridge6.pciSlotNumber = "23"
-pciBridge7.pciSlotNumber = "24"
-scsi0.pciSlotNumber = "16"
-usb.pciSlotNumber = "32"
-vmci0.pciSlotNumber = "36"
-sata0.pciSlotNumber = "37"
-vmci0.id = "-1035185677"
-monitor.phys_bits_used = "42"
-vmotion.checkpointFBSize = "33554432"
-vmotion.checkpointSVGAPrimarySize = "33554432"
-cleanShutdown = "TRUE"
-softPowerOff = "FALSE"
-usb:0.present = "FALSE"
-usb:0.deviceType = "hid"
-usb:0.port = "0"
-usb:0.parent = "-1"
-usb:1.speed = "2"
-usb:1.present = "FALSE"
-usb:1.deviceType = "hub"
-usb:1.port = "1"
-usb:1.parent = "-1"
-numvcpus = "(CPU)"
-sata0:1.present = "FALSE"
-ehci.present = "FALSE"
-sound.present = "FALSE"
-serial0.present = "TRUE"
-serial0.fileType = "file"
-serial0.fileName = "(SERIALFILE)"
-floppy0.present = "FALSE"
-extendedConfigFile = "(EXTCONFIG)"
-log.fileName = (LOGILE)`
-
-// GenerateVMX returns a temporary vmx file for vcli
-func GenerateVMX(cores, memory, disk, name, dir string, numberOfNetworkCards int) string {
-	replace := func(in, replace, with string) string {
-		return strings.Replace(in, replace, with, -1)
-	}
-
-	vmx := replace(vmxFile, "(VMDK)", disk)
-	vmx = replace(vmx, "(CPU)", cores)
-	vmx = replace(vmx, "(NAME)", name)
-	vmx = replace(vmx, "(MEM)", memory)
-	vmx = replace(vmx, "(NVRAM)", dir+"/"+name+".nvram")
-	vmx = replace(vmx, "(EXTCONFIG)", dir+"/"+name+".vmxf")
-	vmx = replace(vmx, "(LOGILE)", dir+"/"+name+".log")
-	vmx = replace(vmx, "(SERIALFILE)", dir+"/serial.log")
-
-	if numberOfNetworkCards > 0 {
-		vmx = replace(vmx, "(ETH0)", "true")
-	} else {
-		vmx = replace(vmx, "(ETH0)", "false")
-	}
-
-	if numberOfNetworkCards > 1 {
-		vmx = replace(vmx, "(ETH1)", "true")
-	} else {
-		vmx = replace(vmx, "(ETH1)", "false")
-	}
-
-	if numberOfNetworkCards > 2 {
-		vmx = replace(vmx, "(ETH2)", "true")
-	} else {
-		vmx = replace(vmx, "(ETH2)", "false")
-	}
-
-	if numberOfNetworkCards > 3 {
-		vmx = replace(vmx, "(ETH3)", "true")
-	} else {
-		vmx = replace(vmx, "(ETH3)", "false")
-	}
-
-	return vmx
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VMX network card types.
+const (
+	NICTypeE1000   = "e1000"
+	NICTypeE1000E  = "e1000e"
+	NICTypeVMXNet3 = "vmxnet3"
+)
+
+// VMX network connection types.
+const (
+	NICConnectionNAT      = "nat"
+	NICConnectionBridged  = "bridged"
+	NICConnectionHostOnly = "hostonly"
+	NICConnectionCustom   = "custom"
+)
+
+// VMX serial port types.
+const (
+	SerialFile = "file"
+	SerialPipe = "pipe"
+)
+
+// VMX firmware types.
+const (
+	FirmwareBIOS = "bios"
+	FirmwareEFI  = "efi"
+)
+
+const (
+	vmxMaxNICs  = 10
+	vmxMaxDisks = 30
+)
+
+// vmxDefaults are the settings vcli applies to every VMware machine it
+// generates, in the order they are written out.
+var vmxDefaults = [][2]string{
+	{"debugStub.listen.guest64", "TRUE"},
+	{"debugStub.hideBreakpoints", "TRUE"},
+	{"debugStub.listen.guest64.remote", "TRUE"},
+	{".encoding", "UTF-8"},
+	{"config.version", "8"},
+	{"bios.bootdelay", "0"},
+	{"virtualHW.version", "11"},
+	{"virtualHW.productCompatibility", "hosted"},
+	{"guestOS", "Other"},
+	{"vcpu.hotadd", "FALSE"},
+	{"mem.hotadd", "FALSE"},
+	{"scsi0.present", "FALSE"},
+	{"scsi0.virtualDev", "lsilogic"},
+	{"scsi0.pciSlotNumber", "16"},
+	{"sata0.present", "TRUE"},
+	{"sata0.pciSlotNumber", "37"},
+	{"usb.present", "FALSE"},
+	{"usb.pciSlotNumber", "32"},
+	{"usb.vbluetooth.startConnected", "TRUE"},
+	{"ehci.present", "FALSE"},
+	{"sound.present", "FALSE"},
+	{"floppy0.present", "FALSE"},
+	{"mks.enable3d", "FALSE"},
+	{"svga.graphicsMemoryKB", "786432"},
+	{"pciBridge0.present", "TRUE"},
+	{"pciBridge0.pciSlotNumber", "17"},
+	{"pciBridge4.present", "TRUE"},
+	{"pciBridge4.virtualDev", "pcieRootPort"},
+	{"pciBridge4.functions", "8"},
+	{"pciBridge4.pciSlotNumber", "21"},
+	{"pciBridge5.present", "TRUE"},
+	{"pciBridge5.virtualDev", "pcieRootPort"},
+	{"pciBridge5.functions", "8"},
+	{"pciBridge5.pciSlotNumber", "22"},
+	{"pciBridge6.present", "TRUE"},
+	{"pciBridge6.virtualDev", "pcieRootPort"},
+	{"pciBridge6.functions", "8"},
+	{"pciBridge6.pciSlotNumber", "23"},
+	{"pciBridge7.present", "TRUE"},
+	{"pciBridge7.pciSlotNumber", "24"},
+	{"vmci0.pciSlotNumber", "36"},
+	{"hpet0.present", "FALSE"},
+	{"powerType.powerOff", "soft"},
+	{"powerType.powerOn", "soft"},
+	{"powerType.suspend", "soft"},
+	{"powerType.reset", "soft"},
+	{"replay.supported", "FALSE"},
+	{"cleanShutdown", "TRUE"},
+	{"softPowerOff", "FALSE"},
+}
+
+// VMX is a VMware virtual machine configuration. Settings that aren't
+// modelled are kept in Extra, so parsed files survive a round trip.
+type VMX struct {
+	Name     string
+	CPUs     int
+	Memory   int
+	Firmware string
+	Disks    []string
+	DiskBus  string
+	NICs     []VMXNetworkCard
+	Serial   *VMXSerial
+
+	// Dir is where VMware keeps the machine's nvram, log and extended
+	// config files. They are left to VMware's defaults if it is empty.
+	Dir string
+
+	// PortForwards are applied through the host's NAT service rather than
+	// by the machine itself; see NATConfig. They are kept in the vmx under
+	// vcli's own keys, which VMware ignores.
+	PortForwards []VMXPortForward
+
+	Extra map[string]string
+}
+
+// VMXNetworkCard is a virtual network card.
+type VMXNetworkCard struct {
+	Type       string
+	Connection string
+	MAC        string
+
+	// VNet is the vmnet a custom connection is attached to.
+	VNet string
+}
+
+// VMXSerial is the machine's first serial port, connected to a file or a
+// named pipe.
+type VMXSerial struct {
+	Type string
+	Path string
+
+	// Client connects to an existing pipe instead of creating one.
+	Client bool
+}
+
+// VMXPortForward forwards a port on the host to the guest through VMware's
+// NAT service.
+type VMXPortForward struct {
+	Protocol  string
+	HostPort  int
+	GuestPort int
+}
+
+func (pf VMXPortForward) String() string {
+
+	return fmt.Sprintf("%s:%d:%d", pf.Protocol, pf.HostPort, pf.GuestPort)
+
+}
+
+func parseVMXPortForward(s string) (VMXPortForward, bool) {
+
+	var pf VMXPortForward
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return pf, false
+	}
+
+	var err1, err2 error
+	pf.Protocol = parts[0]
+	pf.HostPort, err1 = strconv.Atoi(parts[1])
+	pf.GuestPort, err2 = strconv.Atoi(parts[2])
+
+	return pf, err1 == nil && err2 == nil
+
+}
+
+// NewVMX returns a VMX with the settings vcli uses for a single disk
+// Vorteil application.
+func NewVMX(name string, cpus, memory int, disk string) *VMX {
+
+	return &VMX{
+		Name:     name,
+		CPUs:     cpus,
+		Memory:   memory,
+		Firmware: FirmwareBIOS,
+		Disks:    []string{disk},
+	}
+
+}
+
+// Validate checks the VMX only uses options vcli knows how to generate.
+func (vmx *VMX) Validate() error {
+
+	if vmx.CPUs < 1 {
+		return errors.New("vmx: at least one cpu required")
+	}
+
+	if vmx.Memory < 1 {
+		return errors.New("vmx: memory must be positive")
+	}
+
+	switch vmx.Firmware {
+	case "", FirmwareBIOS, FirmwareEFI:
+	default:
+		return fmt.Errorf("vmx: unsupported firmware '%v'", vmx.Firmware)
+	}
+
+	switch vmx.DiskBus {
+	case "", DiskBusSATA, DiskBusSCSI:
+	default:
+		return fmt.Errorf("vmx: unsupported disk bus '%v'", vmx.DiskBus)
+	}
+
+	if len(vmx.Disks) > vmxMaxDisks {
+		return fmt.Errorf("vmx: too many disks: %d; maximum %d", len(vmx.Disks), vmxMaxDisks)
+	}
+
+	if len(vmx.NICs) > vmxMaxNICs {
+		return fmt.Errorf("vmx: too many network cards: %d; maximum %d", len(vmx.NICs), vmxMaxNICs)
+	}
+
+	for i, nic := range vmx.NICs {
+
+		switch nic.Type {
+		case NICTypeE1000, NICTypeE1000E, NICTypeVMXNet3:
+		default:
+			return fmt.Errorf("vmx: network card %d: unsupported type '%v'", i, nic.Type)
+		}
+
+		switch nic.Connection {
+		case NICConnectionNAT, NICConnectionBridged, NICConnectionHostOnly:
+		case NICConnectionCustom:
+			if nic.VNet == "" {
+				return fmt.Errorf("vmx: network card %d: custom connection requires a vnet", i)
+			}
+		default:
+			return fmt.Errorf("vmx: network card %d: unsupported connection '%v'", i, nic.Connection)
+		}
+
+	}
+
+	if vmx.Serial != nil {
+		switch vmx.Serial.Type {
+		case SerialFile, SerialPipe:
+		default:
+			return fmt.Errorf("vmx: unsupported serial port type '%v'", vmx.Serial.Type)
+		}
+	}
+
+	for _, pf := range vmx.PortForwards {
+
+		if pf.Protocol != "tcp" && pf.Protocol != "udp" {
+			return fmt.Errorf("vmx: unsupported port forward protocol '%v'", pf.Protocol)
+		}
+
+		if pf.HostPort < 1 || pf.HostPort > 65535 || pf.GuestPort < 1 || pf.GuestPort > 65535 {
+			return fmt.Errorf("vmx: port forward %d:%d out of bounds", pf.HostPort, pf.GuestPort)
+		}
+
+	}
+
+	return nil
+
+}
+
+type vmxWriter struct {
+	keys   []string
+	values map[string]string
+}
+
+func (w *vmxWriter) set(key, value string) {
+
+	if _, ok := w.values[key]; !ok {
+		w.keys = append(w.keys, key)
+	}
+
+	w.values[key] = value
+
+}
+
+func vmxBool(b bool) string {
+
+	if b {
+		return "TRUE"
+	}
+
+	return "FALSE"
+
+}
+
+// Marshal serializes the VMX into the format VMware expects.
+func (vmx *VMX) Marshal() ([]byte, error) {
+
+	err := vmx.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &vmxWriter{values: make(map[string]string)}
+
+	for _, kv := range vmxDefaults {
+		w.set(kv[0], kv[1])
+	}
+
+	w.set("displayName", vmx.Name)
+	w.set("numvcpus", strconv.Itoa(vmx.CPUs))
+	w.set("memsize", strconv.Itoa(vmx.Memory))
+	if vmx.Firmware != "" {
+		w.set("firmware", vmx.Firmware)
+	}
+
+	if vmx.Dir != "" {
+		w.set("nvram", filepath.Join(vmx.Dir, vmx.Name+".nvram"))
+		w.set("extendedConfigFile", filepath.Join(vmx.Dir, vmx.Name+".vmxf"))
+		w.set("log.fileName", filepath.Join(vmx.Dir, vmx.Name+".log"))
+	}
+
+	bus := "sata0"
+	if vmx.DiskBus == DiskBusSCSI {
+		bus = "scsi0"
+		w.set("scsi0.present", "TRUE")
+		w.set("scsi0.virtualDev", "pvscsi")
+	}
+
+	for i, disk := range vmx.Disks {
+		dev := fmt.Sprintf("%s:%d", bus, i)
+		w.set(dev+".present", "TRUE")
+		w.set(dev+".fileName", disk)
+	}
+
+	for i, nic := range vmx.NICs {
+
+		dev := fmt.Sprintf("ethernet%d", i)
+		w.set(dev+".present", "TRUE")
+		w.set(dev+".connectionType", nic.Connection)
+		if nic.Connection == NICConnectionCustom {
+			w.set(dev+".vnet", nic.VNet)
+		}
+		w.set(dev+".virtualDev", nic.Type)
+		w.set(dev+".wakeOnPcktRcv", "FALSE")
+		if nic.MAC != "" {
+			w.set(dev+".addressType", "static")
+			w.set(dev+".address", nic.MAC)
+		} else {
+			w.set(dev+".addressType", "generated")
+		}
+		w.set(dev+".pciSlotNumber", strconv.Itoa(1024+i))
+
+	}
+
+	w.set("serial0.present", vmxBool(vmx.Serial != nil))
+	if vmx.Serial != nil {
+		w.set("serial0.fileType", vmx.Serial.Type)
+		w.set("serial0.fileName", vmx.Serial.Path)
+		if vmx.Serial.Type == SerialPipe {
+			if vmx.Serial.Client {
+				w.set("serial0.pipe.endPoint", "client")
+			} else {
+				w.set("serial0.pipe.endPoint", "server")
+			}
+			w.set("serial0.tryNoRxLoss", "TRUE")
+		}
+	}
+
+	for i, pf := range vmx.PortForwards {
+		w.set(fmt.Sprintf("vorteil.portForward%d", i), pf.String())
+	}
+
+	var extra []string
+	for k := range vmx.Extra {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+
+	for _, k := range extra {
+		w.set(k, vmx.Extra[k])
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "#!/usr/bin/vmware")
+	for _, k := range w.keys {
+		fmt.Fprintf(buf, "%s = \"%s\"\n", k, vmxEscape(w.values[k]))
+	}
+
+	return buf.Bytes(), nil
+
+}
+
+// vmxEscape encodes the characters that can't appear in a quoted vmx value
+// the way VMware does, as '|' followed by their hex code. Everything else,
+// backslashes included, is written as it is.
+func vmxEscape(s string) string {
+
+	return strings.NewReplacer("|", "|7C", "\"", "|22").Replace(s)
+
+}
+
+// vmxUnescape decodes characters escaped by vmxEscape, or by VMware.
+func vmxUnescape(s string) string {
+
+	if !strings.Contains(s, "|") {
+		return s
+	}
+
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '|' && i+2 < len(s) {
+			if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				buf.WriteByte(byte(b))
+				i += 2
+				continue
+			}
+		}
+		buf.WriteByte(s[i])
+	}
+
+	return buf.String()
+
+}
+
+// ParseVMX reads a vmx file.
+func ParseVMX(r io.Reader) (*VMX, error) {
+
+	vmx := &VMX{Extra: make(map[string]string)}
+	values := make(map[string]string)
+	var keys []string
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		i := strings.Index(text, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("vmx: line %d: expected 'key = \"value\"'", line)
+		}
+
+		key := strings.TrimSpace(text[:i])
+		value := strings.TrimSpace(text[i+1:])
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = vmxUnescape(value[1 : len(value)-1])
+		}
+
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	consumed := make(map[string]bool)
+	take := func(key string) (string, bool) {
+		v, ok := values[key]
+		if ok {
+			consumed[key] = true
+		}
+		return v, ok
+	}
+
+	vmx.Name, _ = take("displayName")
+	vmx.Firmware, _ = take("firmware")
+
+	if v, ok := take("numvcpus"); ok {
+		vmx.CPUs, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("vmx: numvcpus: %v", err)
+		}
+	}
+
+	if v, ok := take("memsize"); ok {
+		vmx.Memory, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("vmx: memsize: %v", err)
+		}
+	}
+
+	bus := "sata0"
+	if values["sata0:0.present"] != "TRUE" && values["scsi0:0.present"] == "TRUE" &&
+		values["scsi0.present"] == "TRUE" && values["scsi0.virtualDev"] == "pvscsi" {
+		bus = "scsi0"
+		vmx.DiskBus = DiskBusSCSI
+		take("scsi0.present")
+		take("scsi0.virtualDev")
+	}
+
+	for i := 0; i < vmxMaxDisks; i++ {
+
+		dev := fmt.Sprintf("%s:%d", bus, i)
+		if values[dev+".present"] != "TRUE" {
+			break
+		}
+
+		file, ok := values[dev+".fileName"]
+		if !ok {
+			break
+		}
+
+		take(dev + ".present")
+		take(dev + ".fileName")
+		vmx.Disks = append(vmx.Disks, file)
+
+	}
+
+	for i := 0; i < vmxMaxNICs; i++ {
+
+		dev := fmt.Sprintf("ethernet%d", i)
+		if !strings.EqualFold(values[dev+".present"], "TRUE") {
+			break
+		}
+
+		take(dev + ".present")
+		nic := VMXNetworkCard{}
+		nic.Type, _ = take(dev + ".virtualDev")
+		nic.Connection, _ = take(dev + ".connectionType")
+		if nic.Connection == NICConnectionCustom {
+			nic.VNet, _ = take(dev + ".vnet")
+		}
+		if typ, _ := take(dev + ".addressType"); typ == "static" {
+			nic.MAC, _ = take(dev + ".address")
+		}
+
+		if values[dev+".wakeOnPcktRcv"] == "FALSE" {
+			take(dev + ".wakeOnPcktRcv")
+		}
+		if values[dev+".pciSlotNumber"] == strconv.Itoa(1024+i) {
+			take(dev + ".pciSlotNumber")
+		}
+
+		vmx.NICs = append(vmx.NICs, nic)
+
+	}
+
+	if v, ok := take("serial0.present"); ok && strings.EqualFold(v, "TRUE") {
+
+		vmx.Serial = new(VMXSerial)
+		vmx.Serial.Type, _ = take("serial0.fileType")
+		vmx.Serial.Path, _ = take("serial0.fileName")
+		if vmx.Serial.Type == SerialPipe {
+			end, _ := take("serial0.pipe.endPoint")
+			vmx.Serial.Client = end == "client"
+			if values["serial0.tryNoRxLoss"] == "TRUE" {
+				take("serial0.tryNoRxLoss")
+			}
+		}
+
+	}
+
+	for i := 0; ; i++ {
+
+		key := fmt.Sprintf("vorteil.portForward%d", i)
+		pf, ok := parseVMXPortForward(values[key])
+		if !ok {
+			break
+		}
+
+		take(key)
+		vmx.PortForwards = append(vmx.PortForwards, pf)
+
+	}
+
+	// generated paths collapse back into Dir
+	if nvram, ok := values["nvram"]; ok && strings.HasSuffix(nvram, vmx.Name+".nvram") {
+		dir := filepath.Dir(nvram)
+		if values["extendedConfigFile"] == filepath.Join(dir, vmx.Name+".vmxf") &&
+			values["log.fileName"] == filepath.Join(dir, vmx.Name+".log") {
+			vmx.Dir = dir
+			take("nvram")
+			take("extendedConfigFile")
+			take("log.fileName")
+		}
+	}
+
+	// defaults are regenerated, so only keep them if they were changed
+	for _, kv := range vmxDefaults {
+		if values[kv[0]] == kv[1] {
+			consumed[kv[0]] = true
+		}
+	}
+
+	for _, k := range keys {
+		if !consumed[k] {
+			vmx.Extra[k] = values[k]
+		}
+	}
+
+	return vmx, nil
+
+}
+
+// NATConfig returns the sections to add to VMware's NAT configuration
+// (vmnetnat.conf or nat.conf) to apply the port forwards to a guest with the
+// given address.
+func (vmx *VMX) NATConfig(guestIP string) string {
+
+	buf := new(bytes.Buffer)
+
+	for _, proto := range []string{"tcp", "udp"} {
+
+		var lines []string
+		for _, pf := range vmx.PortForwards {
+			if pf.Protocol == proto {
+				lines = append(lines, fmt.Sprintf("%d = %s:%d", pf.HostPort, guestIP, pf.GuestPort))
+			}
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buf, "[incoming%s]\n", proto)
+		for _, l := range lines {
+			fmt.Fprintln(buf, l)
+		}
+
+	}
+
+	return buf.String()
+
 }
@@ -0,0 +1,195 @@
+package shared
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func vmxCases() map[string]*VMX {
+
+	basic := NewVMX("app", 1, 64, "app.vmdk")
+	basic.Dir = "/tmp/vorteil/workstation/app"
+	basic.Serial = &VMXSerial{Type: SerialFile, Path: "/tmp/vorteil/workstation/app/serial.log"}
+	basic.NICs = []VMXNetworkCard{
+		{Type: NICTypeE1000, Connection: NICConnectionNAT},
+	}
+
+	full := NewVMX("full", 4, 1024, "full.vmdk")
+	full.Firmware = FirmwareEFI
+	full.Disks = append(full.Disks, "data.vmdk", "scratch.vmdk")
+	full.Serial = &VMXSerial{Type: SerialPipe, Path: "/tmp/full.pipe"}
+	full.NICs = []VMXNetworkCard{
+		{Type: NICTypeVMXNet3, Connection: NICConnectionNAT},
+		{Type: NICTypeE1000, Connection: NICConnectionBridged, MAC: "00:50:56:00:00:01"},
+	}
+	full.Extra = map[string]string{
+		"guestOS":              "other3xlinux-64",
+		"tools.syncTime":       "FALSE",
+		"isolation.tools.copy": "FALSE",
+	}
+
+	headless := NewVMX("headless", 2, 128, "headless.vmdk")
+
+	scsi := NewVMX("scsi", 1, 64, "scsi.vmdk")
+	scsi.DiskBus = DiskBusSCSI
+	scsi.NICs = []VMXNetworkCard{
+		{Type: NICTypeVMXNet3, Connection: NICConnectionNAT},
+	}
+
+	windows := NewVMX("café", 1, 64, `C:\Users\me\vorteil\app.vmdk`)
+	windows.Serial = &VMXSerial{Type: SerialPipe, Path: `\\.\pipe\vorteil-app`}
+
+	nat := NewVMX("nat", 1, 64, "nat.vmdk")
+	nat.NICs = []VMXNetworkCard{
+		{Type: NICTypeE1000, Connection: NICConnectionNAT},
+	}
+	nat.PortForwards = []VMXPortForward{
+		{Protocol: "tcp", HostPort: 8080, GuestPort: 80},
+		{Protocol: "udp", HostPort: 8080, GuestPort: 80},
+	}
+
+	return map[string]*VMX{
+		"basic":    basic,
+		"full":     full,
+		"headless": headless,
+		"scsi":     scsi,
+		"windows":  windows,
+		"nat":      nat,
+	}
+
+}
+
+func golden(t *testing.T, name string, got []byte) {
+
+	path := filepath.Join("testdata", "vmx", name+".golden")
+
+	if *update {
+		err := ioutil.WriteFile(path, got, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("%v does not match golden file:\n%s", name, got)
+	}
+
+}
+
+func TestVMXMarshal(t *testing.T) {
+
+	for name, vmx := range vmxCases() {
+
+		out, err := vmx.Marshal()
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+
+		golden(t, name, out)
+
+	}
+
+}
+
+func TestVMXRoundTrip(t *testing.T) {
+
+	for name := range vmxCases() {
+
+		in, err := ioutil.ReadFile(filepath.Join("testdata", "vmx", name+".golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vmx, err := ParseVMX(bytes.NewReader(in))
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+
+		out, err := vmx.Marshal()
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+
+		if !bytes.Equal(in, out) {
+			t.Errorf("%v did not survive a round trip:\n%s", name, out)
+		}
+
+	}
+
+}
+
+func TestVMXNATConfig(t *testing.T) {
+
+	vmx := NewVMX("app", 1, 64, "app.vmdk")
+	vmx.PortForwards = []VMXPortForward{
+		{Protocol: "tcp", HostPort: 8080, GuestPort: 80},
+		{Protocol: "udp", HostPort: 5353, GuestPort: 53},
+		{Protocol: "tcp", HostPort: 2222, GuestPort: 22},
+	}
+
+	golden(t, "nat-conf", []byte(vmx.NATConfig("172.16.10.128")))
+
+}
+
+func TestVMXParseVMware(t *testing.T) {
+
+	// as written by VMware on Windows, with single backslashes and its own
+	// escapes for quotes
+	in := `.encoding = "UTF-8"
+displayName = "café |22app|22"
+numvcpus = "1"
+memsize = "64"
+sata0.present = "TRUE"
+sata0:0.present = "TRUE"
+sata0:0.fileName = "C:\VMs\app\app.vmdk"
+`
+
+	vmx, err := ParseVMX(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vmx.Name != "café \"app\"" {
+		t.Errorf("unexpected name %q", vmx.Name)
+	}
+
+	if len(vmx.Disks) != 1 || vmx.Disks[0] != `C:\VMs\app\app.vmdk` {
+		t.Errorf("unexpected disks %q", vmx.Disks)
+	}
+
+}
+
+func TestVMXValidate(t *testing.T) {
+
+	invalid := []*VMX{
+		{Name: "x", CPUs: 0, Memory: 64},
+		{Name: "x", CPUs: 1, Memory: 64, Firmware: "uefi"},
+		{Name: "x", CPUs: 1, Memory: 64, DiskBus: "ide"},
+		{Name: "x", CPUs: 1, Memory: 64, NICs: []VMXNetworkCard{{Type: "rtl8139", Connection: NICConnectionNAT}}},
+		{Name: "x", CPUs: 1, Memory: 64, NICs: []VMXNetworkCard{{Type: NICTypeE1000, Connection: "custom"}}},
+		{Name: "x", CPUs: 1, Memory: 64, Serial: &VMXSerial{Type: "device"}},
+		{Name: "x", CPUs: 1, Memory: 64, PortForwards: []VMXPortForward{{Protocol: "sctp", HostPort: 80, GuestPort: 80}}},
+		{Name: "x", CPUs: 1, Memory: 64, PortForwards: []VMXPortForward{{Protocol: "tcp", HostPort: 0, GuestPort: 80}}},
+	}
+
+	for i, vmx := range invalid {
+		if _, err := vmx.Marshal(); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+
+}
@@ -20,23 +20,39 @@ import (
 
 	"github.com/alecthomas/kingpin"
 	"github.com/sisatech/vcli/automation"
+	"github.com/sisatech/vcli/command/attach"
 	"github.com/sisatech/vcli/command/build"
 	"github.com/sisatech/vcli/command/cloud"
+	"github.com/sisatech/vcli/command/compose"
 	"github.com/sisatech/vcli/command/config"
+	"github.com/sisatech/vcli/command/instance"
+	"github.com/sisatech/vcli/command/logs"
+	"github.com/sisatech/vcli/command/network"
 	"github.com/sisatech/vcli/command/repository"
 	"github.com/sisatech/vcli/command/run"
 	"github.com/sisatech/vcli/command/settings"
+	"github.com/sisatech/vcli/command/snapshot"
+	"github.com/sisatech/vcli/command/test"
 	"github.com/sisatech/vcli/home"
+	"github.com/sisatech/vcli/vml"
+	"github.com/sisatech/vcli/vml/archiver"
 )
 
 func main() {
 
+	os.Exit(vcli())
+
+}
+
+func vcli() (code int) {
+
 	defer func() {
 
 		r := recover()
 
 		if r != nil {
 			fmt.Fprintf(os.Stderr, "an unexpected error occurred: %v\n", r)
+			code = 1
 		}
 
 	}()
@@ -44,7 +60,7 @@ func main() {
 	// initialize environment and packages
 	if err := initialize(); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+		return 1
 	}
 
 	defer cleanup()
@@ -59,11 +75,18 @@ func main() {
 
 	// registers commands
 	cmdrun.New().Attach(app)
+	cmdattach.New().Attach(app)
 	cmdbuild.New().Attach(app)
 	cmdvcfg.New().Attach(app)
 	cmdrepo.New().Attach(app)
 	cmdcloud.New().Attach(app)
+	cmdnetwork.New().Attach(app)
+	cmdcompose.New().Attach(app)
+	cmdsnapshot.New().Attach(app)
+	cmdinstance.New().Attach(app)
+	cmdlogs.New().Attach(app)
 	cmdsettings.New().Attach(app)
+	cmdtest.New().Attach(app)
 
 	// set vmware path for mac
 	if runtime.GOOS == "darwin" {
@@ -78,12 +101,23 @@ func main() {
 	if err != nil {
 
 		if os.Args[len(os.Args)-1] == "--completion-bash" || os.Args[len(os.Args)-1] == "--" {
-			return
+			return 0
 		}
 
 		fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+
+		// commands that run a guest pass its exit status back to the shell
+		if e, ok := err.(interface {
+			ExitCode() int
+		}); ok {
+			return e.ExitCode()
+		}
+
+		return 1
 	}
 
+	return 0
+
 }
 
 func initialize() error {
@@ -95,6 +129,16 @@ func initialize() error {
 		return nil
 	}
 
+	if err != nil {
+		return err
+	}
+
+	vml.Storage = home.GlobalDefaults.Storage
+
+	if home.GlobalDefaults.Compression != "" {
+		vml.Compression, err = archiver.ParseEncoding(home.GlobalDefaults.Compression)
+	}
+
 	return err
 
 }
@@ -22,6 +22,7 @@ import (
 type Archiver interface {
 	Put(input io.Reader) (string, error)
 	Get(filename string) (io.ReadCloser, error)
-	List() []os.FileInfo
+	Exists(filename string) (bool, error)
+	List() ([]os.FileInfo, error)
 	Delete(filename string) error
 }
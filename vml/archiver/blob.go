@@ -14,7 +14,6 @@
 package archiver
 
 import (
-	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -22,13 +21,19 @@ import (
 	"io/ioutil"
 	"os"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sisatech/sherlock"
 )
 
 const (
 	nilChecksum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// temporary files are written next to the blobs so they can be renamed
+	// into place, and are hidden from the contents
+	tmpPrefix = ".tmp-"
 )
 
 // BlobArchiver implements Archiver and stores files by computing their SHA256
@@ -37,16 +42,18 @@ type BlobArchiver struct {
 	lock     sync.RWMutex
 	path     string
 	contents contents
-	compress bool
+	encoding Encoding
 	level    int
 }
 
 // New returns a new BlobArchiver using the provided path as the base folder to
-// store blobs.
+// store blobs. Blobs are stored raw, but compressed blobs that are already in
+// the folder can still be read.
 //
 // CONTRACT: if 'path' exists, it must not contain a directory.
 // CONTRACT: if 'path' exists, it must be readable, writable, and executable by
-// 	the process, and all files within must also be readable by the process.
+//
+//	the process, and all files within must also be readable by the process.
 func New(path string) (*BlobArchiver, error) {
 
 	archive := new(BlobArchiver)
@@ -57,35 +64,41 @@ func New(path string) (*BlobArchiver, error) {
 }
 
 // NewCompressed returns a new BlobArchiver using the provided path as the base
-// folder to store blobs, using gz compression at the default compression level.
+// folder to store blobs, compressing them with the given encoding at its
+// default compression level.
 //
 // CONTRACT: if 'path' exists, it must not contain a directory.
 // CONTRACT: if 'path' exists, it must be readable, writable, and executable by
-// 	the process, and all files within must also be readable by the process.
-// CONTRACT: if 'path' exists, any files within must be gz compressed.
-func NewCompressed(path string) (*BlobArchiver, error) {
+//
+//	the process, and all files within must also be readable by the process.
+func NewCompressed(path string, encoding Encoding) (*BlobArchiver, error) {
 
-	return NewCompressedLevel(path, -1)
+	return NewCompressedLevel(path, encoding, 0)
 
 }
 
-// NewCompressed returns a new BlobArchiver using the provided path as the base
-// folder to store blobs, using gz compression at the given compression level.
-// -1 is default compression, 0 is no compression, 1-9 are varying levels of
-// compression trading speed (0) for filesize (9).
+// NewCompressedLevel returns a new BlobArchiver using the provided path as the
+// base folder to store blobs, compressing them with the given encoding at the
+// given compression level. Levels run from 1, the fastest, up to 9 for gzip
+// and 22 for zstd, which produce the smallest blobs. 0 picks the encoding's
+// default level. Each blob is only kept compressed if that makes it smaller.
 //
 // CONTRACT: if 'path' exists, it must not contain a directory.
 // CONTRACT: if 'path' exists, it must be readable, writable, and executable by
-// 	the process, and all files within must also be readable by the process.
-// CONTRACT: if 'path' exists, any files within must be gz compressed.
-func NewCompressedLevel(path string, level int) (*BlobArchiver, error) {
+//
+//	the process, and all files within must also be readable by the process.
+func NewCompressedLevel(path string, encoding Encoding, level int) (*BlobArchiver, error) {
+
+	if encoding != Raw && encoding != Gzip && encoding != Zstd {
+		return nil, errors.New("unknown encoding")
+	}
 
 	archive, err := New(path)
 	if err != nil {
 		return nil, err
 	}
 
-	archive.compress = true
+	archive.encoding = encoding
 	archive.level = level
 
 	return archive, nil
@@ -109,9 +122,30 @@ func (archive *BlobArchiver) init() {
 	sherlock.Check(os.MkdirAll(archive.path, 0777))
 
 	// load file info
-	archive.contents, err = ioutil.ReadDir(archive.path)
+	infos, err := ioutil.ReadDir(archive.path)
 	sherlock.Check(err)
 
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), tmpPrefix) {
+			archive.contents = append(archive.contents, info)
+		}
+	}
+
+}
+
+// index returns where a file is or would be in the contents. The caller must
+// hold the lock.
+func (archive *BlobArchiver) index(filename string) (int, bool) {
+
+	index := sort.Search(len(archive.contents), func(i int) bool {
+
+		return filename <= archive.contents[i].Name()
+
+	})
+
+	return index, index != len(archive.contents) &&
+		archive.contents[index].Name() == filename
+
 }
 
 // Put adds the data stored within 'in' as a new file to the archive.
@@ -125,73 +159,167 @@ func (archive *BlobArchiver) Put(in io.Reader) (string, error) {
 
 	err := sherlock.Try(func() {
 
-		var target io.WriteCloser
-
 		// write input to temporary file
-		tmp, err := ioutil.TempFile("", "")
+		tmp, err := ioutil.TempFile(archive.path, tmpPrefix)
 		sherlock.Check(err)
 		defer os.Remove(tmp.Name())
 		defer tmp.Close()
 
-		target = tmp
-
-		// apply gz compression if enabled
-		if archive.compress {
-
-			target, err = gzip.NewWriterLevel(target, archive.level)
-			sherlock.Check(err)
-
-		}
-
 		// compute SHA256 checksum of input
 		hasher := sha256.New()
 
-		w := io.MultiWriter(hasher, target)
+		w := io.MultiWriter(hasher, tmp)
 
 		sherlock.Check(io.Copy(w, in))
 
 		checksum = hex.EncodeToString(hasher.Sum(nil))
 
+		// return if value exists within contents, marking it as recently
+		// used so that it isn't collected before it is referenced
+		if archive.touch(checksum) {
+			return
+		}
+
+		// apply compression if enabled
+		sherlock.Check(tmp.Seek(0, io.SeekStart))
+		encoded, err := archive.encode(tmp)
+		sherlock.Check(err)
+		defer os.Remove(encoded.Name())
+		defer encoded.Close()
+
 		// take a write lock on the archiver
 		archive.lock.Lock()
 		defer archive.lock.Unlock()
 
-		// lookup index within info list
-		index := sort.Search(len(archive.contents), func(i int) bool {
+		if _, ok := archive.index(checksum); ok {
+			return
+		}
+
+		archive.replace(checksum, encoded)
 
-			name := archive.contents[i].Name()
+	})
 
-			return checksum <= name
+	return checksum, err
 
-		})
+}
 
-		// return if value exists within contentss
-		if index != len(archive.contents) &&
-			archive.contents[index].Name() == checksum {
-			return
-		}
+// touch marks a file as recently modified, returning false if it isn't in
+// the archive.
+func (archive *BlobArchiver) touch(filename string) bool {
 
-		// move file into directory
-		filename := archive.filepath(checksum)
-		err = tmp.Close()
-		sherlock.Check(err)
-		sherlock.Check(os.Rename(tmp.Name(), filename))
+	archive.lock.Lock()
+	defer archive.lock.Unlock()
+
+	index, ok := archive.index(filename)
+	if !ok {
+		return false
+	}
+
+	now := time.Now()
+	path := archive.filepath(filename)
+	if os.Chtimes(path, now, now) != nil {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	if err == nil {
+		archive.contents[index] = info
+	}
+
+	return true
+
+}
+
+// encode writes a copy of raw behind a header naming its encoding to a new
+// temporary file. The copy is compressed if the archive uses compression and
+// it makes the file smaller, and stored raw otherwise.
+func (archive *BlobArchiver) encode(raw *os.File) (*os.File, error) {
+
+	var out *os.File
 
-		// add file info to contents
-		info, err := os.Stat(filename)
+	err := sherlock.Try(func() {
+
+		info, err := raw.Stat()
 		sherlock.Check(err)
-		archive.contents = append(archive.contents, info)
 
-		// sort contents
-		sort.Sort(&archive.contents)
+		if archive.encoding != Raw {
+			out = archive.write(raw, archive.encoding)
+			size, err := out.Seek(0, io.SeekCurrent)
+			sherlock.Check(err)
+			if size < info.Size()+int64(headerSize) {
+				return
+			}
+			out.Close()
+			os.Remove(out.Name())
+			out = nil
+			sherlock.Check(raw.Seek(0, io.SeekStart))
+		}
+
+		out = archive.write(raw, Raw)
 
 	})
 
-	return checksum, err
+	return out, err
 
 }
 
-// Get returns a io.ReadCloser for the desired file.
+// write copies r to a new temporary file behind a header, with the given
+// encoding.
+func (archive *BlobArchiver) write(r io.Reader, encoding Encoding) *os.File {
+
+	tmp, err := ioutil.TempFile(archive.path, tmpPrefix)
+	sherlock.Check(err)
+
+	keep := false
+	defer func() {
+		if !keep {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	sherlock.Check(tmp.Write(header(encoding)))
+
+	w, err := encoder(tmp, encoding, archive.level)
+	sherlock.Check(err)
+
+	sherlock.Check(io.Copy(w, r))
+	sherlock.Check(w.Close())
+
+	keep = true
+
+	return tmp
+
+}
+
+// replace moves a temporary file into place as a blob and updates the
+// contents. The caller must hold the write lock.
+func (archive *BlobArchiver) replace(filename string, tmp *os.File) {
+
+	sherlock.Check(tmp.Close())
+
+	path := archive.filepath(filename)
+	sherlock.Check(os.Rename(tmp.Name(), path))
+
+	info, err := os.Stat(path)
+	sherlock.Check(err)
+
+	index, ok := archive.index(filename)
+	if ok {
+		archive.contents[index] = info
+		return
+	}
+
+	// add file info to contents
+	archive.contents = append(archive.contents, info)
+
+	// sort contents
+	sort.Sort(&archive.contents)
+
+}
+
+// Get returns a io.ReadCloser for the desired file, decompressing it if it was
+// stored compressed.
 func (archive *BlobArchiver) Get(filename string) (io.ReadCloser, error) {
 
 	var err error
@@ -203,30 +331,160 @@ func (archive *BlobArchiver) Get(filename string) (io.ReadCloser, error) {
 
 	err = sherlock.Try(func() {
 
-		ret, err = os.Open(archive.filepath(filename))
+		f, err := os.Open(archive.filepath(filename))
 		sherlock.Check(err)
 
-		// apply gz decompression on the way out if required
-		if archive.compress {
-
-			ret, err = gzip.NewReader(ret)
-			sherlock.Check(err)
+		encoding, err := readHeader(f)
+		if err != nil {
+			f.Close()
+			sherlock.Throw(err)
+		}
 
+		r, err := decoder(f, encoding)
+		if err != nil {
+			f.Close()
+			sherlock.Throw(err)
 		}
 
+		ret = &blobReader{ReadCloser: r, f: f}
+
 	})
 
 	return ret, err
 
 }
 
+// Exists reports whether a file is in the archive.
+func (archive *BlobArchiver) Exists(filename string) (bool, error) {
+
+	if filename == nilChecksum {
+		return true, nil
+	}
+
+	_, err := os.Stat(archive.filepath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+
+}
+
+// Encoding returns how a file is stored.
+func (archive *BlobArchiver) Encoding(filename string) (Encoding, error) {
+
+	f, err := os.Open(archive.filepath(filename))
+	if err != nil {
+		return Raw, err
+	}
+	defer f.Close()
+
+	return readHeader(f)
+
+}
+
+// Recompress stores a file again with the archive's current encoding, if it
+// was stored differently, replacing it in place. It returns the size on disk
+// before and after. The file's contents are checked against its checksum
+// before it is replaced, and if anything goes wrong it is left untouched.
+func (archive *BlobArchiver) Recompress(filename string) (int64, int64, error) {
+
+	var before, after int64
+
+	err := sherlock.Try(func() {
+
+		info, err := os.Stat(archive.filepath(filename))
+		sherlock.Check(err)
+		before = info.Size()
+		after = before
+
+		encoding, err := archive.Encoding(filename)
+		sherlock.Check(err)
+
+		if encoding == archive.encoding {
+			return
+		}
+
+		r, err := archive.Get(filename)
+		sherlock.Check(err)
+		defer r.Close()
+
+		// decode to a temporary file, checking the checksum on the way
+		tmp, err := ioutil.TempFile(archive.path, tmpPrefix)
+		sherlock.Check(err)
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		hasher := sha256.New()
+		sherlock.Check(io.Copy(io.MultiWriter(hasher, tmp), r))
+		sherlock.Assert(errors.New("blob is corrupt"),
+			hex.EncodeToString(hasher.Sum(nil)) == filename)
+
+		sherlock.Check(tmp.Seek(0, io.SeekStart))
+		encoded, err := archive.encode(tmp)
+		sherlock.Check(err)
+		defer os.Remove(encoded.Name())
+		defer encoded.Close()
+
+		info, err = encoded.Stat()
+		sherlock.Check(err)
+
+		// keep the blob as it is unless this makes it smaller
+		if info.Size() >= before {
+			return
+		}
+
+		archive.lock.Lock()
+		defer archive.lock.Unlock()
+
+		archive.replace(filename, encoded)
+		after = info.Size()
+
+	})
+
+	return before, after, err
+
+}
+
+// RemoveTemporary deletes temporary files left behind by writes that were
+// interrupted, if they were last modified before the given time. It returns
+// how many were deleted.
+func (archive *BlobArchiver) RemoveTemporary(before time.Time) (int, error) {
+
+	infos, err := ioutil.ReadDir(archive.path)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, info := range infos {
+
+		if !strings.HasPrefix(info.Name(), tmpPrefix) || !info.ModTime().Before(before) {
+			continue
+		}
+
+		err = os.Remove(archive.filepath(info.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return n, err
+		}
+		n++
+
+	}
+
+	return n, nil
+
+}
+
 // List returns the complete list of files stored within the BlobArchiver.
-func (archive *BlobArchiver) List() []os.FileInfo {
+func (archive *BlobArchiver) List() ([]os.FileInfo, error) {
 
 	archive.lock.RLock()
 	defer archive.lock.RUnlock()
 
-	return archive.contents
+	return archive.contents, nil
 
 }
 
@@ -245,19 +503,56 @@ func (archive *BlobArchiver) Delete(filename string) error {
 		sherlock.Check(os.Remove(archive.filepath(filename)))
 
 		// lookup index within info list
-		index := sort.Search(len(archive.contents), func(i int) bool {
+		index, ok := archive.index(filename)
+		if !ok {
+			return
+		}
+
+		archive.contents = append(archive.contents[:index],
+			archive.contents[index+1:]...)
 
-			return filename == archive.contents[i].Name()
+	})
+
+}
+
+// DeleteIfOlder removes a file from the archive unless it was modified after
+// the given time, returning whether it was removed. The check is made under
+// the same lock Put takes to mark existing files as used, so a file can't be
+// reused by an import while it is being deleted.
+func (archive *BlobArchiver) DeleteIfOlder(filename string, before time.Time) (bool, error) {
+
+	var deleted bool
+
+	err := sherlock.Try(func() {
+
+		if filename == nilChecksum {
+			return
+		}
 
-		})
+		archive.lock.Lock()
+		defer archive.lock.Unlock()
 
-		if index == len(archive.contents) {
+		index, ok := archive.index(filename)
+		if !ok {
 			return
 		}
 
+		info, err := os.Stat(archive.filepath(filename))
+		sherlock.Check(err)
+
+		if !info.ModTime().Before(before) {
+			return
+		}
+
+		sherlock.Check(os.Remove(archive.filepath(filename)))
+
 		archive.contents = append(archive.contents[:index],
 			archive.contents[index+1:]...)
 
+		deleted = true
+
 	})
 
+	return deleted, err
+
 }
@@ -0,0 +1,267 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archiver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+
+	dir, err := ioutil.TempDir("", "vcli-archiver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+
+}
+
+func TestCompressed(t *testing.T) {
+
+	compressible := bytes.Repeat([]byte("vorteil "), 1<<14)
+
+	random := make([]byte, 1<<14)
+	rand.Read(random)
+
+	for _, encoding := range []Encoding{Gzip, Zstd} {
+
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		archive, err := NewCompressed(dir, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, data := range [][]byte{compressible, random, {}} {
+
+			name, err := archive.Put(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := read(t, archive, name)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%v: got %v bytes back (%v), expected %v", encoding,
+					len(got), err, len(data))
+			}
+
+		}
+
+		name, _ := archive.Put(bytes.NewReader(compressible))
+		if e, _ := archive.Encoding(name); e != encoding {
+			t.Errorf("compressible blob stored as %v, expected %v", e, encoding)
+		}
+
+		info, _ := os.Stat(filepath.Join(dir, name))
+		if info.Size() >= int64(len(compressible)) {
+			t.Errorf("%v: compressed blob is %v bytes", encoding, info.Size())
+		}
+
+		name, _ = archive.Put(bytes.NewReader(random))
+		if e, _ := archive.Encoding(name); e != Raw {
+			t.Errorf("incompressible blob stored as %v", e)
+		}
+
+		// no temporary files are left behind
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != len(mustList(t, archive)) {
+			t.Errorf("%v files in the folder but %v blobs", len(files), len(mustList(t, archive)))
+		}
+
+	}
+
+}
+
+func TestRecompress(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := bytes.Repeat([]byte("vorteil "), 1<<14)
+
+	// blobs written before compression worked are raw, with no header
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])
+
+	err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := NewCompressed(dir, Zstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := read(t, archive, name)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("couldn't read raw blob: %v", err)
+	}
+
+	before, after, err := archive.Recompress(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before != int64(len(data)) || after >= before {
+		t.Errorf("recompressed from %v to %v bytes", before, after)
+	}
+
+	if info := mustList(t, archive)[0]; info.Size() != after {
+		t.Errorf("contents list %v bytes, expected %v", info.Size(), after)
+	}
+
+	got, err = read(t, archive, name)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("couldn't read recompressed blob: %v", err)
+	}
+
+	// a second pass has nothing to do
+	before, after, err = archive.Recompress(name)
+	if err != nil || before != after {
+		t.Errorf("recompressed again from %v to %v bytes (%v)", before, after, err)
+	}
+
+	// corrupt blobs are left alone
+	gzipped, err := NewCompressed(dir, Gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	err = ioutil.WriteFile(path, []byte("not what it should be"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = gzipped.Recompress(name)
+	if err == nil {
+		t.Errorf("recompressed a corrupt blob")
+	}
+
+	if got, _ := ioutil.ReadFile(path); string(got) != "not what it should be" {
+		t.Errorf("corrupt blob was replaced")
+	}
+
+}
+
+func TestHeaderLookalike(t *testing.T) {
+
+	// raw data that happens to start with a header must not be mistaken
+	// for an encoded blob
+	data := append(header(Gzip), "hello"...)
+
+	for _, encoding := range []Encoding{Raw, Gzip, Zstd} {
+
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		archive, err := NewCompressed(dir, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		name, err := archive.Put(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := read(t, archive, name)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%v: got %q back (%v), expected %q", encoding, got, err, data)
+		}
+
+	}
+
+}
+
+func TestDeleteIfOlder(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	archive, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := archive.Put(strings.NewReader("blob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(dir, name), past, past)
+	cutoff := time.Now().Add(-time.Hour)
+
+	archive, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// putting the blob again marks it as used after List was read
+	list := mustList(t, archive)
+	if len(list) != 1 || list[0].ModTime().After(cutoff) {
+		t.Fatalf("unexpected contents %v", list)
+	}
+
+	_, err = archive.Put(strings.NewReader("blob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := archive.DeleteIfOlder(name, cutoff)
+	if err != nil || deleted {
+		t.Errorf("deleted a blob that was just reused (%v)", err)
+	}
+
+	deleted, err = archive.DeleteIfOlder(name, time.Now().Add(time.Second))
+	if err != nil || !deleted || len(mustList(t, archive)) != 0 {
+		t.Errorf("didn't delete an old blob (%v)", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+		t.Errorf("blob is still on disk")
+	}
+
+	if ok, err := archive.Exists(name); ok || err != nil {
+		t.Errorf("deleted blob exists (%v)", err)
+	}
+
+}
+
+func TestParseEncoding(t *testing.T) {
+
+	for s, want := range map[string]Encoding{"none": Raw, "raw": Raw, "gzip": Gzip, "zstd": Zstd} {
+		if e, err := ParseEncoding(s); err != nil || e != want {
+			t.Errorf("%v: got %v, %v", s, e, err)
+		}
+	}
+
+	if _, err := ParseEncoding("lzma"); err == nil {
+		t.Errorf("expected an error")
+	}
+
+}
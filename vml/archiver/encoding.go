@@ -0,0 +1,193 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encoding is how a blob is stored on disk. Blobs begin with a short header
+// naming their encoding, so every blob can be stored whichever way suits it
+// best. Blobs without the header are raw blobs written by older versions of
+// vcli, which stored every blob that way.
+type Encoding byte
+
+// Encodings blobs can be stored with.
+const (
+	Raw Encoding = iota
+	Gzip
+	Zstd
+)
+
+const headerVersion = 1
+
+// blobMagic starts the header of an encoded blob, followed by the header
+// version and the encoding.
+var blobMagic = []byte("\x89vblob")
+
+var headerSize = len(blobMagic) + 2
+
+func (e Encoding) String() string {
+
+	switch e {
+	case Raw:
+		return "raw"
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	}
+
+	return fmt.Sprintf("encoding(%d)", byte(e))
+
+}
+
+// ParseEncoding returns the encoding with the given name: 'raw' or 'none',
+// 'gzip' or 'zstd'.
+func ParseEncoding(s string) (Encoding, error) {
+
+	switch s {
+	case "raw", "none":
+		return Raw, nil
+	case "gzip", "gz":
+		return Gzip, nil
+	case "zstd":
+		return Zstd, nil
+	}
+
+	return Raw, fmt.Errorf("unknown compression '%v'; expected zstd, gzip or none", s)
+
+}
+
+func header(e Encoding) []byte {
+
+	return append(append([]byte{}, blobMagic...), headerVersion, byte(e))
+
+}
+
+// readHeader returns the encoding of the blob stored in f, leaving f at the
+// start of the encoded data.
+func readHeader(f *os.File) (Encoding, error) {
+
+	buf := make([]byte, headerSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Raw, err
+	}
+
+	if n == headerSize && bytes.Equal(buf[:len(blobMagic)], blobMagic) &&
+		buf[len(blobMagic)] == headerVersion {
+		return Encoding(buf[headerSize-1]), nil
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+
+	return Raw, err
+
+}
+
+// encoder returns a writer compressing to w at the given level, where a level
+// of zero or less picks the default for the encoding.
+func encoder(w io.Writer, e Encoding, level int) (io.WriteCloser, error) {
+
+	switch e {
+
+	case Raw:
+		return nopWriteCloser{w}, nil
+
+	case Gzip:
+		if level <= 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+
+	case Zstd:
+		zlevel := zstd.SpeedDefault
+		if level > 0 {
+			zlevel = zstd.EncoderLevelFromZstd(level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zlevel))
+
+	}
+
+	return nil, fmt.Errorf("can't encode with %v", e)
+
+}
+
+// decoder returns a reader decompressing r.
+func decoder(r io.Reader, e Encoding) (io.ReadCloser, error) {
+
+	switch e {
+
+	case Raw:
+		return nopCloser{r}, nil
+
+	case Gzip:
+		return gzip.NewReader(r)
+
+	case Zstd:
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+
+	}
+
+	return nil, fmt.Errorf("blob stored with unknown %v", e)
+
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+
+	return nil
+
+}
+
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error {
+
+	return nil
+
+}
+
+// blobReader decodes a blob file, closing both the decoder and the file.
+type blobReader struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r *blobReader) Close() error {
+
+	err := r.ReadCloser.Close()
+	if ferr := r.f.Close(); err == nil {
+		err = ferr
+	}
+
+	return err
+
+}
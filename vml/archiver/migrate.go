@@ -0,0 +1,106 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archiver
+
+import (
+	"fmt"
+	"io"
+)
+
+// Migrate copies every file in src that dst doesn't already have, checking
+// each against its checksum on the way. Nothing is removed from src. The
+// number of files copied is returned, along with how many bytes they held.
+func Migrate(dst, src Archiver) (int, int64, error) {
+
+	existing, err := dst.List()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	have := make(map[string]bool)
+	for _, info := range existing {
+		have[info.Name()] = true
+	}
+
+	list, err := src.List()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var n int
+	var size int64
+
+	for _, info := range list {
+
+		// skip anything that isn't a blob, like a repository database
+		name := info.Name()
+		if have[name] || name == nilChecksum || !isChecksum(name) {
+			continue
+		}
+
+		r, err := src.Get(name)
+		if err != nil {
+			return n, size, err
+		}
+
+		counter := &countingReader{r: r}
+		checksum, err := dst.Put(counter)
+		r.Close()
+		if err != nil {
+			return n, size, err
+		}
+
+		if checksum != name {
+			dst.Delete(checksum)
+			return n, size, fmt.Errorf("blob %v is corrupt", name)
+		}
+
+		n++
+		size += counter.n
+
+	}
+
+	return n, size, nil
+
+}
+
+func isChecksum(name string) bool {
+
+	if len(name) != len(nilChecksum) {
+		return false
+	}
+
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+
+}
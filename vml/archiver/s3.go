@@ -0,0 +1,241 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archiver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sisatech/sherlock"
+	"github.com/sisatech/vcli/s3"
+)
+
+// DefaultPartSize is the size of each part when S3Archiver sends blobs as
+// multipart uploads.
+const DefaultPartSize = 16 << 20
+
+// S3Archiver implements Archiver and stores files in an S3-compatible object
+// store, named by their SHA256 checksums like BlobArchiver.
+type S3Archiver struct {
+	client *s3.Client
+	prefix string
+
+	// Blobs larger than PartSize are sent as multipart uploads.
+	PartSize int
+}
+
+// NewS3 returns a new S3Archiver storing blobs at a location of the form
+// s3://BUCKET[/PREFIX][?endpoint=URL&region=REGION]. Credentials are taken
+// from the standard AWS environment variables.
+func NewS3(location string) (*S3Archiver, error) {
+
+	client, prefix, err := s3.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Archiver{
+		client:   client,
+		prefix:   prefix,
+		PartSize: DefaultPartSize,
+	}, nil
+
+}
+
+// Open returns an S3Archiver for s3:// locations and a BlobArchiver for
+// anything else, which is taken to be a directory.
+func Open(location string) (Archiver, error) {
+
+	if strings.HasPrefix(location, "s3://") {
+		return NewS3(location)
+	}
+
+	return New(location)
+
+}
+
+// CleanLocation checks a location for Open and returns it in the form it
+// should be saved in, with directories made absolute. Empty locations are
+// left empty.
+func CleanLocation(location string) (string, error) {
+
+	if location == "" {
+		return "", nil
+	}
+
+	if strings.HasPrefix(location, "s3://") {
+		_, _, err := s3.Parse(location)
+		return location, err
+	}
+
+	return filepath.Abs(location)
+
+}
+
+// Put adds the data stored within 'in' as a new object in the bucket, unless
+// an object with the same checksum is already there.
+func (archive *S3Archiver) Put(in io.Reader) (string, error) {
+
+	if in == nil {
+		return nilChecksum, nil
+	}
+
+	var checksum string
+
+	err := sherlock.Try(func() {
+
+		// spool input to a temporary file, since the checksum names it
+		tmp, err := ioutil.TempFile("", "")
+		sherlock.Check(err)
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		hasher := sha256.New()
+		sherlock.Check(io.Copy(io.MultiWriter(hasher, tmp), in))
+		checksum = hex.EncodeToString(hasher.Sum(nil))
+
+		_, err = archive.client.Head(archive.prefix + checksum)
+		if err == nil {
+			return
+		}
+		sherlock.Assert(err, s3.IsNotExist(err))
+
+		sherlock.Check(tmp.Seek(0, io.SeekStart))
+		sherlock.Check(archive.client.Upload(archive.prefix+checksum, tmp,
+			archive.PartSize))
+
+	})
+
+	return checksum, err
+
+}
+
+// Get returns a io.ReadCloser for the desired file. The contents are checked
+// against the checksum as they are read, and the final Read returns an error
+// instead of io.EOF if they don't match.
+func (archive *S3Archiver) Get(filename string) (io.ReadCloser, error) {
+
+	if filename == nilChecksum {
+		return nil, nil
+	}
+
+	r, err := archive.client.Get(archive.prefix + filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifier{
+		ReadCloser: r,
+		hasher:     sha256.New(),
+		checksum:   filename,
+	}, nil
+
+}
+
+// Exists reports whether a file is in the bucket, without downloading it.
+func (archive *S3Archiver) Exists(filename string) (bool, error) {
+
+	if filename == nilChecksum {
+		return true, nil
+	}
+
+	_, err := archive.client.Head(archive.prefix + filename)
+	if err != nil {
+		if s3.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+
+}
+
+type verifier struct {
+	io.ReadCloser
+	hasher   hash.Hash
+	checksum string
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+
+	n, err := v.ReadCloser.Read(p)
+	v.hasher.Write(p[:n])
+
+	if err == io.EOF && hex.EncodeToString(v.hasher.Sum(nil)) != v.checksum {
+		return n, fmt.Errorf("blob %v is corrupt", v.checksum)
+	}
+
+	return n, err
+
+}
+
+// List returns the complete list of files stored within the S3Archiver, or
+// nil if the bucket can't be listed.
+func (archive *S3Archiver) List() ([]os.FileInfo, error) {
+
+	objects, err := archive.client.List(archive.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var list contents
+	for _, o := range objects {
+
+		name := strings.TrimPrefix(o.Key, archive.prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+
+		list = append(list, &objectInfo{name: name, size: o.Size,
+			modified: o.Modified})
+
+	}
+
+	return list, nil
+
+}
+
+// Delete removes a file from the archive.
+func (archive *S3Archiver) Delete(filename string) error {
+
+	if filename == nilChecksum {
+		return nil
+	}
+
+	return archive.client.Delete(archive.prefix + filename)
+
+}
+
+// objectInfo describes an object as an os.FileInfo.
+type objectInfo struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+func (info *objectInfo) Name() string       { return info.name }
+func (info *objectInfo) Size() int64        { return info.size }
+func (info *objectInfo) Mode() os.FileMode  { return 0444 }
+func (info *objectInfo) ModTime() time.Time { return info.modified }
+func (info *objectInfo) IsDir() bool        { return false }
+func (info *objectInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,266 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archiver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sisatech/vcli/s3/s3test"
+)
+
+func newS3(t *testing.T, server *s3test.Server) *S3Archiver {
+
+	archive, err := NewS3("s3://bucket/blobs?endpoint=" + server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return archive
+
+}
+
+func read(t *testing.T, archive Archiver, name string) ([]byte, error) {
+
+	r, err := archive.Get(name)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+
+}
+
+func mustList(t *testing.T, archive Archiver) []os.FileInfo {
+
+	list, err := archive.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return list
+
+}
+
+func TestS3Archiver(t *testing.T) {
+
+	server := s3test.NewServer()
+	defer server.Close()
+
+	archive := newS3(t, server)
+
+	small, err := archive.Put(strings.NewReader("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// big enough to be sent in two parts
+	data := bytes.Repeat([]byte("0123456789abcdef"), 6<<16)
+	archive.PartSize = 5 << 20
+
+	big, err := archive.Put(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := archive.Put(strings.NewReader("small"))
+	if err != nil || again != small {
+		t.Errorf("putting a blob twice gave %v, %v", again, err)
+	}
+
+	got, err := read(t, archive, big)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("got %v bytes back (%v), expected %v", len(got), err, len(data))
+	}
+
+	list := mustList(t, archive)
+	if len(list) != 2 || list[0].Name() > list[1].Name() {
+		t.Errorf("unexpected listing of %v blobs", len(list))
+	}
+
+	err = archive.Delete(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mustList(t, archive)) != 1 {
+		t.Errorf("blob wasn't deleted")
+	}
+
+	r, err := archive.Put(nil)
+	if err != nil || r != nilChecksum {
+		t.Errorf("nil blob gave %v, %v", r, err)
+	}
+
+}
+
+func TestS3ArchiverExists(t *testing.T) {
+
+	server := s3test.NewServer()
+	defer server.Close()
+
+	archive := newS3(t, server)
+
+	name, err := archive.Put(strings.NewReader("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for sum, want := range map[string]bool{
+		name:                    true,
+		nilChecksum:             true,
+		strings.Repeat("0", 64): false,
+	} {
+		ok, err := archive.Exists(sum)
+		if err != nil || ok != want {
+			t.Errorf("%v: exists is %v (%v), expected %v", sum, ok, err, want)
+		}
+	}
+
+	if n := server.Downloads(); n != 0 {
+		t.Errorf("checking blobs exist downloaded %v of them", n)
+	}
+
+}
+
+func TestS3ArchiverListError(t *testing.T) {
+
+	server := s3test.NewServer()
+	archive := newS3(t, server)
+	server.Close()
+
+	if _, err := archive.List(); err == nil {
+		t.Errorf("listing an unreachable bucket gave no error")
+	}
+
+	dir, err := ioutil.TempDir("", "vcli-archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dst, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Migrate(dst, archive); err == nil {
+		t.Errorf("migrating from an unreachable bucket gave no error")
+	}
+
+}
+
+func TestS3ArchiverCorrupt(t *testing.T) {
+
+	server := s3test.NewServer()
+	defer server.Close()
+
+	archive := newS3(t, server)
+
+	name, err := archive.Put(strings.NewReader("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetObject("bucket", "blobs/"+name, []byte("tampered"))
+
+	_, err = read(t, archive, name)
+	if err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("expected a corrupt blob error, got %v", err)
+	}
+
+}
+
+func TestMigrate(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "vcli-archiver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a repository database sits alongside local blobs
+	err = ioutil.WriteFile(filepath.Join(dir, "tiny.db"), []byte("db"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, s := range []string{"one", "two", "three"} {
+		name, err := local.Put(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	server := s3test.NewServer()
+	defer server.Close()
+
+	remote := newS3(t, server)
+
+	_, err = remote.Put(strings.NewReader("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, size, err := Migrate(remote, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 || size != 8 {
+		t.Errorf("copied %v blobs and %v bytes, expected 2 and 8", n, size)
+	}
+
+	for _, name := range names {
+		if _, err := read(t, remote, name); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+
+	if _, ok := server.Object("bucket", "blobs/tiny.db"); ok {
+		t.Errorf("migrated something that isn't a blob")
+	}
+
+	// corrupt blobs are refused on the way back
+	server.SetObject("bucket", "blobs/"+names[1], []byte("owt"))
+
+	dir2, err := ioutil.TempDir("", "vcli-archiver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir2)
+
+	local2, err := New(dir2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = Migrate(local2, remote)
+	if err == nil {
+		t.Errorf("migrated a corrupt blob")
+	}
+
+}
@@ -15,6 +15,8 @@ package vml
 
 import (
 	"database/sql"
+	"encoding/json"
+	"io/ioutil"
 
 	sqlite3 "github.com/mattn/go-sqlite3"
 	"github.com/sisatech/sherlock"
@@ -56,6 +58,8 @@ func (repo *TinyRepo) database(path string) {
 			FOREIGN KEY (parent) REFERENCES nodes(path)
 		)`))
 
+	// the tag column is left over from when a version could only have one
+	// tag, and is always empty
 	sherlock.Check(repo.db.Exec(`CREATE TABLE IF NOT EXISTS apps (
 			path TEXT,
 			ref TEXT,
@@ -66,10 +70,24 @@ func (repo *TinyRepo) database(path string) {
 			files TEXT,
 			icon TEXT,
 			config TEXT,
+			version TEXT NOT NULL DEFAULT '',
+			author TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			message TEXT NOT NULL DEFAULT '',
 			PRIMARY KEY (ref),
 			FOREIGN KEY (path) REFERENCES nodes(path)
 		)`))
 
+	sherlock.Check(repo.db.Exec(`CREATE TABLE IF NOT EXISTS tags (
+			path TEXT,
+			tag TEXT,
+			ref TEXT,
+			PRIMARY KEY (path, tag),
+			FOREIGN KEY (ref) REFERENCES apps(ref) ON DELETE CASCADE
+		)`))
+
+	repo.migrate()
+
 	var n int
 	row := repo.db.QueryRow(`SELECT COUNT(*) FROM nodes`)
 	sherlock.Check(row.Scan(&n))
@@ -81,3 +99,101 @@ func (repo *TinyRepo) database(path string) {
 	}
 
 }
+
+// migrate brings databases created by older versions of vcli up to date.
+func (repo *TinyRepo) migrate() {
+
+	columns := repo.columns("apps")
+
+	if !columns["version"] {
+
+		for _, col := range []string{"version", "author", "description", "message"} {
+			if !columns[col] {
+				sherlock.Check(repo.db.Exec(`ALTER TABLE apps ADD COLUMN ` +
+					col + ` TEXT NOT NULL DEFAULT ''`))
+			}
+		}
+
+		repo.reindex()
+
+	}
+
+	// move tags out of the apps table
+	sherlock.Check(repo.db.Exec(`INSERT OR IGNORE INTO tags (path, tag, ref)
+		SELECT path, tag, ref FROM apps WHERE tag != ''`))
+	sherlock.Check(repo.db.Exec(`UPDATE apps SET tag='' WHERE tag != ''`))
+
+}
+
+func (repo *TinyRepo) columns(table string) map[string]bool {
+
+	rows, err := repo.db.Query(`PRAGMA table_info(` + table + `)`)
+	sherlock.Check(err)
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+
+		var cid, notnull, pk int
+		var name, ctype string
+		var dflt interface{}
+		sherlock.Check(rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk))
+		columns[name] = true
+
+	}
+
+	return columns
+
+}
+
+// metadata is the part of an app's build config indexed for each version.
+type metadata struct {
+	Version     string `json:"version"`
+	Author      string `json:"author"`
+	Description string `json:"description"`
+}
+
+func readMetadata(cfg []byte) metadata {
+
+	var meta metadata
+	json.Unmarshal(cfg, &meta)
+
+	return meta
+
+}
+
+// reindex fills in the metadata of every version from its build config.
+func (repo *TinyRepo) reindex() {
+
+	rows, err := repo.db.Query(`SELECT ref, config FROM apps`)
+	sherlock.Check(err)
+
+	configs := make(map[string]string)
+	for rows.Next() {
+		var ref, config string
+		sherlock.Check(rows.Scan(&ref, &config))
+		configs[ref] = config
+	}
+	sherlock.Check(rows.Close())
+
+	for ref, config := range configs {
+
+		r, err := repo.archive.Get(config)
+		if err != nil {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			continue
+		}
+
+		meta := readMetadata(data)
+		sherlock.Check(repo.db.Exec(`UPDATE apps SET version=$1, author=$2,
+			description=$3 WHERE ref=$4`, meta.Version, meta.Author,
+			meta.Description, ref))
+
+	}
+
+}
@@ -0,0 +1,349 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sisatech/sherlock"
+	"github.com/sisatech/vcli/vml/archiver"
+)
+
+// Problem is an inconsistency in a repository's database found by Fsck.
+type Problem struct {
+	Description string
+	Fixed       bool
+}
+
+// FsckReport describes the state of a repository.
+type FsckReport struct {
+	Checked  int
+	Missing  []string
+	Corrupt  []string
+	Orphaned []string
+
+	// Broken lists the versions that need a missing or corrupt blob, as
+	// PATH@REF.
+	Broken []string
+
+	Problems []*Problem
+}
+
+// OK reports whether nothing was found wrong with the repository, apart from
+// orphaned blobs, which do no harm.
+func (report *FsckReport) OK() bool {
+
+	for _, p := range report.Problems {
+		if !p.Fixed {
+			return false
+		}
+	}
+
+	return len(report.Missing) == 0 && len(report.Corrupt) == 0
+
+}
+
+// Fsck checks the repository. Every blob a version refers to is read back and
+// compared with its checksum, and blobs no version refers to are reported as
+// orphans. The nodes and tags in the database are checked against the app
+// versions, and if fix is set any problems with them are fixed. Blobs are
+// never changed: missing and corrupt blobs can't be fixed, and orphans are
+// left for GC.
+func (repo *TinyRepo) Fsck(fix bool) (*FsckReport, error) {
+
+	repo.summaryReset()
+
+	report := new(FsckReport)
+
+	err := sherlock.Try(func() {
+
+		repo.fsckNodes(report, fix)
+		repo.fsckTags(report, fix)
+		repo.fsckBlobs(report)
+
+	})
+
+	return report, err
+
+}
+
+func (report *FsckReport) problem(fixed bool, format string, args ...interface{}) {
+
+	report.Problems = append(report.Problems, &Problem{
+		Description: fmt.Sprintf(format, args...),
+		Fixed:       fixed,
+	})
+
+}
+
+// strings runs a query returning a single column of strings.
+func (repo *TinyRepo) strings(query string, args ...interface{}) []string {
+
+	rows, err := repo.db.Query(query, args...)
+	sherlock.Check(err)
+	defer rows.Close()
+
+	var list []string
+	for rows.Next() {
+		var s string
+		sherlock.Check(rows.Scan(&s))
+		list = append(list, s)
+	}
+
+	return list
+
+}
+
+func (repo *TinyRepo) fsckNodes(report *FsckReport, fix bool) {
+
+	// versions of apps with no app node
+	for _, path := range repo.strings(`SELECT DISTINCT path FROM apps
+		WHERE path NOT IN (SELECT path FROM nodes WHERE type=$1)
+		ORDER BY path`, application) {
+
+		fixed := false
+		if fix {
+			fixed = sherlock.Try(func() { repo.addAppNode(path) }) == nil
+		}
+
+		report.problem(fixed, "app %v has versions but no node", path)
+
+	}
+
+	// app nodes with no versions
+	for _, path := range repo.strings(`SELECT path FROM nodes WHERE type=$1
+		AND path NOT IN (SELECT path FROM apps) ORDER BY path`, application) {
+
+		if fix {
+			repo.deleteAppNode(path)
+		}
+
+		report.problem(fix, "app node %v has no versions", path)
+
+	}
+
+	// directories with nothing in them, deepest first so that emptying
+	// one directory doesn't hide its parent
+	dirs := repo.strings(`SELECT path FROM nodes WHERE type=$1 AND path != ''
+		AND path NOT IN (SELECT parent FROM nodes)`, directory)
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+
+	for _, path := range dirs {
+
+		if fix {
+			repo.deleteDirNode(path)
+		}
+
+		report.problem(fix, "directory %v is empty", path)
+
+	}
+
+}
+
+func (repo *TinyRepo) fsckTags(report *FsckReport, fix bool) {
+
+	rows, err := repo.db.Query(`SELECT tags.path, tags.tag FROM tags
+		LEFT JOIN apps ON apps.ref=tags.ref
+		WHERE apps.path IS NULL OR apps.path != tags.path`)
+	sherlock.Check(err)
+
+	type tag struct{ path, tag string }
+	var bad []tag
+	for rows.Next() {
+		var t tag
+		sherlock.Check(rows.Scan(&t.path, &t.tag))
+		bad = append(bad, t)
+	}
+	sherlock.Check(rows.Close())
+
+	for _, t := range bad {
+
+		if fix {
+			sherlock.Check(repo.db.Exec(`DELETE FROM tags WHERE path=$1
+				AND tag=$2`, t.path, t.tag))
+		}
+
+		report.problem(fix, "tag %v of %v points at another app's version",
+			t.tag, t.path)
+
+	}
+
+}
+
+func (repo *TinyRepo) fsckBlobs(report *FsckReport) {
+
+	referenced, err := repo.Blobs()
+	sherlock.Check(err)
+
+	list, err := repo.archive.List()
+	sherlock.Check(err)
+
+	present := make(map[string]bool)
+	for _, info := range list {
+		if checksumPattern.MatchString(info.Name()) {
+			present[info.Name()] = true
+		}
+	}
+
+	bad := make(map[string]bool)
+	for _, blob := range referenced {
+
+		delete(present, blob)
+
+		ok, err := repo.archive.Exists(blob)
+		sherlock.Check(err)
+
+		if !ok {
+			report.Missing = append(report.Missing, blob)
+			bad[blob] = true
+			continue
+		}
+
+		report.Checked++
+
+		if !repo.verifyBlob(blob) {
+			report.Corrupt = append(report.Corrupt, blob)
+			bad[blob] = true
+		}
+
+	}
+
+	// shared blobs may belong to other repositories
+	if !repo.shared {
+		for blob := range present {
+			if blob != emptyBlob {
+				report.Orphaned = append(report.Orphaned, blob)
+			}
+		}
+		sort.Strings(report.Orphaned)
+	}
+
+	if len(bad) == 0 {
+		return
+	}
+
+	rows, err := repo.db.Query(`SELECT path, ref, app, config, icon, files
+		FROM apps ORDER BY path, date`)
+	sherlock.Check(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var path, ref, app, config, icon, files string
+		sherlock.Check(rows.Scan(&path, &ref, &app, &config, &icon, &files))
+		if bad[app] || bad[config] || bad[icon] || bad[files] {
+			report.Broken = append(report.Broken, path+"@"+ref)
+		}
+	}
+
+}
+
+// verifyBlob reads a blob back and compares it with its checksum.
+func (repo *TinyRepo) verifyBlob(blob string) bool {
+
+	r, err := repo.archive.Get(blob)
+	if err != nil || r == nil {
+		return err == nil
+	}
+	defer r.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, r)
+
+	return err == nil && hex.EncodeToString(hasher.Sum(nil)) == blob
+
+}
+
+// GC deletes every blob no version of an app refers to, along with any
+// temporary files left behind by interrupted imports. Anything modified
+// within the grace period is kept, so that blobs belonging to an import that
+// is still running aren't collected, and the age of each blob is checked
+// again as it is deleted in case an import has just reused it. Blobs are
+// deleted one at a time, so GC is safe to interrupt. It returns how many
+// blobs were deleted and how much space was freed, or would have been if
+// dryRun is set.
+func (repo *TinyRepo) GC(grace time.Duration, dryRun bool) (int, int64, error) {
+
+	archive, ok := repo.archive.(*archiver.BlobArchiver)
+	if repo.shared || !ok {
+		return 0, 0, errors.New("blobs in S3 or a storage directory may be used by other repositories, so they can't be collected")
+	}
+
+	var n int
+	var size int64
+
+	err := sherlock.Try(func() {
+
+		referenced, err := repo.Blobs()
+		sherlock.Check(err)
+
+		keep := make(map[string]bool)
+		for _, blob := range referenced {
+			keep[blob] = true
+		}
+
+		cutoff := time.Now().Add(-grace)
+
+		contents, err := archive.List()
+		sherlock.Check(err)
+
+		// copy the list, since deleting changes it
+		list := append([]os.FileInfo{}, contents...)
+
+		for _, info := range list {
+
+			name := info.Name()
+			if keep[name] || !checksumPattern.MatchString(name) ||
+				info.ModTime().After(cutoff) {
+				continue
+			}
+
+			// the database may have been changed by an import
+			var k int
+			row := repo.db.QueryRow(`SELECT COUNT(*) FROM apps WHERE app=$1
+				OR files=$1 OR icon=$1 OR config=$1`, name)
+			sherlock.Check(row.Scan(&k))
+			if k > 0 {
+				continue
+			}
+
+			if !dryRun {
+				deleted, err := archive.DeleteIfOlder(name, cutoff)
+				sherlock.Check(err)
+				if !deleted {
+					continue
+				}
+			}
+
+			n++
+			size += info.Size()
+
+		}
+
+		if !dryRun {
+			_, err = archive.RemoveTemporary(cutoff)
+			sherlock.Check(err)
+		}
+
+	})
+
+	return n, size, err
+
+}
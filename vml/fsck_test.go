@@ -0,0 +1,238 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteKeepsSiblings(t *testing.T) {
+
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	importApp(t, repo, "team/web", "web", "1.0.0")
+	importApp(t, repo, "team/db", "db", "1.0.0")
+
+	_, err := repo.Delete("team/web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := repo.Nodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 || nodes[0].Path != "team" || nodes[1].Path != "team/db" {
+		t.Errorf("unexpected nodes after delete: %v", nodes)
+	}
+
+}
+
+func TestFsck(t *testing.T) {
+
+	repo, dir, cleanup := tempRepoDir(t)
+	defer cleanup()
+
+	web := importApp(t, repo, "team/web", "web", "1.0.0")
+	importApp(t, repo, "team/db", "db", "1.1.0")
+	importApp(t, repo, "other", "other", "1.2.0")
+
+	report, err := repo.Fsck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() || report.Checked != 6 || len(report.Orphaned) != 0 {
+		t.Fatalf("unexpected report for a healthy repository: %+v", report)
+	}
+
+	list, err := repo.Records("team/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := list[0].App
+
+	list, err = repo.Records("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := list[0].Config
+
+	err = ioutil.WriteFile(filepath.Join(dir, corrupt), []byte("tampered"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Remove(filepath.Join(dir, missing))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphan, err := repo.archive.Put(strings.NewReader("orphan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// break the database the way older versions of vcli could, without
+	// the foreign key checks getting in the way
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "tiny.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, query := range []string{
+		`INSERT INTO nodes (name, path, parent, type) VALUES
+			('empty', 'empty', '', 'dir'), ('gone', 'empty/gone', 'empty', 'app')`,
+		`DELETE FROM nodes WHERE path='team/db'`,
+		`INSERT INTO tags (path, tag, ref) VALUES ('other', 'stray', '` + web + `')`,
+	} {
+		_, err = db.Exec(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err = repo.Fsck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.OK() {
+		t.Errorf("broken repository reported as ok")
+	}
+
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != corrupt {
+		t.Errorf("expected %v to be corrupt, got %v", corrupt, report.Corrupt)
+	}
+
+	if len(report.Missing) != 1 || report.Missing[0] != missing {
+		t.Errorf("expected %v to be missing, got %v", missing, report.Missing)
+	}
+
+	if len(report.Orphaned) != 1 || report.Orphaned[0] != orphan {
+		t.Errorf("expected %v to be orphaned, got %v", orphan, report.Orphaned)
+	}
+
+	if strings.Join(report.Broken, " ") != "other@"+list[0].Ref+" team/web@"+web {
+		t.Errorf("unexpected broken versions: %v", report.Broken)
+	}
+
+	if len(report.Problems) != 3 {
+		for _, p := range report.Problems {
+			t.Log(p.Description)
+		}
+		t.Errorf("expected 3 problems, got %v", len(report.Problems))
+	}
+
+	report, err = repo.Fsck(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range report.Problems {
+		if !p.Fixed {
+			t.Errorf("not fixed: %v", p.Description)
+		}
+	}
+
+	report, err = repo.Fsck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Problems) != 0 {
+		t.Errorf("%v problems left after fixing", len(report.Problems))
+	}
+
+	if repo.nodeType("team/db") != application || repo.nodeType("empty") != none {
+		t.Errorf("nodes weren't fixed")
+	}
+
+}
+
+func TestGC(t *testing.T) {
+
+	repo, dir, cleanup := tempRepoDir(t)
+	defer cleanup()
+
+	importApp(t, repo, "app", "app", "1.0.0")
+
+	old, err := repo.archive.Put(strings.NewReader("old orphan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recent, err := repo.archive.Put(strings.NewReader("recent orphan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := filepath.Join(dir, ".tmp-123")
+	err = ioutil.WriteFile(tmp, []byte("partial"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, old))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(dir, old), past, past)
+	os.Chtimes(tmp, past, past)
+
+	// reopen so the archive sees the new modification times
+	repo.Close()
+	repo, err = NewTinyRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, size, err := repo.GC(time.Hour, true)
+	if err != nil || n != 1 || size != info.Size() {
+		t.Errorf("dry run would delete %v blobs, %v bytes (%v)", n, size, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, old)); err != nil {
+		t.Errorf("dry run deleted a blob")
+	}
+
+	n, _, err = repo.GC(time.Hour, false)
+	if err != nil || n != 1 {
+		t.Errorf("deleted %v blobs (%v)", n, err)
+	}
+
+	for name, want := range map[string]bool{old: false, recent: true, ".tmp-123": false} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if (err == nil) != want {
+			t.Errorf("%v: expected exists to be %v", name, want)
+		}
+	}
+
+	report, err := repo.Fsck(false)
+	if err != nil || !report.OK() {
+		t.Errorf("repository broken by gc: %+v (%v)", report, err)
+	}
+
+}
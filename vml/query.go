@@ -0,0 +1,74 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"strings"
+
+	"github.com/sisatech/sherlock"
+	"github.com/sisatech/vcli/semver"
+)
+
+// SplitAddress splits an address of the form PATH@REF. The ref is empty if
+// the address has no @.
+func SplitAddress(addr string) (string, string) {
+
+	i := strings.LastIndex(addr, "@")
+	if i == -1 {
+		return addr, ""
+	}
+
+	return addr[:i], addr[i+1:]
+
+}
+
+// query returns the reference hash of the highest app version satisfying a
+// version constraint such as ^1.2, going by the versions in their build
+// configs, or an empty string if none do.
+func (repo *TinyRepo) query(path, constraint string) string {
+
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return ""
+	}
+
+	rows, err := repo.db.Query(`SELECT ref, version FROM apps WHERE path=$1
+		ORDER BY date DESC, extra DESC`, path)
+	sherlock.Check(err)
+	defer rows.Close()
+
+	var best string
+	var bestVersion semver.Version
+
+	for rows.Next() {
+
+		var ref, version string
+		sherlock.Check(rows.Scan(&ref, &version))
+
+		v, err := semver.Parse(version)
+		if err != nil || !c.Matches(v) {
+			continue
+		}
+
+		// newer imports win ties
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best = ref
+			bestVersion = v
+		}
+
+	}
+
+	return best
+
+}
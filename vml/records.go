@@ -0,0 +1,355 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/sisatech/sherlock"
+	"github.com/sisatech/vcli/vml/archiver"
+)
+
+// emptyBlob is the checksum of a missing icon or files tar, which is never
+// actually stored.
+const emptyBlob = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+var checksumPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// Node is an entry in the repository tree, either an app or a directory.
+type Node struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// Record is everything needed to recreate a version of an app in another
+// repository: its reference, when it was imported, the checksums of the blobs
+// holding its files, its indexed metadata and its tags.
+type Record struct {
+	Path        string   `json:"path"`
+	Ref         string   `json:"ref"`
+	Date        int64    `json:"date"`
+	Extra       int64    `json:"extra"`
+	App         string   `json:"app"`
+	Config      string   `json:"config"`
+	Icon        string   `json:"icon"`
+	Files       string   `json:"files"`
+	Version     string   `json:"version,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Blobs returns the checksums of the blobs a version is made of.
+func (rec *Record) Blobs() []string {
+
+	return []string{rec.App, rec.Config, rec.Icon, rec.Files}
+
+}
+
+func (rec *Record) validate() error {
+
+	if rec.Path == "" || rec.Path[0] == '/' || rec.Path[len(rec.Path)-1] == '/' {
+		return fmt.Errorf("invalid app path: '%v'", rec.Path)
+	}
+
+	for _, sum := range rec.Blobs() {
+		if !checksumPattern.MatchString(sum) {
+			return fmt.Errorf("invalid blob checksum: '%v'", sum)
+		}
+	}
+
+	ref := reference(rec.App, rec.Config, rec.Icon, rec.Files, rec.Date,
+		rec.Extra)[:8]
+	if ref != rec.Ref {
+		return fmt.Errorf("reference %v doesn't match its contents", rec.Ref)
+	}
+
+	return nil
+
+}
+
+// Nodes returns every node in the repository except the root, sorted by path.
+func (repo *TinyRepo) Nodes() ([]Node, error) {
+
+	var nodes []Node
+
+	err := sherlock.Try(func() {
+
+		rows, err := repo.db.Query(`SELECT path, type FROM nodes
+			WHERE path != '' ORDER BY path ASC`)
+		sherlock.Check(err)
+		defer rows.Close()
+
+		for rows.Next() {
+			var node Node
+			sherlock.Check(rows.Scan(&node.Path, &node.Type))
+			nodes = append(nodes, node)
+		}
+
+	})
+
+	return nodes, err
+
+}
+
+// Records returns a record for every version of an app, oldest first. An app
+// that doesn't exist has no records.
+func (repo *TinyRepo) Records(path string) ([]*Record, error) {
+
+	var list []*Record
+
+	err := sherlock.Try(func() {
+
+		tags := make(map[string][]string)
+
+		rows, err := repo.db.Query(`SELECT ref, tag FROM tags
+			WHERE path=$1 ORDER BY tag ASC`, path)
+		sherlock.Check(err)
+
+		for rows.Next() {
+			var ref, tag string
+			sherlock.Check(rows.Scan(&ref, &tag))
+			tags[ref] = append(tags[ref], tag)
+		}
+		sherlock.Check(rows.Close())
+
+		rows, err = repo.db.Query(`SELECT ref, date, extra, app, config,
+			icon, files, version, author, description, message FROM apps
+			WHERE path=$1 ORDER BY date ASC, extra ASC`, path)
+		sherlock.Check(err)
+		defer rows.Close()
+
+		for rows.Next() {
+
+			rec := &Record{Path: path}
+			sherlock.Check(rows.Scan(&rec.Ref, &rec.Date, &rec.Extra,
+				&rec.App, &rec.Config, &rec.Icon, &rec.Files,
+				&rec.Version, &rec.Author, &rec.Description,
+				&rec.Message))
+			rec.Tags = tags[rec.Ref]
+			list = append(list, rec)
+
+		}
+
+	})
+
+	return list, err
+
+}
+
+// AddRecord adds a version of an app described by a record, keeping its
+// reference and date, then applies the record's tags to it. Every blob the
+// record refers to must already be in the repository. Tags already on another
+// version of the app are moved. AddRecord reports whether the version was new.
+func (repo *TinyRepo) AddRecord(rec *Record) (bool, error) {
+
+	repo.summaryReset()
+
+	var added bool
+
+	err := sherlock.Try(func() {
+
+		sherlock.Check(rec.validate())
+
+		var path string
+		row := repo.db.QueryRow(`SELECT path FROM apps WHERE ref=$1`, rec.Ref)
+		err := row.Scan(&path)
+		sherlock.Assert(err, err == nil || err == sql.ErrNoRows)
+
+		if err == nil && path != rec.Path {
+			sherlock.Throw(fmt.Errorf("reference %v already in use by %v",
+				rec.Ref, path))
+		}
+
+		if err == sql.ErrNoRows {
+
+			for _, sum := range rec.Blobs() {
+				ok, err := repo.HasBlob(sum)
+				sherlock.Check(err)
+				if !ok {
+					sherlock.Throw(fmt.Errorf("missing blob: %v", sum))
+				}
+			}
+
+			repo.addAppNode(rec.Path)
+
+			sherlock.Check(repo.db.Exec(`INSERT INTO apps (path, ref, date,
+				extra, tag, app, files, icon, config, version, author,
+				description, message) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+				rec.Path, rec.Ref, rec.Date, rec.Extra, "", rec.App,
+				rec.Files, rec.Icon, rec.Config, rec.Version, rec.Author,
+				rec.Description, rec.Message))
+
+			repo.summaryModify(rec.Path, application)
+			added = true
+
+		}
+
+		for _, tag := range rec.Tags {
+
+			var ref string
+			row := repo.db.QueryRow(`SELECT ref FROM tags WHERE path=$1
+				AND tag=$2`, rec.Path, tag)
+			err := row.Scan(&ref)
+			sherlock.Assert(err, err == nil || err == sql.ErrNoRows)
+
+			if ref == rec.Ref {
+				continue
+			}
+
+			sherlock.Check(repo.db.Exec(`INSERT OR REPLACE INTO tags
+				(path, tag, ref) VALUES ($1, $2, $3)`, rec.Path, tag,
+				rec.Ref))
+
+			repo.summaryModify(rec.Path, application)
+
+		}
+
+	})
+
+	return added, err
+
+}
+
+// Blobs returns the checksum of every blob a version in the repository refers
+// to, sorted and without duplicates. The empty blob is left out.
+func (repo *TinyRepo) Blobs() ([]string, error) {
+
+	var blobs []string
+
+	err := sherlock.Try(func() {
+
+		rows, err := repo.db.Query(`SELECT app FROM apps UNION
+			SELECT config FROM apps UNION SELECT icon FROM apps UNION
+			SELECT files FROM apps ORDER BY 1`)
+		sherlock.Check(err)
+		defer rows.Close()
+
+		for rows.Next() {
+			var sum string
+			sherlock.Check(rows.Scan(&sum))
+			if sum != emptyBlob {
+				blobs = append(blobs, sum)
+			}
+		}
+
+	})
+
+	return blobs, err
+
+}
+
+// HasBlob reports whether the repository holds a blob.
+func (repo *TinyRepo) HasBlob(sum string) (bool, error) {
+
+	if !checksumPattern.MatchString(sum) {
+		return false, fmt.Errorf("invalid blob checksum: '%v'", sum)
+	}
+
+	return repo.archive.Exists(sum)
+
+}
+
+// GetBlob opens a blob for reading. The reader is nil for the empty blob.
+func (repo *TinyRepo) GetBlob(sum string) (io.ReadCloser, error) {
+
+	ok, err := repo.HasBlob(sum)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return repo.archive.Get(sum)
+
+}
+
+// PutBlob adds a blob to the repository, failing if its contents don't match
+// the checksum.
+func (repo *TinyRepo) PutBlob(sum string, r io.Reader) error {
+
+	if !checksumPattern.MatchString(sum) {
+		return fmt.Errorf("invalid blob checksum: '%v'", sum)
+	}
+
+	got, err := repo.archive.Put(r)
+	if err != nil {
+		return err
+	}
+
+	if got == sum {
+		return nil
+	}
+
+	// don't keep the blob unless a version already refers to it
+	var k int
+	row := repo.db.QueryRow(`SELECT COUNT(*) FROM apps WHERE app=$1
+		OR files=$1 OR icon=$1 OR config=$1`, got)
+	if err = row.Scan(&k); err == nil && k == 0 && !repo.shared {
+		repo.archive.Delete(got)
+	}
+
+	return errors.New("blob doesn't match its checksum")
+
+}
+
+// Compress stores every blob the repository refers to again with the current
+// Compression, where that makes it smaller. Each blob is replaced on its own,
+// so an interrupted Compress loses nothing. It returns how many blobs were
+// replaced and the total size of every blob before and after.
+func (repo *TinyRepo) Compress() (int, int64, int64, error) {
+
+	var n int
+	var before, after int64
+
+	archive, ok := repo.archive.(*archiver.BlobArchiver)
+	if !ok {
+		return 0, 0, 0, errors.New("blobs in S3 can't be compressed")
+	}
+
+	blobs, err := repo.Blobs()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	for _, blob := range blobs {
+
+		// missing blobs are left for fsck to report
+		b, a, err := archive.Recompress(blob)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return n, before, after, fmt.Errorf("blob %v: %v", blob, err)
+		}
+
+		before += b
+		after += a
+		if a != b {
+			n++
+		}
+
+	}
+
+	return n, before, after, nil
+
+}
@@ -0,0 +1,338 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Store is a repository that versions can be copied between. It is
+// implemented by TinyRepo and by Remote.
+type Store interface {
+	Nodes() ([]Node, error)
+	Records(path string) ([]*Record, error)
+	AddRecord(rec *Record) (bool, error)
+	HasBlob(sum string) (bool, error)
+	GetBlob(sum string) (io.ReadCloser, error)
+	PutBlob(sum string, r io.Reader) error
+}
+
+// Remote is a client for a repository served over HTTP by Server.
+type Remote struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewRemote returns a Remote for the repository served at the given URL. The
+// token is sent with every request if it isn't empty.
+func NewRemote(location, token string) (*Remote, error) {
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote url: '%v'", location)
+	}
+
+	return &Remote{
+		URL:   strings.TrimSuffix(location, "/"),
+		Token: token,
+	}, nil
+
+}
+
+func (remote *Remote) do(method, path string, body io.Reader) (*http.Response, error) {
+
+	req, err := http.NewRequest(method, remote.URL+apiPrefix+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if remote.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+remote.Token)
+	}
+
+	client := remote.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		if len(msg) == 0 {
+			return nil, fmt.Errorf("remote: %v", resp.Status)
+		}
+		return nil, fmt.Errorf("remote: %v", strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+
+}
+
+func (remote *Remote) decode(method, path string, body io.Reader, v interface{}) error {
+
+	resp, err := remote.do(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+
+}
+
+// Nodes returns every node in the remote repository.
+func (remote *Remote) Nodes() ([]Node, error) {
+
+	var nodes []Node
+	err := remote.decode("GET", "/nodes", nil, &nodes)
+
+	return nodes, err
+
+}
+
+// Records returns a record for every version of an app in the remote
+// repository, oldest first.
+func (remote *Remote) Records(path string) ([]*Record, error) {
+
+	var list []*Record
+	err := remote.decode("GET", "/records?path="+url.QueryEscape(path), nil,
+		&list)
+
+	return list, err
+
+}
+
+// AddRecord adds a version to the remote repository. Its blobs must already
+// have been uploaded.
+func (remote *Remote) AddRecord(rec *Record) (bool, error) {
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := remote.do("POST", "/records", bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusCreated, nil
+
+}
+
+// HasBlob reports whether the remote repository holds a blob.
+func (remote *Remote) HasBlob(sum string) (bool, error) {
+
+	resp, err := remote.do("HEAD", "/blobs/"+sum, nil)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	return true, nil
+
+}
+
+// GetBlob downloads a blob from the remote repository.
+func (remote *Remote) GetBlob(sum string) (io.ReadCloser, error) {
+
+	resp, err := remote.do("GET", "/blobs/"+sum, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+
+}
+
+// PutBlob uploads a blob to the remote repository.
+func (remote *Remote) PutBlob(sum string, r io.Reader) error {
+
+	if r == nil {
+		r = bytes.NewReader(nil)
+	}
+
+	resp, err := remote.do("PUT", "/blobs/"+sum, r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+
+}
+
+// SyncSummary counts what Sync copied.
+type SyncSummary struct {
+	Versions int
+	Blobs    int
+	Bytes    int64
+}
+
+// Sync copies every version of every app under prefix from src to dst that
+// dst doesn't already have, uploading only the blobs dst is missing. Tags are
+// applied to dst as they are in src, but tags dst has that src doesn't are
+// left alone. An empty prefix syncs the whole repository.
+func Sync(dst, src Store, prefix string) (SyncSummary, error) {
+
+	var sum SyncSummary
+
+	nodes, err := src.Nodes()
+	if err != nil {
+		return sum, err
+	}
+
+	var found bool
+	for _, node := range nodes {
+
+		if node.Type != application || !under(node.Path, prefix) {
+			continue
+		}
+
+		found = true
+
+		err = syncApp(dst, src, node.Path, &sum)
+		if err != nil {
+			return sum, fmt.Errorf("%v: %v", node.Path, err)
+		}
+
+	}
+
+	if !found && prefix != "" {
+		return sum, ErrNotFound
+	}
+
+	return sum, nil
+
+}
+
+func under(path, prefix string) bool {
+
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+
+}
+
+func syncApp(dst, src Store, path string, sum *SyncSummary) error {
+
+	have, err := dst.Records(path)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	for _, rec := range have {
+		existing[rec.Ref] = true
+	}
+
+	records, err := src.Records(path)
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+
+		if !existing[rec.Ref] {
+			for _, blob := range rec.Blobs() {
+				err = syncBlob(dst, src, blob, sum)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		added, err := dst.AddRecord(rec)
+		if err != nil {
+			return err
+		}
+
+		if added {
+			sum.Versions++
+		}
+
+	}
+
+	return nil
+
+}
+
+func syncBlob(dst, src Store, blob string, sum *SyncSummary) error {
+
+	ok, err := dst.HasBlob(blob)
+	if err != nil || ok {
+		return err
+	}
+
+	r, err := src.GetBlob(blob)
+	if err != nil {
+		return err
+	}
+
+	// the empty blob has no reader
+	if r == nil {
+		return dst.PutBlob(blob, bytes.NewReader(nil))
+	}
+	defer r.Close()
+
+	counter := &countReader{r: r}
+	err = dst.PutBlob(blob, counter)
+	if err != nil {
+		return err
+	}
+
+	sum.Blobs++
+	sum.Bytes += counter.n
+
+	return nil
+
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+
+}
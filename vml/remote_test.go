@@ -0,0 +1,323 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func tempRepo(t *testing.T) (*TinyRepo, func()) {
+
+	repo, _, cleanup := tempRepoDir(t)
+
+	return repo, cleanup
+
+}
+
+func tempRepoDir(t *testing.T) (*TinyRepo, string, func()) {
+
+	dir, err := ioutil.TempDir("", "vcli-vml-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewTinyRepo(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return repo, dir, func() {
+		repo.Close()
+		os.RemoveAll(dir)
+	}
+
+}
+
+func importApp(t *testing.T, repo *TinyRepo, path, app, version string) string {
+
+	cfg := `{"version":"` + version + `","author":"test"}`
+
+	ref, _, err := repo.Import(path, strings.NewReader(app),
+		strings.NewReader(cfg), nil, nil, "release "+version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ref
+
+}
+
+func serve(t *testing.T, token string) (*Remote, func()) {
+
+	repo, cleanup := tempRepo(t)
+	srv := httptest.NewServer(NewServer(repo, token))
+
+	remote, err := NewRemote(srv.URL, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return remote, func() {
+		srv.Close()
+		cleanup()
+	}
+
+}
+
+func TestPushPull(t *testing.T) {
+
+	local, cleanup := tempRepo(t)
+	defer cleanup()
+
+	importApp(t, local, "team/web", "web v1", "1.0.0")
+	ref := importApp(t, local, "team/web", "web v2", "1.1.0")
+	importApp(t, local, "team/db", "web v1", "2.0.0")
+	importApp(t, local, "other", "other", "0.1.0")
+
+	_, err := local.Tag("team/web", ref, "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote, stop := serve(t, "")
+	defer stop()
+
+	sum, err := Sync(remote, local, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the db app shares its binary with the first web version
+	if sum.Versions != 3 || sum.Blobs != 5 {
+		t.Errorf("pushed %v versions and %v blobs, expected 3 and 5",
+			sum.Versions, sum.Blobs)
+	}
+
+	sum, err = Sync(remote, local, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum.Versions != 0 || sum.Blobs != 0 {
+		t.Errorf("second push copied %+v", sum)
+	}
+
+	nodes, err := remote.Nodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, node := range nodes {
+		if node.Path == "other" {
+			t.Errorf("pushed app outside of prefix")
+		}
+	}
+
+	clone, cleanup2 := tempRepo(t)
+	defer cleanup2()
+
+	_, err = Sync(clone, remote, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"team/web", "team/db"} {
+
+		want, err := local.Records(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := clone.Records(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%v: records differ after clone", path)
+		}
+
+	}
+
+	out, err := clone.Export("team/web@stable", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	data, err := ioutil.ReadAll(out.App())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "web v2" {
+		t.Errorf("exported '%s', expected 'web v2'", data)
+	}
+
+}
+
+func TestPullMovesTags(t *testing.T) {
+
+	local, cleanup := tempRepo(t)
+	defer cleanup()
+
+	remote, stop := serve(t, "")
+	defer stop()
+
+	old := importApp(t, local, "app", "v1", "1.0.0")
+	local.Tag("app", old, "latest")
+
+	_, err := Sync(remote, local, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, cleanup2 := tempRepo(t)
+	defer cleanup2()
+
+	_, err = Sync(other, remote, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := importApp(t, other, "app", "v2", "2.0.0")
+	other.Untag("app", "latest")
+	other.Tag("app", ref, "latest")
+
+	_, err = Sync(remote, other, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Sync(local, remote, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := local.deref("app", "latest"); got != ref {
+		t.Errorf("tag points at %v after pull, expected %v", got, ref)
+	}
+
+}
+
+func TestToken(t *testing.T) {
+
+	local, cleanup := tempRepo(t)
+	defer cleanup()
+
+	importApp(t, local, "app", "v1", "1.0.0")
+
+	remote, stop := serve(t, "secret")
+	defer stop()
+
+	remote.Token = "wrong"
+	_, err := Sync(remote, local, "")
+	if err == nil {
+		t.Errorf("push succeeded with the wrong token")
+	}
+
+	remote.Token = "secret"
+	_, err = Sync(remote, local, "")
+	if err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestBadBlob(t *testing.T) {
+
+	remote, stop := serve(t, "")
+	defer stop()
+
+	sum := checksum(strings.NewReader("expected"))
+
+	err := remote.PutBlob(sum, strings.NewReader("something else"))
+	if err == nil {
+		t.Fatalf("blob with the wrong checksum was accepted")
+	}
+
+	ok, err := remote.HasBlob(checksum(strings.NewReader("something else")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Errorf("blob with the wrong checksum was kept")
+	}
+
+	err = remote.PutBlob(sum, strings.NewReader("expected"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := remote.GetBlob(sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(data, []byte("expected")) {
+		t.Errorf("downloaded '%s', expected 'expected'", data)
+	}
+
+	_, err = remote.GetBlob(strings.Repeat("0", 64))
+	if err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+}
+
+func TestRecordMustMatchReference(t *testing.T) {
+
+	local, cleanup := tempRepo(t)
+	defer cleanup()
+
+	importApp(t, local, "app", "v1", "1.0.0")
+
+	list, err := local.Records("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote, stop := serve(t, "")
+	defer stop()
+
+	_, err = Sync(remote, local, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := *list[0]
+	rec.Path = "elsewhere"
+	_, err = remote.AddRecord(&rec)
+	if err == nil {
+		t.Errorf("reference was reused for another app")
+	}
+
+	rec = *list[0]
+	rec.Date++
+	_, err = remote.AddRecord(&rec)
+	if err == nil {
+		t.Errorf("record with a forged reference was accepted")
+	}
+
+}
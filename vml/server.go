@@ -0,0 +1,207 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const apiPrefix = "/v1"
+
+// Server serves a TinyRepo over HTTP so that other repositories can push to
+// and pull from it with Remote. The protocol is:
+//
+//	GET  /v1/nodes              list every node
+//	GET  /v1/records?path=PATH  list every version of an app
+//	POST /v1/records            add a version whose blobs are present
+//	HEAD /v1/blobs/SHA256       check for a blob
+//	GET  /v1/blobs/SHA256       download a blob
+//	PUT  /v1/blobs/SHA256       upload a blob
+//
+// If Token isn't empty every request must carry it as a bearer token. Blobs
+// are streamed without holding the server's lock, since the archiver is safe
+// for concurrent use.
+type Server struct {
+	lock  sync.Mutex
+	repo  *TinyRepo
+	Token string
+}
+
+// NewServer returns a Server for a repository.
+func NewServer(repo *TinyRepo, token string) *Server {
+
+	return &Server{
+		repo:  repo,
+		Token: token,
+	}
+
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if srv.Token != "" {
+		auth := []byte(r.Header.Get("Authorization"))
+		want := []byte("Bearer " + srv.Token)
+		if subtle.ConstantTimeCompare(auth, want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	if path == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+
+	case path == "/nodes" && r.Method == "GET":
+		srv.nodes(w, r)
+
+	case path == "/records" && r.Method == "GET":
+		srv.records(w, r)
+
+	case path == "/records" && r.Method == "POST":
+		srv.addRecord(w, r)
+
+	case strings.HasPrefix(path, "/blobs/"):
+		sum := strings.TrimPrefix(path, "/blobs/")
+		if !checksumPattern.MatchString(sum) {
+			http.NotFound(w, r)
+			return
+		}
+		srv.blob(w, r, sum)
+
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+
+	}
+
+}
+
+func reply(w http.ResponseWriter, v interface{}, err error) {
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+
+}
+
+func (srv *Server) nodes(w http.ResponseWriter, r *http.Request) {
+
+	srv.lock.Lock()
+	nodes, err := srv.repo.Nodes()
+	srv.lock.Unlock()
+
+	if nodes == nil {
+		nodes = []Node{}
+	}
+
+	reply(w, nodes, err)
+
+}
+
+func (srv *Server) records(w http.ResponseWriter, r *http.Request) {
+
+	srv.lock.Lock()
+	list, err := srv.repo.Records(r.URL.Query().Get("path"))
+	srv.lock.Unlock()
+
+	if list == nil {
+		list = []*Record{}
+	}
+
+	reply(w, list, err)
+
+}
+
+func (srv *Server) addRecord(w http.ResponseWriter, r *http.Request) {
+
+	rec := new(Record)
+	err := json.NewDecoder(r.Body).Decode(rec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	srv.lock.Lock()
+	added, err := srv.repo.AddRecord(rec)
+	srv.lock.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if added {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+}
+
+func (srv *Server) blob(w http.ResponseWriter, r *http.Request, sum string) {
+
+	switch r.Method {
+
+	case "HEAD", "GET":
+
+		rc, err := srv.repo.GetBlob(sum)
+
+		if err == ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if rc == nil {
+			return
+		}
+		defer rc.Close()
+
+		if r.Method == "GET" {
+			io.Copy(w, rc)
+		}
+
+	case "PUT":
+
+		err := srv.repo.PutBlob(sum, r.Body)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		w.Header().Set("Allow", "HEAD, GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+	}
+
+}
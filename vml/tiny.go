@@ -14,10 +14,13 @@
 package vml
 
 import (
+	"bytes"
 	"database/sql"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/sisatech/sherlock"
@@ -26,6 +29,14 @@ import (
 
 var (
 	ErrNotFound = errors.New("target not found")
+
+	// Storage is where TinyRepos keep their blobs, as understood by
+	// archiver.Open. If it is empty blobs are kept alongside the database.
+	Storage string
+
+	// Compression is how TinyRepos compress blobs kept in a directory.
+	// Blobs that don't get smaller are kept raw.
+	Compression = archiver.Zstd
 )
 
 // TinyRepo provides a very simple and minimal package for version controlling
@@ -33,6 +44,10 @@ var (
 type TinyRepo struct {
 	archive archiver.Archiver
 	db      *sql.DB
+
+	// blobs kept in S3 or another directory may be shared with other
+	// repositories, so they are never deleted along with apps
+	shared bool
 }
 
 // NewTinyRepo returns a new TinyRepo based on the given path.
@@ -46,11 +61,18 @@ func NewTinyRepo(path string) (*TinyRepo, error) {
 		repo = new(TinyRepo)
 
 		// initialize archive
-		repo.archive, err = archiver.New(path)
+		switch {
+		case Storage == "":
+			repo.archive, err = archiver.NewCompressed(path, Compression)
+		case strings.HasPrefix(Storage, "s3://"):
+			repo.archive, err = archiver.NewS3(Storage)
+			repo.shared = true
+		default:
+			repo.archive, err = archiver.NewCompressed(Storage, Compression)
+			repo.shared = true
+		}
 		sherlock.Check(err)
 
-		// TODO NewCompressed. Currently bugged.
-
 		// initialize database
 		repo.database(path + "/tiny.db")
 
@@ -129,8 +151,15 @@ func (out *ExportOutput) Close() error {
 
 }
 
+// Export opens a version of an app for reading. The version is selected by
+// ref as described by Deref. If ref is empty and the path is an address of the
+// form PATH@REF, the version is selected by the part after the @.
 func (repo *TinyRepo) Export(path, ref string) (*ExportOutput, error) {
 
+	if ref == "" {
+		path, ref = SplitAddress(path)
+	}
+
 	var err error
 	out := &ExportOutput{}
 
@@ -167,9 +196,11 @@ func (repo *TinyRepo) Export(path, ref string) (*ExportOutput, error) {
 
 // Import adds the given application elements to the TinyRepo. The application
 // elements are io.ReadClosers. Importantly, files is a tar produced by running
-// archiver.NewTarWriter on the files directory.
+// archiver.NewTarWriter on the files directory. The version, author and
+// description in the config are indexed, and message is kept as the version's
+// changelog entry.
 func (repo *TinyRepo) Import(path string, app, cfg, icon,
-	files io.Reader) (string, Summary, error) {
+	files io.Reader, message string) (string, Summary, error) {
 
 	repo.summaryReset()
 
@@ -181,7 +212,10 @@ func (repo *TinyRepo) Import(path string, app, cfg, icon,
 		appID, err := repo.archive.Put(app)
 		sherlock.Check(err)
 
-		cfgID, err := repo.archive.Put(cfg)
+		cfgData, err := ioutil.ReadAll(cfg)
+		sherlock.Check(err)
+
+		cfgID, err := repo.archive.Put(bytes.NewReader(cfgData))
 		sherlock.Check(err)
 
 		iconID, err := repo.archive.Put(icon)
@@ -190,7 +224,8 @@ func (repo *TinyRepo) Import(path string, app, cfg, icon,
 		filesID, err := repo.archive.Put(files)
 		sherlock.Check(err)
 
-		ref = repo.addApp(path, appID, cfgID, iconID, filesID)
+		ref = repo.addApp(path, appID, cfgID, iconID, filesID,
+			readMetadata(cfgData), message)
 
 	})
 
@@ -258,7 +293,8 @@ func (repo *TinyRepo) addDirNode(path string) {
 
 }
 
-func (repo *TinyRepo) addApp(path, app, cfg, icon, files string) string {
+func (repo *TinyRepo) addApp(path, app, cfg, icon, files string, meta metadata,
+	message string) string {
 
 	repo.addAppNode(path)
 
@@ -268,8 +304,10 @@ func (repo *TinyRepo) addApp(path, app, cfg, icon, files string) string {
 
 	ref := reference(app, cfg, icon, files, timestamp, extra)[:8]
 
-	sherlock.Check(repo.db.Exec(`INSERT INTO apps (path, ref, date, extra, tag, app, files, icon, config)
-		VALUES (?,?,?,?,?,?,?,?,?)`, path, ref, timestamp, extra, "", app, files, icon, cfg))
+	sherlock.Check(repo.db.Exec(`INSERT INTO apps (path, ref, date, extra, tag, app, files, icon, config,
+		version, author, description, message) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`, path, ref,
+		timestamp, extra, "", app, files, icon, cfg, meta.Version, meta.Author,
+		meta.Description, message))
 
 	repo.summaryModify(path, application)
 
@@ -283,7 +321,7 @@ func (repo *TinyRepo) deref(path, ref string) string {
 	if ref == "" {
 
 		row := repo.db.QueryRow(`SELECT ref FROM apps
-			WHERE path=$1 ORDER BY date DESC LIMIT 1`, path)
+			WHERE path=$1 ORDER BY date DESC, extra DESC LIMIT 1`, path)
 
 		var tmp string
 		err := row.Scan(&tmp)
@@ -296,37 +334,32 @@ func (repo *TinyRepo) deref(path, ref string) string {
 	}
 
 	// ref was provided - lookup
-	var n int
-
-	rows, err := repo.db.Query(`SELECT ref, tag FROM apps
-		WHERE path=$1 AND (ref=$2 OR tag=$2)`, path, ref)
-	sherlock.Check(err)
-	defer rows.Close()
-
 	var tmp string
 
-	for rows.Next() {
-
-		n++
-
-		var tmpRef, tmpTag string
-		sherlock.Check(rows.Scan(&tmpRef, &tmpTag))
-
-		if tmpRef == ref {
-			return ref
-		}
-
-		tmp = tmpRef
+	row := repo.db.QueryRow(`SELECT ref FROM apps WHERE path=$1 AND ref=$2`,
+		path, ref)
+	err := row.Scan(&tmp)
+	sherlock.Assert(err, err == nil || err == sql.ErrNoRows)
+	if err == nil {
+		return tmp
+	}
 
+	row = repo.db.QueryRow(`SELECT ref FROM tags WHERE path=$1 AND tag=$2`,
+		path, ref)
+	err = row.Scan(&tmp)
+	sherlock.Assert(err, err == nil || err == sql.ErrNoRows)
+	if err == nil {
+		return tmp
 	}
 
-	return tmp
+	return repo.query(path, ref)
 
 }
 
-// Tag sets the tag value of an app to the given value. The app version is
-// dereferenced from the ref argument, so it can apply to a version hash or an
-// existing tag equally. It applied to an existing tag, it will overwrite it.
+// Tag adds a tag to an app version. The app version is dereferenced from the
+// ref argument, so it can apply to a version hash, an existing tag or a version
+// query equally. A version can have any number of tags, but each tag belongs
+// to a single version of the app.
 func (repo *TinyRepo) Tag(path, ref, tag string) (Summary, error) {
 
 	repo.summaryReset()
@@ -334,15 +367,19 @@ func (repo *TinyRepo) Tag(path, ref, tag string) (Summary, error) {
 	err := sherlock.Try(func() {
 
 		// check if tag is in use
-		hash := repo.deref(path, tag)
-		sherlock.Assert(errors.New("tag already in use"), hash == "")
+		var n int
+		row := repo.db.QueryRow(`SELECT (SELECT COUNT(*) FROM tags
+			WHERE path=$1 AND tag=$2) + (SELECT COUNT(*) FROM apps
+			WHERE path=$1 AND ref=$2)`, path, tag)
+		sherlock.Check(row.Scan(&n))
+		sherlock.Assert(errors.New("tag already in use"), n == 0)
 
 		// find target to tag
-		hash = repo.deref(path, ref)
+		hash := repo.deref(path, ref)
 		sherlock.Assert(ErrNotFound, hash != "")
 
-		sherlock.Check(repo.db.Exec(`UPDATE apps SET tag=$1
-			WHERE path=$2 AND ref=$3`, tag, path, hash))
+		sherlock.Check(repo.db.Exec(`INSERT INTO tags (path, tag, ref)
+			VALUES ($1, $2, $3)`, path, tag, hash))
 
 		repo.summaryModify(path, application)
 
@@ -352,18 +389,31 @@ func (repo *TinyRepo) Tag(path, ref, tag string) (Summary, error) {
 
 }
 
+// Untag removes a tag from an app. If ref isn't a tag, every tag is removed
+// from the version it refers to.
 func (repo *TinyRepo) Untag(path, ref string) (Summary, error) {
 
 	repo.summaryReset()
 
 	err := sherlock.Try(func() {
 
-		// find target to tag
-		hash := repo.deref(path, ref)
-		sherlock.Assert(ErrNotFound, hash != "")
+		res, err := repo.db.Exec(`DELETE FROM tags WHERE path=$1 AND tag=$2`,
+			path, ref)
+		sherlock.Check(err)
 
-		sherlock.Check(repo.db.Exec(`UPDATE apps SET tag=$1
-			WHERE path=$2 AND ref=$3`, "", path, hash))
+		n, err := res.RowsAffected()
+		sherlock.Check(err)
+
+		if n == 0 {
+
+			// find target to untag
+			hash := repo.deref(path, ref)
+			sherlock.Assert(ErrNotFound, hash != "")
+
+			sherlock.Check(repo.db.Exec(`DELETE FROM tags WHERE path=$1
+				AND ref=$2`, path, hash))
+
+		}
 
 		repo.summaryModify(path, application)
 
@@ -408,11 +458,18 @@ func (repo *TinyRepo) deleteApp(path, ref string) {
 	var app, files, icon, config string
 	sherlock.Check(row.Scan(&app, &files, &icon, &config))
 
+	sherlock.Check(repo.db.Exec(`DELETE FROM tags WHERE path=$1 AND ref=$2`,
+		path, ref))
+
 	sherlock.Check(repo.db.Exec(`DELETE FROM apps WHERE path=$1 AND ref=$2`,
 		path, ref))
 
 	repo.summaryModify(path, application)
 
+	if repo.shared {
+		return
+	}
+
 	// cleanup archive resources if possible
 	var k int
 
@@ -455,7 +512,16 @@ func (repo *TinyRepo) deleteDirNode(path string) {
 		return
 	}
 
-	// delete app node
+	// keep directories that still have children
+	var k int
+	row := repo.db.QueryRow(`SELECT COUNT(*) FROM nodes WHERE parent=$1`, path)
+	sherlock.Check(row.Scan(&k))
+
+	if k > 0 {
+		return
+	}
+
+	// delete directory node
 	sherlock.Check(repo.db.Exec(`DELETE FROM nodes WHERE path=$1`, path))
 
 	// add to summary
@@ -565,21 +631,43 @@ func (repo *TinyRepo) ListDir(path string) (DirectoryList, error) {
 
 type AppList []*AppEntry
 
+// AppEntry describes a version of an app. Version is the version's reference
+// hash, and AppVersion the version given in its build config.
 type AppEntry struct {
-	Uploaded  int64
-	Version   string
-	Alias     string
-	Reference string
+	Uploaded    int64
+	Version     string
+	Alias       string
+	Reference   string
+	Tags        []string
+	AppVersion  string
+	Author      string
+	Description string
+	Message     string
 }
 
+// ListApp returns every version of an app, newest first.
 func (repo *TinyRepo) ListApp(path string) (AppList, error) {
 
 	var list AppList
 
 	err := sherlock.Try(func() {
 
-		rows, err := repo.db.Query(`SELECT date, ref, tag FROM apps
-			WHERE path=$1 ORDER BY date DESC`, path)
+		tags := make(map[string][]string)
+
+		rows, err := repo.db.Query(`SELECT ref, tag FROM tags
+			WHERE path=$1 ORDER BY tag ASC`, path)
+		sherlock.Check(err)
+
+		for rows.Next() {
+			var ref, tag string
+			sherlock.Check(rows.Scan(&ref, &tag))
+			tags[ref] = append(tags[ref], tag)
+		}
+		sherlock.Check(rows.Close())
+
+		rows, err = repo.db.Query(`SELECT date, ref, version, author,
+			description, message FROM apps WHERE path=$1
+			ORDER BY date DESC, extra DESC`, path)
 		sherlock.Check(err)
 		defer rows.Close()
 
@@ -587,9 +675,12 @@ func (repo *TinyRepo) ListApp(path string) (AppList, error) {
 
 			entry := new(AppEntry)
 			sherlock.Check(rows.Scan(&entry.Uploaded, &entry.Version,
-				&entry.Alias))
+				&entry.AppVersion, &entry.Author, &entry.Description,
+				&entry.Message))
+			entry.Tags = tags[entry.Version]
 			entry.Reference = entry.Version
-			if entry.Alias != "" {
+			if len(entry.Tags) > 0 {
+				entry.Alias = entry.Tags[0]
 				entry.Reference = entry.Alias
 			}
 			list = append(list, entry)
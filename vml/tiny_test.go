@@ -0,0 +1,294 @@
+// Copyright 2016 Sisa-Tech Pty Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package vml
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sisatech/sherlock"
+)
+
+func TestMigrateSingleTagDatabase(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "vcli-vml-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// blobs and database as written before versions were indexed and could
+	// have more than one tag
+	cfg := []byte(`{"version":"1.2.0","author":"someone","description":"old"}`)
+	sum := sha256.Sum256(cfg)
+	config := hex.EncodeToString(sum[:])
+
+	err = ioutil.WriteFile(filepath.Join(dir, config), cfg, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "tiny.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		`CREATE TABLE nodes (name TEXT, path TEXT, parent TEXT, type TEXT,
+			PRIMARY KEY (path), FOREIGN KEY (parent) REFERENCES nodes(path))`,
+		`CREATE TABLE apps (path TEXT, ref TEXT, date INT, extra INT, tag TEXT,
+			app TEXT, files TEXT, icon TEXT, config TEXT, PRIMARY KEY (ref),
+			FOREIGN KEY (path) REFERENCES nodes(path))`,
+		`INSERT INTO nodes VALUES ('', '', '', 'dir'), ('app', 'app', '', 'app')`,
+		`INSERT INTO apps VALUES ('app', 'aaaaaaaa', 1, 0, 'stable', '` + emptyBlob + `',
+			'` + emptyBlob + `', '` + emptyBlob + `', '` + config + `')`,
+		`INSERT INTO apps VALUES ('app', 'bbbbbbbb', 2, 0, '', '` + emptyBlob + `',
+			'` + emptyBlob + `', '` + emptyBlob + `', '` + config + `')`,
+	} {
+		_, err = db.Exec(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	repo, err := NewTinyRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	list, err := repo.Records("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("expected 2 versions, got %v", len(list))
+	}
+
+	if strings.Join(list[0].Tags, ",") != "stable" || len(list[1].Tags) != 0 {
+		t.Errorf("tags weren't migrated: %v, %v", list[0].Tags, list[1].Tags)
+	}
+
+	for _, rec := range list {
+		if rec.Version != "1.2.0" || rec.Author != "someone" || rec.Description != "old" {
+			t.Errorf("%v wasn't indexed: %+v", rec.Ref, rec)
+		}
+	}
+
+	var n int
+	err = repo.db.QueryRow(`SELECT COUNT(*) FROM apps WHERE tag != ''`).Scan(&n)
+	if err != nil || n != 0 {
+		t.Errorf("%v versions still have an old tag (%v)", n, err)
+	}
+
+	// migrating again changes nothing
+	repo.Close()
+	repo, err = NewTinyRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := repo.Records("app")
+	if err != nil || len(again) != 2 || strings.Join(again[0].Tags, ",") != "stable" {
+		t.Errorf("second migration changed the versions: %v (%v)", again, err)
+	}
+
+}
+
+func TestTags(t *testing.T) {
+
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	first := importApp(t, repo, "app", "app", "1.0.0")
+	second := importApp(t, repo, "app", "app", "1.1.0")
+
+	for _, tag := range []string{"stable", "lts"} {
+		if _, err := repo.Tag("app", first, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.Tag("app", second, "stable"); err == nil {
+		t.Errorf("tagged two versions 'stable'")
+	}
+
+	if _, err := repo.Tag("app", first, second); err == nil {
+		t.Errorf("tagged a version with another version's reference")
+	}
+
+	tags := func() (string, string) {
+		list, err := repo.Records("app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(list[0].Tags, ","), strings.Join(list[1].Tags, ",")
+	}
+
+	if a, b := tags(); a != "lts,stable" || b != "" {
+		t.Errorf("unexpected tags %q and %q", a, b)
+	}
+
+	// tags can be used to tag again
+	if _, err := repo.Tag("app", "lts", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Untag("app", "lts"); err != nil {
+		t.Fatal(err)
+	}
+
+	if a, _ := tags(); a != "stable,v1" {
+		t.Errorf("untagging one tag left %q", a)
+	}
+
+	if _, err := repo.Untag("app", first); err != nil {
+		t.Fatal(err)
+	}
+
+	if a, _ := tags(); a != "" {
+		t.Errorf("untagging a version left %q", a)
+	}
+
+}
+
+func TestQuery(t *testing.T) {
+
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	refs := make(map[string]string)
+	for _, version := range []string{"1.2.0", "1.4.1", "2.0.0", "1.3.0"} {
+		refs[version] = importApp(t, repo, "app", "app", version)
+	}
+
+	// a newer import of the same version wins
+	refs["1.4.1"] = importApp(t, repo, "app", "app", "1.4.1")
+
+	tests := map[string]string{
+		"^1.2":   "1.4.1",
+		"~1.3":   "1.3.0",
+		"1.2":    "1.2.0",
+		">=1.5":  "2.0.0",
+		"<1.3.0": "1.2.0",
+		">=3":    "",
+		"bad":    "",
+	}
+
+	for constraint, want := range tests {
+
+		out, err := repo.Export("app@"+constraint, "")
+		if want == "" {
+			if err == nil {
+				out.Close()
+				t.Errorf("%v: expected no match", constraint)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", constraint, err)
+			continue
+		}
+
+		cfg, err := ioutil.ReadAll(out.Config())
+		out.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(cfg), `"`+want+`"`) {
+			t.Errorf("%v: got %s, expected %v", constraint, cfg, want)
+		}
+
+	}
+
+	// tags and references take priority over queries
+	if _, err := repo.Tag("app", refs["1.2.0"], "^2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ref := deref(t, repo, "^2"); ref != refs["1.2.0"] {
+		t.Errorf("query took priority over a tag")
+	}
+
+	if ref := deref(t, repo, "^1"); ref != refs["1.4.1"] {
+		t.Errorf("^1 resolved to %v, expected the newest 1.4.1 import %v", ref, refs["1.4.1"])
+	}
+
+}
+
+func deref(t *testing.T, repo *TinyRepo, ref string) string {
+
+	var hash string
+	err := sherlock.Try(func() {
+		hash = repo.deref("app", ref)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+
+}
+
+func TestDirectoryStorage(t *testing.T) {
+
+	storage, err := ioutil.TempDir("", "vcli-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storage)
+
+	Storage = storage
+	defer func() { Storage = "" }()
+
+	repo, cleanup := tempRepo(t)
+	defer cleanup()
+
+	ref := importApp(t, repo, "app", "app", "1.0.0")
+
+	// a storage directory may be a share used by other repositories, so
+	// its blobs are never collected, but they can still be compressed
+	if _, _, err := repo.GC(0, true); err == nil {
+		t.Errorf("gc collected from directory storage")
+	}
+
+	if _, _, _, err := repo.Compress(); err != nil {
+		t.Errorf("compress refused directory storage: %v", err)
+	}
+
+	if _, err := repo.Delete("app", ref); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := repo.Fsck(false)
+	if err != nil || len(report.Orphaned) != 0 {
+		t.Errorf("fsck reported shared blobs as orphaned: %v (%v)", report, err)
+	}
+
+	files, err := ioutil.ReadDir(storage)
+	if err != nil || len(files) != 2 {
+		t.Errorf("expected the app's 2 blobs to outlive it in storage, found %v (%v)", len(files), err)
+	}
+
+}